@@ -0,0 +1,73 @@
+/*
+Copyright 2024
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionSecretSynced reports whether the imagePullSecret in the namespace matches the credentials
+	ConditionSecretSynced = "SecretSynced"
+	// ConditionServiceAccountsPatched reports whether the managed ServiceAccounts reference the imagePullSecret
+	ConditionServiceAccountsPatched = "ServiceAccountsPatched"
+	// ConditionPodsCleaned reports whether the Pods failing to pull their image were deleted
+	ConditionPodsCleaned = "PodsCleaned"
+)
+
+// ImagePullSecretStatusStatus holds the reconciliation decisions of the controller for a namespace
+type ImagePullSecretStatusStatus struct {
+	// SecretName is the name of the imagePullSecret
+	SecretName string `json:"secretName,omitempty"`
+
+	// Conditions are the latest observations of the reconciliation
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=ipss
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="SecretSynced",type=string,JSONPath=`.status.conditions[?(@.type=="SecretSynced")].status`
+//+kubebuilder:printcolumn:name="ServiceAccountsPatched",type=string,JSONPath=`.status.conditions[?(@.type=="ServiceAccountsPatched")].status`
+//+kubebuilder:printcolumn:name="PodsCleaned",type=string,JSONPath=`.status.conditions[?(@.type=="PodsCleaned")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ImagePullSecretStatus is maintained by the controller in every namespace it reconciles, so namespace
+// owners can see the state of the imagePullSecret without access to the logs of the controller
+type ImagePullSecretStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status ImagePullSecretStatusStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ImagePullSecretStatusList contains a list of ImagePullSecretStatus
+type ImagePullSecretStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImagePullSecretStatus `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImagePullSecretStatus{}, &ImagePullSecretStatusList{})
+}
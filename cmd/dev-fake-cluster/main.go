@@ -0,0 +1,118 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// dev-fake-cluster runs the reconcilers once against an in-memory cluster loaded from a YAML fixture and
+// prints the resulting changes, so the reconcile logic can be exercised and demoed without a live cluster
+// or envtest binaries. The controller is configured from the environment, like the manager.
+//
+//	go run ./cmd/dev-fake-cluster hack/dev-fake-cluster.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/controller"
+	"github.com/tamcore/imagepullsecret-patcher/internal/devcluster"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(imagepullsecretv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run reconciles the fixture named in args and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dev-fake-cluster", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dev-fake-cluster [flags] <fixture>")
+		flags.PrintDefaults()
+	}
+	opts := zap.Options{Development: true}
+	opts.BindFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts), zap.WriteTo(stderr)))
+	log := ctrl.Log.WithName("dev-fake-cluster")
+
+	path := flags.Arg(0)
+	objects, err := devcluster.Load(path, scheme)
+	if err != nil {
+		log.Error(err, "unable to load fake cluster")
+		return 2
+	}
+	k8sClient := devcluster.NewClient(scheme, objects...)
+	log.Info("loaded fake cluster", "fixture", path, "objects", len(objects))
+
+	ctx := ctrl.LoggerInto(context.Background(), log)
+	before, err := devcluster.TakeSnapshot(ctx, k8sClient)
+	if err != nil {
+		log.Error(err, "unable to snapshot fake cluster")
+		return 2
+	}
+
+	c := config.NewConfig()
+	failed, err := controller.SweepOnce(ctx, k8sClient, c, notify.NewNotifier(config.NewStore(c)))
+	if err != nil {
+		log.Error(err, "unable to sweep namespaces")
+		return 2
+	}
+
+	after, err := devcluster.TakeSnapshot(ctx, k8sClient)
+	if err != nil {
+		log.Error(err, "unable to snapshot fake cluster")
+		return 2
+	}
+	if devcluster.WriteDiff(stdout, before, after) == 0 {
+		fmt.Fprintln(stdout, "No changes")
+	}
+	fmt.Fprintln(stdout)
+
+	audits, err := utils.AuditCluster(ctx, k8sClient, c)
+	if err != nil {
+		log.Error(err, "unable to verify namespaces")
+		return 2
+	}
+	if !utils.WriteAuditSummary(stdout, audits) || failed > 0 {
+		return 1
+	}
+	return 0
+}
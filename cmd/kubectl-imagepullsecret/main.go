@@ -0,0 +1,288 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-imagepullsecret queries and operates a deployment of imagepullsecret-patcher via its admin API.
+// Installed into the PATH, it's invoked as `kubectl imagepullsecret`.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/pkg/state"
+)
+
+const usage = `Usage: kubectl imagepullsecret [flags] <command> [namespace] [serviceaccount]
+
+Commands:
+  status [namespace]                     show the sync status of all namespaces, or of namespace
+  explain <namespace> [serviceaccount]   explain, why the imagePullSecret is distributed to namespace, or not.
+                                         With serviceaccount, the rules matching it and the actions of a reconcile are shown.
+  excluded                               list the namespaces excluded by the configuration, by reason
+  resync                                 reconcile all managed Secrets
+  pause <namespace>                      exclude namespace from reconciling, by setting the admin-paused annotation
+  resume <namespace>                     remove the admin-paused annotation from namespace. The exclude annotation
+                                         set by the users is kept.
+
+Flags:
+`
+
+// errUsage is returned for invalid commands or arguments
+var errUsage = errors.New("invalid usage")
+
+// errOutOfSync is returned by status, if a namespace is out of sync
+var errOutOfSync = errors.New("namespaces out of sync")
+
+// exclusionReasons explains the reasons, why a namespace is excluded
+var exclusionReasons = map[string]string{
+	"glob":        "its name matches CONFIG_EXCLUDED_NAMESPACES",
+	"selector":    "the value of its CONFIG_NAMESPACE_LABEL is excluded, or not included",
+	"annotation":  "it carries the exclude annotation",
+	"paused":      "it carries the admin-paused annotation, e.g. after `kubectl imagepullsecret pause`",
+	"operator":    "it's the namespace of the controller, see CONFIG_OPERATOR_NAMESPACE_POLICY",
+	"terminating": "it's being deleted",
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command in args and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("kubectl-imagepullsecret", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	// -url
+	var url string
+	// -token
+	var token string
+	// -timeout
+	var timeout time.Duration
+	flags.StringVar(&url, "url", os.Getenv("IMAGEPULLSECRET_PATCHER_URL"),
+		"base URL of the admin API, e.g. http://localhost:8082 with kubectl port-forward. "+
+			"Defaults to IMAGEPULLSECRET_PATCHER_URL")
+	flags.StringVar(&token, "token", os.Getenv("IMAGEPULLSECRET_PATCHER_TOKEN"),
+		"bearer token of the admin API, see CONFIG_ADMIN_TOKEN. Defaults to IMAGEPULLSECRET_PATCHER_TOKEN")
+	flags.DurationVar(&timeout, "timeout", 30*time.Second, "timeout of the command")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if url == "" {
+		fmt.Fprintln(stderr, "Error: -url or IMAGEPULLSECRET_PATCHER_URL is required")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := runCommand(ctx, state.NewClient(url, token), flags.Args(), stdout)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errOutOfSync):
+		return 1
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(stderr, "Error: "+err.Error())
+		flags.Usage()
+		return 2
+	default:
+		fmt.Fprintln(stderr, "Error: "+err.Error())
+		return 1
+	}
+}
+
+// runCommand dispatches args to the command named by their first element
+func runCommand(ctx context.Context, client *state.Client, args []string, w io.Writer) error {
+	command, args := args[0], args[1:]
+	switch {
+	case command == "status" && len(args) <= 1:
+		return status(ctx, client, args, w)
+	case command == "explain" && len(args) == 1:
+		return explain(ctx, client, args[0], w)
+	case command == "explain" && len(args) == 2:
+		return explainServiceAccount(ctx, client, args[0], args[1], w)
+	case command == "excluded" && len(args) == 0:
+		return excluded(ctx, client, w)
+	case command == "resync" && len(args) == 0:
+		if err := client.Resync(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "Resync of all managed Secrets triggered")
+		return nil
+	case command == "pause" && len(args) == 1:
+		if err := client.Pause(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Namespace %s paused\n", args[0])
+		return nil
+	case command == "resume" && len(args) == 1:
+		if err := client.Resume(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Namespace %s resumed\n", args[0])
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errUsage, strings.Join(append([]string{command}, args...), " "))
+}
+
+// status prints the sync status of all namespaces, or of the namespace in args. It returns errOutOfSync,
+// if one of them is out of sync.
+func status(ctx context.Context, client *state.Client, args []string, w io.Writer) error {
+	namespaces, err := client.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		var found []state.NamespaceSync
+		for _, ns := range namespaces {
+			if ns.Namespace == args[0] {
+				found = append(found, ns)
+			}
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("namespace %s not found", args[0])
+		}
+		namespaces = found
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Namespace < namespaces[j].Namespace })
+
+	outOfSync := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tSYNCED\tDETAILS")
+	for _, ns := range namespaces {
+		if !ns.InSync() {
+			outOfSync++
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", ns.Namespace, ns.InSync(), syncDetails(ns))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if outOfSync > 0 {
+		return errOutOfSync
+	}
+	return nil
+}
+
+// syncDetails describes, how ns differs from the expected state
+func syncDetails(ns state.NamespaceSync) string {
+	if ns.Excluded {
+		return "excluded"
+	}
+	var details []string
+	if ns.SecretExpected && !ns.SecretExists {
+		details = append(details, "imagePullSecret missing")
+	}
+	if ns.SecretExists && !ns.SecretUpToDate {
+		details = append(details, "imagePullSecret outdated")
+	}
+	if len(ns.ServiceAccountsMissingSecret) > 0 {
+		details = append(details, "ServiceAccounts missing the imagePullSecret: "+strings.Join(ns.ServiceAccountsMissingSecret, ", "))
+	}
+	return strings.Join(details, "; ")
+}
+
+// explain prints, why the imagePullSecret is distributed to namespace, or not
+func explain(ctx context.Context, client *state.Client, namespace string, w io.Writer) error {
+	current, err := client.State(ctx)
+	if err != nil {
+		return err
+	}
+	ns, err := client.Namespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	if ns.Managed {
+		fmt.Fprintf(w, "Namespace %s is managed by profile %s: the imagePullSecret %s is distributed to it.\n",
+			ns.Name, current.Profile, current.SecretName)
+		return nil
+	}
+	reason, ok := exclusionReasons[ns.Reason]
+	if !ok {
+		reason = ns.Reason
+	}
+	fmt.Fprintf(w, "Namespace %s is excluded from profile %s, as %s.\n", ns.Name, current.Profile, reason)
+	return nil
+}
+
+// explainServiceAccount prints the rules matching serviceAccount in namespace, and what reconciling it would do
+func explainServiceAccount(ctx context.Context, client *state.Client, namespace string, serviceAccount string, w io.Writer) error {
+	explanation, err := client.ExplainServiceAccount(ctx, namespace, serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	verdict := "not managed"
+	if explanation.Managed {
+		verdict = "managed"
+	}
+	fmt.Fprintf(w, "ServiceAccount %s/%s is %s.\n\n", explanation.Namespace, explanation.ServiceAccount, verdict)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tMATCHED\tDETAIL")
+	for _, rule := range explanation.Rules {
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", rule.Rule, rule.Matched, rule.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nActions:")
+	for _, action := range explanation.Actions {
+		fmt.Fprintln(w, "  - "+action)
+	}
+	return nil
+}
+
+// excluded prints the namespaces excluded by the configuration, by reason
+func excluded(ctx context.Context, client *state.Client, w io.Writer) error {
+	excluded, err := client.ExcludedNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	reasons := make([]string, 0, len(excluded))
+	for reason := range excluded {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tREASON")
+	for _, reason := range reasons {
+		namespaces := excluded[reason]
+		sort.Strings(namespaces)
+		for _, namespace := range namespaces {
+			fmt.Fprintf(tw, "%s\t%s\n", namespace, reason)
+		}
+	}
+	return tw.Flush()
+}
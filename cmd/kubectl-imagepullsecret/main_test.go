@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/admin"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// newTestServer serves the admin API of a cluster with the namespaces default and kube-system,
+// in which the imagePullSecret is missing in default
+func newTestServer(t *testing.T, objects ...client.Object) (string, client.Client, *config.Config) {
+	t.Setenv("IMAGEPULLSECRET_PATCHER_URL", "")
+	t.Setenv("IMAGEPULLSECRET_PATCHER_TOKEN", "")
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: `{"auths":{}}`,
+		SecretNamespace:  "kube-system",
+		AdminToken:       "secret-token",
+	})
+	k8sClient := fake.NewClientBuilder().WithObjects(append([]client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}},
+	}, objects...)...).Build()
+	server := httptest.NewServer((&admin.Server{Client: k8sClient, Config: config.NewStore(c)}).Handler())
+	t.Cleanup(server.Close)
+	return server.URL, k8sClient, c
+}
+
+func Test_run(t *testing.T) {
+	url, _, _ := newTestServer(t, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+
+	tests := []struct {
+		name       string
+		args       []string
+		want       int
+		wantOutput string
+	}{
+		{
+			name: "No command. Should print the usage.",
+			args: []string{"-url", url},
+			want: 2,
+		},
+		{
+			name: "No URL. Should fail.",
+			args: []string{"status"},
+			want: 2,
+		},
+		{
+			name: "Unknown command. Should print the usage.",
+			args: []string{"-url", url, "-token", "secret-token", "unknown"},
+			want: 2,
+		},
+		{
+			name: "Wrong token. Should fail.",
+			args: []string{"-url", url, "-token", "wrong", "status"},
+			want: 1,
+		},
+		{
+			name:       "Status with a namespace out of sync. Should exit with 1.",
+			args:       []string{"-url", url, "-token", "secret-token", "status"},
+			want:       1,
+			wantOutput: "imagePullSecret missing",
+		},
+		{
+			name:       "Status of a namespace in sync. Should succeed.",
+			args:       []string{"-url", url, "-token", "secret-token", "status", "kube-system"},
+			wantOutput: "excluded",
+		},
+		{
+			name:       "Explain an excluded namespace. Should name the reason.",
+			args:       []string{"-url", url, "-token", "secret-token", "explain", "kube-system"},
+			wantOutput: "its name matches CONFIG_EXCLUDED_NAMESPACES",
+		},
+		{
+			name:       "Explain a managed namespace. Should succeed.",
+			args:       []string{"-url", url, "-token", "secret-token", "explain", "default"},
+			wantOutput: "is managed by profile",
+		},
+		{
+			name:       "Excluded namespaces. Should list them by reason.",
+			args:       []string{"-url", url, "-token", "secret-token", "excluded"},
+			wantOutput: "kube-system  glob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			if got := run(tt.args, stdout, stderr); got != tt.want {
+				t.Errorf("run() = %v, want %v, stderr: %s", got, tt.want, stderr)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("run() output = %q, want it to contain %q", stdout, tt.wantOutput)
+			}
+		})
+	}
+}
+
+// Pausing must not touch the exclude annotation, so resuming never lifts an exclusion set by the users
+func Test_run_PauseResume(t *testing.T) {
+	url, k8sClient, c := newTestServer(t,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{"pborn.eu/imagepullsecret-patcher-exclude": "true"}}},
+	)
+	runCommand := func(args ...string) string {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if got := run(append([]string{"-url", url, "-token", "secret-token"}, args...), stdout, stderr); got != 0 {
+			t.Fatalf("run(%v) = %v, want 0, stderr: %s", args, got, stderr)
+		}
+		return stdout.String()
+	}
+	annotations := func(name string) map[string]string {
+		ns := &corev1.Namespace{}
+		if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: name}, ns); err != nil {
+			t.Fatal(err)
+		}
+		return ns.GetAnnotations()
+	}
+
+	runCommand("pause", "default")
+	if got := annotations("default"); got[c.AnnotationAdminPaused] != "true" || got[c.ExcludeAnnotation] != "" {
+		t.Errorf("annotations after pause = %v, want only %s", got, c.AnnotationAdminPaused)
+	}
+	if got := runCommand("explain", "default"); !strings.Contains(got, "admin-paused annotation") {
+		t.Errorf("explain after pause = %q, want it to name the admin-paused annotation", got)
+	}
+	runCommand("resume", "default")
+	if got := annotations("default"); got[c.AnnotationAdminPaused] != "" {
+		t.Errorf("annotations after resume = %v, want %s removed", got, c.AnnotationAdminPaused)
+	}
+
+	runCommand("pause", "team-a")
+	runCommand("resume", "team-a")
+	if got := annotations("team-a"); got[c.ExcludeAnnotation] != "true" {
+		t.Errorf("annotations after resume = %v, want the exclude annotation of the users kept", got)
+	}
+}
@@ -17,21 +17,52 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/KimMachineGun/automemlimit/memlimit"
 	"go.uber.org/automaxprocs/maxprocs"
+	uberzap "go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/admin"
+	"github.com/tamcore/imagepullsecret-patcher/internal/audit"
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
+	"github.com/tamcore/imagepullsecret-patcher/internal/capabilities"
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/conflict"
 	"github.com/tamcore/imagepullsecret-patcher/internal/controller"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/fairness"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/migrate"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+	"github.com/tamcore/imagepullsecret-patcher/internal/secretcache"
+	"github.com/tamcore/imagepullsecret-patcher/internal/suggest"
+	"github.com/tamcore/imagepullsecret-patcher/internal/teardown"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+	"github.com/tamcore/imagepullsecret-patcher/internal/watchdog"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,6 +74,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(imagepullsecretv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -51,11 +83,24 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
+	var metricsCertPath, metricsCertName, metricsCertKey string
 	var noAutoMaxProcs bool
 	var noAutoMemlimit bool
 	var autoMemlimitRatio float64
+	var gogc int
+	var gracefulShutdownTimeout time.Duration
+	var verify bool
+	var sweep bool
+	var migrateObjects bool
+	var migrateDryRun bool
+	var migrateFromAnnotationDomain string
 	var featureDeletePods bool
+	var featureDeletePodsAnyOwner bool
+	var requireDefaultServiceAccount bool
 	var featureWatchDockerConfigJSONPath bool
+	var featureSecretInAllNamespaces bool
+	var featurePatchWorkloads bool
+	var featureTemplateDockerConfigJSON bool
 
 	// -serviceaccounts
 	var serviceAccounts string
@@ -63,12 +108,178 @@ func main() {
 	var dockerConfigJSON string
 	// -dockerconfigjsonpath
 	var dockerConfigJSONPath string
+	// -age-keyfile
+	var ageKeyFile string
+	// -signature-public-keyfile
+	var signaturePublicKeyFile string
+	// -source-secret
+	var sourceSecret string
+	// -oidc-token-endpoint
+	var oidcTokenEndpoint string
+	// -oidc-token-path
+	var oidcTokenPath string
+	// -oidc-registry
+	var oidcRegistry string
+	// -oidc-username
+	var oidcUsername string
+	// -credential-sources
+	var credentialSources string
 	// -secretname
 	var secretName string
 	// -secretnamespace
 	var secretNamespace string
 	// -excluded-namespaces
 	var excludedNamespaces string
+	// -namespace-label
+	var namespaceLabel string
+	// -excluded-namespace-label-values
+	var excludedNamespaceLabelValues string
+	// -included-namespace-label-values
+	var includedNamespaceLabelValues string
+	// -namespace-min-age
+	var namespaceMinAge time.Duration
+	// -requeue-after
+	var requeueAfter time.Duration
+	// -workload-kinds
+	var workloadKinds string
+	// -workload-selector
+	var workloadSelector string
+	// -pod-cleanup-owner-kinds
+	var podCleanupOwnerKinds string
+	// -pod-cleanup-delay
+	var podCleanupDelay time.Duration
+	// -pod-cleanup-parallelism
+	var podCleanupParallelism int
+	// -pod-cleanup-timeout
+	var podCleanupTimeout time.Duration
+	// -daemonset-pod-cleanup-backoff
+	var daemonSetPodCleanupBackoff time.Duration
+	// -admin-bind-address
+	var adminBindAddress string
+	// -delete-orphaned-secrets
+	var featureDeleteOrphanedSecrets bool
+	// -orphaned-secrets-interval
+	var orphanedSecretsInterval time.Duration
+	// -check-secret-quota
+	var featureCheckSecretQuota bool
+	// -immutable-secrets
+	var featureImmutableSecrets bool
+	// -notify-webhook-url
+	var notifyWebhookURL string
+	// -notify-failure-threshold
+	var notifyFailureThreshold int
+	// -event-log-size
+	var eventLogSize int
+	// -circuit-breaker-threshold
+	var circuitBreakerThreshold int
+	// -circuit-breaker-cooldown
+	var circuitBreakerCooldown time.Duration
+	// -paused
+	var paused bool
+	// -self-test-namespace
+	var selfTestNamespace string
+	// -self-test-create-namespace
+	var featureSelfTestCreateNamespace bool
+	// -serviceaccount-patch-strategy
+	var serviceAccountPatchStrategy string
+	// -profile
+	var profile string
+	// -gitops-conflict-policy
+	var gitOpsConflictPolicy string
+	// -annotation-domain
+	var annotationDomain string
+	// -sweep-checkpoint
+	var featureSweepCheckpoint bool
+	// -manage-serviceaccounts
+	var manageServiceAccounts bool
+	// -enable-secret-controller
+	var enableSecretController bool
+	// -enable-serviceaccount-controller
+	var enableServiceAccountController bool
+	// -manage-secrets
+	var manageSecrets bool
+	// -sweep-chunk-size
+	var sweepChunkSize int
+	// -operator-namespace-policy
+	var operatorNamespacePolicy string
+	// -namespace-rate-limit
+	var namespaceRateLimit int
+	// -namespace-rate-burst
+	var namespaceRateBurst int
+	// -conflict-threshold
+	var conflictThreshold int
+	// -external-secrets-conflict-policy
+	var externalSecretsConflictPolicy string
+	// -sealed-secrets-conflict-policy
+	var sealedSecretsConflictPolicy string
+	// -image-pull-statistics
+	var featureImagePullStatistics bool
+	// -audit-sink
+	var auditSink string
+	// -max-concurrent-reconciles
+	var maxConcurrentReconciles string
+	// -dockerconfigjson-url
+	var dockerConfigJSONURL string
+	// -dockerconfigjson-url-ca-file
+	var dockerConfigJSONURLCAFile string
+	// -dockerconfigjson-url-cert-file
+	var dockerConfigJSONURLCertFile string
+	// -dockerconfigjson-url-key-file
+	var dockerConfigJSONURLKeyFile string
+	// -dockerconfigjson-url-authorization
+	var dockerConfigJSONURLAuthorization string
+	// -dockerconfigjson-url-interval
+	var dockerConfigJSONURLInterval time.Duration
+	// -detach-unmanaged-serviceaccounts
+	var featureDetachServiceAccounts bool
+	// -delete-unused-secrets
+	var featureDeleteUnusedSecrets bool
+	// -namespace-status
+	var featureNamespaceStatus bool
+	// -suggestion-output
+	var suggestionOutput string
+	// -pod-cleanup-critical-priority
+	var podCleanupCriticalPriority int
+	// -pod-cleanup-critical-namespace-selector
+	var podCleanupCriticalSelector string
+	// -pod-cleanup-critical-interval
+	var podCleanupCriticalInterval time.Duration
+	// -secret-namespace-create
+	var featureCreateSecretNamespace bool
+	// -operator-namespace
+	var operatorNamespace string
+	// -watchdog-timeout
+	var watchdogTimeout time.Duration
+	// -verify-rotated-credentials
+	var featureVerifyRotatedCredentials bool
+	// -last-known-good
+	var featureLastKnownGood bool
+	// -ca-bundle-file
+	var caBundleFile string
+	// -cache-sync-period
+	var cacheSyncPeriod time.Duration
+	// -cache-slim-pods
+	var cacheSlimPods bool
+	// -pod-cleanup-reasons
+	var podCleanupReasons string
+	// -pod-cleanup-registries
+	var podCleanupRegistries string
+	// -pod-cleanup-min-age
+	var podCleanupMinAge time.Duration
+	// -namespace-sync-annotations
+	var featureNamespaceSyncAnnotations bool
+	// -extra-secrets
+	var extraSecrets string
+	// -standby-verify-interval
+	var standbyVerifyInterval time.Duration
+	// -evict-pods
+	var featureEvictPods bool
+	// -scope-secret-cache
+	var featureScopeSecretCache bool
+	// -pod-cleanup-windows
+	var podCleanupWindows string
+	// -pod-cleanup-window-timezone
+	var podCleanupWindowTimezone string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
 		"The address the metric endpoint binds to.")
@@ -79,32 +290,247 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", false,
 		"If set the metrics endpoint is served securely")
+	flag.StringVar(&metricsCertPath, "metrics-cert-path", "",
+		"The directory that contains the metrics server certificate, e.g. mounted from a Secret issued by cert-manager. "+
+			"The certificate is reloaded on rotation. A self-signed certificate is generated, if not set.")
+	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
+	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&noAutoMaxProcs, "no-auto-maxprocs", false,
 		"Do not automatically set GOMAXPROCS to match container or system cpu quota.")
 	flag.BoolVar(&noAutoMemlimit, "no-auto-memlimit", false,
 		"Do not automatically set GOMEMLIMIT to match container or system memory limit.")
+	flag.BoolVar(&verify, "verify", false,
+		"Compare the imagePullSecret and ServiceAccounts of every namespace against the expected state, "+
+			"print a diff summary and exit non-zero if any namespace is out of sync. Doesn't start the controller.")
+	flag.BoolVar(&sweep, "sweep", false,
+		"Reconcile every namespace once and exit non-zero if any reconcile failed, without watches or leader election, "+
+			"e.g. as CronJob where a long-running controller is not allowed. Doesn't start the controller.")
+	flag.BoolVar(&migrateObjects, "migrate", false,
+		"Rewrite the annotations and labels of previous versions on all namespaces, Secrets and ServiceAccounts to the "+
+			"current scheme, print every migrated object and exit non-zero if any failed. Doesn't start the controller.")
+	flag.BoolVar(&migrateDryRun, "migrate-dry-run", false,
+		"With -migrate, only print the objects which would be migrated, without patching them.")
+	flag.StringVar(&migrateFromAnnotationDomain, "migrate-from-annotation-domain", "",
+		"With -migrate, rename the annotations of this previous CONFIG_ANNOTATION_DOMAIN to the current one.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second,
+		"How long to wait on shutdown for in-flight reconciles, resyncs and the file watcher to finish.")
 
 	flag.BoolVar(&featureDeletePods, "deletepods", false,
 		"Auto delete Pods in ErrImagePull or ImagePullBackOff, "+
 			"after patching their ServiceAccount or the ImagePullSecret attached to it.")
+	flag.BoolVar(&featureDeletePodsAnyOwner, "deletepods-any-owner", false,
+		"Also delete bare Pods and Pods of owners not listed in pod-cleanup-owner-kinds.")
 	flag.BoolVar(&featureWatchDockerConfigJSONPath, "watchdockerconfigjsonpath", false,
 		"Watch the file referenced in dockerConfigJSONPath for changes "+
 			"and trigger a reconciliation of all secrets if it's changed.")
 
+	flag.BoolVar(&featureSecretInAllNamespaces, "create-secret-in-all-namespaces", false,
+		"Create the imagePullSecret in every namespace, that is not excluded, "+
+			"regardless of whether it contains a managed ServiceAccount.")
+	flag.BoolVar(&featurePatchWorkloads, "patch-workloads", false,
+		"Attach the imagePullSecret to the Pod template of workloads, "+
+			"for clusters where mutating ServiceAccounts is not allowed.")
+	flag.BoolVar(&featureTemplateDockerConfigJSON, "template-dockerconfigjson", false,
+		"Render the dockerconfigjson as Go template per namespace, "+
+			"to inject {{ .Namespace }} into registry paths or usernames.")
+
 	flag.Float64Var(&autoMemlimitRatio, "auto-memlimit-ratio", float64(0.9),
 		"The ratio of reserved GOMEMLIMIT memory to the detected maximum container or system memory.")
+	flag.IntVar(&gogc, "gogc", 0,
+		"The GOGC percentage, overriding the GOGC environment variable. Lower values trade CPU for a smaller heap, "+
+			"higher ones spend memory to collect less often, e.g. with very large caches and a GOMEMLIMIT as safety net. "+
+			"-1 disables the garbage collector up to GOMEMLIMIT. Unchanged, if 0.")
 	flag.StringVar(&serviceAccounts, "serviceaccounts", "",
 		"comma-separated list of serviceaccounts to patch")
 	flag.StringVar(&dockerConfigJSON, "dockerconfigjson", "",
 		"json credential for authenticating container registry")
 	flag.StringVar(&dockerConfigJSONPath, "dockerconfigjsonpath", "",
 		"path for mounted json credentials")
+	flag.StringVar(&ageKeyFile, "age-keyfile", "",
+		"path to the age key used to decrypt age or sops encrypted credentials")
+	flag.StringVar(&signaturePublicKeyFile, "signature-public-keyfile", "",
+		"path to the public key used to verify the detached signature of the credentials")
+	flag.StringVar(&sourceSecret, "source-secret", "",
+		"name of the Secret in secretnamespace to read credentials from")
+	flag.StringVar(&oidcTokenEndpoint, "oidc-token-endpoint", "",
+		"token endpoint, which exchanges the projected ServiceAccount token for registry credentials")
+	flag.StringVar(&oidcTokenPath, "oidc-token-path", "",
+		"path to the projected ServiceAccount token")
+	flag.StringVar(&oidcRegistry, "oidc-registry", "",
+		"registry the exchanged credentials are valid for")
+	flag.StringVar(&oidcUsername, "oidc-username", "",
+		"username sent to the registry together with the exchanged token")
+	flag.StringVar(&credentialSources, "credential-sources", "",
+		"comma-separated, ordered list of credential sources (env, file, secret, oidc, url). The first healthy one is used")
 	flag.StringVar(&secretName, "secretname", "",
 		"name of to be managed secret")
 	flag.StringVar(&secretNamespace, "secretnamespace", "",
 		"namespace where original secret can be found")
 	flag.StringVar(&excludedNamespaces, "excluded-namespaces", "",
 		"comma-separated namespaces excluded from processing")
+	flag.StringVar(&namespaceLabel, "namespace-label", "",
+		"namespace label, whose value is matched against excluded-namespace-label-values and included-namespace-label-values")
+	flag.StringVar(&excludedNamespaceLabelValues, "excluded-namespace-label-values", "",
+		"comma-separated values of namespace-label excluded from processing")
+	flag.StringVar(&includedNamespaceLabelValues, "included-namespace-label-values", "",
+		"comma-separated values of namespace-label to process exclusively")
+	flag.DurationVar(&namespaceMinAge, "namespace-min-age", 0,
+		"minimum age of namespaces, before they are processed")
+	flag.BoolVar(&requireDefaultServiceAccount, "require-default-serviceaccount", false,
+		"only process namespaces, once their default ServiceAccount exists")
+	flag.DurationVar(&requeueAfter, "requeue-after", 0,
+		"interval in which managed ServiceAccounts are re-verified. Disabled, if 0")
+	flag.StringVar(&workloadKinds, "workload-kinds", "",
+		"comma-separated workload kinds to patch. Supported are Deployment, StatefulSet and CronJob")
+	flag.StringVar(&workloadSelector, "workload-selector", "",
+		"label selector for workloads to patch")
+	flag.StringVar(&podCleanupOwnerKinds, "pod-cleanup-owner-kinds", "",
+		"comma-separated owner kinds whose Pods may be deleted")
+	flag.DurationVar(&podCleanupDelay, "pod-cleanup-delay", 0,
+		"time to wait after patching, before Pods are deleted")
+	flag.IntVar(&podCleanupParallelism, "pod-cleanup-parallelism", 0,
+		"maximum number of Pods deleted concurrently")
+	flag.DurationVar(&podCleanupTimeout, "pod-cleanup-timeout", 0,
+		"timeout for deleting a single Pod")
+	flag.DurationVar(&daemonSetPodCleanupBackoff, "daemonset-pod-cleanup-backoff", 0,
+		"minimum age of DaemonSet Pods, before they are deleted")
+	flag.StringVar(&adminBindAddress, "admin-bind-address", "",
+		"The address the admin API binds to. Disabled, if empty. The token is read from CONFIG_ADMIN_TOKEN")
+	flag.BoolVar(&featureDeleteOrphanedSecrets, "delete-orphaned-secrets", false,
+		"Delete managed Secrets, that no longer match secretname, instead of only reporting them.")
+	flag.DurationVar(&orphanedSecretsInterval, "orphaned-secrets-interval", 0,
+		"interval in which orphaned managed Secrets are collected. Disabled, if negative")
+	flag.BoolVar(&paused, "paused", false,
+		"Halt all mutations, while still watching and reporting drift.")
+	flag.StringVar(&notifyWebhookURL, "notify-webhook-url", "",
+		"webhook, e.g. of Slack, notified about repeated sync failures and invalid credentials")
+	flag.IntVar(&notifyFailureThreshold, "notify-failure-threshold", 0,
+		"number of consecutive sync failures of a namespace, before the webhook is notified")
+	flag.IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 0,
+		"number of 429 or 5xx answers of the API server within the cooldown, "+
+			"after which audits and sweeps are skipped. Disabled, if negative")
+	flag.DurationVar(&circuitBreakerCooldown, "circuit-breaker-cooldown", 0,
+		"time without 429 or 5xx answers of the API server, after which audits and sweeps are resumed")
+	flag.IntVar(&eventLogSize, "event-log-size", 0,
+		"number of significant actions kept in memory and served on /debug/events of the admin API")
+	flag.BoolVar(&featureCheckSecretQuota, "check-secret-quota", false,
+		"Check the ResourceQuota for Secrets before creating the imagePullSecret, "+
+			"instead of retrying a Create, that is rejected by the quota.")
+	flag.BoolVar(&featureImmutableSecrets, "immutable-secrets", false,
+		"Mark the imagePullSecrets as immutable, and rotate them by deleting and recreating them in place.")
+	flag.StringVar(&selfTestNamespace, "self-test-namespace", "",
+		"canary namespace, in which the imagePullSecret and a ServiceAccount are reconciled at startup, to verify RBAC and config. Disabled, if empty")
+	flag.BoolVar(&featureSelfTestCreateNamespace, "self-test-create-namespace", false,
+		"Create the self-test namespace, if it does not exist, and delete it after the self-test.")
+	flag.StringVar(&serviceAccountPatchStrategy, "serviceaccount-patch-strategy", "",
+		"how ServiceAccounts are written: merge (JSON merge patch), strategic (strategic merge patch), update or apply (server-side apply)")
+	flag.StringVar(&profile, "profile", "",
+		"name of the profile, which managed Secrets are labeled with, to tell apart multiple deployments of the controller")
+	flag.StringVar(&gitOpsConflictPolicy, "gitops-conflict-policy", "",
+		"how imagePullSecrets claimed by ArgoCD or Flux are handled: skip, adopt or alert")
+	flag.StringVar(&annotationDomain, "annotation-domain", "",
+		"domain prefixing the annotations and labels of the controller, e.g. imagepullsecret.mycompany.io. Defaults to pborn.eu")
+	flag.BoolVar(&featureSweepCheckpoint, "sweep-checkpoint", false,
+		"checkpoint the progress of the initial sweep in a ConfigMap, so a new leader resumes it after a failover")
+	flag.BoolVar(&manageServiceAccounts, "manage-serviceaccounts", true,
+		"attach the imagePullSecret to ServiceAccounts. If false, the imagePullSecret is only distributed to all namespaces, e.g. as ServiceAccounts are mutated by another system")
+	flag.BoolVar(&enableSecretController, "enable-secret-controller", true,
+		"watch all Secrets and correct drift of the managed ones. If false, the imagePullSecret is only written, "+
+			"when a ServiceAccount or namespace is reconciled")
+	flag.BoolVar(&enableServiceAccountController, "enable-serviceaccount-controller", true,
+		"watch ServiceAccounts and attach the imagePullSecret to them. If false, ServiceAccounts are neither cached nor touched "+
+			"and the imagePullSecret is only distributed to every namespace")
+	flag.BoolVar(&manageSecrets, "manage-secrets", true,
+		"create and patch the imagePullSecret. If false, only its name is attached to ServiceAccounts, e.g. as the External Secrets Operator provisions it in every namespace")
+	flag.IntVar(&sweepChunkSize, "sweep-chunk-size", 0,
+		"number of namespaces swept between two checkpoints. Defaults to 100")
+	flag.StringVar(&operatorNamespacePolicy, "operator-namespace-policy", "",
+		"how the namespace of the controller is handled: auto (like every other namespace), include (even if excluded by a glob or the namespace label) or exclude")
+	flag.IntVar(&namespaceRateLimit, "namespace-rate-limit", 0,
+		"requests per second added to the workqueues per namespace, so a namespace generating a storm of events can't starve others. Disabled, if negative")
+	flag.IntVar(&namespaceRateBurst, "namespace-rate-burst", 0,
+		"number of requests per namespace, which are added to the workqueues without delay, before the rate limit applies")
+	flag.IntVar(&conflictThreshold, "conflict-threshold", 0,
+		"number of times per hour a managed Secret or ServiceAccount may be modified back by another actor, before a conflict is reported. Disabled, if negative")
+	flag.StringVar(&externalSecretsConflictPolicy, "external-secrets-conflict-policy", "",
+		"how imagePullSecrets owned by the External Secrets Operator are handled: skip, adopt or alert")
+	flag.StringVar(&sealedSecretsConflictPolicy, "sealed-secrets-conflict-policy", "",
+		"how imagePullSecrets owned by the Sealed Secrets controller are handled: skip, adopt or alert")
+	flag.BoolVar(&featureImagePullStatistics, "image-pull-statistics", false,
+		"count the Pods failing to pull their image per namespace, as metrics")
+	flag.StringVar(&auditSink, "audit-sink", "",
+		"path of a file, or http(s) URL, to which every mutation is appended as JSON line. Disabled, if empty")
+	flag.StringVar(&maxConcurrentReconciles, "max-concurrent-reconciles", "",
+		"number of requests reconciled concurrently by each controller, or auto to derive it from the CPU quota")
+	flag.StringVar(&dockerConfigJSONURL, "dockerconfigjson-url", "",
+		"HTTP(S) endpoint serving the dockerconfigjson, which is polled for changes")
+	flag.StringVar(&dockerConfigJSONURLCAFile, "dockerconfigjson-url-ca-file", "",
+		"CA bundle trusted for -dockerconfigjson-url, in addition to the system CAs")
+	flag.StringVar(&dockerConfigJSONURLCertFile, "dockerconfigjson-url-cert-file", "",
+		"client certificate presented to -dockerconfigjson-url (mTLS)")
+	flag.StringVar(&dockerConfigJSONURLKeyFile, "dockerconfigjson-url-key-file", "",
+		"key of the client certificate presented to -dockerconfigjson-url (mTLS)")
+	flag.StringVar(&dockerConfigJSONURLAuthorization, "dockerconfigjson-url-authorization", "",
+		"Authorization header sent to -dockerconfigjson-url, e.g. \"Bearer <token>\"")
+	flag.DurationVar(&dockerConfigJSONURLInterval, "dockerconfigjson-url-interval", 0,
+		"interval, in which -dockerconfigjson-url is polled for changes")
+	flag.BoolVar(&featureDetachServiceAccounts, "detach-unmanaged-serviceaccounts", false,
+		"remove the imagePullSecret from ServiceAccounts, which are no longer managed, e.g. after CONFIG_SERVICEACCOUNTS shrank")
+	flag.BoolVar(&featureDeleteUnusedSecrets, "delete-unused-secrets", false,
+		"delete the imagePullSecret, once it was removed from the last ServiceAccount of a namespace. Requires -detach-unmanaged-serviceaccounts")
+	flag.BoolVar(&featureNamespaceStatus, "namespace-status", false,
+		"maintain an ImagePullSecretStatus object with the conditions of the reconciliation in every namespace. Requires the ImagePullSecretStatus CRD")
+	flag.StringVar(&suggestionOutput, "suggestion-output", "",
+		"instead of mutating the cluster, write the desired changes as JSON Patches to this directory, or to the ConfigMap <name> in the secret namespace, if given as configmap:<name>")
+	flag.IntVar(&podCleanupCriticalPriority, "pod-cleanup-critical-priority", 0,
+		"minimum priority of Pods, which are deleted last and one at a time. Disabled, if 0")
+	flag.StringVar(&podCleanupCriticalSelector, "pod-cleanup-critical-namespace-selector", "",
+		"label selector for namespaces, whose Pods are deleted last and one at a time")
+	flag.DurationVar(&podCleanupCriticalInterval, "pod-cleanup-critical-interval", 0,
+		"minimum interval between two deletions of critical Pods, across all namespaces")
+	flag.BoolVar(&featureCreateSecretNamespace, "secret-namespace-create", false,
+		"create the secret namespace on startup, if it doesn't exist yet. Requires -secretnamespace")
+	flag.StringVar(&operatorNamespace, "operator-namespace", "",
+		"namespace the controller runs in, if it can't be detected, e.g. when running out-of-cluster")
+	flag.DurationVar(&watchdogTimeout, "watchdog-timeout", 0,
+		"maximum time a credential watcher may go without a heartbeat, before the liveness check fails. Disabled, if negative")
+	flag.BoolVar(&featureVerifyRotatedCredentials, "verify-rotated-credentials", false,
+		"verify rotated credentials with an auth handshake against their registries, and keep distributing the last known good ones, if they are rejected")
+	flag.BoolVar(&featureLastKnownGood, "last-known-good", false,
+		"persist the last credentials read successfully in a Secret in the secret namespace, and serve them, while the credential source is broken, e.g. at startup")
+	flag.StringVar(&caBundleFile, "ca-bundle-file", "",
+		"PEM file of CAs trusted in addition to the system CAs by all outbound calls, e.g. of a TLS-intercepting proxy")
+	flag.DurationVar(&cacheSyncPeriod, "cache-sync-period", 0,
+		"interval, in which the informer caches are resynced, replaying every object to the controllers. "+
+			"Longer periods save CPU and API load in clusters with many namespaces, at the cost of slower self-healing. "+
+			"Defaults to the controller-runtime default of 10h, if 0")
+	flag.BoolVar(&cacheSlimPods, "cache-slim-pods", false,
+		"strip cached Pods down to the fields read by the Pod cleanup. "+
+			"Reduces the memory of the Pod cache considerably in large clusters, but other fields of Pods appear empty to the controller")
+	flag.StringVar(&podCleanupReasons, "pod-cleanup-reasons", "",
+		"comma-separated list of waiting reasons, for which Pods are deleted. Only ErrImagePull and ImagePullBackOff are fixed by the imagePullSecret")
+	flag.StringVar(&podCleanupRegistries, "pod-cleanup-registries", "",
+		"comma-separated list of registries, e.g. registry.example.com or *.example.com, for whose images Pods are deleted. Pods failing to pull from other registries are left alone. All registries, if empty")
+	flag.DurationVar(&podCleanupMinAge, "pod-cleanup-min-age", 0,
+		"minimum age of Pods, before they are deleted, giving the kubelet time to retry the image pull on its own")
+	flag.BoolVar(&featureNamespaceSyncAnnotations, "namespace-sync-annotations", false,
+		"annotate every reconciled namespace with the time of the last successful sync of the imagePullSecret and the error of the last failed one, so dashboards reading namespace metadata can show the sync health")
+	flag.StringVar(&extraSecrets, "extra-secrets", "",
+		"comma-separated list of Secrets in the secret namespace, e.g. a shared CA bundle or registry mirror config, which are copied to every namespace the imagePullSecret is propagated to")
+	flag.DurationVar(&standbyVerifyInterval, "standby-verify-interval", 0,
+		"interval in which replicas, which are not the leader, audit the cluster without writing and export the drift as metrics. "+
+			"Disabled, if 0")
+	flag.BoolVar(&featureEvictPods, "evict-pods", false,
+		"Evict Pods via the eviction API, respecting PodDisruptionBudgets, instead of deleting them. "+
+			"Falls back to deleting them, if the cluster does not serve the eviction API")
+	flag.BoolVar(&featureScopeSecretCache, "scope-secret-cache", false,
+		"watch only the Secrets managed by the controller, selected by their managed-by label, and the Secrets of the secret namespace, instead of all Secrets of the cluster. "+
+			"Reduces the API and memory load in clusters with heavy Secret churn outside of the controller")
+	flag.StringVar(&podCleanupWindows, "pod-cleanup-windows", "",
+		"semicolon-separated maintenance windows, each a cron expression followed by its duration, e.g. \"0 22 * * * 8h\", "+
+			"outside of which Pod cleanup is deferred. Pods may be deleted at any time, if empty")
+	flag.StringVar(&podCleanupWindowTimezone, "pod-cleanup-window-timezone", "",
+		"timezone, in which the cron expressions of the maintenance windows are evaluated")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -130,28 +556,58 @@ func main() {
 			setupLog.Error(err, "failed to set GOMEMLIMIT")
 		}
 	}
+	if gogc != 0 {
+		debug.SetGCPercent(gogc)
+	}
+	metrics.ObserveRuntimeLimits()
 
+	// Never write credential material to the logs
+	opts.ZapOpts = append(opts.ZapOpts, uberzap.WrapCore(redact.NewCore))
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress:   metricsAddr,
-			SecureServing: secureMetrics,
-		},
-		HealthProbeBindAddress:        probeAddr,
-		LeaderElection:                enableLeaderElection,
-		LeaderElectionID:              "tamcore.github.com-imagepullsecret-patcher",
-		LeaderElectionReleaseOnCancel: true,
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+	// A missing certificate would silently fall back to a self-signed one
+	if metricsCertPath != "" {
+		if !secureMetrics {
+			setupLog.Error(nil, "-metrics-cert-path requires -metrics-secure")
+			os.Exit(1)
+		}
+		for _, name := range []string{metricsCertName, metricsCertKey} {
+			if _, err := os.Stat(filepath.Join(metricsCertPath, name)); err != nil {
+				setupLog.Error(err, "unable to read metrics server certificate")
+				os.Exit(1)
+			}
+		}
 	}
 
 	configOptions := config.ConfigOptions{
 		FeatureDeletePods:                featureDeletePods,
+		FeatureDeletePodsAnyOwner:        featureDeletePodsAnyOwner,
+		RequireDefaultServiceAccount:     requireDefaultServiceAccount,
+		Paused:                           paused,
 		FeatureWatchDockerConfigJSONPath: featureWatchDockerConfigJSONPath,
+		FeatureSecretInAllNamespaces:     featureSecretInAllNamespaces,
+		FeaturePatchWorkloads:            featurePatchWorkloads,
+		FeatureTemplateDockerConfigJSON:  featureTemplateDockerConfigJSON,
+		FeatureDeleteOrphanedSecrets:     featureDeleteOrphanedSecrets,
+		FeatureCheckSecretQuota:          featureCheckSecretQuota,
+		FeatureImmutableSecrets:          featureImmutableSecrets,
+		FeatureSelfTestCreateNamespace:   featureSelfTestCreateNamespace,
+		FeatureSweepCheckpoint:           featureSweepCheckpoint,
+		FeatureSecretOnly:                !manageServiceAccounts,
+		FeatureServiceAccountOnly:        !manageSecrets,
+		DisableSecretController:          !enableSecretController,
+		DisableServiceAccountController:  !enableServiceAccountController,
+		FeatureImagePullStatistics:       featureImagePullStatistics,
+		FeatureDetachServiceAccounts:     featureDetachServiceAccounts,
+		FeatureDeleteUnusedSecrets:       featureDeleteUnusedSecrets,
+		FeatureNamespaceStatus:           featureNamespaceStatus,
+		FeatureCreateSecretNamespace:     featureCreateSecretNamespace,
+		FeatureVerifyRotatedCredentials:  featureVerifyRotatedCredentials,
+		FeatureLastKnownGood:             featureLastKnownGood,
+		CacheSlimPods:                    cacheSlimPods,
+		FeatureNamespaceSyncAnnotations:  featureNamespaceSyncAnnotations,
+		FeatureEvictPods:                 featureEvictPods,
+		FeatureScopeSecretCache:          featureScopeSecretCache,
 	}
 	if dockerConfigJSON != "" {
 		configOptions.DockerConfigJSON = dockerConfigJSON
@@ -159,6 +615,30 @@ func main() {
 	if dockerConfigJSONPath != "" {
 		configOptions.DockerConfigJSONPath = dockerConfigJSONPath
 	}
+	if ageKeyFile != "" {
+		configOptions.AgeKeyFile = ageKeyFile
+	}
+	if signaturePublicKeyFile != "" {
+		configOptions.SignaturePublicKeyFile = signaturePublicKeyFile
+	}
+	if sourceSecret != "" {
+		configOptions.SourceSecret = sourceSecret
+	}
+	if oidcTokenEndpoint != "" {
+		configOptions.OIDCTokenEndpoint = oidcTokenEndpoint
+	}
+	if oidcTokenPath != "" {
+		configOptions.OIDCTokenPath = oidcTokenPath
+	}
+	if oidcRegistry != "" {
+		configOptions.OIDCRegistry = oidcRegistry
+	}
+	if oidcUsername != "" {
+		configOptions.OIDCUsername = oidcUsername
+	}
+	if credentialSources != "" {
+		configOptions.CredentialSources = credentialSources
+	}
 	if secretName != "" {
 		configOptions.SecretName = secretName
 	}
@@ -168,37 +648,561 @@ func main() {
 	if excludedNamespaces != "" {
 		configOptions.ExcludedNamespaces = excludedNamespaces
 	}
+	if namespaceLabel != "" {
+		configOptions.NamespaceLabel = namespaceLabel
+	}
+	if excludedNamespaceLabelValues != "" {
+		configOptions.ExcludedNamespaceLabelValues = excludedNamespaceLabelValues
+	}
+	if includedNamespaceLabelValues != "" {
+		configOptions.IncludedNamespaceLabelValues = includedNamespaceLabelValues
+	}
 	if serviceAccounts != "" {
 		configOptions.ServiceAccounts = serviceAccounts
 	}
+	if namespaceMinAge != 0 {
+		configOptions.NamespaceMinAge = namespaceMinAge
+	}
+	if requeueAfter != 0 {
+		configOptions.RequeueAfter = requeueAfter
+	}
+	if workloadKinds != "" {
+		configOptions.WorkloadKinds = workloadKinds
+	}
+	if workloadSelector != "" {
+		configOptions.WorkloadSelector = workloadSelector
+	}
+	if podCleanupOwnerKinds != "" {
+		configOptions.PodCleanupOwnerKinds = podCleanupOwnerKinds
+	}
+	if podCleanupDelay != 0 {
+		configOptions.PodCleanupDelay = podCleanupDelay
+	}
+	if podCleanupParallelism != 0 {
+		configOptions.PodCleanupParallelism = podCleanupParallelism
+	}
+	if podCleanupTimeout != 0 {
+		configOptions.PodCleanupTimeout = podCleanupTimeout
+	}
+	if daemonSetPodCleanupBackoff != 0 {
+		configOptions.DaemonSetPodCleanupBackoff = daemonSetPodCleanupBackoff
+	}
+	if adminBindAddress != "" {
+		configOptions.AdminBindAddress = adminBindAddress
+	}
+	if orphanedSecretsInterval != 0 {
+		configOptions.OrphanedSecretsInterval = orphanedSecretsInterval
+	}
+	if notifyWebhookURL != "" {
+		configOptions.NotifyWebhookURL = notifyWebhookURL
+	}
+	if notifyFailureThreshold != 0 {
+		configOptions.NotifyFailureThreshold = notifyFailureThreshold
+	}
+	if eventLogSize != 0 {
+		configOptions.EventLogSize = eventLogSize
+	}
+	if circuitBreakerThreshold != 0 {
+		configOptions.CircuitBreakerThreshold = circuitBreakerThreshold
+	}
+	if circuitBreakerCooldown != 0 {
+		configOptions.CircuitBreakerCooldown = circuitBreakerCooldown
+	}
+	if selfTestNamespace != "" {
+		configOptions.SelfTestNamespace = selfTestNamespace
+	}
+	if serviceAccountPatchStrategy != "" {
+		configOptions.ServiceAccountPatchStrategy = serviceAccountPatchStrategy
+	}
+	if profile != "" {
+		configOptions.Profile = profile
+	}
+	if gitOpsConflictPolicy != "" {
+		configOptions.GitOpsConflictPolicy = gitOpsConflictPolicy
+	}
+	if annotationDomain != "" {
+		configOptions.AnnotationDomain = annotationDomain
+	}
+	if sweepChunkSize != 0 {
+		configOptions.SweepChunkSize = sweepChunkSize
+	}
+	if operatorNamespacePolicy != "" {
+		configOptions.OperatorNamespacePolicy = operatorNamespacePolicy
+	}
+	if namespaceRateLimit != 0 {
+		configOptions.NamespaceRateLimit = namespaceRateLimit
+	}
+	if namespaceRateBurst != 0 {
+		configOptions.NamespaceRateBurst = namespaceRateBurst
+	}
+	if conflictThreshold != 0 {
+		configOptions.ConflictThreshold = conflictThreshold
+	}
+	if externalSecretsConflictPolicy != "" {
+		configOptions.ExternalSecretsConflictPolicy = externalSecretsConflictPolicy
+	}
+	if sealedSecretsConflictPolicy != "" {
+		configOptions.SealedSecretsConflictPolicy = sealedSecretsConflictPolicy
+	}
+	if auditSink != "" {
+		configOptions.AuditSink = auditSink
+	}
+	if maxConcurrentReconciles != "" {
+		configOptions.MaxConcurrentReconciles = maxConcurrentReconciles
+	}
+	if dockerConfigJSONURL != "" {
+		configOptions.DockerConfigJSONURL = dockerConfigJSONURL
+	}
+	if dockerConfigJSONURLCAFile != "" {
+		configOptions.DockerConfigJSONURLCAFile = dockerConfigJSONURLCAFile
+	}
+	if dockerConfigJSONURLCertFile != "" {
+		configOptions.DockerConfigJSONURLCertFile = dockerConfigJSONURLCertFile
+	}
+	if dockerConfigJSONURLKeyFile != "" {
+		configOptions.DockerConfigJSONURLKeyFile = dockerConfigJSONURLKeyFile
+	}
+	if dockerConfigJSONURLAuthorization != "" {
+		configOptions.DockerConfigJSONURLAuthorization = dockerConfigJSONURLAuthorization
+	}
+	if dockerConfigJSONURLInterval != 0 {
+		configOptions.DockerConfigJSONURLInterval = dockerConfigJSONURLInterval
+	}
+	if suggestionOutput != "" {
+		configOptions.SuggestionOutput = suggestionOutput
+	}
+	if podCleanupCriticalPriority != 0 {
+		configOptions.PodCleanupCriticalPriority = podCleanupCriticalPriority
+	}
+	if podCleanupCriticalSelector != "" {
+		configOptions.PodCleanupCriticalSelector = podCleanupCriticalSelector
+	}
+	if podCleanupCriticalInterval != 0 {
+		configOptions.PodCleanupCriticalInterval = podCleanupCriticalInterval
+	}
+	if operatorNamespace != "" {
+		configOptions.OperatorNamespace = operatorNamespace
+	}
+	if watchdogTimeout != 0 {
+		configOptions.WatchdogTimeout = watchdogTimeout
+	}
+	if caBundleFile != "" {
+		configOptions.CABundleFile = caBundleFile
+	}
+	if cacheSyncPeriod != 0 {
+		configOptions.CacheSyncPeriod = cacheSyncPeriod
+	}
+	if podCleanupReasons != "" {
+		configOptions.PodCleanupReasons = podCleanupReasons
+	}
+	if podCleanupRegistries != "" {
+		configOptions.PodCleanupRegistries = podCleanupRegistries
+	}
+	if podCleanupMinAge != 0 {
+		configOptions.PodCleanupMinAge = podCleanupMinAge
+	}
+	if extraSecrets != "" {
+		configOptions.ExtraSecrets = extraSecrets
+	}
+	if standbyVerifyInterval != 0 {
+		configOptions.StandbyVerifyInterval = standbyVerifyInterval
+	}
+	if podCleanupWindows != "" {
+		configOptions.PodCleanupWindows = podCleanupWindows
+	}
+	if podCleanupWindowTimezone != "" {
+		configOptions.PodCleanupWindowTimezone = podCleanupWindowTimezone
+	}
 	controllerConfig := config.NewConfig(configOptions)
+	// Migrations patch objects directly, which would break the promise of suggestion mode to never mutate the cluster
+	if migrateObjects && !migrateDryRun && controllerConfig.SuggestionOutput != "" {
+		setupLog.Error(nil, "-migrate requires -migrate-dry-run with CONFIG_SUGGESTION_OUTPUT")
+		os.Exit(1)
+	}
+
+	// Tell several deployments of the controller apart in metrics, logs and events
+	profileName := utils.ProfileName(controllerConfig)
+	metrics.SetProfile(profileName)
+	logger := ctrl.Log.WithValues("profile", profileName)
+	setupLog = logger.WithName("setup")
+
+	// Trust the custom CA bundle for all outbound calls, e.g. to credential providers and registries
+	if controllerConfig.CABundleFile != "" {
+		caBundleFile, err := utils.NormalizePath(controllerConfig.CABundleFile)
+		if err == nil {
+			err = outbound.SetCABundleFile(caBundleFile)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to load CA bundle")
+			os.Exit(1)
+		}
+	}
+
+	// Track errors of the API server on all requests. The breaker is configured
+	// below, once the controller config is known.
+	apiBreaker := &breaker.Breaker{}
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.Wrap(apiBreaker.WrapTransport)
+
+	// Objects without a controller are read from the API server, instead of caching every one of them in the cluster
+	uncached := []client.Object{}
+	if controllerConfig.DisableSecretController {
+		uncached = append(uncached, &corev1.Secret{})
+	}
+	if controllerConfig.DisableServiceAccountController {
+		uncached = append(uncached, &corev1.ServiceAccount{})
+	}
+	clientOptions := client.Options{Cache: &client.CacheOptions{DisableFor: uncached}}
+	// Tune the informer caches for clusters with many namespaces and Pods
+	cacheOptions := cache.Options{}
+	if controllerConfig.CacheSyncPeriod > 0 {
+		cacheOptions.SyncPeriod = &controllerConfig.CacheSyncPeriod
+	}
+	if controllerConfig.CacheSlimPods {
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{
+			&corev1.Pod{}: {Transform: utils.SlimPod},
+		}
+	}
+	// Scope the cache of Secrets to the managed ones and the secret namespace
+	var secretCache *secretcache.Cache
+	var newCache cache.NewCacheFunc
+	if controllerConfig.FeatureScopeSecretCache {
+		newCache = func(cfg *rest.Config, opts cache.Options) (cache.Cache, error) {
+			var err error
+			secretCache, err = secretcache.New(cfg, opts)
+			return secretCache, err
+		}
+	}
+	// In suggestion mode, mutations are written as suggestions instead
+	var newClient client.NewClientFunc
+	if controllerConfig.SuggestionOutput != "" {
+		newClient = suggest.NewClientFunc(controllerConfig)
+	}
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme: scheme,
+		Logger: logger,
+		Metrics: metricsserver.Options{
+			BindAddress:   metricsAddr,
+			SecureServing: secureMetrics,
+			// The certificate is watched and reloaded by the metrics server
+			CertDir:  metricsCertPath,
+			CertName: metricsCertName,
+			KeyName:  metricsCertKey,
+			ExtraHandlers: map[string]http.Handler{
+				"/metrics/openmetrics": metrics.OpenMetricsHandler(),
+			},
+		},
+		HealthProbeBindAddress:        probeAddr,
+		LeaderElection:                enableLeaderElection,
+		LeaderElectionID:              "tamcore.github.com-imagepullsecret-patcher",
+		LeaderElectionNamespace:       controllerConfig.OperatorNamespace,
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &gracefulShutdownTimeout,
+		Client:                        clientOptions,
+		Cache:                         cacheOptions,
+		NewCache:                      newCache,
+		NewClient:                     newClient,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	// The controllers read the config from a snapshot, which can be replaced at runtime
+	configStore := config.NewStore(controllerConfig)
+
+	notifier := notify.NewNotifier(configStore)
+	utils.CredentialsRejectedHandler = notifier.RecordCredentialsRejected
+	teardown.Default.OnDelete(notifier.Forget)
+	eventlog.Default = eventlog.New(controllerConfig.EventLogSize)
+	auditLog := audit.NewSink(controllerConfig)
+	if auditLog != nil {
+		eventlog.Default.SetSink(auditLog.Observe)
+	}
+	apiBreaker.Configure(controllerConfig.CircuitBreakerThreshold, controllerConfig.CircuitBreakerCooldown)
+	fairness.Default = fairness.New(controllerConfig.NamespaceRateLimit, controllerConfig.NamespaceRateBurst)
+	redact.Register(controllerConfig.DockerConfigJSON, controllerConfig.AgeKey, controllerConfig.AdminToken, controllerConfig.DockerConfigJSONURLAuthorization)
+	recorder := utils.NewProfileRecorder(redact.NewRecorder(mgr.GetEventRecorderFor("imagepullsecret-patcher")), controllerConfig)
+	conflict.Default = conflict.New(recorder, controllerConfig.ConflictThreshold)
+
+	// Adjust to the version of the cluster, so a single build works across Kubernetes versions
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	serverCapabilities, err := capabilities.Detect(discoveryClient)
+	if err != nil {
+		setupLog.Error(err, "unable to detect server capabilities")
+		os.Exit(1)
+	}
+	setupLog.Info("detected server capabilities", "version", serverCapabilities.Version,
+		"serverSideApply", serverCapabilities.ServerSideApply, "immutableSecrets", serverCapabilities.ImmutableSecrets,
+		"eviction", serverCapabilities.EvictionGroupVersion)
+	capabilities.Default = serverCapabilities
+	configStore.Update(func(c *config.Config) {
+		serverCapabilities.Adjust(ctrl.LoggerInto(context.Background(), setupLog), c)
+	})
+	controllerConfig = configStore.Load()
+
+	if verify {
+		os.Exit(runVerify(restConfig, controllerConfig))
+	}
+	if migrateObjects {
+		os.Exit(runMigrate(restConfig, controllerConfig, migrateFromAnnotationDomain, migrateDryRun))
+	}
+	// Ease the bootstrap of fresh clusters, in which nothing created the secret namespace yet
+	if controllerConfig.FeatureCreateSecretNamespace {
+		if err = utils.CreateSecretNamespace(ctrl.LoggerInto(context.Background(), setupLog), mgr.GetClient(), controllerConfig); err != nil {
+			setupLog.Error(err, "unable to create secret namespace")
+			os.Exit(1)
+		}
+	}
+	if sweep {
+		os.Exit(runSweep(restConfig, controllerConfig, notifier))
+	}
+
+	// Disable features lacking permissions, instead of failing every reconcile with Forbidden errors
+	rbacPreflight := &controller.RBACPreflight{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Config:    configStore,
+	}
+	if err = rbacPreflight.Run(ctrl.LoggerInto(context.Background(), setupLog)); err != nil {
+		setupLog.Error(err, "unable to run RBAC preflight")
+		os.Exit(1)
+	}
+	controllerConfig = configStore.Load()
 
-	if err = (&controller.ServiceAccountReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: controllerConfig,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ServiceAccount")
+	// Cancel and purge work for namespaces being deleted, instead of retrying it during their teardown
+	namespaceInformer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Namespace{})
+	if err != nil {
+		setupLog.Error(err, "unable to watch namespaces")
+		os.Exit(1)
+	}
+	if _, err = namespaceInformer.AddEventHandler(teardown.Default.ResourceEventHandler()); err != nil {
+		setupLog.Error(err, "unable to watch namespaces")
 		os.Exit(1)
 	}
-	if err = (&controller.SecretReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: controllerConfig,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Secret")
+	// Watch the managed Secrets only, and all Secrets of the secret namespace, which follows the config
+	if secretCache != nil {
+		if err = secretCache.Pin(func() string {
+			return configStore.Load().SecretNamespace
+		}); err != nil {
+			setupLog.Error(err, "unable to watch secrets")
+			os.Exit(1)
+		}
+		if _, err = namespaceInformer.AddEventHandler(secretCache.NamespaceHandler()); err != nil {
+			setupLog.Error(err, "unable to watch namespaces")
+			os.Exit(1)
+		}
+	}
+	// Track the paused annotation on the namespace of the controller, instead of fetching it on every reconcile
+	if _, err = namespaceInformer.AddEventHandler(utils.PausedHandler(configStore)); err != nil {
+		setupLog.Error(err, "unable to watch namespaces")
 		os.Exit(1)
 	}
+	// Count the namespaces excluded by the configuration, to spot overly broad exclusions
+	if _, err = namespaceInformer.AddEventHandler(utils.NamespaceExclusionHandler(configStore)); err != nil {
+		setupLog.Error(err, "unable to watch namespaces")
+		os.Exit(1)
+	}
+
+	// Count the Pods failing to pull their image, to see whether distributing the credentials fixes them
+	if controllerConfig.FeatureImagePullStatistics {
+		podInformer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Pod{})
+		if err != nil {
+			setupLog.Error(err, "unable to watch pods")
+			os.Exit(1)
+		}
+		if _, err = podInformer.AddEventHandler(utils.ImagePullFailureHandler()); err != nil {
+			setupLog.Error(err, "unable to watch pods")
+			os.Exit(1)
+		}
+	}
+
+	// In secret-only mode, ServiceAccounts are left to another system
+	if !controllerConfig.FeatureSecretOnly {
+		serviceAccountReconciler := &controller.ServiceAccountReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Config:    configStore,
+			Recorder:  recorder,
+			Notifier:  notifier,
+			Breaker:   apiBreaker,
+			APIReader: mgr.GetAPIReader(),
+		}
+		// Sweep the existing ServiceAccounts in checkpointed chunks, so a failover doesn't start over
+		if controllerConfig.FeatureSweepCheckpoint {
+			serviceAccountReconciler.Sweep = controller.NewInitialSweep(mgr.GetClient(), mgr.GetAPIReader(), configStore)
+			serviceAccountReconciler.Sweep.Reconciler = teardown.Default.Reconciler(serviceAccountReconciler)
+			if err = mgr.Add(serviceAccountReconciler.Sweep); err != nil {
+				setupLog.Error(err, "unable to set up initial sweep")
+				os.Exit(1)
+			}
+		}
+		if err = serviceAccountReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ServiceAccount")
+			os.Exit(1)
+		}
+	}
+	// In ServiceAccount-only mode, the imagePullSecret is provisioned externally
+	var secretReconciler *controller.SecretReconciler
+	// Restart the Pod, instead of silently freezing credential rotation, if a credential watcher dies
+	credentialWatchdog := watchdog.New()
+	if !controllerConfig.DisableSecretController {
+		secretReconciler = &controller.SecretReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Config:   configStore,
+			Recorder: recorder,
+			Notifier: notifier,
+			Watchdog: credentialWatchdog,
+			Elected:  mgr.Elected(),
+		}
+		if err = secretReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Secret")
+			os.Exit(1)
+		}
+	}
+	if controllerConfig.FeatureSecretInAllNamespaces {
+		if err = (&controller.NamespaceReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Config:   configStore,
+			Recorder: recorder,
+			Notifier: notifier,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Namespace")
+			os.Exit(1)
+		}
+	}
+	if controllerConfig.FeaturePatchWorkloads {
+		for _, kind := range strings.Split(controllerConfig.WorkloadKinds, ",") {
+			if err = (&controller.WorkloadReconciler{
+				Client:  mgr.GetClient(),
+				Scheme:  mgr.GetScheme(),
+				Config:  configStore,
+				Breaker: apiBreaker,
+				Kind:    strings.TrimSpace(kind),
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", kind)
+				os.Exit(1)
+			}
+		}
+	}
+	// Orphaned Secrets are listed via the index of the Secret controller
+	if controllerConfig.OrphanedSecretsInterval > 0 && !controllerConfig.DisableSecretController {
+		if err = mgr.Add(&controller.OrphanedSecretCollector{
+			Client:   mgr.GetClient(),
+			Config:   configStore,
+			Recorder: recorder,
+			Breaker:  apiBreaker,
+		}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "OrphanedSecret")
+			os.Exit(1)
+		}
+	}
+	// Only the leader persists the last known good credentials, suggestion mode writes nothing
+	if controllerConfig.FeatureLastKnownGood && controllerConfig.SuggestionOutput == "" {
+		if err = mgr.Add(&controller.LastKnownGoodRecorder{
+			Client: mgr.GetClient(),
+			Config: configStore,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up last known good credentials")
+			os.Exit(1)
+		}
+	}
+	// Replicas waiting for the leader lease audit the cluster without writing, to spot what the leader misses
+	if controllerConfig.StandbyVerifyInterval > 0 && enableLeaderElection {
+		if err = mgr.Add(&controller.StandbyVerifier{
+			Client:  mgr.GetClient(),
+			Config:  configStore,
+			Breaker: apiBreaker,
+			Elected: mgr.Elected(),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up standby verification")
+			os.Exit(1)
+		}
+		// Suggestion mode writes nothing, so standbys fall back to the hashes recorded on the imagePullSecrets
+		if controllerConfig.SuggestionOutput == "" {
+			if err = mgr.Add(&controller.CredentialHashPublisher{
+				Client: mgr.GetClient(),
+				Config: configStore,
+			}); err != nil {
+				setupLog.Error(err, "unable to set up credential hash publishing")
+				os.Exit(1)
+			}
+		}
+	}
+	// Pod cleanups deferred outside of the maintenance windows run once one opens
+	if controllerConfig.FeatureDeletePods && controllerConfig.PodCleanupWindows != "" {
+		if err = mgr.Add(&controller.DeferredPodCleanup{
+			Client:   mgr.GetClient(),
+			Config:   configStore,
+			Recorder: recorder,
+			Queue:    maintenance.Default,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up deferred Pod cleanup")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if auditLog != nil {
+		if err = mgr.Add(auditLog); err != nil {
+			setupLog.Error(err, "unable to set up audit sink")
+			os.Exit(1)
+		}
+	}
+
+	if controllerConfig.AdminBindAddress != "" {
+		adminServer := &admin.Server{
+			Client:  mgr.GetClient(),
+			Config:  configStore,
+			Breaker: apiBreaker,
+		}
+		if secretReconciler != nil {
+			adminServer.Resyncer = secretReconciler
+		}
+		if err = mgr.Add(adminServer); err != nil {
+			setupLog.Error(err, "unable to set up admin API")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
+	if err := mgr.AddHealthzCheck("credential-watchers", credentialWatchdog.Checker); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("rbac", rbacPreflight.Checker); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if controllerConfig.SelfTestNamespace != "" {
+		selfTest := &controller.SelfTest{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Config:    configStore,
+			Elected:   mgr.Elected(),
+		}
+		if err := mgr.Add(selfTest); err != nil {
+			setupLog.Error(err, "unable to set up self-test")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("self-test", selfTest.Checker); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -206,3 +1210,67 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runVerify audits every namespace and prints a diff summary. It returns the exit code.
+func runVerify(restConfig *rest.Config, c *config.Config) int {
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 2
+	}
+
+	audits, err := utils.AuditCluster(context.Background(), k8sClient, c)
+	if err != nil {
+		setupLog.Error(err, "unable to verify namespaces")
+		return 2
+	}
+	if !utils.WriteAuditSummary(os.Stdout, audits) {
+		return 1
+	}
+	return 0
+}
+
+// runMigrate rewrites the markers of previous versions on all objects to the current scheme. It returns the exit code.
+func runMigrate(restConfig *rest.Config, c *config.Config, fromAnnotationDomain string, dryRun bool) int {
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 2
+	}
+
+	summary, err := migrate.Run(ctrl.LoggerInto(context.Background(), setupLog), k8sClient, c,
+		migrate.Migrations(c, fromAnnotationDomain), dryRun, os.Stdout)
+	if err != nil {
+		setupLog.Error(err, "unable to migrate objects")
+		return 2
+	}
+	if !migrate.WriteSummary(os.Stdout, summary, dryRun) {
+		return 1
+	}
+	return 0
+}
+
+// runSweep reconciles every namespace once. It returns the exit code.
+func runSweep(restConfig *rest.Config, c *config.Config, notifier *notify.Notifier) int {
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 2
+	}
+
+	// In suggestion mode, mutations are written as suggestions instead, like in the manager
+	var sweepClient client.Client = k8sClient
+	if c.SuggestionOutput != "" {
+		sweepClient = suggest.NewClient(k8sClient, c)
+	}
+
+	failed, err := controller.SweepOnce(ctrl.LoggerInto(context.Background(), setupLog), sweepClient, c, notifier)
+	if err != nil {
+		setupLog.Error(err, "unable to sweep namespaces")
+		return 2
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
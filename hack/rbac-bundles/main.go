@@ -0,0 +1,259 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// rbac-bundles generates the RBAC bundles of the Helm chart into deploy/helm/_generated/rbac/<bundle>/role.yaml
+// from the markers of the controller, so the bundles can't drift from the permissions the code requires.
+// A bundle marker adds the rule of the kubebuilder marker on the next line to the listed bundles:
+//
+//	//+imagepullsecret-patcher:rbac:bundles=secret-only;serviceaccount-only
+//	//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//
+// Opt-in permissions, which aren't part of the ClusterRole covering every feature, are declared without a
+// kubebuilder marker, so controller-gen leaves them out:
+//
+//	//+imagepullsecret-patcher:rbac:optin=secret-namespace,groups=core,resources=namespaces,verbs=create
+//
+// It's run by make manifests from the root of the repository.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	bundlesMarker = "+imagepullsecret-patcher:rbac:bundles="
+	optInMarker   = "+imagepullsecret-patcher:rbac:optin="
+	rbacMarker    = "+kubebuilder:rbac:"
+)
+
+// sources are the directories scanned for markers
+var sources = []string{"api", "cmd", "internal"}
+
+// rule is a rule of a ClusterRole
+type rule struct {
+	groups    []string
+	resources []string
+	verbs     []string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run generates the bundles and returns the exit code
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rbac-bundles", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", ".", "root of the repository, which is scanned for markers")
+	output := flags.String("output", "deploy/helm/_generated/rbac", "directory the bundles are written to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	bundles, err := collect(*root)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for name, rules := range bundles {
+		dir := filepath.Join(*output, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(filepath.Join(dir, "role.yaml"), render(normalize(rules)), 0o644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// collect returns the rules of every bundle declared in the Go files of the sources below root
+func collect(root string) (map[string][]rule, error) {
+	bundles := map[string][]rule{}
+	for _, source := range sources {
+		err := filepath.WalkDir(filepath.Join(root, source), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".go" || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return parse(path, f, bundles)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bundles, nil
+}
+
+// parse adds the rules declared by the markers in r to bundles. name is used in errors only.
+func parse(name string, r io.Reader, bundles map[string][]rule) error {
+	scanner := bufio.NewScanner(r)
+	var pending []string
+	pendingLine := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		comment, isComment := strings.CutPrefix(text, "//")
+		comment = strings.TrimSpace(comment)
+
+		if pending != nil {
+			args, ok := strings.CutPrefix(comment, rbacMarker)
+			if !isComment || !ok {
+				return fmt.Errorf("%s:%d: %s must be followed by a %s marker", name, pendingLine, bundlesMarker, rbacMarker)
+			}
+			rule, err := parseRule(args)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", name, line, err)
+			}
+			for _, bundle := range pending {
+				bundles[bundle] = append(bundles[bundle], rule)
+			}
+			pending = nil
+			continue
+		}
+		if !isComment {
+			continue
+		}
+		if names, ok := strings.CutPrefix(comment, bundlesMarker); ok {
+			pending = strings.Split(names, ";")
+			pendingLine = line
+			continue
+		}
+		if args, ok := strings.CutPrefix(comment, optInMarker); ok {
+			bundle, args, _ := strings.Cut(args, ",")
+			rule, err := parseRule(args)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", name, line, err)
+			}
+			bundles[bundle] = append(bundles[bundle], rule)
+		}
+	}
+	if pending != nil {
+		return fmt.Errorf("%s:%d: %s must be followed by a %s marker", name, pendingLine, bundlesMarker, rbacMarker)
+	}
+	return scanner.Err()
+}
+
+// parseRule parses the arguments of a kubebuilder rbac marker, e.g. groups=core,resources=secrets,verbs=get;list
+func parseRule(args string) (rule, error) {
+	r := rule{}
+	for _, arg := range strings.Split(args, ",") {
+		key, value, _ := strings.Cut(arg, "=")
+		values := strings.Split(value, ";")
+		switch key {
+		case "groups":
+			for i := range values {
+				if values[i] == "core" {
+					values[i] = ""
+				}
+			}
+			r.groups = values
+		case "resources":
+			r.resources = values
+		case "verbs":
+			r.verbs = values
+		default:
+			return rule{}, fmt.Errorf("unsupported argument '%s' of rbac marker", key)
+		}
+	}
+	if r.groups == nil || r.resources == nil || r.verbs == nil {
+		return rule{}, fmt.Errorf("rbac marker requires groups, resources and verbs")
+	}
+	return r, nil
+}
+
+// normalize merges rules like controller-gen: the verbs of every resource are merged, and the resources of a
+// group with the same verbs share a rule. The rules are ordered by group and resources.
+func normalize(rules []rule) []rule {
+	verbs := map[[2]string]map[string]bool{}
+	for _, r := range rules {
+		for _, group := range r.groups {
+			for _, resource := range r.resources {
+				key := [2]string{group, resource}
+				if verbs[key] == nil {
+					verbs[key] = map[string]bool{}
+				}
+				for _, verb := range r.verbs {
+					verbs[key][verb] = true
+				}
+			}
+		}
+	}
+
+	resources := map[[2]string][]string{}
+	for key, set := range verbs {
+		sorted := make([]string, 0, len(set))
+		for verb := range set {
+			sorted = append(sorted, verb)
+		}
+		sort.Strings(sorted)
+		shared := [2]string{key[0], strings.Join(sorted, ";")}
+		resources[shared] = append(resources[shared], key[1])
+	}
+
+	normalized := make([]rule, 0, len(resources))
+	for shared, names := range resources {
+		sort.Strings(names)
+		normalized = append(normalized, rule{groups: []string{shared[0]}, resources: names, verbs: strings.Split(shared[1], ";")})
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		if normalized[i].groups[0] != normalized[j].groups[0] {
+			return normalized[i].groups[0] < normalized[j].groups[0]
+		}
+		return strings.Join(normalized[i].resources, ",") < strings.Join(normalized[j].resources, ",")
+	})
+	return normalized
+}
+
+// render returns the ClusterRole holding rules in the format of controller-gen
+func render(rules []rule) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata: {}\nrules:\n")
+	for _, r := range rules {
+		writeList(buf, "- apiGroups:", r.groups)
+		writeList(buf, "  resources:", r.resources)
+		writeList(buf, "  verbs:", r.verbs)
+	}
+	return buf.Bytes()
+}
+
+// writeList writes the YAML list of values below key
+func writeList(buf *bytes.Buffer, key string, values []string) {
+	buf.WriteString(key + "\n")
+	for _, value := range values {
+		if value == "" {
+			value = `""`
+		}
+		buf.WriteString("  - " + value + "\n")
+	}
+}
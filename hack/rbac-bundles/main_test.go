@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The generated bundles are committed, so they have to be regenerated along with the markers
+func Test_Bundles_UpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+	bundles, err := collect(root)
+	if err != nil {
+		t.Fatalf("collect() error = %v", err)
+	}
+	for _, name := range []string{"secret-only", "serviceaccount-only", "delete-pods", "patch-workloads", "self-test-namespace", "secret-namespace"} {
+		if _, ok := bundles[name]; !ok {
+			t.Errorf("collect() is missing the bundle %s", name)
+		}
+	}
+	for name, rules := range bundles {
+		committed, err := os.ReadFile(filepath.Join(root, "deploy", "helm", "_generated", "rbac", name, "role.yaml"))
+		if err != nil {
+			t.Errorf("bundle %s is not generated, run make manifests: %v", name, err)
+			continue
+		}
+		if got := string(render(normalize(rules))); got != string(committed) {
+			t.Errorf("bundle %s is outdated, run make manifests:\n%s", name, got)
+		}
+	}
+}
+
+func Test_parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Bundle marker. Should add the rule of the next marker to every bundle.",
+			source: `//+imagepullsecret-patcher:rbac:bundles=secret-only;serviceaccount-only
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=delete`,
+			want: map[string]string{"secret-only": "secrets", "serviceaccount-only": "secrets"},
+		},
+		{
+			name:   "Opt-in marker. Should add its own rule.",
+			source: `//+imagepullsecret-patcher:rbac:optin=secret-namespace,groups=core,resources=namespaces,verbs=create`,
+			want:   map[string]string{"secret-namespace": "namespaces"},
+		},
+		{
+			name: "Bundle marker without kubebuilder marker. Should fail.",
+			source: `//+imagepullsecret-patcher:rbac:bundles=secret-only
+
+func f() {}`,
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported argument. Should fail.",
+			source:  `//+imagepullsecret-patcher:rbac:optin=secret-namespace,groups=core,resources=namespaces,verbs=create,namespace=x`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundles := map[string][]rule{}
+			err := parse("test.go", strings.NewReader(tt.source), bundles)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(bundles) != len(tt.want) {
+				t.Errorf("parse() = %v, want %v", bundles, tt.want)
+			}
+			for name, resource := range tt.want {
+				if len(bundles[name]) != 1 || bundles[name][0].resources[0] != resource {
+					t.Errorf("parse() bundle %s = %v, want %s", name, bundles[name], resource)
+				}
+			}
+		})
+	}
+}
+
+func Test_normalize(t *testing.T) {
+	got := string(render(normalize([]rule{
+		{groups: []string{""}, resources: []string{"secrets"}, verbs: []string{"get", "list"}},
+		{groups: []string{"apps"}, resources: []string{"deployments"}, verbs: []string{"get"}},
+		{groups: []string{""}, resources: []string{"namespaces"}, verbs: []string{"list"}},
+		{groups: []string{""}, resources: []string{"namespaces"}, verbs: []string{"get"}},
+	})))
+	want := `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata: {}
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - namespaces
+  - secrets
+  verbs:
+  - get
+  - list
+- apiGroups:
+  - apps
+  resources:
+  - deployments
+  verbs:
+  - get
+`
+	if got != want {
+		t.Errorf("normalize() =\n%s\nwant\n%s", got, want)
+	}
+}
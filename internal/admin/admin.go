@@ -0,0 +1,288 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+	"github.com/tamcore/imagepullsecret-patcher/pkg/state"
+)
+
+// Resyncer enqueues all managed objects for reconciliation
+type Resyncer interface {
+	Resync(ctx context.Context) error
+}
+
+// Server serves an authenticated REST API, to drive the controller programmatically
+type Server struct {
+	Client   client.Client
+	Config   *config.Store
+	Resyncer Resyncer
+	Breaker  *breaker.Breaker
+}
+
+// resyncTimeout bounds how long a resync request waits for the controller to pick up all Secrets
+const resyncTimeout = 30 * time.Second
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;patch
+
+// NeedLeaderElection ensures the API is only served by the leader, as only it runs the controllers
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+// Start serves the API on AdminBindAddress, until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	c := s.Config.Load()
+	if c.AdminToken == "" {
+		return fmt.Errorf("`CONFIG_ADMIN_TOKEN` is required to serve the admin API")
+	}
+
+	server := &http.Server{
+		Addr:              c.AdminBindAddress,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Requests, e.g. a running resync, are cancelled on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.FromContext(ctx).Info("serving admin API", "address", c.AdminBindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the authenticated routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/namespaces", s.listNamespaces)
+	mux.HandleFunc("GET /api/v1/namespaces/excluded", s.listExcludedNamespaces)
+	mux.HandleFunc("GET /api/v1/namespaces/{name}", s.getNamespace)
+	mux.HandleFunc("GET /api/v1/namespaces/{name}/serviceaccounts/{serviceaccount}/explain", s.explainServiceAccount)
+	mux.HandleFunc("POST /api/v1/namespaces/{name}/pause", s.pauseNamespace)
+	mux.HandleFunc("DELETE /api/v1/namespaces/{name}/pause", s.resumeNamespace)
+	mux.HandleFunc("POST /api/v1/resync", s.resync)
+	mux.HandleFunc("GET /api/v1/audit", s.audit)
+	mux.HandleFunc("GET /api/v1/state", s.state)
+	mux.HandleFunc("GET /debug/events", s.events)
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.Load().AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listNamespaces(w http.ResponseWriter, r *http.Request) {
+	namespaceList := &corev1.NamespaceList{}
+	if err := s.Client.List(r.Context(), namespaceList); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	namespaces := []string{}
+	for i := range namespaceList.Items {
+		if !utils.IsNamespaceExcluded(s.Config.Load(), &namespaceList.Items[i]) {
+			namespaces = append(namespaces, namespaceList.Items[i].GetName())
+		}
+	}
+	writeJSON(w, namespaces)
+}
+
+// listExcludedNamespaces returns the namespaces excluded by the configuration, by reason
+func (s *Server) listExcludedNamespaces(w http.ResponseWriter, r *http.Request) {
+	excluded, err := utils.ExcludedNamespaces(r.Context(), s.Client, s.Config.Load())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, excluded)
+}
+
+// fetchStatus returns the status of a failed read, which is only 404, if the object doesn't exist. Any other
+// error, e.g. missing permissions or an unavailable API server, is reported as 500.
+func fetchStatus(err error) int {
+	if apierrs.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// getNamespace returns whether the imagePullSecret is distributed to the namespace, or why it's excluded
+func (s *Server) getNamespace(w http.ResponseWriter, r *http.Request) {
+	ns, err := utils.FetchNamespace(r.Context(), s.Client, r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), fetchStatus(err))
+		return
+	}
+
+	reason := utils.NamespaceExclusionReason(s.Config.Load(), ns)
+	if reason == "" && ns.Status.Phase == corev1.NamespaceTerminating {
+		reason = "terminating"
+	}
+	writeJSON(w, state.Namespace{Name: ns.GetName(), Managed: reason == "", Reason: reason})
+}
+
+// explainServiceAccount returns, which rules of the configuration match the ServiceAccount, and what reconciling it would do
+func (s *Server) explainServiceAccount(w http.ResponseWriter, r *http.Request) {
+	ns, err := utils.FetchNamespace(r.Context(), s.Client, r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), fetchStatus(err))
+		return
+	}
+	serviceAccount := &corev1.ServiceAccount{}
+	key := types.NamespacedName{Name: r.PathValue("serviceaccount"), Namespace: ns.GetName()}
+	if err := s.Client.Get(r.Context(), key, serviceAccount); err != nil {
+		http.Error(w, err.Error(), fetchStatus(err))
+		return
+	}
+
+	explanation, err := utils.ExplainServiceAccount(r.Context(), s.Client, s.Config.Load(), ns, serviceAccount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, explanation)
+}
+
+// pauseNamespace excludes the namespace from reconciling, by setting the admin-paused annotation. The exclude
+// annotation is left to the users, so resuming never lifts an exclusion they set themselves.
+func (s *Server) pauseNamespace(w http.ResponseWriter, r *http.Request) {
+	s.patchNamespaceAnnotation(w, r, true)
+}
+
+// resumeNamespace removes the admin-paused annotation from the namespace
+func (s *Server) resumeNamespace(w http.ResponseWriter, r *http.Request) {
+	s.patchNamespaceAnnotation(w, r, false)
+}
+
+func (s *Server) patchNamespaceAnnotation(w http.ResponseWriter, r *http.Request, paused bool) {
+	ns, err := utils.FetchNamespace(r.Context(), s.Client, r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), fetchStatus(err))
+		return
+	}
+
+	patchFrom := client.MergeFrom(ns.DeepCopy())
+	annotations := ns.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if paused {
+		annotations[s.Config.Load().AnnotationAdminPaused] = "true"
+	} else {
+		delete(annotations, s.Config.Load().AnnotationAdminPaused)
+	}
+	ns.SetAnnotations(annotations)
+
+	if err := s.Client.Patch(r.Context(), ns, patchFrom); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resync enqueues all managed Secrets, until they're picked up by the controller or resyncTimeout passes
+func (s *Server) resync(w http.ResponseWriter, r *http.Request) {
+	if s.Resyncer == nil {
+		http.Error(w, "resync is not available", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), resyncTimeout)
+	defer cancel()
+	if err := s.Resyncer.Resync(ctx); err != nil {
+		log.FromContext(ctx).Error(err, "error resyncing secrets")
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) audit(w http.ResponseWriter, r *http.Request) {
+	if s.Breaker.IsOpen() {
+		http.Error(w, "audit is suspended, as the API server is overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	audits, err := utils.AuditCluster(r.Context(), s.Client, s.Config.Load())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, audits)
+}
+
+// state returns the profile, the name of the managed imagePullSecrets and a hash of the current credentials
+func (s *Server) state(w http.ResponseWriter, r *http.Request) {
+	c := s.Config.Load()
+	credentialHash, err := utils.CredentialHash(r.Context(), s.Client, c)
+	if err != nil {
+		http.Error(w, redact.Error(err).Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, state.State{
+		Profile:        utils.ProfileName(c),
+		SecretName:     c.SecretName,
+		CredentialHash: credentialHash,
+	})
+}
+
+// events returns the last significant actions of the controller, oldest first
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, eventlog.Default.Entries())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+type fakeResyncer struct {
+	called chan struct{}
+}
+
+func (f *fakeResyncer) Resync(ctx context.Context) error {
+	close(f.called)
+	return nil
+}
+
+func newTestServer() (*Server, *fakeResyncer) {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: `{"auths":{}}`,
+		SecretNamespace:  "kube-system",
+		AdminToken:       "secret-token",
+	})
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}},
+	).Build()
+	resyncer := &fakeResyncer{called: make(chan struct{})}
+	return &Server{Client: k8sClient, Config: config.NewStore(c), Resyncer: resyncer}, resyncer
+}
+
+func doRequest(s *Server, method string, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func Test_Authentication(t *testing.T) {
+	s, _ := newTestServer()
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{
+			name:  "missing token",
+			token: "",
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "wrong token",
+			token: "wrong-token",
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "valid token",
+			token: "secret-token",
+			want:  http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(s, http.MethodGet, "/api/v1/namespaces", tt.token).Code; got != tt.want {
+				t.Errorf("status = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ListNamespaces(t *testing.T) {
+	s, _ := newTestServer()
+	rec := doRequest(s, http.MethodGet, "/api/v1/namespaces", "secret-token")
+
+	namespaces := []string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &namespaces); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(namespaces) != 1 || namespaces[0] != "default" {
+		t.Errorf("namespaces = %v, want [default]", namespaces)
+	}
+}
+
+func Test_ListExcludedNamespaces(t *testing.T) {
+	s, _ := newTestServer()
+	if got := doRequest(s, http.MethodPost, "/api/v1/namespaces/default/pause", "secret-token").Code; got != http.StatusNoContent {
+		t.Fatalf("pause status = %v, want %v", got, http.StatusNoContent)
+	}
+	rec := doRequest(s, http.MethodGet, "/api/v1/namespaces/excluded", "secret-token")
+
+	excluded := map[string][]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &excluded); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got := excluded["glob"]; len(got) != 1 || got[0] != "kube-system" {
+		t.Errorf("glob = %v, want [kube-system]", got)
+	}
+	if got := excluded["paused"]; len(got) != 1 || got[0] != "default" {
+		t.Errorf("paused = %v, want [default]", got)
+	}
+	if got, ok := excluded["selector"]; !ok || len(got) != 0 {
+		t.Errorf("selector = %v, want []", got)
+	}
+}
+
+func Test_PauseNamespace(t *testing.T) {
+	s, _ := newTestServer()
+	ns := &corev1.Namespace{}
+
+	if got := doRequest(s, http.MethodPost, "/api/v1/namespaces/default/pause", "secret-token").Code; got != http.StatusNoContent {
+		t.Fatalf("pause status = %v, want %v", got, http.StatusNoContent)
+	}
+	if err := s.Client.Get(context.TODO(), types.NamespacedName{Name: "default"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if ns.GetAnnotations()[s.Config.Load().AnnotationAdminPaused] != "true" {
+		t.Errorf("namespace was not paused")
+	}
+	if _, ok := ns.GetAnnotations()[s.Config.Load().ExcludeAnnotation]; ok {
+		t.Errorf("exclude annotation was set, want it left to the users")
+	}
+
+	if got := doRequest(s, http.MethodDelete, "/api/v1/namespaces/default/pause", "secret-token").Code; got != http.StatusNoContent {
+		t.Fatalf("resume status = %v, want %v", got, http.StatusNoContent)
+	}
+	if err := s.Client.Get(context.TODO(), types.NamespacedName{Name: "default"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ns.GetAnnotations()[s.Config.Load().AnnotationAdminPaused]; ok {
+		t.Errorf("namespace was not resumed")
+	}
+
+	if got := doRequest(s, http.MethodPost, "/api/v1/namespaces/missing/pause", "secret-token").Code; got != http.StatusNotFound {
+		t.Errorf("missing namespace status = %v, want %v", got, http.StatusNotFound)
+	}
+}
+
+func Test_FetchErrors(t *testing.T) {
+	s, _ := newTestServer()
+	// Objects named restricted can't be read, e.g. as the RBAC of the controller is too narrow
+	s.Client = interceptor.NewClient(s.Client.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if key.Name == "restricted" {
+				return apierrs.NewForbidden(schema.GroupResource{}, key.Name, nil)
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	})
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{
+			name: "Missing namespace. Should be 404.",
+			path: "/api/v1/namespaces/missing",
+			want: http.StatusNotFound,
+		},
+		{
+			name: "Missing ServiceAccount. Should be 404.",
+			path: "/api/v1/namespaces/default/serviceaccounts/missing/explain",
+			want: http.StatusNotFound,
+		},
+		{
+			name: "Namespace, which can't be read. Should be 500.",
+			path: "/api/v1/namespaces/restricted",
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "Explained ServiceAccount in a namespace, which can't be read. Should be 500.",
+			path: "/api/v1/namespaces/restricted/serviceaccounts/default/explain",
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "Explained ServiceAccount, which can't be read. Should be 500.",
+			path: "/api/v1/namespaces/default/serviceaccounts/restricted/explain",
+			want: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(s, http.MethodGet, tt.path, "secret-token").Code; got != tt.want {
+				t.Errorf("status = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Resync(t *testing.T) {
+	s, resyncer := newTestServer()
+	if got := doRequest(s, http.MethodPost, "/api/v1/resync", "secret-token").Code; got != http.StatusAccepted {
+		t.Fatalf("status = %v, want %v", got, http.StatusAccepted)
+	}
+	<-resyncer.called
+}
+
+func Test_Audit(t *testing.T) {
+	s, _ := newTestServer()
+	rec := doRequest(s, http.MethodGet, "/api/v1/audit", "secret-token")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	audits := []struct {
+		Namespace                    string   `json:"namespace"`
+		Excluded                     bool     `json:"excluded"`
+		ServiceAccountsMissingSecret []string `json:"serviceAccountsMissingSecret"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, audit := range audits {
+		switch audit.Namespace {
+		case "default":
+			if len(audit.ServiceAccountsMissingSecret) != 1 {
+				t.Errorf("default: serviceAccountsMissingSecret = %v, want [default]", audit.ServiceAccountsMissingSecret)
+			}
+		case "kube-system":
+			if !audit.Excluded {
+				t.Errorf("kube-system: excluded = false, want true")
+			}
+		}
+	}
+}
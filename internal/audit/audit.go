@@ -0,0 +1,239 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+const (
+	// bufferSize is the number of records buffered for the sink. Once it's full, recording mutations blocks,
+	// until the sink caught up.
+	bufferSize = 1000
+	// postAttempts is the number of attempts to post a record to an HTTP endpoint
+	postAttempts = 5
+	// postRetryInterval is the initial backoff between attempts, which is doubled after every attempt
+	postRetryInterval = time.Second
+	// drainTimeout bounds the time to write the buffered records on shutdown
+	drainTimeout = 30 * time.Second
+)
+
+// kinds maps the mutating actions of the event log to the kind of the mutated object
+var kinds = map[string]string{
+	eventlog.ActionSecretCreated:         "Secret",
+	eventlog.ActionSecretUpdated:         "Secret",
+	eventlog.ActionSecretDeleted:         "Secret",
+	eventlog.ActionServiceAccountPatched: "ServiceAccount",
+	eventlog.ActionPodDeleted:            "Pod",
+}
+
+// Record is a single mutation, written as one JSON line
+type Record struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	Profile   string    `json:"profile"`
+}
+
+// Sink appends every mutation recorded in the event log as JSON line to a file, or posts it to an HTTP endpoint.
+// A nil Sink discards all entries.
+type Sink struct {
+	// Target is the path of the file, or the URL of the HTTP endpoint
+	Target  string
+	Profile string
+
+	client  *http.Client
+	records chan Record
+	// stopped is closed, once the sink stopped writing
+	stopped chan struct{}
+}
+
+// NewSink returns a Sink writing to AuditSink, or nil if it's not configured
+func NewSink(c *config.Config) *Sink {
+	if c.AuditSink == "" {
+		return nil
+	}
+	return &Sink{
+		Target:  c.AuditSink,
+		Profile: utils.ProfileName(c),
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: outbound.Transport},
+		records: make(chan Record, bufferSize),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Observe queues entry, if it's a mutation. It matches eventlog.Log.SetSink. If the buffer is full,
+// it blocks until the sink caught up, so mutations are slowed down instead of missing in the audit trail.
+// Entries recorded after the sink stopped are counted as failures.
+func (s *Sink) Observe(entry eventlog.Entry) {
+	if s == nil {
+		return
+	}
+	kind, ok := kinds[entry.Action]
+	if entry.Action == eventlog.ActionWorkloadPatched {
+		// The kind of workloads is recorded as message
+		kind, ok = entry.Message, true
+	}
+	if !ok {
+		return
+	}
+
+	record := Record{
+		Time:      entry.Time.UTC(),
+		Action:    entry.Action,
+		Kind:      kind,
+		Namespace: entry.Namespace,
+		Name:      entry.Name,
+		Hash:      entry.Hash,
+		Profile:   s.Profile,
+	}
+	select {
+	case <-s.stopped:
+		metrics.AuditSinkFailuresTotal.Inc()
+		return
+	default:
+	}
+	select {
+	case s.records <- record:
+	case <-s.stopped:
+		metrics.AuditSinkFailuresTotal.Inc()
+	}
+}
+
+// NeedLeaderElection ensures mutations are written by every replica, which performs them
+func (s *Sink) NeedLeaderElection() bool {
+	return false
+}
+
+// Start writes the queued records until ctx is cancelled. The records buffered by then are written
+// within drainTimeout, before it returns.
+func (s *Sink) Start(ctx context.Context) error {
+	defer close(s.stopped)
+
+	write := s.post
+	if !s.isHTTP() {
+		file, err := os.OpenFile(s.Target, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit sink: %w", err)
+		}
+		defer file.Close()
+		write = func(_ context.Context, line []byte) error {
+			return appendLine(file, line)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+			defer cancel()
+			s.drain(drainCtx, write)
+			return nil
+		case record := <-s.records:
+			s.write(ctx, write, record)
+		}
+	}
+}
+
+// drain writes the buffered records, until the buffer is empty or ctx is done
+func (s *Sink) drain(ctx context.Context, write func(context.Context, []byte) error) {
+	for {
+		select {
+		case record := <-s.records:
+			s.write(ctx, write, record)
+		default:
+			return
+		}
+	}
+}
+
+// write marshals record and writes it, counting failures
+func (s *Sink) write(ctx context.Context, write func(context.Context, []byte) error, record Record) {
+	line, err := json.Marshal(record)
+	if err == nil {
+		err = write(ctx, line)
+	}
+	if err != nil {
+		metrics.AuditSinkFailuresTotal.Inc()
+		log.FromContext(ctx).Error(err, "Failed to write audit record", "action", record.Action, "namespace", record.Namespace, "name", record.Name)
+	}
+}
+
+func (s *Sink) isHTTP() bool {
+	return strings.HasPrefix(s.Target, "http://") || strings.HasPrefix(s.Target, "https://")
+}
+
+// post sends line to the HTTP endpoint. Failed attempts, except for rejected records, are retried with backoff.
+func (s *Sink) post(ctx context.Context, line []byte) error {
+	retryAfter := postRetryInterval
+	for attempt := 1; ; attempt++ {
+		retriable, err := s.postOnce(ctx, line)
+		if err == nil || !retriable || attempt == postAttempts {
+			return err
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return err
+		}
+		retryAfter *= 2
+	}
+}
+
+// postOnce sends line to the HTTP endpoint once, and reports whether a failure may be retried
+func (s *Sink) postOnce(ctx context.Context, line []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Target, bytes.NewReader(append(line, '\n')))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retriable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retriable, fmt.Errorf("audit sink answered with status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// appendLine writes line followed by a newline with a single write, so lines are never interleaved
+func appendLine(w io.Writer, line []byte) error {
+	_, err := w.Write(append(line, '\n'))
+	return err
+}
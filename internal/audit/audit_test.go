@@ -0,0 +1,237 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+)
+
+func newTestSink(target string) *Sink {
+	return NewSink(config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: "xx",
+		SecretNamespace:  "kube-system",
+		AuditSink:        target,
+	}))
+}
+
+func Test_NewSink_Disabled(t *testing.T) {
+	sink := newTestSink("")
+	if sink != nil {
+		t.Fatalf("NewSink() = %v, want nil", sink)
+	}
+	// A nil Sink must discard entries
+	sink.Observe(eventlog.Entry{Action: eventlog.ActionSecretCreated})
+}
+
+func Test_Sink_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sink := newTestSink(path)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sink.Start(ctx) }()
+
+	log := eventlog.New(10)
+	log.SetSink(sink.Observe)
+	log.RecordHash(eventlog.ActionSecretCreated, "default", "image-pull-secret", "", "abc")
+	log.Record(eventlog.ActionError, "default", "", "failed")
+	log.Record(eventlog.ActionWorkloadPatched, "default", "app", "Deployment")
+	log.Record(eventlog.ActionServiceAccountPatched, "default", "default", "")
+
+	want := []Record{
+		{},
+		{Action: eventlog.ActionSecretCreated, Kind: "Secret", Namespace: "default", Name: "image-pull-secret", Hash: "abc"},
+		{Action: eventlog.ActionWorkloadPatched, Kind: "Deployment", Namespace: "default", Name: "app"},
+		{Action: eventlog.ActionServiceAccountPatched, Kind: "ServiceAccount", Namespace: "default", Name: "default"},
+	}
+	var got []Record
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		got = readRecords(t, path)
+		if len(got) == len(want) {
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if i > 0 && got[i].Profile == "" {
+			t.Errorf("record %d has no profile", i)
+		}
+		got[i].Time, got[i].Profile = time.Time{}, ""
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func Test_Sink_HTTP(t *testing.T) {
+	records := make(chan Record, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := Record{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Errorf("failed to decode record: %v", err)
+		}
+		records <- record
+	}))
+	t.Cleanup(server.Close)
+
+	sink := newTestSink(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = sink.Start(ctx) }()
+
+	sink.Observe(eventlog.Entry{Action: eventlog.ActionSecretDeleted, Namespace: "default", Name: "image-pull-secret"})
+
+	select {
+	case record := <-records:
+		if record.Kind != "Secret" || record.Action != eventlog.ActionSecretDeleted || record.Name != "image-pull-secret" {
+			t.Errorf("unexpected record %+v", record)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected audit record")
+	}
+}
+
+func Test_Sink_HTTP_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	records := make(chan Record, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		record := Record{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			t.Errorf("failed to decode record: %v", err)
+		}
+		records <- record
+	}))
+	t.Cleanup(server.Close)
+
+	sink := newTestSink(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = sink.Start(ctx) }()
+
+	sink.Observe(eventlog.Entry{Action: eventlog.ActionSecretDeleted, Namespace: "default", Name: "image-pull-secret"})
+
+	select {
+	case record := <-records:
+		if record.Name != "image-pull-secret" {
+			t.Errorf("unexpected record %+v", record)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected audit record to be retried")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("got %d attempts, want 2", got)
+	}
+}
+
+func Test_Sink_Backpressure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink := newTestSink(path)
+	sink.records = make(chan Record, 1)
+	entry := eventlog.Entry{Action: eventlog.ActionSecretCreated, Namespace: "default", Name: "image-pull-secret"}
+
+	sink.Observe(entry)
+	observed := make(chan struct{})
+	go func() {
+		sink.Observe(entry)
+		close(observed)
+	}()
+	select {
+	case <-observed:
+		t.Fatal("Observe() should block, while the buffer is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sink.Start(ctx) }()
+	select {
+	case <-observed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Observe() should return, once the sink caught up")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := readRecords(t, path); len(got) != 2 {
+		t.Errorf("got %d records, want 2", len(got))
+	}
+}
+
+func Test_Sink_DrainOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink := newTestSink(path)
+	for i := 0; i < 3; i++ {
+		sink.Observe(eventlog.Entry{Action: eventlog.ActionSecretCreated, Namespace: "default", Name: "image-pull-secret"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sink.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := readRecords(t, path); len(got) != 3 {
+		t.Errorf("got %d records, want the 3 buffered ones", len(got))
+	}
+
+	// Entries recorded after the sink stopped must not block
+	sink.Observe(eventlog.Entry{Action: eventlog.ActionSecretCreated, Namespace: "default", Name: "image-pull-secret"})
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode record: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// Breaker opens, once the API server answered Threshold requests with 429 or 5xx
+// within Cooldown, and closes again after Cooldown passed without such an answer.
+// While it's open, non-critical work like audits and sweeps is skipped, and only
+// priority reconciles are serviced.
+// A nil Breaker, or one with a Threshold below 1, never opens.
+// Once the Breaker is in use, e.g. wraps a transport, change Threshold and Cooldown with Configure only.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures []time.Time
+	open     bool
+	now      func() time.Time
+}
+
+// Configure sets Threshold and Cooldown of a Breaker, which may already be in use
+func (b *Breaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Threshold = threshold
+	b.Cooldown = cooldown
+	b.prune()
+}
+
+// IsOpen returns whether non-critical work should be skipped
+func (b *Breaker) IsOpen() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune()
+	return b.open
+}
+
+// RecordStatus tracks the status code of a response of the API server
+func (b *Breaker) RecordStatus(statusCode int) {
+	if b == nil {
+		return
+	}
+	if statusCode != http.StatusTooManyRequests && statusCode < http.StatusInternalServerError {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Threshold < 1 {
+		return
+	}
+	b.failures = append(b.failures, b.clock())
+	b.prune()
+	if len(b.failures) >= b.Threshold {
+		b.setOpen(true)
+	}
+}
+
+// WrapTransport returns a RoundTripper, which records the status codes of all responses.
+// It matches the signature of rest.Config.Wrap.
+func (b *Breaker) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if resp != nil {
+			b.RecordStatus(resp.StatusCode)
+		}
+		return resp, err
+	})
+}
+
+// Reconciler wraps r, so its reconciles are deferred by Cooldown, while the breaker is open.
+// Only controllers doing non-critical work are wrapped, so priority reconciles continue,
+// e.g. of new ServiceAccounts or Secrets missing the credentials.
+func (b *Breaker) Reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	if b == nil {
+		return r
+	}
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if b.IsOpen() {
+			b.mu.Lock()
+			cooldown := b.Cooldown
+			b.mu.Unlock()
+			metrics.CircuitBreakerDeferredTotal.Inc()
+			return reconcile.Result{RequeueAfter: cooldown}, nil
+		}
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// prune drops failures older than Cooldown, and closes the breaker once none are left. The caller must hold mu.
+func (b *Breaker) prune() {
+	cutoff := b.clock().Add(-b.Cooldown)
+	i := 0
+	for i < len(b.failures) && b.failures[i].Before(cutoff) {
+		i++
+	}
+	b.failures = b.failures[i:]
+	if len(b.failures) == 0 {
+		b.setOpen(false)
+	}
+}
+
+func (b *Breaker) setOpen(open bool) {
+	b.open = open
+	if open {
+		metrics.CircuitBreakerOpen.Set(1)
+	} else {
+		metrics.CircuitBreakerOpen.Set(0)
+	}
+}
+
+func (b *Breaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
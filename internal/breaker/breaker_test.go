@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package breaker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_Breaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		statuses  []int
+		elapsed   time.Duration
+		want      bool
+	}{
+		{
+			name:      "Successful responses. Should stay closed.",
+			threshold: 2,
+			statuses:  []int{http.StatusOK, http.StatusNotFound, http.StatusConflict},
+			want:      false,
+		},
+		{
+			name:      "Errors below threshold. Should stay closed.",
+			threshold: 3,
+			statuses:  []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+			want:      false,
+		},
+		{
+			name:      "Errors reach threshold. Should open.",
+			threshold: 2,
+			statuses:  []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+			want:      true,
+		},
+		{
+			name:      "Errors reach threshold, cooldown passed. Should close again.",
+			threshold: 2,
+			statuses:  []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+			elapsed:   2 * time.Minute,
+			want:      false,
+		},
+		{
+			name:      "Threshold disabled. Should never open.",
+			threshold: -1,
+			statuses:  []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			b := &Breaker{
+				Threshold: tt.threshold,
+				Cooldown:  time.Minute,
+				now:       func() time.Time { return now },
+			}
+			for _, status := range tt.statuses {
+				b.RecordStatus(status)
+			}
+			now = now.Add(tt.elapsed)
+			if got := b.IsOpen(); got != tt.want {
+				t.Errorf("IsOpen() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Nil breaker. Should never open.", func(t *testing.T) {
+		var b *Breaker
+		b.RecordStatus(http.StatusTooManyRequests)
+		if b.IsOpen() {
+			t.Errorf("IsOpen() = true, want false")
+		}
+	})
+}
+
+func Test_Breaker_Reconciler(t *testing.T) {
+	b := &Breaker{}
+	b.Configure(1, time.Minute)
+	called := 0
+	r := b.Reconciler(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		called++
+		return reconcile.Result{}, nil
+	}))
+
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{}); err != nil || called != 1 {
+		t.Fatalf("closed breaker: called = %d, err = %v, want 1 call", called, err)
+	}
+
+	b.RecordStatus(http.StatusTooManyRequests)
+	result, err := r.Reconcile(context.TODO(), reconcile.Request{})
+	if err != nil || called != 1 {
+		t.Fatalf("open breaker: called = %d, err = %v, want no further call", called, err)
+	}
+	if result.RequeueAfter != time.Minute {
+		t.Errorf("open breaker: RequeueAfter = %v, want %v", result.RequeueAfter, time.Minute)
+	}
+
+	var nilBreaker *Breaker
+	if _, err := nilBreaker.Reconciler(r).Reconcile(context.TODO(), reconcile.Request{}); err != nil {
+		t.Errorf("nil breaker: err = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+var (
+	// serverSideApplyGA is the first version, in which server-side apply is GA
+	serverSideApplyGA = version.MustParseGeneric("1.22")
+	// immutableSecretsGA is the first version, in which Secrets can be marked immutable by default
+	immutableSecretsGA = version.MustParseGeneric("1.21")
+)
+
+// Capabilities are the version-dependent APIs of the cluster, which the controller relies on
+type Capabilities struct {
+	// Version is the git version of the API server
+	Version string
+	// ServerSideApply reports, if patches can be applied server-side
+	ServerSideApply bool
+	// ImmutableSecrets reports, if Secrets can be marked immutable
+	ImmutableSecrets bool
+	// EvictionGroupVersion is the group version of the eviction subresource of Pods, or empty, if it isn't served
+	EvictionGroupVersion string
+}
+
+// Latest are the capabilities of every supported cluster version, e.g. of the fake clients of tests
+var Latest = Capabilities{
+	ServerSideApply:      true,
+	ImmutableSecrets:     true,
+	EvictionGroupVersion: "policy/v1",
+}
+
+// Default are the capabilities of the cluster the controllers run against. It assumes Latest, until replaced.
+var Default = Latest
+
+// gate turns off a feature, which the cluster doesn't support
+type gate struct {
+	feature string
+	// supported reports, if the cluster supports the feature
+	supported func(caps Capabilities) bool
+	// enabled reports, if the feature is enabled
+	enabled func(c *config.Config) bool
+	// disable turns off the feature
+	disable func(c *config.Config)
+}
+
+var gates = []gate{
+	{
+		feature:   "serviceaccount-patch-strategy-apply",
+		supported: func(caps Capabilities) bool { return caps.ServerSideApply },
+		enabled:   func(c *config.Config) bool { return c.ServiceAccountPatchStrategy == config.PatchStrategyApply },
+		disable:   func(c *config.Config) { c.ServiceAccountPatchStrategy = config.PatchStrategyMerge },
+	},
+	{
+		feature:   "immutable-secrets",
+		supported: func(caps Capabilities) bool { return caps.ImmutableSecrets },
+		enabled:   func(c *config.Config) bool { return c.FeatureImmutableSecrets },
+		disable:   func(c *config.Config) { c.FeatureImmutableSecrets = false },
+	},
+	{
+		feature:   "evict-pods",
+		supported: func(caps Capabilities) bool { return caps.EvictionGroupVersion != "" },
+		enabled:   func(c *config.Config) bool { return c.FeatureEvictPods },
+		disable:   func(c *config.Config) { c.FeatureEvictPods = false },
+	},
+}
+
+// Detect discovers the capabilities of the cluster from its version and the served resources
+func Detect(d discovery.DiscoveryInterface) (Capabilities, error) {
+	info, err := d.ServerVersion()
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to fetch server version: %w", err)
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to parse server version: %w", err)
+	}
+	caps := Capabilities{
+		Version:          info.GitVersion,
+		ServerSideApply:  serverVersion.AtLeast(serverSideApplyGA),
+		ImmutableSecrets: serverVersion.AtLeast(immutableSecretsGA),
+	}
+
+	// The group version of the eviction subresource moved from policy/v1beta1 to policy/v1
+	resources, err := d.ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to discover core resources: %w", err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "pods/eviction" {
+			caps.EvictionGroupVersion = schema.GroupVersion{Group: resource.Group, Version: resource.Version}.String()
+		}
+	}
+
+	metrics.SetServerCapability("server-side-apply", caps.ServerSideApply)
+	metrics.SetServerCapability("immutable-secrets", caps.ImmutableSecrets)
+	metrics.SetServerCapability("eviction", caps.EvictionGroupVersion != "")
+	return caps, nil
+}
+
+// Adjust turns off the features of c, which the cluster doesn't support, and returns them. It must be
+// called before c is shared with the controllers, as it modifies c.
+func (caps Capabilities) Adjust(ctx context.Context, c *config.Config) []string {
+	var disabled []string
+	for _, gate := range gates {
+		if !gate.enabled(c) || gate.supported(caps) {
+			continue
+		}
+		gate.disable(c)
+		disabled = append(disabled, gate.feature)
+		log.FromContext(ctx).Info("Feature '"+gate.feature+"' disabled, as the cluster doesn't support it", "version", caps.Version)
+		eventlog.Record(eventlog.ActionError, "", "", "feature '"+gate.feature+"' disabled, as the cluster "+caps.Version+" doesn't support it")
+	}
+	return disabled
+}
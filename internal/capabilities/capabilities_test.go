@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func newDiscovery(gitVersion string, eviction *metav1.APIResource) *fakediscovery.FakeDiscovery {
+	resources := &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true, Kind: "Pod"}},
+	}
+	if eviction != nil {
+		resources.APIResources = append(resources.APIResources, *eviction)
+	}
+	return &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{Resources: []*metav1.APIResourceList{resources}},
+		FakedServerVersion: &version.Info{GitVersion: gitVersion},
+	}
+}
+
+func Test_Detect(t *testing.T) {
+	tests := []struct {
+		name       string
+		gitVersion string
+		eviction   *metav1.APIResource
+		want       Capabilities
+	}{
+		{
+			name:       "current cluster",
+			gitVersion: "v1.31.2",
+			eviction:   &metav1.APIResource{Name: "pods/eviction", Group: "policy", Version: "v1", Kind: "Eviction"},
+			want: Capabilities{
+				Version:              "v1.31.2",
+				ServerSideApply:      true,
+				ImmutableSecrets:     true,
+				EvictionGroupVersion: "policy/v1",
+			},
+		},
+		{
+			name:       "managed cluster with vendor suffix",
+			gitVersion: "v1.24.17-eks-5e0fdde",
+			eviction:   &metav1.APIResource{Name: "pods/eviction", Group: "policy", Version: "v1", Kind: "Eviction"},
+			want: Capabilities{
+				Version:              "v1.24.17-eks-5e0fdde",
+				ServerSideApply:      true,
+				ImmutableSecrets:     true,
+				EvictionGroupVersion: "policy/v1",
+			},
+		},
+		{
+			name:       "older cluster",
+			gitVersion: "v1.21.14",
+			eviction:   &metav1.APIResource{Name: "pods/eviction", Group: "policy", Version: "v1beta1", Kind: "Eviction"},
+			want: Capabilities{
+				Version:              "v1.21.14",
+				ImmutableSecrets:     true,
+				EvictionGroupVersion: "policy/v1beta1",
+			},
+		},
+		{
+			name:       "eviction not served",
+			gitVersion: "v1.20.15",
+			want:       Capabilities{Version: "v1.20.15"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect(newDiscovery(tt.gitVersion, tt.eviction))
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Detect(newDiscovery("unknown", nil)); err == nil {
+		t.Error("Detect() succeeded for an unparsable version, want an error")
+	}
+}
+
+func Test_Adjust(t *testing.T) {
+	newConfig := func() *config.Config {
+		return config.NewConfig(config.ConfigOptions{
+			DockerConfigJSON:            "{}",
+			SecretNamespace:             "kube-system",
+			ServiceAccountPatchStrategy: config.PatchStrategyApply,
+			FeatureImmutableSecrets:     true,
+			FeatureDeletePods:           true,
+			FeatureEvictPods:            true,
+		})
+	}
+
+	c := newConfig()
+	if disabled := Latest.Adjust(context.TODO(), c); len(disabled) != 0 {
+		t.Errorf("Adjust() disabled %v on a current cluster, want nothing", disabled)
+	}
+
+	c = newConfig()
+	disabled := Capabilities{Version: "v1.20.15"}.Adjust(context.TODO(), c)
+	want := []string{"serviceaccount-patch-strategy-apply", "immutable-secrets", "evict-pods"}
+	if !reflect.DeepEqual(disabled, want) {
+		t.Errorf("Adjust() disabled %v, want %v", disabled, want)
+	}
+	if c.ServiceAccountPatchStrategy != config.PatchStrategyMerge || c.FeatureImmutableSecrets || c.FeatureEvictPods {
+		t.Errorf("Adjust() left unsupported features enabled: %+v", c)
+	}
+	if !c.FeatureDeletePods {
+		t.Error("Adjust() disabled the deletion of Pods, want a fallback from their eviction")
+	}
+}
@@ -18,101 +18,977 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caitlinelfring/go-env-default"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
 	"github.com/tamcore/imagepullsecret-patcher/internal/namespace"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
 	AnnotationManagedBy = "app.kubernetes.io/managed-by"
 	AnnotationAppName   = "imagepullsecret-patcher"
+	// DefaultProfile is the profile of Secrets, if CONFIG_PROFILE is not set
+	DefaultProfile = "default"
+	// CredentialSourceEnv, CredentialSourceFile, CredentialSourceSecret and CredentialSourceOIDC
+	// name the credential sources, which can be chained with CONFIG_CREDENTIAL_SOURCES
+	CredentialSourceEnv    = "env"
+	CredentialSourceFile   = "file"
+	CredentialSourceSecret = "secret"
+	CredentialSourceOIDC   = "oidc"
+	CredentialSourceURL    = "url"
+	// PatchStrategyMerge, PatchStrategyStrategic, PatchStrategyUpdate and PatchStrategyApply
+	// name the ways ServiceAccounts can be written, configured with CONFIG_SERVICEACCOUNT_PATCH_STRATEGY
+	PatchStrategyMerge     = "merge"
+	PatchStrategyStrategic = "strategic"
+	PatchStrategyUpdate    = "update"
+	PatchStrategyApply     = "apply"
+	// GitOpsConflictPolicySkip, GitOpsConflictPolicyAdopt and GitOpsConflictPolicyAlert name the ways
+	// imagePullSecrets claimed by ArgoCD or Flux are handled, configured with CONFIG_GITOPS_CONFLICT_POLICY.
+	// They apply to imagePullSecrets owned by the External Secrets Operator or Sealed Secrets as well.
+	GitOpsConflictPolicySkip  = "skip"
+	GitOpsConflictPolicyAdopt = "adopt"
+	GitOpsConflictPolicyAlert = "alert"
+	// MaxConcurrentReconcilesAuto derives CONFIG_MAX_CONCURRENT_RECONCILES from GOMAXPROCS, i.e. the CPU quota
+	MaxConcurrentReconcilesAuto = "auto"
+	// OperatorNamespacePolicyAuto, OperatorNamespacePolicyInclude and OperatorNamespacePolicyExclude name the ways
+	// the namespace of the controller is handled, configured with CONFIG_OPERATOR_NAMESPACE_POLICY
+	OperatorNamespacePolicyAuto    = "auto"
+	OperatorNamespacePolicyInclude = "include"
+	OperatorNamespacePolicyExclude = "exclude"
+	// DefaultAnnotationDomain prefixes the annotations and labels of the controller, if CONFIG_ANNOTATION_DOMAIN is not set
+	DefaultAnnotationDomain = "pborn.eu"
+	// annotationExclude excludes a namespace, if CONFIG_EXCLUDE_ANNOTATION is not set
+	annotationExclude = "imagepullsecret-patcher-exclude"
+	// annotationNoPodDelete keeps the Pods of a namespace, if CONFIG_NO_POD_DELETE_ANNOTATION is not set
+	annotationNoPodDelete = "imagepullsecret-patcher-no-pod-delete"
+	// annotationRecreate deletes and recreates the imagePullSecret
+	annotationRecreate = "imagepullsecret-recreate"
+	// annotationPaused halts all mutations, if set on the namespace of the controller
+	annotationPaused = "imagepullsecret-patcher-paused"
+	// annotationAdminPaused excludes a namespace, if set by the admin API
+	annotationAdminPaused = "imagepullsecret-patcher-admin-paused"
+	// annotationChangelog records the latest changes on patched ServiceAccounts
+	annotationChangelog = "imagepullsecret-patcher-changelog"
+	// annotationAttached names the imagePullSecret the controller added to a ServiceAccount
+	annotationAttached = "imagepullsecret-attached"
+	// annotationLastSync holds the time of the last successful sync of a namespace
+	annotationLastSync = "imagepullsecret-last-sync"
+	// annotationLastError holds the error of the last failed sync of a namespace
+	annotationLastError = "imagepullsecret-last-error"
+	// annotationSource marks a Secret as source of truth, which is never managed
+	annotationSource = "imagepullsecret-patcher-source"
+	// annotationCredentialHash holds the sha256 of the .dockerconfigjson last written or published
+	annotationCredentialHash = "imagepullsecret-patcher-credential-hash"
+	// annotationPodCleanupDeferred marks a namespace, whose Pod cleanup waits for the next maintenance window
+	annotationPodCleanupDeferred = "imagepullsecret-patcher-pod-cleanup-deferred"
+	// annotationRegistry holds the registry of kubernetes.io/basic-auth source Secrets
+	annotationRegistry = "imagepullsecret-patcher-registry"
+	// labelProfile identifies the profile, i.e. the deployment of the controller, a managed Secret belongs to
+	labelProfile = "imagepullsecret-patcher-profile"
 )
 
 type Config struct {
 	DockerConfigJSON                 string
 	DockerConfigJSONPath             string
+	SourceSecret                     string
+	ExtraSecrets                     string
+	OIDCTokenEndpoint                string
+	OIDCTokenPath                    string
+	OIDCRegistry                     string
+	OIDCUsername                     string
+	DockerConfigJSONURL              string
+	DockerConfigJSONURLCAFile        string
+	DockerConfigJSONURLCertFile      string
+	DockerConfigJSONURLKeyFile       string
+	CABundleFile                     string
+	DockerConfigJSONURLAuthorization string
+	DockerConfigJSONURLInterval      time.Duration
+	WatchdogTimeout                  time.Duration
+	CredentialSources                string
+	AgeKey                           string
+	AgeKeyFile                       string
+	SignaturePublicKeyFile           string
+	DockerConfigJSONSignature        string
 	SecretName                       string
 	SecretNamespace                  string
+	OperatorNamespace                string
+	Profile                          string
 	ExcludedNamespaces               string
+	NamespaceLabel                   string
+	ExcludedNamespaceLabelValues     string
+	IncludedNamespaceLabelValues     string
+	AnnotationDomain                 string
 	ExcludeAnnotation                string
+	NoPodDeleteAnnotation            string
 	ServiceAccounts                  string
+	NamespaceMinAge                  time.Duration
+	RequireDefaultServiceAccount     bool
+	ServiceAccountPatchStrategy      string
+	GitOpsConflictPolicy             string
+	ExternalSecretsConflictPolicy    string
+	SealedSecretsConflictPolicy      string
+	OperatorNamespacePolicy          string
+	RequeueAfter                     time.Duration
+	Paused                           bool
+	WorkloadKinds                    string
+	WorkloadSelector                 string
 	AnnotationManagedBy              string
 	AnnotationAppName                string
+	AnnotationRecreate               string
+	AnnotationPaused                 string
+	AnnotationAdminPaused            string
+	AnnotationChangelog              string
+	AnnotationAttached               string
+	AnnotationLastSync               string
+	AnnotationLastError              string
+	AnnotationSource                 string
+	AnnotationCredentialHash         string
+	AnnotationPodCleanupDeferred     string
+	AnnotationRegistry               string
+	LabelProfile                     string
+	PodCleanupOwnerKinds             string
+	PodCleanupDelay                  time.Duration
+	PodCleanupParallelism            int
+	PodCleanupTimeout                time.Duration
+	PodCleanupCriticalPriority       int
+	PodCleanupCriticalSelector       string
+	PodCleanupCriticalInterval       time.Duration
+	PodCleanupReasons                string
+	PodCleanupRegistries             string
+	PodCleanupMinAge                 time.Duration
+	PodCleanupWindows                string
+	PodCleanupWindowTimezone         string
+	MaxConcurrentReconciles          string
+	ConcurrentReconciles             int
+	DaemonSetPodCleanupBackoff       time.Duration
+	AdminBindAddress                 string
+	AdminToken                       string
+	NotifyWebhookURL                 string
+	AuditSink                        string
+	SuggestionOutput                 string
+	NotifyFailureThreshold           int
+	EventLogSize                     int
+	CacheSyncPeriod                  time.Duration
+	CacheSlimPods                    bool
+	FeatureScopeSecretCache          bool
+	CircuitBreakerThreshold          int
+	CircuitBreakerCooldown           time.Duration
+	NamespaceRateLimit               int
+	NamespaceRateBurst               int
+	ConflictThreshold                int
+	OrphanedSecretsInterval          time.Duration
+	StandbyVerifyInterval            time.Duration
+	SelfTestNamespace                string
+	SweepChunkSize                   int
 	FeatureDeletePods                bool
+	FeatureDeletePodsAnyOwner        bool
+	FeatureEvictPods                 bool
 	FeatureWatchDockerConfigJSONPath bool
+	FeatureSecretInAllNamespaces     bool
+	FeaturePatchWorkloads            bool
+	FeatureTemplateDockerConfigJSON  bool
+	FeatureDeleteOrphanedSecrets     bool
+	FeatureCheckSecretQuota          bool
+	FeatureImmutableSecrets          bool
+	FeatureSelfTestCreateNamespace   bool
+	FeatureSweepCheckpoint           bool
+	FeatureSecretOnly                bool
+	FeatureServiceAccountOnly        bool
+	DisableSecretController          bool
+	DisableServiceAccountController  bool
+	FeatureImagePullStatistics       bool
+	FeatureDetachServiceAccounts     bool
+	FeatureDeleteUnusedSecrets       bool
+	FeatureNamespaceStatus           bool
+	FeatureNamespaceSyncAnnotations  bool
+	FeatureCreateSecretNamespace     bool
+	FeatureVerifyRotatedCredentials  bool
+	FeatureLastKnownGood             bool
 }
 
 type ConfigOptions struct {
 	DockerConfigJSON                 string
 	DockerConfigJSONPath             string
+	SourceSecret                     string
+	ExtraSecrets                     string
+	OIDCTokenEndpoint                string
+	OIDCTokenPath                    string
+	OIDCRegistry                     string
+	OIDCUsername                     string
+	DockerConfigJSONURL              string
+	DockerConfigJSONURLCAFile        string
+	DockerConfigJSONURLCertFile      string
+	DockerConfigJSONURLKeyFile       string
+	CABundleFile                     string
+	DockerConfigJSONURLAuthorization string
+	DockerConfigJSONURLInterval      time.Duration
+	WatchdogTimeout                  time.Duration
+	CredentialSources                string
+	AgeKey                           string
+	AgeKeyFile                       string
+	SignaturePublicKeyFile           string
+	DockerConfigJSONSignature        string
 	SecretName                       string
 	SecretNamespace                  string
+	OperatorNamespace                string
+	Profile                          string
 	ExcludedNamespaces               string
+	NamespaceLabel                   string
+	ExcludedNamespaceLabelValues     string
+	IncludedNamespaceLabelValues     string
+	AnnotationDomain                 string
 	ExcludeAnnotation                string
+	NoPodDeleteAnnotation            string
 	ServiceAccounts                  string
+	NamespaceMinAge                  time.Duration
+	RequireDefaultServiceAccount     bool
+	ServiceAccountPatchStrategy      string
+	GitOpsConflictPolicy             string
+	ExternalSecretsConflictPolicy    string
+	SealedSecretsConflictPolicy      string
+	OperatorNamespacePolicy          string
+	RequeueAfter                     time.Duration
+	Paused                           bool
+	WorkloadKinds                    string
+	WorkloadSelector                 string
+	PodCleanupOwnerKinds             string
+	PodCleanupDelay                  time.Duration
+	PodCleanupParallelism            int
+	PodCleanupTimeout                time.Duration
+	PodCleanupCriticalPriority       int
+	PodCleanupCriticalSelector       string
+	PodCleanupCriticalInterval       time.Duration
+	PodCleanupReasons                string
+	PodCleanupRegistries             string
+	PodCleanupMinAge                 time.Duration
+	PodCleanupWindows                string
+	PodCleanupWindowTimezone         string
+	MaxConcurrentReconciles          string
+	DaemonSetPodCleanupBackoff       time.Duration
+	AdminBindAddress                 string
+	AdminToken                       string
+	NotifyWebhookURL                 string
+	AuditSink                        string
+	SuggestionOutput                 string
+	NotifyFailureThreshold           int
+	EventLogSize                     int
+	CacheSyncPeriod                  time.Duration
+	CacheSlimPods                    bool
+	FeatureScopeSecretCache          bool
+	CircuitBreakerThreshold          int
+	CircuitBreakerCooldown           time.Duration
+	NamespaceRateLimit               int
+	NamespaceRateBurst               int
+	ConflictThreshold                int
+	OrphanedSecretsInterval          time.Duration
+	StandbyVerifyInterval            time.Duration
+	SelfTestNamespace                string
+	SweepChunkSize                   int
 	FeatureDeletePods                bool
+	FeatureDeletePodsAnyOwner        bool
+	FeatureEvictPods                 bool
 	FeatureWatchDockerConfigJSONPath bool
+	FeatureSecretInAllNamespaces     bool
+	FeaturePatchWorkloads            bool
+	FeatureTemplateDockerConfigJSON  bool
+	FeatureDeleteOrphanedSecrets     bool
+	FeatureCheckSecretQuota          bool
+	FeatureImmutableSecrets          bool
+	FeatureSelfTestCreateNamespace   bool
+	FeatureSweepCheckpoint           bool
+	FeatureSecretOnly                bool
+	FeatureServiceAccountOnly        bool
+	DisableSecretController          bool
+	DisableServiceAccountController  bool
+	FeatureImagePullStatistics       bool
+	FeatureDetachServiceAccounts     bool
+	FeatureDeleteUnusedSecrets       bool
+	FeatureNamespaceStatus           bool
+	FeatureNamespaceSyncAnnotations  bool
+	FeatureCreateSecretNamespace     bool
+	FeatureVerifyRotatedCredentials  bool
+	FeatureLastKnownGood             bool
 }
 
 func NewConfig(options ...ConfigOptions) *Config {
 	c := &Config{
 		DockerConfigJSON:                 env.GetDefault("CONFIG_DOCKERCONFIGJSON", ""),
 		DockerConfigJSONPath:             env.GetDefault("CONFIG_DOCKERCONFIGJSONPATH", ""),
+		SourceSecret:                     env.GetDefault("CONFIG_SOURCE_SECRET", ""),
+		ExtraSecrets:                     env.GetDefault("CONFIG_EXTRA_SECRETS", ""),
+		OIDCTokenEndpoint:                env.GetDefault("CONFIG_OIDC_TOKEN_ENDPOINT", ""),
+		OIDCTokenPath:                    env.GetDefault("CONFIG_OIDC_TOKEN_PATH", "/var/run/secrets/tokens/registry-token"),
+		OIDCRegistry:                     env.GetDefault("CONFIG_OIDC_REGISTRY", ""),
+		OIDCUsername:                     env.GetDefault("CONFIG_OIDC_USERNAME", "oauth2accesstoken"),
+		DockerConfigJSONURL:              env.GetDefault("CONFIG_DOCKERCONFIGJSON_URL", ""),
+		DockerConfigJSONURLCAFile:        env.GetDefault("CONFIG_DOCKERCONFIGJSON_URL_CA_FILE", ""),
+		DockerConfigJSONURLCertFile:      env.GetDefault("CONFIG_DOCKERCONFIGJSON_URL_CERT_FILE", ""),
+		DockerConfigJSONURLKeyFile:       env.GetDefault("CONFIG_DOCKERCONFIGJSON_URL_KEY_FILE", ""),
+		CABundleFile:                     env.GetDefault("CONFIG_CA_BUNDLE_FILE", ""),
+		DockerConfigJSONURLAuthorization: env.GetDefault("CONFIG_DOCKERCONFIGJSON_URL_AUTHORIZATION", ""),
+		DockerConfigJSONURLInterval:      env.GetDurationDefault("CONFIG_DOCKERCONFIGJSON_URL_INTERVAL", time.Minute),
+		WatchdogTimeout:                  env.GetDurationDefault("CONFIG_WATCHDOG_TIMEOUT", 5*time.Minute),
+		CredentialSources:                env.GetDefault("CONFIG_CREDENTIAL_SOURCES", ""),
+		AgeKey:                           env.GetDefault("CONFIG_AGE_KEY", ""),
+		AgeKeyFile:                       env.GetDefault("CONFIG_AGE_KEYFILE", ""),
+		SignaturePublicKeyFile:           env.GetDefault("CONFIG_SIGNATURE_PUBLIC_KEYFILE", ""),
+		DockerConfigJSONSignature:        env.GetDefault("CONFIG_DOCKERCONFIGJSON_SIGNATURE", ""),
 		SecretName:                       env.GetDefault("CONFIG_SECRETNAME", "global-imagepullsecret"),
 		SecretNamespace:                  env.GetDefault("CONFIG_SECRET_NAMESPACE", ""),
+		OperatorNamespace:                env.GetDefault("CONFIG_OPERATOR_NAMESPACE", ""),
+		Profile:                          env.GetDefault("CONFIG_PROFILE", ""),
 		ExcludedNamespaces:               env.GetDefault("CONFIG_EXCLUDED_NAMESPACES", "kube-*"),
-		ExcludeAnnotation:                env.GetDefault("CONFIG_EXCLUDE_ANNOTATION", "pborn.eu/imagepullsecret-patcher-exclude"),
+		NamespaceLabel:                   env.GetDefault("CONFIG_NAMESPACE_LABEL", ""),
+		ExcludedNamespaceLabelValues:     env.GetDefault("CONFIG_EXCLUDED_NAMESPACE_LABEL_VALUES", ""),
+		IncludedNamespaceLabelValues:     env.GetDefault("CONFIG_INCLUDED_NAMESPACE_LABEL_VALUES", ""),
+		AnnotationDomain:                 env.GetDefault("CONFIG_ANNOTATION_DOMAIN", DefaultAnnotationDomain),
+		ExcludeAnnotation:                env.GetDefault("CONFIG_EXCLUDE_ANNOTATION", ""),
+		NoPodDeleteAnnotation:            env.GetDefault("CONFIG_NO_POD_DELETE_ANNOTATION", ""),
 		ServiceAccounts:                  env.GetDefault("CONFIG_SERVICEACCOUNTS", "default"),
+		NamespaceMinAge:                  env.GetDurationDefault("CONFIG_NAMESPACE_MIN_AGE", 0),
+		RequireDefaultServiceAccount:     env.GetBoolDefault("CONFIG_REQUIRE_DEFAULT_SERVICEACCOUNT", false),
+		ServiceAccountPatchStrategy:      env.GetDefault("CONFIG_SERVICEACCOUNT_PATCH_STRATEGY", PatchStrategyMerge),
+		GitOpsConflictPolicy:             env.GetDefault("CONFIG_GITOPS_CONFLICT_POLICY", GitOpsConflictPolicyAdopt),
+		ExternalSecretsConflictPolicy:    env.GetDefault("CONFIG_EXTERNAL_SECRETS_CONFLICT_POLICY", GitOpsConflictPolicySkip),
+		SealedSecretsConflictPolicy:      env.GetDefault("CONFIG_SEALED_SECRETS_CONFLICT_POLICY", GitOpsConflictPolicySkip),
+		OperatorNamespacePolicy:          env.GetDefault("CONFIG_OPERATOR_NAMESPACE_POLICY", OperatorNamespacePolicyAuto),
+		RequeueAfter:                     env.GetDurationDefault("CONFIG_REQUEUE_AFTER", 0),
+		Paused:                           env.GetBoolDefault("CONFIG_PAUSED", false),
+		WorkloadKinds:                    env.GetDefault("CONFIG_WORKLOAD_KINDS", "Deployment,StatefulSet,CronJob"),
+		WorkloadSelector:                 env.GetDefault("CONFIG_WORKLOAD_SELECTOR", ""),
 		AnnotationManagedBy:              AnnotationManagedBy,
 		AnnotationAppName:                AnnotationAppName,
+		PodCleanupOwnerKinds:             env.GetDefault("CONFIG_POD_CLEANUP_OWNER_KINDS", "ReplicaSet,StatefulSet"),
+		PodCleanupDelay:                  env.GetDurationDefault("CONFIG_POD_CLEANUP_DELAY", 0),
+		PodCleanupParallelism:            env.GetIntDefault("CONFIG_POD_CLEANUP_PARALLELISM", 10),
+		PodCleanupTimeout:                env.GetDurationDefault("CONFIG_POD_CLEANUP_TIMEOUT", 30*time.Second),
+		PodCleanupCriticalPriority:       env.GetIntDefault("CONFIG_POD_CLEANUP_CRITICAL_PRIORITY", 0),
+		PodCleanupCriticalSelector:       env.GetDefault("CONFIG_POD_CLEANUP_CRITICAL_NAMESPACE_SELECTOR", ""),
+		PodCleanupCriticalInterval:       env.GetDurationDefault("CONFIG_POD_CLEANUP_CRITICAL_INTERVAL", 10*time.Second),
+		PodCleanupReasons:                env.GetDefault("CONFIG_POD_CLEANUP_REASONS", "ErrImagePull,ImagePullBackOff"),
+		PodCleanupRegistries:             env.GetDefault("CONFIG_POD_CLEANUP_REGISTRIES", ""),
+		PodCleanupMinAge:                 env.GetDurationDefault("CONFIG_POD_CLEANUP_MIN_AGE", 0),
+		PodCleanupWindows:                env.GetDefault("CONFIG_POD_CLEANUP_WINDOWS", ""),
+		PodCleanupWindowTimezone:         env.GetDefault("CONFIG_POD_CLEANUP_WINDOW_TIMEZONE", "UTC"),
+		MaxConcurrentReconciles:          env.GetDefault("CONFIG_MAX_CONCURRENT_RECONCILES", "1"),
+		DaemonSetPodCleanupBackoff:       env.GetDurationDefault("CONFIG_DAEMONSET_POD_CLEANUP_BACKOFF", 10*time.Minute),
+		AdminBindAddress:                 env.GetDefault("CONFIG_ADMIN_BIND_ADDRESS", ""),
+		AdminToken:                       env.GetDefault("CONFIG_ADMIN_TOKEN", ""),
+		NotifyWebhookURL:                 env.GetDefault("CONFIG_NOTIFY_WEBHOOK_URL", ""),
+		AuditSink:                        env.GetDefault("CONFIG_AUDIT_SINK", ""),
+		SuggestionOutput:                 env.GetDefault("CONFIG_SUGGESTION_OUTPUT", ""),
+		NotifyFailureThreshold:           env.GetIntDefault("CONFIG_NOTIFY_FAILURE_THRESHOLD", 5),
+		EventLogSize:                     env.GetIntDefault("CONFIG_EVENT_LOG_SIZE", 100),
+		CacheSyncPeriod:                  env.GetDurationDefault("CONFIG_CACHE_SYNC_PERIOD", 0),
+		CacheSlimPods:                    env.GetBoolDefault("CONFIG_CACHE_SLIM_PODS", false),
+		FeatureScopeSecretCache:          env.GetBoolDefault("CONFIG_SCOPE_SECRET_CACHE", false),
+		CircuitBreakerThreshold:          env.GetIntDefault("CONFIG_CIRCUIT_BREAKER_THRESHOLD", 20),
+		CircuitBreakerCooldown:           env.GetDurationDefault("CONFIG_CIRCUIT_BREAKER_COOLDOWN", time.Minute),
+		NamespaceRateLimit:               env.GetIntDefault("CONFIG_NAMESPACE_RATE_LIMIT", 10),
+		NamespaceRateBurst:               env.GetIntDefault("CONFIG_NAMESPACE_RATE_BURST", 100),
+		ConflictThreshold:                env.GetIntDefault("CONFIG_CONFLICT_THRESHOLD", 5),
+		OrphanedSecretsInterval:          env.GetDurationDefault("CONFIG_ORPHANED_SECRETS_INTERVAL", 10*time.Minute),
+		StandbyVerifyInterval:            env.GetDurationDefault("CONFIG_STANDBY_VERIFY_INTERVAL", 0),
+		SelfTestNamespace:                env.GetDefault("CONFIG_SELF_TEST_NAMESPACE", ""),
+		SweepChunkSize:                   env.GetIntDefault("CONFIG_SWEEP_CHUNK_SIZE", 100),
 		FeatureDeletePods:                env.GetBoolDefault("CONFIG_DELETE_PODS", false),
+		FeatureDeletePodsAnyOwner:        env.GetBoolDefault("CONFIG_DELETE_PODS_ANY_OWNER", false),
+		FeatureEvictPods:                 env.GetBoolDefault("CONFIG_EVICT_PODS", false),
 		FeatureWatchDockerConfigJSONPath: env.GetBoolDefault("CONFIG_WATCH_DOCKERCONFIGJSONPATH", false),
+		FeatureSecretInAllNamespaces:     env.GetBoolDefault("CONFIG_CREATE_SECRET_IN_ALL_NAMESPACES", false),
+		FeaturePatchWorkloads:            env.GetBoolDefault("CONFIG_PATCH_WORKLOADS", false),
+		FeatureTemplateDockerConfigJSON:  env.GetBoolDefault("CONFIG_TEMPLATE_DOCKERCONFIGJSON", false),
+		FeatureDeleteOrphanedSecrets:     env.GetBoolDefault("CONFIG_DELETE_ORPHANED_SECRETS", false),
+		FeatureCheckSecretQuota:          env.GetBoolDefault("CONFIG_CHECK_SECRET_QUOTA", false),
+		FeatureImmutableSecrets:          env.GetBoolDefault("CONFIG_IMMUTABLE_SECRETS", false),
+		FeatureSelfTestCreateNamespace:   env.GetBoolDefault("CONFIG_SELF_TEST_CREATE_NAMESPACE", false),
+		FeatureSweepCheckpoint:           env.GetBoolDefault("CONFIG_SWEEP_CHECKPOINT", false),
+		FeatureSecretOnly:                !env.GetBoolDefault("CONFIG_MANAGE_SERVICEACCOUNTS", true),
+		FeatureServiceAccountOnly:        !env.GetBoolDefault("CONFIG_MANAGE_SECRETS", true),
+		DisableSecretController:          !env.GetBoolDefault("CONFIG_ENABLE_SECRET_CONTROLLER", true),
+		DisableServiceAccountController:  !env.GetBoolDefault("CONFIG_ENABLE_SERVICEACCOUNT_CONTROLLER", true),
+		FeatureImagePullStatistics:       env.GetBoolDefault("CONFIG_IMAGE_PULL_STATISTICS", false),
+		FeatureDetachServiceAccounts:     env.GetBoolDefault("CONFIG_DETACH_UNMANAGED_SERVICEACCOUNTS", false),
+		FeatureDeleteUnusedSecrets:       env.GetBoolDefault("CONFIG_DELETE_UNUSED_SECRETS", false),
+		FeatureNamespaceStatus:           env.GetBoolDefault("CONFIG_NAMESPACE_STATUS", false),
+		FeatureNamespaceSyncAnnotations:  env.GetBoolDefault("CONFIG_NAMESPACE_SYNC_ANNOTATIONS", false),
+		FeatureCreateSecretNamespace:     env.GetBoolDefault("CONFIG_SECRET_NAMESPACE_CREATE", false),
+		FeatureVerifyRotatedCredentials:  env.GetBoolDefault("CONFIG_VERIFY_ROTATED_CREDENTIALS", false),
+		FeatureLastKnownGood:             env.GetBoolDefault("CONFIG_LAST_KNOWN_GOOD", false),
 	}
 
 	for _, opt := range options {
 		if opt.FeatureDeletePods {
 			c.FeatureDeletePods = opt.FeatureDeletePods
 		}
+		if opt.FeatureDeletePodsAnyOwner {
+			c.FeatureDeletePodsAnyOwner = opt.FeatureDeletePodsAnyOwner
+		}
+		if opt.FeatureEvictPods {
+			c.FeatureEvictPods = opt.FeatureEvictPods
+		}
 		if opt.FeatureWatchDockerConfigJSONPath {
 			c.FeatureWatchDockerConfigJSONPath = opt.FeatureWatchDockerConfigJSONPath
 		}
+		if opt.FeatureSecretInAllNamespaces {
+			c.FeatureSecretInAllNamespaces = opt.FeatureSecretInAllNamespaces
+		}
+		if opt.FeaturePatchWorkloads {
+			c.FeaturePatchWorkloads = opt.FeaturePatchWorkloads
+		}
+		if opt.FeatureTemplateDockerConfigJSON {
+			c.FeatureTemplateDockerConfigJSON = opt.FeatureTemplateDockerConfigJSON
+		}
+		if opt.FeatureDeleteOrphanedSecrets {
+			c.FeatureDeleteOrphanedSecrets = opt.FeatureDeleteOrphanedSecrets
+		}
+		if opt.FeatureCheckSecretQuota {
+			c.FeatureCheckSecretQuota = opt.FeatureCheckSecretQuota
+		}
+		if opt.FeatureImmutableSecrets {
+			c.FeatureImmutableSecrets = opt.FeatureImmutableSecrets
+		}
+		if opt.FeatureSelfTestCreateNamespace {
+			c.FeatureSelfTestCreateNamespace = opt.FeatureSelfTestCreateNamespace
+		}
+		if opt.FeatureSweepCheckpoint {
+			c.FeatureSweepCheckpoint = opt.FeatureSweepCheckpoint
+		}
+		if opt.FeatureSecretOnly {
+			c.FeatureSecretOnly = opt.FeatureSecretOnly
+		}
+		if opt.FeatureServiceAccountOnly {
+			c.FeatureServiceAccountOnly = opt.FeatureServiceAccountOnly
+		}
+		if opt.DisableSecretController {
+			c.DisableSecretController = opt.DisableSecretController
+		}
+		if opt.DisableServiceAccountController {
+			c.DisableServiceAccountController = opt.DisableServiceAccountController
+		}
+		if opt.FeatureImagePullStatistics {
+			c.FeatureImagePullStatistics = opt.FeatureImagePullStatistics
+		}
+		if opt.FeatureDetachServiceAccounts {
+			c.FeatureDetachServiceAccounts = opt.FeatureDetachServiceAccounts
+		}
+		if opt.FeatureDeleteUnusedSecrets {
+			c.FeatureDeleteUnusedSecrets = opt.FeatureDeleteUnusedSecrets
+		}
+		if opt.FeatureNamespaceStatus {
+			c.FeatureNamespaceStatus = opt.FeatureNamespaceStatus
+		}
+		if opt.FeatureNamespaceSyncAnnotations {
+			c.FeatureNamespaceSyncAnnotations = opt.FeatureNamespaceSyncAnnotations
+		}
+		if opt.FeatureCreateSecretNamespace {
+			c.FeatureCreateSecretNamespace = opt.FeatureCreateSecretNamespace
+		}
+		if opt.FeatureVerifyRotatedCredentials {
+			c.FeatureVerifyRotatedCredentials = opt.FeatureVerifyRotatedCredentials
+		}
+		if opt.FeatureLastKnownGood {
+			c.FeatureLastKnownGood = opt.FeatureLastKnownGood
+		}
 		if opt.DockerConfigJSON != "" {
 			c.DockerConfigJSON = opt.DockerConfigJSON
 		}
 		if opt.DockerConfigJSONPath != "" {
 			c.DockerConfigJSONPath = opt.DockerConfigJSONPath
 		}
+		if opt.SourceSecret != "" {
+			c.SourceSecret = opt.SourceSecret
+		}
+		if opt.ExtraSecrets != "" {
+			c.ExtraSecrets = opt.ExtraSecrets
+		}
+		if opt.OIDCTokenEndpoint != "" {
+			c.OIDCTokenEndpoint = opt.OIDCTokenEndpoint
+		}
+		if opt.OIDCTokenPath != "" {
+			c.OIDCTokenPath = opt.OIDCTokenPath
+		}
+		if opt.OIDCRegistry != "" {
+			c.OIDCRegistry = opt.OIDCRegistry
+		}
+		if opt.OIDCUsername != "" {
+			c.OIDCUsername = opt.OIDCUsername
+		}
+		if opt.DockerConfigJSONURL != "" {
+			c.DockerConfigJSONURL = opt.DockerConfigJSONURL
+		}
+		if opt.DockerConfigJSONURLCAFile != "" {
+			c.DockerConfigJSONURLCAFile = opt.DockerConfigJSONURLCAFile
+		}
+		if opt.DockerConfigJSONURLCertFile != "" {
+			c.DockerConfigJSONURLCertFile = opt.DockerConfigJSONURLCertFile
+		}
+		if opt.DockerConfigJSONURLKeyFile != "" {
+			c.DockerConfigJSONURLKeyFile = opt.DockerConfigJSONURLKeyFile
+		}
+		if opt.CABundleFile != "" {
+			c.CABundleFile = opt.CABundleFile
+		}
+		if opt.DockerConfigJSONURLAuthorization != "" {
+			c.DockerConfigJSONURLAuthorization = opt.DockerConfigJSONURLAuthorization
+		}
+		if opt.DockerConfigJSONURLInterval != 0 {
+			c.DockerConfigJSONURLInterval = opt.DockerConfigJSONURLInterval
+		}
+		if opt.WatchdogTimeout != 0 {
+			c.WatchdogTimeout = opt.WatchdogTimeout
+		}
+		if opt.CredentialSources != "" {
+			c.CredentialSources = opt.CredentialSources
+		}
+		if opt.AgeKey != "" {
+			c.AgeKey = opt.AgeKey
+		}
+		if opt.AgeKeyFile != "" {
+			c.AgeKeyFile = opt.AgeKeyFile
+		}
+		if opt.SignaturePublicKeyFile != "" {
+			c.SignaturePublicKeyFile = opt.SignaturePublicKeyFile
+		}
+		if opt.DockerConfigJSONSignature != "" {
+			c.DockerConfigJSONSignature = opt.DockerConfigJSONSignature
+		}
 		if opt.SecretName != "" {
 			c.SecretName = opt.SecretName
 		}
 		if opt.SecretNamespace != "" {
 			c.SecretNamespace = opt.SecretNamespace
 		}
+		if opt.OperatorNamespace != "" {
+			c.OperatorNamespace = opt.OperatorNamespace
+		}
+		if opt.Profile != "" {
+			c.Profile = opt.Profile
+		}
 		if opt.ExcludedNamespaces != "" {
 			c.ExcludedNamespaces = opt.ExcludedNamespaces
 		}
+		if opt.NamespaceLabel != "" {
+			c.NamespaceLabel = opt.NamespaceLabel
+		}
+		if opt.ExcludedNamespaceLabelValues != "" {
+			c.ExcludedNamespaceLabelValues = opt.ExcludedNamespaceLabelValues
+		}
+		if opt.IncludedNamespaceLabelValues != "" {
+			c.IncludedNamespaceLabelValues = opt.IncludedNamespaceLabelValues
+		}
+		if opt.AnnotationDomain != "" {
+			c.AnnotationDomain = opt.AnnotationDomain
+		}
 		if opt.ExcludeAnnotation != "" {
 			c.ExcludeAnnotation = opt.ExcludeAnnotation
 		}
+		if opt.NoPodDeleteAnnotation != "" {
+			c.NoPodDeleteAnnotation = opt.NoPodDeleteAnnotation
+		}
 		if opt.ServiceAccounts != "" {
 			c.ServiceAccounts = opt.ServiceAccounts
 		}
+		if opt.NamespaceMinAge != 0 {
+			c.NamespaceMinAge = opt.NamespaceMinAge
+		}
+		if opt.RequireDefaultServiceAccount {
+			c.RequireDefaultServiceAccount = opt.RequireDefaultServiceAccount
+		}
+		if opt.ServiceAccountPatchStrategy != "" {
+			c.ServiceAccountPatchStrategy = opt.ServiceAccountPatchStrategy
+		}
+		if opt.GitOpsConflictPolicy != "" {
+			c.GitOpsConflictPolicy = opt.GitOpsConflictPolicy
+		}
+		if opt.ExternalSecretsConflictPolicy != "" {
+			c.ExternalSecretsConflictPolicy = opt.ExternalSecretsConflictPolicy
+		}
+		if opt.SealedSecretsConflictPolicy != "" {
+			c.SealedSecretsConflictPolicy = opt.SealedSecretsConflictPolicy
+		}
+		if opt.OperatorNamespacePolicy != "" {
+			c.OperatorNamespacePolicy = opt.OperatorNamespacePolicy
+		}
+		if opt.RequeueAfter != 0 {
+			c.RequeueAfter = opt.RequeueAfter
+		}
+		if opt.Paused {
+			c.Paused = opt.Paused
+		}
+		if opt.WorkloadKinds != "" {
+			c.WorkloadKinds = opt.WorkloadKinds
+		}
+		if opt.WorkloadSelector != "" {
+			c.WorkloadSelector = opt.WorkloadSelector
+		}
+		if opt.PodCleanupOwnerKinds != "" {
+			c.PodCleanupOwnerKinds = opt.PodCleanupOwnerKinds
+		}
+		if opt.PodCleanupDelay != 0 {
+			c.PodCleanupDelay = opt.PodCleanupDelay
+		}
+		if opt.PodCleanupParallelism != 0 {
+			c.PodCleanupParallelism = opt.PodCleanupParallelism
+		}
+		if opt.PodCleanupTimeout != 0 {
+			c.PodCleanupTimeout = opt.PodCleanupTimeout
+		}
+		if opt.PodCleanupCriticalPriority != 0 {
+			c.PodCleanupCriticalPriority = opt.PodCleanupCriticalPriority
+		}
+		if opt.PodCleanupCriticalSelector != "" {
+			c.PodCleanupCriticalSelector = opt.PodCleanupCriticalSelector
+		}
+		if opt.PodCleanupCriticalInterval != 0 {
+			c.PodCleanupCriticalInterval = opt.PodCleanupCriticalInterval
+		}
+		if opt.PodCleanupReasons != "" {
+			c.PodCleanupReasons = opt.PodCleanupReasons
+		}
+		if opt.PodCleanupRegistries != "" {
+			c.PodCleanupRegistries = opt.PodCleanupRegistries
+		}
+		if opt.PodCleanupMinAge != 0 {
+			c.PodCleanupMinAge = opt.PodCleanupMinAge
+		}
+		if opt.PodCleanupWindows != "" {
+			c.PodCleanupWindows = opt.PodCleanupWindows
+		}
+		if opt.PodCleanupWindowTimezone != "" {
+			c.PodCleanupWindowTimezone = opt.PodCleanupWindowTimezone
+		}
+		if opt.MaxConcurrentReconciles != "" {
+			c.MaxConcurrentReconciles = opt.MaxConcurrentReconciles
+		}
+		if opt.DaemonSetPodCleanupBackoff != 0 {
+			c.DaemonSetPodCleanupBackoff = opt.DaemonSetPodCleanupBackoff
+		}
+		if opt.AdminBindAddress != "" {
+			c.AdminBindAddress = opt.AdminBindAddress
+		}
+		if opt.AdminToken != "" {
+			c.AdminToken = opt.AdminToken
+		}
+		if opt.NotifyWebhookURL != "" {
+			c.NotifyWebhookURL = opt.NotifyWebhookURL
+		}
+		if opt.AuditSink != "" {
+			c.AuditSink = opt.AuditSink
+		}
+		if opt.SuggestionOutput != "" {
+			c.SuggestionOutput = opt.SuggestionOutput
+		}
+		if opt.NotifyFailureThreshold != 0 {
+			c.NotifyFailureThreshold = opt.NotifyFailureThreshold
+		}
+		if opt.EventLogSize != 0 {
+			c.EventLogSize = opt.EventLogSize
+		}
+		if opt.CacheSyncPeriod != 0 {
+			c.CacheSyncPeriod = opt.CacheSyncPeriod
+		}
+		if opt.CacheSlimPods {
+			c.CacheSlimPods = opt.CacheSlimPods
+		}
+		if opt.FeatureScopeSecretCache {
+			c.FeatureScopeSecretCache = opt.FeatureScopeSecretCache
+		}
+		if opt.CircuitBreakerThreshold != 0 {
+			c.CircuitBreakerThreshold = opt.CircuitBreakerThreshold
+		}
+		if opt.CircuitBreakerCooldown != 0 {
+			c.CircuitBreakerCooldown = opt.CircuitBreakerCooldown
+		}
+		if opt.NamespaceRateLimit != 0 {
+			c.NamespaceRateLimit = opt.NamespaceRateLimit
+		}
+		if opt.NamespaceRateBurst != 0 {
+			c.NamespaceRateBurst = opt.NamespaceRateBurst
+		}
+		if opt.ConflictThreshold != 0 {
+			c.ConflictThreshold = opt.ConflictThreshold
+		}
+		if opt.OrphanedSecretsInterval != 0 {
+			c.OrphanedSecretsInterval = opt.OrphanedSecretsInterval
+		}
+		if opt.StandbyVerifyInterval != 0 {
+			c.StandbyVerifyInterval = opt.StandbyVerifyInterval
+		}
+		if opt.SelfTestNamespace != "" {
+			c.SelfTestNamespace = opt.SelfTestNamespace
+		}
+		if opt.SweepChunkSize != 0 {
+			c.SweepChunkSize = opt.SweepChunkSize
+		}
 	}
 
-	if c.SecretNamespace == "" {
+	// The namespace of the controller exists, the one to create must be named explicitly
+	if c.FeatureCreateSecretNamespace && c.SecretNamespace == "" {
+		panic("`CONFIG_SECRET_NAMESPACE_CREATE` requires `CONFIG_SECRET_NAMESPACE`")
+	}
+	if c.OperatorNamespace == "" {
 		operatorNamespace, err := namespace.GetOperatorNamespace()
+		// The namespace of the controller is only required as default of the secret namespace
+		if err != nil && c.SecretNamespace == "" {
+			panic(fmt.Sprintf("Unable to detect the namespace of the controller (%s). Specify it with `CONFIG_OPERATOR_NAMESPACE` or `POD_NAMESPACE`, or specify `CONFIG_SECRET_NAMESPACE`", err))
+		}
+		c.OperatorNamespace = operatorNamespace
+	}
+	if c.SecretNamespace == "" {
+		c.SecretNamespace = c.OperatorNamespace
+	}
+
+	// The domain may be given with a trailing slash, e.g. imagepullsecret.mycompany.io/
+	c.AnnotationDomain = strings.TrimSuffix(c.AnnotationDomain, "/")
+	if errs := validation.IsDNS1123Subdomain(c.AnnotationDomain); len(errs) > 0 {
+		panic(fmt.Sprintf("Invalid `CONFIG_ANNOTATION_DOMAIN` (%s): %s", c.AnnotationDomain, strings.Join(errs, ", ")))
+	}
+	if c.ExcludeAnnotation == "" {
+		c.ExcludeAnnotation = c.AnnotationDomain + "/" + annotationExclude
+	}
+	if c.NoPodDeleteAnnotation == "" {
+		c.NoPodDeleteAnnotation = c.AnnotationDomain + "/" + annotationNoPodDelete
+	}
+	c.AnnotationRecreate = c.AnnotationDomain + "/" + annotationRecreate
+	c.AnnotationPaused = c.AnnotationDomain + "/" + annotationPaused
+	c.AnnotationAdminPaused = c.AnnotationDomain + "/" + annotationAdminPaused
+	c.AnnotationChangelog = c.AnnotationDomain + "/" + annotationChangelog
+	c.AnnotationAttached = c.AnnotationDomain + "/" + annotationAttached
+	c.AnnotationLastSync = c.AnnotationDomain + "/" + annotationLastSync
+	c.AnnotationLastError = c.AnnotationDomain + "/" + annotationLastError
+	c.AnnotationSource = c.AnnotationDomain + "/" + annotationSource
+	c.AnnotationCredentialHash = c.AnnotationDomain + "/" + annotationCredentialHash
+	c.AnnotationPodCleanupDeferred = c.AnnotationDomain + "/" + annotationPodCleanupDeferred
+	c.AnnotationRegistry = c.AnnotationDomain + "/" + annotationRegistry
+	c.LabelProfile = c.AnnotationDomain + "/" + labelProfile
+
+	// The admin API is only reachable from within the Pod, e.g. through a port-forward, unless a host is given
+	if c.AdminBindAddress != "" {
+		host, port, err := net.SplitHostPort(c.AdminBindAddress)
 		if err != nil {
-			panic(err)
+			panic(fmt.Sprintf("Invalid `CONFIG_ADMIN_BIND_ADDRESS` (%s): %v", c.AdminBindAddress, err))
 		}
-		c.SecretNamespace = operatorNamespace
+		if host == "" {
+			c.AdminBindAddress = net.JoinHostPort("127.0.0.1", port)
+		}
+	}
+
+	if _, err := labels.Parse(c.WorkloadSelector); err != nil {
+		panic(fmt.Sprintf("Invalid `CONFIG_WORKLOAD_SELECTOR` (%s): %v", c.WorkloadSelector, err))
+	}
+	if _, err := labels.Parse(c.PodCleanupCriticalSelector); err != nil {
+		panic(fmt.Sprintf("Invalid `CONFIG_POD_CLEANUP_CRITICAL_NAMESPACE_SELECTOR` (%s): %v", c.PodCleanupCriticalSelector, err))
+	}
+	if c.PodCleanupCriticalInterval < 0 {
+		panic(fmt.Sprintf("Invalid `CONFIG_POD_CLEANUP_CRITICAL_INTERVAL` (%s). Must not be negative", c.PodCleanupCriticalInterval))
+	}
+	if c.PodCleanupMinAge < 0 {
+		panic(fmt.Sprintf("Invalid `CONFIG_POD_CLEANUP_MIN_AGE` (%s). Must not be negative", c.PodCleanupMinAge))
+	}
+	if _, err := maintenance.ParseWindows(c.PodCleanupWindows, c.PodCleanupWindowTimezone); err != nil {
+		panic(fmt.Sprintf("Invalid `CONFIG_POD_CLEANUP_WINDOWS` (%s): %v", c.PodCleanupWindows, err))
+	}
+
+	if errs := validation.IsValidLabelValue(c.Profile); len(errs) > 0 {
+		panic(fmt.Sprintf("Invalid `CONFIG_PROFILE` (%s): %s", c.Profile, strings.Join(errs, ", ")))
+	}
+	switch c.ServiceAccountPatchStrategy {
+	case PatchStrategyMerge, PatchStrategyStrategic, PatchStrategyUpdate, PatchStrategyApply:
+	default:
+		panic(fmt.Sprintf("Unknown `CONFIG_SERVICEACCOUNT_PATCH_STRATEGY` (%s). Supported are merge, strategic, update and apply", c.ServiceAccountPatchStrategy))
+	}
+	// Apply configurations only hold the fields owned by the controller, so they can't be diffed against the ServiceAccount
+	if c.SuggestionOutput != "" && c.ServiceAccountPatchStrategy == PatchStrategyApply {
+		panic("Cannot specify `CONFIG_SUGGESTION_OUTPUT` together with `CONFIG_SERVICEACCOUNT_PATCH_STRATEGY=apply`")
 	}
 
-	if c.DockerConfigJSON == "" && c.DockerConfigJSONPath == "" {
-		panic("Neither `CONFIG_DOCKERCONFIGJSON or `CONFIG_DOCKERCONFIGJSONPATH defined.")
+	// Pods are only deleted, once their ServiceAccount references the imagePullSecret
+	if c.DisableServiceAccountController && c.FeatureDeletePods {
+		panic("Cannot specify `CONFIG_DELETE_PODS` together with `CONFIG_ENABLE_SERVICEACCOUNT_CONTROLLER=false`")
+	}
+	// Without the ServiceAccount controller, nothing attaches the imagePullSecret to ServiceAccounts
+	if c.DisableServiceAccountController {
+		c.FeatureSecretOnly = true
+	}
+	if c.FeatureSecretOnly && c.FeatureServiceAccountOnly {
+		panic("Cannot specify both `CONFIG_MANAGE_SERVICEACCOUNTS=false` and `CONFIG_MANAGE_SECRETS=false`")
+	}
+	if c.FeatureServiceAccountOnly && c.FeatureSecretInAllNamespaces {
+		panic("Cannot specify `CONFIG_CREATE_SECRET_IN_ALL_NAMESPACES` together with `CONFIG_MANAGE_SECRETS=false`")
+	}
+	// Without ServiceAccounts to follow, the imagePullSecret is distributed to all namespaces
+	if c.FeatureSecretOnly {
+		c.FeatureSecretInAllNamespaces = true
+	}
+	// Changed credentials are only propagated to the managed Secrets by the Secret controller
+	if c.DisableSecretController && !c.FeatureServiceAccountOnly &&
+		(c.FeatureWatchDockerConfigJSONPath || c.SourceSecret != "" || c.OIDCTokenEndpoint != "" || c.DockerConfigJSONURL != "") {
+		panic("Cannot specify `CONFIG_WATCH_DOCKERCONFIGJSONPATH`, `CONFIG_SOURCE_SECRET`, `CONFIG_OIDC_TOKEN_ENDPOINT` or `CONFIG_DOCKERCONFIGJSON_URL` together with `CONFIG_ENABLE_SECRET_CONTROLLER=false`")
+	}
+	// Extra Secrets are copied along with the imagePullSecret by the Secret controller
+	if c.ExtraSecrets != "" {
+		if c.DisableSecretController {
+			panic("Cannot specify `CONFIG_EXTRA_SECRETS` together with `CONFIG_ENABLE_SECRET_CONTROLLER=false`")
+		}
+		for _, name := range strings.Split(c.ExtraSecrets, ",") {
+			if name == c.SecretName || name == c.SourceSecret {
+				panic(fmt.Sprintf("Invalid `CONFIG_EXTRA_SECRETS` (%s). Must not contain the imagePullSecret or the source Secret", c.ExtraSecrets))
+			}
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				panic(fmt.Sprintf("Invalid `CONFIG_EXTRA_SECRETS` (%s): %s", c.ExtraSecrets, strings.Join(errs, ", ")))
+			}
+		}
+	}
+	// Without Secrets to manage, there's nothing left for the Secret controller to do
+	if c.FeatureServiceAccountOnly {
+		c.DisableSecretController = true
+	}
+
+	// GOMAXPROCS is set to the CPU quota by automaxprocs, before the config is read
+	if c.MaxConcurrentReconciles == MaxConcurrentReconcilesAuto {
+		c.ConcurrentReconciles = runtime.GOMAXPROCS(0)
+	} else if workers, err := strconv.Atoi(c.MaxConcurrentReconciles); err == nil && workers > 0 {
+		c.ConcurrentReconciles = workers
+	} else {
+		panic(fmt.Sprintf("Invalid `CONFIG_MAX_CONCURRENT_RECONCILES` (%s). Supported are positive numbers and auto", c.MaxConcurrentReconciles))
+	}
+
+	// Templates only become valid credentials, once rendered per namespace
+	if c.FeatureVerifyRotatedCredentials && c.FeatureTemplateDockerConfigJSON {
+		panic("Cannot specify `CONFIG_VERIFY_ROTATED_CREDENTIALS` together with `CONFIG_TEMPLATE_DOCKERCONFIGJSON`")
+	}
+	// Without the Secret controller, Secrets aren't cached at all
+	if c.FeatureScopeSecretCache && c.DisableSecretController {
+		panic("Cannot specify `CONFIG_SCOPE_SECRET_CACHE` together with `CONFIG_ENABLE_SECRET_CONTROLLER=false`")
+	}
+	if c.FeatureEvictPods && !c.FeatureDeletePods {
+		panic("`CONFIG_EVICT_PODS` requires `CONFIG_DELETE_PODS`")
+	}
+	if c.FeatureDeleteUnusedSecrets && !c.FeatureDetachServiceAccounts {
+		panic("`CONFIG_DELETE_UNUSED_SECRETS` requires `CONFIG_DETACH_UNMANAGED_SERVICEACCOUNTS`")
+	}
+
+	if c.FeatureSweepCheckpoint && c.SweepChunkSize < 1 {
+		panic(fmt.Sprintf("`CONFIG_SWEEP_CHUNK_SIZE` (%d) must be at least 1", c.SweepChunkSize))
+	}
+
+	if c.NamespaceRateLimit > 0 && c.NamespaceRateBurst < 1 {
+		panic(fmt.Sprintf("`CONFIG_NAMESPACE_RATE_BURST` (%d) must be at least 1", c.NamespaceRateBurst))
+	}
+
+	switch c.GitOpsConflictPolicy {
+	case GitOpsConflictPolicySkip, GitOpsConflictPolicyAdopt, GitOpsConflictPolicyAlert:
+	default:
+		panic(fmt.Sprintf("Unknown `CONFIG_GITOPS_CONFLICT_POLICY` (%s). Supported are skip, adopt and alert", c.GitOpsConflictPolicy))
+	}
+	switch c.ExternalSecretsConflictPolicy {
+	case GitOpsConflictPolicySkip, GitOpsConflictPolicyAdopt, GitOpsConflictPolicyAlert:
+	default:
+		panic(fmt.Sprintf("Unknown `CONFIG_EXTERNAL_SECRETS_CONFLICT_POLICY` (%s). Supported are skip, adopt and alert", c.ExternalSecretsConflictPolicy))
+	}
+	switch c.SealedSecretsConflictPolicy {
+	case GitOpsConflictPolicySkip, GitOpsConflictPolicyAdopt, GitOpsConflictPolicyAlert:
+	default:
+		panic(fmt.Sprintf("Unknown `CONFIG_SEALED_SECRETS_CONFLICT_POLICY` (%s). Supported are skip, adopt and alert", c.SealedSecretsConflictPolicy))
+	}
+
+	switch c.OperatorNamespacePolicy {
+	case OperatorNamespacePolicyAuto, OperatorNamespacePolicyInclude, OperatorNamespacePolicyExclude:
+	default:
+		panic(fmt.Sprintf("Unknown `CONFIG_OPERATOR_NAMESPACE_POLICY` (%s). Supported are auto, include and exclude", c.OperatorNamespacePolicy))
+	}
+
+	// The imagePullSecret is provisioned externally, e.g. by the External Secrets Operator, so no credentials are needed
+	if c.FeatureServiceAccountOnly {
+		return c
+	}
+	if c.DockerConfigJSON == "" && c.DockerConfigJSONPath == "" && c.SourceSecret == "" && c.OIDCTokenEndpoint == "" && c.DockerConfigJSONURL == "" {
+		panic("Neither `CONFIG_DOCKERCONFIGJSON`, `CONFIG_DOCKERCONFIGJSONPATH`, `CONFIG_SOURCE_SECRET`, `CONFIG_OIDC_TOKEN_ENDPOINT` nor `CONFIG_DOCKERCONFIGJSON_URL` defined.")
+	}
+	if c.OIDCTokenEndpoint != "" && c.OIDCRegistry == "" {
+		panic("`CONFIG_OIDC_REGISTRY` is required together with `CONFIG_OIDC_TOKEN_ENDPOINT`")
+	}
+	// Credentials served at DockerConfigJSONURL aren't signed, the endpoint is authenticated by TLS instead
+	if c.DockerConfigJSONURL != "" && !strings.HasPrefix(c.DockerConfigJSONURL, "https://") {
+		panic(fmt.Sprintf("Invalid `CONFIG_DOCKERCONFIGJSON_URL` (%s). Must be an https:// URL", c.DockerConfigJSONURL))
+	}
+	if c.DockerConfigJSONURL != "" && c.DockerConfigJSONURLInterval <= 0 {
+		panic(fmt.Sprintf("`CONFIG_DOCKERCONFIGJSON_URL_INTERVAL` (%s) must be positive", c.DockerConfigJSONURLInterval))
+	}
+	if (c.DockerConfigJSONURLCertFile == "") != (c.DockerConfigJSONURLKeyFile == "") {
+		panic("`CONFIG_DOCKERCONFIGJSON_URL_CERT_FILE` and `CONFIG_DOCKERCONFIGJSON_URL_KEY_FILE` must be specified together")
+	}
+	if c.CredentialSources != "" {
+		validateCredentialSources(c)
+		return c
 	}
 	if c.DockerConfigJSON != "" && c.DockerConfigJSONPath != "" {
-		panic(fmt.Sprintf("Cannot specify both `CONFIG_DOCKERCONFIGJSON` (%s) and `CONFIG_DOCKERCONFIGJSONPATH` (%s)", c.DockerConfigJSON, c.DockerConfigJSONPath))
+		panic(fmt.Sprintf("Cannot specify both `CONFIG_DOCKERCONFIGJSON` and `CONFIG_DOCKERCONFIGJSONPATH` (%s)", c.DockerConfigJSONPath))
+	}
+	if c.SourceSecret != "" && (c.DockerConfigJSON != "" || c.DockerConfigJSONPath != "") {
+		panic("Cannot specify `CONFIG_SOURCE_SECRET` together with `CONFIG_DOCKERCONFIGJSON` or `CONFIG_DOCKERCONFIGJSONPATH`")
+	}
+	if c.OIDCTokenEndpoint != "" && (c.DockerConfigJSON != "" || c.DockerConfigJSONPath != "" || c.SourceSecret != "") {
+		panic("Cannot specify `CONFIG_OIDC_TOKEN_ENDPOINT` together with another credential source, unless they're chained with `CONFIG_CREDENTIAL_SOURCES`")
+	}
+	if c.DockerConfigJSONURL != "" && (c.DockerConfigJSON != "" || c.DockerConfigJSONPath != "" || c.SourceSecret != "" || c.OIDCTokenEndpoint != "") {
+		panic("Cannot specify `CONFIG_DOCKERCONFIGJSON_URL` together with another credential source, unless they're chained with `CONFIG_CREDENTIAL_SOURCES`")
 	}
 
 	return c
 }
+
+// validateCredentialSources ensures every chained credential source is known and configured
+func validateCredentialSources(c *Config) {
+	configured := map[string]bool{
+		CredentialSourceEnv:    c.DockerConfigJSON != "",
+		CredentialSourceFile:   c.DockerConfigJSONPath != "",
+		CredentialSourceSecret: c.SourceSecret != "",
+		CredentialSourceOIDC:   c.OIDCTokenEndpoint != "",
+		CredentialSourceURL:    c.DockerConfigJSONURL != "",
+	}
+	for _, source := range strings.Split(c.CredentialSources, ",") {
+		isConfigured, ok := configured[strings.TrimSpace(source)]
+		if !ok {
+			panic(fmt.Sprintf("Unknown credential source `%s` in `CONFIG_CREDENTIAL_SOURCES`. Supported are env, file, secret, oidc and url", source))
+		}
+		if !isConfigured {
+			panic(fmt.Sprintf("Credential source `%s` in `CONFIG_CREDENTIAL_SOURCES` is not configured", source))
+		}
+	}
+}
+
+// DomainAnnotations maps the names of the annotations prefixed with the annotation domain to their keys in c.
+// The exclude and no-pod-delete annotations map to the configured keys, which may use another domain.
+func DomainAnnotations(c *Config) map[string]string {
+	return map[string]string{
+		annotationExclude:            strings.TrimSpace(strings.Split(c.ExcludeAnnotation, ",")[0]),
+		annotationNoPodDelete:        c.NoPodDeleteAnnotation,
+		annotationRecreate:           c.AnnotationRecreate,
+		annotationPaused:             c.AnnotationPaused,
+		annotationAdminPaused:        c.AnnotationAdminPaused,
+		annotationChangelog:          c.AnnotationChangelog,
+		annotationAttached:           c.AnnotationAttached,
+		annotationLastSync:           c.AnnotationLastSync,
+		annotationLastError:          c.AnnotationLastError,
+		annotationSource:             c.AnnotationSource,
+		annotationCredentialHash:     c.AnnotationCredentialHash,
+		annotationPodCleanupDeferred: c.AnnotationPodCleanupDeferred,
+		annotationRegistry:           c.AnnotationRegistry,
+	}
+}
+
+// DomainLabels maps the names of the labels prefixed with the annotation domain to their keys in c
+func DomainLabels(c *Config) map[string]string {
+	return map[string]string{
+		labelProfile: c.LabelProfile,
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// newConfigPanic returns the message, with which NewConfig panics for options, or an empty string
+func newConfigPanic(options ConfigOptions) (message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message = r.(string)
+		}
+	}()
+	NewConfig(options)
+	return ""
+}
+
+func Test_NewConfig_SecretController(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   ConfigOptions
+		wantPanic string
+		wantCheck func(c *Config) bool
+	}{
+		{
+			name:      "enabled by default",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx"},
+			wantCheck: func(c *Config) bool { return !c.DisableSecretController },
+		},
+		{
+			name:      "disabled",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", DisableSecretController: true},
+			wantCheck: func(c *Config) bool { return c.DisableSecretController },
+		},
+		{
+			name:      "disabled without Secrets to manage",
+			options:   ConfigOptions{SecretNamespace: "kube-system", FeatureServiceAccountOnly: true},
+			wantCheck: func(c *Config) bool { return c.DisableSecretController },
+		},
+		{
+			name:      "disabled with a watched credentials file",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSONPath: "/secrets/.dockerconfigjson", FeatureWatchDockerConfigJSONPath: true, DisableSecretController: true},
+			wantPanic: "`CONFIG_ENABLE_SECRET_CONTROLLER=false`",
+		},
+		{
+			name:      "disabled with a source Secret",
+			options:   ConfigOptions{SecretNamespace: "kube-system", SourceSecret: "registry-credentials", DisableSecretController: true},
+			wantPanic: "`CONFIG_ENABLE_SECRET_CONTROLLER=false`",
+		},
+		{
+			name:      "disabled with extra Secrets",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", ExtraSecrets: "registry-ca", DisableSecretController: true},
+			wantPanic: "`CONFIG_EXTRA_SECRETS`",
+		},
+		{
+			name:      "disabled with a scoped Secret cache",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", FeatureScopeSecretCache: true, DisableSecretController: true},
+			wantPanic: "`CONFIG_SCOPE_SECRET_CACHE`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := newConfigPanic(tt.options)
+			if tt.wantPanic != "" {
+				if !strings.Contains(message, tt.wantPanic) {
+					t.Errorf("NewConfig() panic = %q, want it to contain %q", message, tt.wantPanic)
+				}
+				return
+			}
+			if message != "" {
+				t.Fatalf("NewConfig() panic = %q", message)
+			}
+			if c := NewConfig(tt.options); !tt.wantCheck(c) {
+				t.Errorf("NewConfig() = %+v, unexpected Secret controller settings", c)
+			}
+		})
+	}
+}
+
+func Test_NewConfig_ServiceAccountController(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   ConfigOptions
+		wantPanic string
+		wantCheck func(c *Config) bool
+	}{
+		{
+			name:      "enabled by default",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx"},
+			wantCheck: func(c *Config) bool { return !c.DisableServiceAccountController && !c.FeatureSecretOnly },
+		},
+		{
+			name:    "disabled distributes the imagePullSecret to all namespaces",
+			options: ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", DisableServiceAccountController: true},
+			wantCheck: func(c *Config) bool {
+				return c.DisableServiceAccountController && c.FeatureSecretOnly && c.FeatureSecretInAllNamespaces
+			},
+		},
+		{
+			name:      "disabled with Pod cleanup",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", FeatureDeletePods: true, DisableServiceAccountController: true},
+			wantPanic: "`CONFIG_DELETE_PODS`",
+		},
+		{
+			name:      "disabled without Secrets to manage",
+			options:   ConfigOptions{SecretNamespace: "kube-system", FeatureServiceAccountOnly: true, DisableServiceAccountController: true},
+			wantPanic: "`CONFIG_MANAGE_SECRETS=false`",
+		},
+		{
+			name:      "both controllers disabled",
+			options:   ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSON: "xx", DisableSecretController: true, DisableServiceAccountController: true},
+			wantCheck: func(c *Config) bool { return c.DisableSecretController && c.FeatureSecretOnly },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := newConfigPanic(tt.options)
+			if tt.wantPanic != "" {
+				if !strings.Contains(message, tt.wantPanic) {
+					t.Errorf("NewConfig() panic = %q, want it to contain %q", message, tt.wantPanic)
+				}
+				return
+			}
+			if message != "" {
+				t.Fatalf("NewConfig() panic = %q", message)
+			}
+			if c := NewConfig(tt.options); !tt.wantCheck(c) {
+				t.Errorf("NewConfig() = %+v, unexpected ServiceAccount controller settings", c)
+			}
+		})
+	}
+}
+
+func Test_NewConfig_DockerConfigJSONURL(t *testing.T) {
+	if message := newConfigPanic(ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSONURL: "https://credentials.example.com/dockerconfigjson"}); message != "" {
+		t.Errorf("NewConfig() panic = %q for an https:// URL", message)
+	}
+	if message := newConfigPanic(ConfigOptions{SecretNamespace: "kube-system", DockerConfigJSONURL: "http://credentials.example.com/dockerconfigjson"}); !strings.Contains(message, "Must be an https:// URL") {
+		t.Errorf("NewConfig() panic = %q, want an http:// URL to be rejected", message)
+	}
+}
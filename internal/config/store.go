@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the current Config as an immutable snapshot. Replacing it, e.g. on a reload or by the
+// admin API, doesn't race with the workers of the controllers reading it concurrently.
+type Store struct {
+	mu      sync.Mutex
+	current atomic.Pointer[Config]
+}
+
+// NewStore returns a Store holding c. c must not be modified afterwards.
+func NewStore(c *Config) *Store {
+	s := &Store{}
+	s.current.Store(c)
+	return s
+}
+
+// Load returns the current snapshot of the Config. It must not be modified, use Update instead.
+func (s *Store) Load() *Config {
+	return s.current.Load()
+}
+
+// Update replaces the current snapshot with a copy of it, modified by update. The previous snapshot is left
+// untouched, as long as Config only holds values. Fields of reference types, e.g. maps or slices, must be
+// copied here, before adding them to Config.
+func (s *Store) Update(update func(c *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := *s.current.Load()
+	update(&next)
+	s.current.Store(&next)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Store_Update(t *testing.T) {
+	previous := NewConfig(ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	store := NewStore(previous)
+
+	store.Update(func(c *Config) {
+		c.SecretNamespace = "kube-credentials"
+	})
+	if got := store.Load().SecretNamespace; got != "kube-credentials" {
+		t.Errorf("Load().SecretNamespace = %v, want kube-credentials", got)
+	}
+	if previous.SecretNamespace != "kube-system" {
+		t.Errorf("Update() modified the previous snapshot, SecretNamespace = %v", previous.SecretNamespace)
+	}
+}
+
+// Update copies the Config shallowly, so fields of reference types would be shared between snapshots
+func Test_Config_ValuesOnly(t *testing.T) {
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Pointer, reflect.Interface, reflect.Func, reflect.Chan, reflect.Struct:
+			t.Errorf("Config.%s is of kind %s, copy it in Store.Update", field.Name, field.Type.Kind())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conflict
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// window is the time, within which corrections of an object are counted
+const window = time.Hour
+
+// Detector counts how often objects are modified back by other actors. Once an object was corrected
+// more than Threshold times within an hour, the fight is reported, naming the other field manager.
+type Detector struct {
+	// Recorder records the ConflictDetected Event on the object. No Event is recorded, if nil.
+	Recorder record.EventRecorder
+	// Threshold is the number of corrections per hour, above which a fight is reported. Disabled, if not positive.
+	Threshold int
+
+	mu          sync.Mutex
+	corrections map[string][]time.Time
+	now         func() time.Time
+}
+
+// Default is the Detector used by the controllers. It doesn't report anything, until replaced.
+var Default = New(nil, 0)
+
+// New returns a Detector, reporting fights via recorder, once an object was corrected more than threshold times per hour
+func New(recorder record.EventRecorder, threshold int) *Detector {
+	return &Detector{
+		Recorder:    recorder,
+		Threshold:   threshold,
+		corrections: map[string][]time.Time{},
+		now:         time.Now,
+	}
+}
+
+// Observe is called once per correction with the in-cluster state of obj of kind, which was corrected. The
+// correction is only counted, if obj was modified last by another field manager, after the controller had
+// written it. Objects, which the controller hasn't written yet, e.g. created by Helm or kubectl, are adopted
+// rather than modified back. It returns the other field manager, once a fight is reported, and an empty
+// string otherwise.
+func (d *Detector) Observe(ctx context.Context, obj client.Object, kind string) string {
+	if d.Threshold <= 0 {
+		return ""
+	}
+	manager := LastManager(obj)
+	if manager == "" || !hasOwnManager(obj) {
+		return ""
+	}
+
+	d.mu.Lock()
+	now := d.now()
+	key := kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+	corrections := d.corrections[key][:0]
+	for _, at := range d.corrections[key] {
+		if now.Sub(at) < window {
+			corrections = append(corrections, at)
+		}
+	}
+	corrections = append(corrections, now)
+	fight := len(corrections) > d.Threshold
+	if fight {
+		// Start over, so the fight is reported again, if it goes on
+		delete(d.corrections, key)
+	} else {
+		d.corrections[key] = corrections
+	}
+	d.prune(now)
+	d.mu.Unlock()
+
+	if !fight {
+		return ""
+	}
+
+	message := kind + " '" + obj.GetName() + "' was modified back by '" + manager + "' more than " + strconv.Itoa(d.Threshold) + " times within an hour"
+	log.FromContext(ctx).Info(message + " in namespace '" + obj.GetNamespace() + "'")
+	metrics.ConflictDetectedTotal.WithLabelValues(kind).Inc()
+	eventlog.Record(eventlog.ActionError, obj.GetNamespace(), obj.GetName(), message)
+	if d.Recorder != nil {
+		d.Recorder.Event(obj, corev1.EventTypeWarning, "ConflictDetected", message)
+	}
+	return manager
+}
+
+// prune forgets objects without corrections within the window. The caller must hold mu.
+func (d *Detector) prune(now time.Time) {
+	for key, corrections := range d.corrections {
+		if len(corrections) > 0 && now.Sub(corrections[len(corrections)-1]) >= window {
+			delete(d.corrections, key)
+		}
+	}
+}
+
+// ownManagers are the field managers of the controller: the one used for server-side apply,
+// and the default of client-go, which is derived from the name of the binary
+var ownManagers = []string{config.AnnotationAppName, filepath.Base(os.Args[0])}
+
+// LastManager returns the field manager, which modified obj last, unless it's the controller itself
+func LastManager(obj client.Object) string {
+	var manager string
+	var modifiedAt time.Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time == nil || entry.Time.Time.Before(modifiedAt) {
+			continue
+		}
+		manager = entry.Manager
+		modifiedAt = entry.Time.Time
+	}
+	if IsOwnManager(manager) {
+		return ""
+	}
+	return manager
+}
+
+// IsOwnManager returns whether manager is one of the field managers of the controller
+func IsOwnManager(manager string) bool {
+	for _, own := range ownManagers {
+		if manager == own {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOwnManager returns whether the controller is one of the field managers of obj
+func hasOwnManager(obj client.Object) bool {
+	own, _ := splitManagedFields(obj)
+	return len(own) > 0
+}
+
+// IsOwnChange returns whether the only change from oldObj to newObj was made by the controller itself,
+// according to their managed fields
+func IsOwnChange(oldObj client.Object, newObj client.Object) bool {
+	oldOwn, oldOthers := splitManagedFields(oldObj)
+	newOwn, newOthers := splitManagedFields(newObj)
+	return !reflect.DeepEqual(oldOwn, newOwn) && reflect.DeepEqual(oldOthers, newOthers)
+}
+
+// splitManagedFields splits the managed fields of obj into the entries of the controller and the ones of others
+func splitManagedFields(obj client.Object) (own []metav1.ManagedFieldsEntry, others []metav1.ManagedFieldsEntry) {
+	for _, entry := range obj.GetManagedFields() {
+		if IsOwnManager(entry.Manager) {
+			own = append(own, entry)
+		} else {
+			others = append(others, entry)
+		}
+	}
+	return own, others
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conflict
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// modifiedBy returns a Secret, which was modified last by manager
+func modifiedBy(manager string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "image-pull-secret",
+			Namespace: "team-a",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: config.AnnotationAppName, Time: &metav1.Time{Time: time.Now().Add(-time.Minute)}},
+				{Manager: manager, Time: &metav1.Time{Time: time.Now()}},
+			},
+		},
+	}
+}
+
+func Test_LastManager(t *testing.T) {
+	if manager := LastManager(modifiedBy("mutating-webhook")); manager != "mutating-webhook" {
+		t.Errorf("LastManager should return the other field manager, got '%s'", manager)
+	}
+	if manager := LastManager(modifiedBy(config.AnnotationAppName)); manager != "" {
+		t.Errorf("LastManager should ignore the controller itself, got '%s'", manager)
+	}
+	if manager := LastManager(&corev1.Secret{}); manager != "" {
+		t.Errorf("LastManager should return nothing without managed fields, got '%s'", manager)
+	}
+}
+
+func Test_Detector_Observe(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	recorder := record.NewFakeRecorder(10)
+	detector := New(recorder, 2)
+	detector.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if manager := detector.Observe(ctx, modifiedBy("mutating-webhook"), "Secret"); manager != "" {
+			t.Errorf("correction %d within the threshold should not be reported", i)
+		}
+		detector.Observe(ctx, modifiedBy(config.AnnotationAppName), "Secret")
+	}
+	if manager := detector.Observe(ctx, modifiedBy("mutating-webhook"), "Secret"); manager != "mutating-webhook" {
+		t.Errorf("correction exceeding the threshold should be reported, got '%s'", manager)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("ConflictDetected Event should be recorded, got %d Events", len(recorder.Events))
+	}
+	if value := testutil.ToFloat64(metrics.ConflictDetectedTotal.WithLabelValues("Secret")); value != 1 {
+		t.Errorf("conflict_detected_total should be 1, got %f", value)
+	}
+
+	now = now.Add(2 * window)
+	for i := 0; i < 2; i++ {
+		detector.Observe(ctx, modifiedBy("mutating-webhook"), "Secret")
+		now = now.Add(window)
+	}
+	if manager := detector.Observe(ctx, modifiedBy("mutating-webhook"), "Secret"); manager != "" {
+		t.Errorf("corrections spread over more than an hour should not be reported, got '%s'", manager)
+	}
+}
+
+func Test_Detector_Observe_NotWrittenYet(t *testing.T) {
+	detector := New(nil, 1)
+	createdBy := func(manager string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "default",
+				Namespace: "team-a",
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					{Manager: manager, Time: &metav1.Time{Time: time.Now()}},
+				},
+			},
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if manager := detector.Observe(context.Background(), createdBy("helm"), "ServiceAccount"); manager != "" {
+			t.Errorf("the first patch of an object created by another field manager should not be counted, got '%s'", manager)
+		}
+	}
+}
+
+func Test_Detector_Disabled(t *testing.T) {
+	detector := New(nil, -1)
+	for i := 0; i < 10; i++ {
+		if manager := detector.Observe(context.Background(), modifiedBy("mutating-webhook"), "Secret"); manager != "" {
+			t.Errorf("disabled Detector should not report anything, got '%s'", manager)
+		}
+	}
+}
+
+func Test_IsOwnChange(t *testing.T) {
+	old := modifiedBy("mutating-webhook")
+	ownChange := old.DeepCopy()
+	ownChange.ManagedFields[0].Time = &metav1.Time{Time: time.Now().Add(time.Minute)}
+	otherChange := ownChange.DeepCopy()
+	otherChange.ManagedFields[1].Time = &metav1.Time{Time: time.Now().Add(time.Minute)}
+
+	if !IsOwnChange(old, ownChange) {
+		t.Errorf("IsOwnChange should be true, if only the controller changed the object")
+	}
+	if IsOwnChange(old, otherChange) {
+		t.Errorf("IsOwnChange should be false, if another field manager changed the object")
+	}
+	if IsOwnChange(old, old.DeepCopy()) {
+		t.Errorf("IsOwnChange should be false, if the managed fields are unchanged, e.g. on a resync")
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// CredentialHashPublisher periodically publishes the hash of the current credentials on the namespace of the
+// controller, which the StandbyVerifier compares the imagePullSecrets against
+type CredentialHashPublisher struct {
+	client.Client
+	Config *config.Store
+}
+
+// NeedLeaderElection ensures only the leader, which refreshes the credentials, publishes their hash
+func (r *CredentialHashPublisher) NeedLeaderElection() bool {
+	return true
+}
+
+// Start publishes the credential hash every StandbyVerifyInterval, until ctx is cancelled
+func (r *CredentialHashPublisher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.Config.Load().StandbyVerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := utils.PublishCredentialHash(ctx, r.Client, r.Config.Load()); err != nil {
+			log.FromContext(ctx).Error(err, "error publishing credential hash")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// lastKnownGoodInterval is how often the last known good credentials are refreshed
+const lastKnownGoodInterval = time.Minute
+
+// LastKnownGoodRecorder periodically persists the credentials read from their source,
+// so they can be served while the source is broken (CONFIG_LAST_KNOWN_GOOD)
+type LastKnownGoodRecorder struct {
+	client.Client
+	Config *config.Store
+}
+
+// NeedLeaderElection ensures only the leader writes the last known good credentials
+func (r *LastKnownGoodRecorder) NeedLeaderElection() bool {
+	return true
+}
+
+// Start persists the credentials every lastKnownGoodInterval, until ctx is cancelled
+func (r *LastKnownGoodRecorder) Start(ctx context.Context) error {
+	ticker := time.NewTicker(lastKnownGoodInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := utils.PersistLastKnownGood(ctx, r.Client, r.Config.Load()); err != nil {
+			log.FromContext(ctx).Error(err, "error persisting last known good credentials")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
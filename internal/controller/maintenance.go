@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// deferredPodCleanupInterval is the interval, in which the maintenance windows are checked
+const deferredPodCleanupInterval = time.Minute
+
+// DeferredPodCleanup runs the Pod cleanups deferred outside of the maintenance windows,
+// once one of them opens
+type DeferredPodCleanup struct {
+	client.Client
+	Config   *config.Store
+	Recorder record.EventRecorder
+	Queue    *maintenance.Queue
+}
+
+// Start checks the maintenance windows every deferredPodCleanupInterval, until ctx is cancelled
+func (r *DeferredPodCleanup) Start(ctx context.Context) error {
+	ticker := time.NewTicker(deferredPodCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		r.Run(ctx, time.Now())
+	}
+}
+
+// Run cleans up the Pods of all queued namespaces, and of the ones marked with the pod-cleanup-deferred annotation,
+// if one of the maintenance windows is open at now. Cleanups, which fail or still wait for PodCleanupDelay, are
+// queued again, the others are unmarked.
+func (r *DeferredPodCleanup) Run(ctx context.Context, now time.Time) {
+	c := r.Config.Load()
+	log := log.FromContext(ctx)
+
+	windows, err := maintenance.ParseWindows(c.PodCleanupWindows, c.PodCleanupWindowTimezone)
+	if err != nil || !windows.Open(now) {
+		return
+	}
+	// The queue is lost on restarts and changes of the leader, the annotations on the namespaces are not
+	if err := utils.RestoreDeferredPodCleanups(ctx, r.Client, c, r.Queue); err != nil {
+		log.Error(err, "error restoring deferred Pod cleanups")
+	}
+	if r.Queue.Len() == 0 {
+		return
+	}
+
+	namespaces := r.Queue.Drain()
+	log.Info("Maintenance window opened, running deferred Pod cleanups", "namespaces", len(namespaces))
+	for _, namespace := range namespaces {
+		if ctx.Err() != nil {
+			r.Queue.Add(namespace)
+			continue
+		}
+		requeueAfter, err := utils.CleanupPodsForNamespace(ctx, c, r.Client, r.Recorder, namespace)
+		if apierrs.IsNotFound(err) {
+			continue
+		}
+		if requeueAfter > 0 {
+			// The cleanup waits for PodCleanupDelay, pick it up again on one of the next ticks
+			r.Queue.Add(namespace)
+			continue
+		}
+		utils.RecordNamespaceCondition(ctx, r.Client, c, namespace, imagepullsecretv1alpha1.ConditionPodsCleaned, err)
+		if err != nil {
+			log.Error(err, "error running deferred Pod cleanup", "namespace", namespace)
+			r.Queue.Add(namespace)
+			continue
+		}
+		if err := utils.SetPodCleanupDeferred(ctx, r.Client, c, namespace, false); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "error unmarking deferred Pod cleanup", "namespace", namespace)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
+)
+
+var _ = Describe("Deferred Pod cleanup", func() {
+	Context("When Pod cleanup was deferred outside of the maintenance windows", func() {
+		ctx := context.Background()
+
+		newCleanup := func(windows string) (*DeferredPodCleanup, *corev1.Pod, client.Client) {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				FeatureDeletePods: true,
+				PodCleanupWindows: windows,
+			})
+			namespace, serviceAccount, _, _ := makeObjects("testns-maintenance-1", "default", c.SecretName)
+			serviceAccount.ImagePullSecrets = []corev1.LocalObjectReference{{Name: c.SecretName}}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "app",
+					Namespace:       namespace.GetName(),
+					OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "app", Controller: ptr.To(true)}},
+				},
+				Spec: corev1.PodSpec{ServiceAccountName: serviceAccount.GetName()},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "app",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+					}},
+				},
+			}
+			cleanupClient := fake.NewClientBuilder().WithObjects(&namespace, &serviceAccount, pod).Build()
+
+			queue := maintenance.NewQueue()
+			queue.Add(namespace.GetName())
+			queue.Add("testns-maintenance-deleted")
+			return &DeferredPodCleanup{Client: cleanupClient, Config: config.NewStore(c), Queue: queue}, pod, cleanupClient
+		}
+
+		It("should keep the cleanups queued, while the windows are closed", func() {
+			// February 30th never comes, so the window is always closed
+			cleanup, pod, cleanupClient := newCleanup("0 0 30 2 * 1h")
+			cleanup.Run(ctx, time.Now())
+
+			Expect(cleanup.Queue.Len()).To(Equal(2))
+			Expect(cleanupClient.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{})).Should(Succeed())
+		})
+
+		It("should run the cleanups recorded on the namespaces, after the queue was lost", func() {
+			cleanup, pod, cleanupClient := newCleanup("* * * * * 1m")
+			cleanup.Queue = maintenance.NewQueue()
+			namespace := &corev1.Namespace{}
+			Expect(cleanupClient.Get(ctx, client.ObjectKey{Name: pod.GetNamespace()}, namespace)).Should(Succeed())
+			namespace.Annotations = map[string]string{cleanup.Config.Load().AnnotationPodCleanupDeferred: "true"}
+			Expect(cleanupClient.Update(ctx, namespace)).Should(Succeed())
+
+			cleanup.Run(ctx, time.Now())
+
+			Expect(cleanupClient.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{})).ShouldNot(Succeed())
+			Expect(cleanupClient.Get(ctx, client.ObjectKeyFromObject(namespace), namespace)).Should(Succeed())
+			Expect(namespace.GetAnnotations()).NotTo(HaveKey(cleanup.Config.Load().AnnotationPodCleanupDeferred))
+		})
+
+		It("should run the cleanups, once a window opened", func() {
+			cleanup, pod, cleanupClient := newCleanup("* * * * * 1m")
+			cleanup.Run(ctx, time.Now())
+
+			Expect(cleanup.Queue.Len()).To(Equal(0))
+			Expect(cleanupClient.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{})).ShouldNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// NamespaceReconciler reconciles the imagePullSecret in every namespace,
+// regardless of whether it contains a managed ServiceAccount
+type NamespaceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Config   *config.Store
+	Recorder record.EventRecorder
+	Notifier *notify.Notifier
+}
+
+//+imagepullsecret-patcher:rbac:bundles=secret-only
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+imagepullsecret-patcher:rbac:bundles=secret-only
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	c := r.Config.Load()
+	log := log.FromContext(ctx)
+
+	ns, err := utils.FetchNamespace(ctx, r.Client, req.Name)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if utils.IsNamespaceExcluded(c, ns) || !ns.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// Give namespace provisioning controllers time to settle
+	if remaining := utils.GetNamespaceMinAgeRemaining(c, ns); remaining > 0 {
+		log.Info("Namespace '" + ns.GetName() + "' is too young, requeuing after " + remaining.String())
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	if paused, err := utils.IsPaused(ctx, r.Client, c); err != nil {
+		return ctrl.Result{}, err
+	} else if paused {
+		if drift, err := utils.HasImagePullSecretDrift(ctx, r.Client, c, c.SecretName, ns.GetName()); err != nil {
+			return ctrl.Result{}, err
+		} else if drift {
+			utils.ReportDrift(ctx, "Secret", ns.GetName(), c.SecretName)
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+	}
+
+	result, err := utils.ReconcileImagePullSecret(ctx, r.Client, c, c.SecretName, ns.GetName())
+	observeResult("Secret", result)
+	r.Notifier.RecordResult(ctx, ns.GetName(), err)
+	utils.RecordSecretCondition(ctx, r.Client, c, ns.GetName(), result, err)
+	utils.RecordNamespaceSync(ctx, r.Client, c, ns.GetName(), err)
+	if err != nil {
+		eventlog.Record(eventlog.ActionError, ns.GetName(), c.SecretName, err.Error())
+		if errors.Is(err, utils.ErrSecretQuotaExceeded) {
+			if r.Recorder != nil {
+				r.Recorder.Event(ns, corev1.EventTypeWarning, "SecretQuotaExceeded", err.Error())
+			}
+			log.Info("ResourceQuota for Secrets in namespace '" + ns.GetName() + "' is exhausted, requeuing after " + secretQuotaRequeueAfter.String())
+			return ctrl.Result{RequeueAfter: secretQuotaRequeueAfter}, nil
+		}
+		if utils.IsOwnershipConflict(err) {
+			log.Info("imagePullSecret in namespace '" + ns.GetName() + "' is managed by someone else, requeuing after " + ownershipConflictRequeueAfter.String())
+			return ctrl.Result{RequeueAfter: ownershipConflictRequeueAfter}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("Failed to reconcile imagePullSecret in Namespace '"+ns.GetName()+"': %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("NamespaceController").
+		WithOptions(controllerOptions(r.Config.Load())).
+		For(&corev1.Namespace{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return !utils.IsNamespaceExcluded(r.Config.Load(), e.Object)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return !utils.IsNamespaceExcluded(r.Config.Load(), e.ObjectNew)
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return !utils.IsNamespaceExcluded(r.Config.Load(), e.Object)
+			},
+			// Ignore Deletion events
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+		}).
+		WithEventFilter(ignoreOwnChanges).
+		Complete(r)
+}
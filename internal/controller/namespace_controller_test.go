@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Namespace Controller", func() {
+	Context("When reconciling a Namespace", func() {
+		var err error
+		ctx := context.Background()
+		config := config.NewConfig(
+			config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				FeatureSecretInAllNamespaces: true,
+			},
+		)
+
+		It("should create the Secret in a namespace without managed ServiceAccounts", func() {
+			namespace, _, _, secretNN := makeObjects("testns-all-1", "default", config.SecretName)
+
+			By("Creating the Namespace to reconcile")
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Reconciling the Namespace")
+			namespaceReconciler := &NamespaceReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(config),
+			}
+			_, err = namespaceReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: namespace.GetName()},
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if Secret was successfully created in the reconciliation")
+			foundSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretNN, foundSecret)).Should(Succeed())
+			Expect(string(foundSecret.Data[corev1.DockerConfigJsonKey])).To(Equal(imagePullSecretData))
+		})
+
+		It("should not create the Secret in an excluded namespace", func() {
+			namespace, _, _, secretNN := makeObjects("testns-all-2", "default", config.SecretName)
+
+			By("Creating the Namespace to reconcile")
+			namespace.Annotations = map[string]string{
+				config.ExcludeAnnotation: "true",
+			}
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Reconciling the Namespace")
+			namespaceReconciler := &NamespaceReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(config),
+			}
+			_, err = namespaceReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: namespace.GetName()},
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if Secret was NOT created in the reconciliation")
+			err = k8sClient.Get(ctx, secretNN, &corev1.Secret{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When the imagePullSecret is claimed by a GitOps tool", func() {
+		ctx := context.Background()
+
+		It("should re-check a Secret claimed by ArgoCD after a fixed interval with the alert policy", func() {
+			alertConfig := config.NewConfig(
+				config.ConfigOptions{
+					DockerConfigJSON:             imagePullSecretData,
+					SecretNamespace:              "kube-system",
+					FeatureSecretInAllNamespaces: true,
+					GitOpsConflictPolicy:         config.GitOpsConflictPolicyAlert,
+				},
+			)
+			namespace, _, _, secretNN := makeObjects("testns-all-3", "default", alertConfig.SecretName)
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Creating the Secret claimed by ArgoCD")
+			claimed := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        secretNN.Name,
+					Namespace:   secretNN.Namespace,
+					Annotations: map[string]string{"argocd.argoproj.io/tracking-id": "registry:/Secret:" + secretNN.String()},
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			}
+			Expect(k8sClient.Create(ctx, claimed)).Should(Succeed())
+
+			By("Reconciling the Namespace")
+			namespaceReconciler := &NamespaceReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(alertConfig),
+			}
+			result, err := namespaceReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: namespace.GetName()},
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(Equal(ownershipConflictRequeueAfter))
+
+			By("Checking if Secret was left untouched")
+			foundSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretNN, foundSecret)).Should(Succeed())
+			Expect(string(foundSecret.Data[corev1.DockerConfigJsonKey])).To(Equal(`{"auths":{}}`))
+		})
+	})
+})
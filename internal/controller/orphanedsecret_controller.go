@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// OrphanedSecretCollector periodically looks for managed Secrets, that no longer match
+// the configured SecretName, and reports or deletes them
+type OrphanedSecretCollector struct {
+	client.Client
+	Config   *config.Store
+	Recorder record.EventRecorder
+	Breaker  *breaker.Breaker
+
+	// reported holds the orphaned Secrets found by the previous collection, so each one is only
+	// reported once, instead of on every run
+	mu       sync.Mutex
+	reported map[types.UID]bool
+}
+
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// NeedLeaderElection ensures only the leader deletes orphaned Secrets
+func (r *OrphanedSecretCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs a collection every OrphanedSecretsInterval, until ctx is cancelled
+func (r *OrphanedSecretCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.Config.Load().OrphanedSecretsInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Collect(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "error collecting orphaned secrets")
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Collect reports all orphaned Secrets in namespaces, that are not excluded,
+// and deletes them if FeatureDeleteOrphanedSecrets is set. Secrets already reported
+// by the previous collection are not reported again. The ImagePullSecretStatus objects
+// of namespaces excluded by now are deleted as well.
+func (r *OrphanedSecretCollector) Collect(ctx context.Context) error {
+	c := r.Config.Load()
+	log := log.FromContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Breaker.IsOpen() {
+		log.Info("Skipping collection of orphaned secrets, as the API server is overloaded")
+		return nil
+	}
+
+	paused, err := utils.IsPaused(ctx, r.Client, c)
+	if err != nil {
+		return err
+	}
+
+	secretList := &corev1.SecretList{}
+	if err := r.List(ctx, secretList, utils.ManagedSecretsSelector); err != nil {
+		return fmt.Errorf("error listing secrets: %w", err)
+	}
+
+	orphaned := 0
+	reported := map[types.UID]bool{}
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		if !utils.IsOrphanedSecret(c, secret) {
+			continue
+		}
+		ns, err := utils.FetchNamespace(ctx, r.Client, secret.GetNamespace())
+		if err != nil {
+			log.Error(err, "error fetching namespace")
+			continue
+		}
+		if utils.IsNamespaceExcluded(c, ns) {
+			continue
+		}
+		orphaned++
+
+		if !c.FeatureDeleteOrphanedSecrets || paused {
+			reported[secret.GetUID()] = true
+			if r.reported[secret.GetUID()] {
+				continue
+			}
+			log.Info("Found orphaned Secret '" + secret.GetName() + "' in namespace '" + secret.GetNamespace() + "'")
+			if r.Recorder != nil {
+				r.Recorder.Event(secret, corev1.EventTypeWarning, "OrphanedSecret",
+					"Secret is managed by imagepullsecret-patcher, but does not match the configured SecretName '"+c.SecretName+"'")
+			}
+			continue
+		}
+
+		if err := r.Delete(ctx, secret); err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "error deleting orphaned Secret '"+secret.GetName()+"' in namespace '"+secret.GetNamespace()+"'")
+			continue
+		}
+		log.Info("Deleted orphaned Secret '" + secret.GetName() + "' in namespace '" + secret.GetNamespace() + "'")
+		eventlog.Record(eventlog.ActionSecretDeleted, secret.GetNamespace(), secret.GetName(), "orphaned")
+	}
+	metrics.OrphanedSecrets.Set(float64(orphaned))
+	r.reported = reported
+
+	if !paused {
+		if _, err := utils.PruneNamespaceStatuses(ctx, r.Client, c); err != nil {
+			log.Error(err, "error pruning ImagePullSecretStatuses")
+		}
+	}
+
+	return nil
+}
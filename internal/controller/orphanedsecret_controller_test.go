@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespacedRecorder only forwards the events of objects in namespace, as the collector
+// also reports the orphaned Secrets left in the shared cluster by other specs
+type namespacedRecorder struct {
+	record.EventRecorder
+	namespace string
+}
+
+func (r namespacedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if o, ok := object.(client.Object); ok && o.GetNamespace() == r.namespace {
+		r.EventRecorder.Event(object, eventtype, reason, message)
+	}
+}
+
+var _ = Describe("OrphanedSecret Collector", func() {
+	Context("When collecting orphaned Secrets", func() {
+		ctx := context.Background()
+
+		labelProfile := config.NewConfig(config.ConfigOptions{DockerConfigJSON: imagePullSecretData, SecretNamespace: "kube-system"}).LabelProfile
+		newSecret := func(name string, namespace string) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Annotations: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+					},
+					Labels: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+						labelProfile:               config.DefaultProfile,
+					},
+				},
+			}
+		}
+
+		It("should only report orphaned Secrets by default", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON: imagePullSecretData,
+				SecretNamespace:  "kube-system",
+			})
+			namespace, _, _, _ := makeObjects("testns-orphaned-1", "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+			orphaned := newSecret("old-imagepullsecret", namespace.GetName())
+			Expect(k8sClient.Create(ctx, orphaned)).Should(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			collector := &OrphanedSecretCollector{
+				Client:   k8sClient,
+				Config:   config.NewStore(c),
+				Recorder: namespacedRecorder{EventRecorder: recorder, namespace: namespace.GetName()},
+			}
+			Expect(collector.Collect(ctx)).Should(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: orphaned.GetName(), Namespace: orphaned.GetNamespace()}, &corev1.Secret{})).Should(Succeed())
+			Expect(recorder.Events).To(Receive(ContainSubstring("OrphanedSecret")))
+
+			By("Collecting again without changes")
+			Expect(collector.Collect(ctx)).Should(Succeed())
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should delete orphaned Secrets, but keep the current one", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				FeatureDeleteOrphanedSecrets: true,
+			})
+			namespace, _, _, secretNN := makeObjects("testns-orphaned-2", "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+			orphaned := newSecret("old-imagepullsecret", namespace.GetName())
+			Expect(k8sClient.Create(ctx, orphaned)).Should(Succeed())
+			Expect(k8sClient.Create(ctx, newSecret(secretNN.Name, secretNN.Namespace))).Should(Succeed())
+
+			collector := &OrphanedSecretCollector{Client: k8sClient, Config: config.NewStore(c)}
+			Expect(collector.Collect(ctx)).Should(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: orphaned.GetName(), Namespace: orphaned.GetNamespace()}, &corev1.Secret{})).ShouldNot(Succeed())
+			Expect(k8sClient.Get(ctx, secretNN, &corev1.Secret{})).Should(Succeed())
+		})
+	})
+})
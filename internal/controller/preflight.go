@@ -0,0 +1,377 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// clusterScopedResources are the resources, whose permissions can't be granted by a RoleBinding
+var clusterScopedResources = map[string]bool{
+	"namespaces": true,
+}
+
+// Permission is a verb on a resource, which the controller requires in all managed namespaces
+type Permission struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+}
+
+// String returns the permission in the form "verb resource.group/subresource"
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	return p.Verb + " " + resource
+}
+
+// resource returns the resource of the permission, including its subresource
+func (p Permission) resource() string {
+	if p.Subresource != "" {
+		return p.Resource + "/" + p.Subresource
+	}
+	return p.Resource
+}
+
+// preflightCheck holds the permissions required by a feature. Without disable, the
+// permissions are required by the controller itself and can't be degraded gracefully.
+type preflightCheck struct {
+	feature     string
+	permissions []Permission
+	// enabled reports, if the feature is enabled
+	enabled func(c *config.Config) bool
+	// disable turns off the feature
+	disable func(c *config.Config)
+}
+
+var preflightChecks = []preflightCheck{
+	{
+		permissions: []Permission{
+			{Resource: "secrets", Verb: "create"},
+			{Resource: "secrets", Verb: "patch"},
+		},
+		enabled: func(c *config.Config) bool { return !c.FeatureServiceAccountOnly },
+	},
+	{
+		permissions: []Permission{{Resource: "serviceaccounts", Verb: "patch"}},
+		enabled:     func(c *config.Config) bool { return !c.FeatureSecretOnly },
+	},
+	{
+		feature:     "delete-pods",
+		permissions: []Permission{{Resource: "pods", Verb: "delete"}},
+		enabled:     func(c *config.Config) bool { return c.FeatureDeletePods },
+		disable:     func(c *config.Config) { c.FeatureDeletePods = false },
+	},
+	{
+		feature:     "evict-pods",
+		permissions: []Permission{{Resource: "pods", Subresource: "eviction", Verb: "create"}},
+		enabled:     func(c *config.Config) bool { return c.FeatureEvictPods },
+		disable:     func(c *config.Config) { c.FeatureEvictPods = false },
+	},
+	{
+		feature:     "delete-orphaned-secrets",
+		permissions: []Permission{{Resource: "secrets", Verb: "delete"}},
+		enabled:     func(c *config.Config) bool { return c.FeatureDeleteOrphanedSecrets },
+		disable:     func(c *config.Config) { c.FeatureDeleteOrphanedSecrets = false },
+	},
+	{
+		feature:     "check-secret-quota",
+		permissions: []Permission{{Resource: "resourcequotas", Verb: "list"}},
+		enabled:     func(c *config.Config) bool { return c.FeatureCheckSecretQuota },
+		disable:     func(c *config.Config) { c.FeatureCheckSecretQuota = false },
+	},
+	{
+		feature: "patch-workloads",
+		permissions: []Permission{
+			{Group: "apps", Resource: "deployments", Verb: "patch"},
+			{Group: "apps", Resource: "statefulsets", Verb: "patch"},
+			{Group: "batch", Resource: "cronjobs", Verb: "patch"},
+		},
+		enabled: func(c *config.Config) bool { return c.FeaturePatchWorkloads },
+		disable: func(c *config.Config) { c.FeaturePatchWorkloads = false },
+	},
+	{
+		feature: "namespace-status",
+		permissions: []Permission{
+			{Group: imagepullsecretv1alpha1.GroupVersion.Group, Resource: "imagepullsecretstatuses", Verb: "create"},
+			{Group: imagepullsecretv1alpha1.GroupVersion.Group, Resource: "imagepullsecretstatuses", Verb: "delete"},
+			{Group: imagepullsecretv1alpha1.GroupVersion.Group, Resource: "imagepullsecretstatuses", Subresource: "status", Verb: "update"},
+		},
+		enabled: func(c *config.Config) bool { return c.FeatureNamespaceStatus },
+		disable: func(c *config.Config) { c.FeatureNamespaceStatus = false },
+	},
+	{
+		feature:     "namespace-sync-annotations",
+		permissions: []Permission{{Resource: "namespaces", Verb: "patch"}},
+		enabled:     func(c *config.Config) bool { return c.FeatureNamespaceSyncAnnotations },
+		disable:     func(c *config.Config) { c.FeatureNamespaceSyncAnnotations = false },
+	},
+	{
+		feature: "self-test-create-namespace",
+		permissions: []Permission{
+			{Resource: "namespaces", Verb: "create"},
+			{Resource: "namespaces", Verb: "delete"},
+		},
+		enabled: func(c *config.Config) bool { return c.FeatureSelfTestCreateNamespace },
+		disable: func(c *config.Config) { c.FeatureSelfTestCreateNamespace = false },
+	},
+}
+
+// optionalPermissions are the permissions of the full ClusterRole, which not every configuration requires.
+// Once granted without being required, they're reported, so the ClusterRole can be narrowed to an RBAC bundle.
+var optionalPermissions = []struct {
+	permission Permission
+	required   func(c *config.Config) bool
+}{
+	{Permission{Resource: "secrets", Verb: "create"}, func(c *config.Config) bool { return !c.FeatureServiceAccountOnly }},
+	{Permission{Resource: "secrets", Verb: "patch"}, func(c *config.Config) bool { return !c.FeatureServiceAccountOnly }},
+	{Permission{Resource: "secrets", Verb: "delete"}, func(c *config.Config) bool { return !c.FeatureServiceAccountOnly }},
+	{Permission{Resource: "serviceaccounts", Verb: "patch"}, func(c *config.Config) bool { return !c.FeatureSecretOnly }},
+	{Permission{Resource: "pods", Verb: "delete"}, func(c *config.Config) bool { return c.FeatureDeletePods }},
+	{Permission{Resource: "pods", Subresource: "eviction", Verb: "create"}, func(c *config.Config) bool { return c.FeatureDeletePods }},
+	{Permission{Resource: "resourcequotas", Verb: "list"}, func(c *config.Config) bool { return c.FeatureCheckSecretQuota }},
+	{Permission{Group: "apps", Resource: "deployments", Verb: "patch"}, func(c *config.Config) bool { return c.FeaturePatchWorkloads }},
+	{Permission{Group: "apps", Resource: "statefulsets", Verb: "patch"}, func(c *config.Config) bool { return c.FeaturePatchWorkloads }},
+	{Permission{Group: "batch", Resource: "cronjobs", Verb: "patch"}, func(c *config.Config) bool { return c.FeaturePatchWorkloads }},
+	{
+		Permission{Group: imagepullsecretv1alpha1.GroupVersion.Group, Resource: "imagepullsecretstatuses", Verb: "create"},
+		func(c *config.Config) bool { return c.FeatureNamespaceStatus },
+	},
+	{
+		Permission{Resource: "namespaces", Verb: "create"},
+		func(c *config.Config) bool { return c.FeatureSelfTestCreateNamespace || c.FeatureCreateSecretNamespace },
+	},
+	{Permission{Resource: "namespaces", Verb: "delete"}, func(c *config.Config) bool { return c.FeatureSelfTestCreateNamespace }},
+	{Permission{Resource: "serviceaccounts", Verb: "create"}, func(c *config.Config) bool { return c.SelfTestNamespace != "" }},
+	{Permission{Resource: "serviceaccounts", Verb: "delete"}, func(c *config.Config) bool { return c.SelfTestNamespace != "" }},
+}
+
+// RBACPreflight checks the permissions of the controller via SelfSubjectAccessReviews at startup.
+// Features lacking a permission are disabled, instead of failing every reconcile with Forbidden errors.
+// Missing permissions required by the controller itself fail the readiness check.
+type RBACPreflight struct {
+	client.Client
+	// APIReader lists the namespaces, in which permissions not granted cluster-wide are reviewed.
+	// If unset, only cluster-wide permissions are recognized.
+	APIReader client.Reader
+	Config    *config.Store
+
+	// namespaces caches the managed namespaces during a run
+	namespaces []string
+
+	mu       sync.Mutex
+	missing  []Permission
+	disabled []string
+	excess   []Permission
+}
+
+//+imagepullsecret-patcher:rbac:bundles=secret-only;serviceaccount-only
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=selfsubjectaccessreviews,verbs=create
+
+// Run reviews every permission required by the controller and its enabled features. It must be
+// called before the controllers are set up, as it modifies the Config.
+func (p *RBACPreflight) Run(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	p.namespaces = nil
+
+	var missing []Permission
+	var disabled []string
+	for _, check := range preflightChecks {
+		if check.enabled != nil && !check.enabled(p.Config.Load()) {
+			continue
+		}
+		var denied []Permission
+		for _, permission := range check.permissions {
+			allowed, err := p.review(ctx, permission)
+			if err != nil {
+				return err
+			}
+			metrics.SetPermissionMissing(permission.resource(), permission.Verb, !allowed)
+			if !allowed {
+				denied = append(denied, permission)
+			}
+		}
+		if len(denied) == 0 {
+			continue
+		}
+		if check.disable == nil {
+			missing = append(missing, denied...)
+			log.Error(nil, "Required permissions are missing", "permissions", permissionList(denied))
+			continue
+		}
+		p.Config.Update(check.disable)
+		disabled = append(disabled, check.feature)
+		log.Info("Feature '"+check.feature+"' disabled, as permissions are missing", "permissions", permissionList(denied))
+		eventlog.Record(eventlog.ActionError, "", "", "feature '"+check.feature+"' disabled, as permissions are missing: "+permissionList(denied))
+	}
+
+	excess, err := p.reviewExcess(ctx)
+	if err != nil {
+		return err
+	}
+	if len(excess) > 0 {
+		log.Info("Permissions beyond the enabled features are granted, consider narrowing the ClusterRole to an RBAC bundle",
+			"permissions", permissionList(excess))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.missing = missing
+	p.disabled = disabled
+	p.excess = excess
+	return nil
+}
+
+// reviewExcess returns the optional permissions, which are granted, although the config doesn't require them
+func (p *RBACPreflight) reviewExcess(ctx context.Context) ([]Permission, error) {
+	c := p.Config.Load()
+
+	var excess []Permission
+	for _, optional := range optionalPermissions {
+		if optional.required(c) {
+			metrics.SetPermissionExcess(optional.permission.resource(), optional.permission.Verb, false)
+			continue
+		}
+		allowed, err := p.review(ctx, optional.permission)
+		if err != nil {
+			return nil, err
+		}
+		metrics.SetPermissionExcess(optional.permission.resource(), optional.permission.Verb, allowed)
+		if allowed {
+			excess = append(excess, optional.permission)
+		}
+	}
+	return excess, nil
+}
+
+// Disabled returns the features disabled by the last run
+func (p *RBACPreflight) Disabled() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.disabled
+}
+
+// Excess returns the permissions granted without being required, as found by the last run
+func (p *RBACPreflight) Excess() []Permission {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.excess
+}
+
+// Checker is a readiness check, which fails while permissions required by the controller itself are missing
+func (p *RBACPreflight) Checker(_ *http.Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.missing) > 0 {
+		return fmt.Errorf("required permissions are missing: %s", permissionList(p.missing))
+	}
+	return nil
+}
+
+// review reports, if the controller is allowed permission in all namespaces. Permissions on namespaced
+// resources, which aren't granted cluster-wide, are reviewed in every namespace, which isn't excluded,
+// so permissions granted by RoleBindings in the managed namespaces are recognized as well.
+func (p *RBACPreflight) review(ctx context.Context, permission Permission) (bool, error) {
+	allowed, err := p.reviewIn(ctx, permission, "")
+	if err != nil || allowed || clusterScopedResources[permission.Resource] || p.APIReader == nil {
+		return allowed, err
+	}
+
+	namespaces, err := p.managedNamespaces(ctx)
+	if err != nil || len(namespaces) == 0 {
+		return false, err
+	}
+	for _, namespace := range namespaces {
+		allowed, err := p.reviewIn(ctx, permission, namespace)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// reviewIn reports, if the controller is allowed permission in namespace, or in all namespaces, if it is empty
+func (p *RBACPreflight) reviewIn(ctx context.Context, permission Permission, namespace string) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   namespace,
+				Group:       permission.Group,
+				Resource:    permission.Resource,
+				Subresource: permission.Subresource,
+				Verb:        permission.Verb,
+			},
+		},
+	}
+	if err := p.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("failed to review permission '%s': %w", permission, err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// managedNamespaces returns the names of the namespaces, which aren't excluded
+func (p *RBACPreflight) managedNamespaces(ctx context.Context) ([]string, error) {
+	if p.namespaces != nil {
+		return p.namespaces, nil
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+	if err := p.APIReader.List(ctx, namespaceList); err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	c := p.Config.Load()
+	p.namespaces = []string{}
+	for i := range namespaceList.Items {
+		if !utils.IsNamespaceExcluded(c, &namespaceList.Items[i]) {
+			p.namespaces = append(p.namespaces, namespaceList.Items[i].GetName())
+		}
+	}
+	return p.namespaces, nil
+}
+
+// permissionList joins permissions into a human readable list
+func permissionList(permissions []Permission) string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// denyingClient answers SelfSubjectAccessReviews, denying the permissions in denied
+func denyingClient(denied ...Permission) client.Client {
+	return fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+			review := obj.(*authorizationv1.SelfSubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = true
+			for _, permission := range denied {
+				if permission.Group == attributes.Group && permission.Resource == attributes.Resource && permission.Verb == attributes.Verb {
+					review.Status.Allowed = false
+				}
+			}
+			return nil
+		},
+	}).Build()
+}
+
+// roleBindingClient answers SelfSubjectAccessReviews like a cluster, which grants the permissions in granted
+// only by RoleBindings in the namespaces, and every other permission cluster-wide
+func roleBindingClient(granted []Permission, namespaces ...string) client.Client {
+	objects := []client.Object{}
+	for _, namespace := range namespaces {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	}
+	return fake.NewClientBuilder().WithObjects(objects...).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+			review := obj.(*authorizationv1.SelfSubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = true
+			for _, permission := range granted {
+				if permission.Group == attributes.Group && permission.Resource == attributes.Resource && permission.Verb == attributes.Verb {
+					review.Status.Allowed = attributes.Namespace != ""
+				}
+			}
+			return nil
+		},
+	}).Build()
+}
+
+var _ = Describe("RBACPreflight", func() {
+	Context("When reviewing permissions", func() {
+		ctx := context.Background()
+
+		It("should keep every feature, if all permissions are granted", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:      imagePullSecretData,
+				FeatureDeletePods:     true,
+				FeaturePatchWorkloads: true,
+			})
+			preflight := &RBACPreflight{Client: denyingClient(), Config: config.NewStore(c)}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Config.Load().FeatureDeletePods).To(BeTrue())
+			Expect(preflight.Config.Load().FeaturePatchWorkloads).To(BeTrue())
+			Expect(preflight.Disabled()).To(BeEmpty())
+			Expect(preflight.Checker(nil)).To(Succeed())
+		})
+
+		It("should disable features lacking a permission", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:      imagePullSecretData,
+				FeatureDeletePods:     true,
+				FeaturePatchWorkloads: true,
+			})
+			preflight := &RBACPreflight{
+				Client: denyingClient(
+					Permission{Resource: "pods", Verb: "delete"},
+					Permission{Group: "batch", Resource: "cronjobs", Verb: "patch"},
+				),
+				Config: config.NewStore(c),
+			}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Config.Load().FeatureDeletePods).To(BeFalse())
+			Expect(preflight.Config.Load().FeaturePatchWorkloads).To(BeFalse())
+			Expect(preflight.Disabled()).To(ConsistOf("delete-pods", "patch-workloads"))
+			Expect(preflight.Checker(nil)).To(Succeed())
+			Expect(testutil.ToFloat64(metrics.PermissionMissing.WithLabelValues("pods", "delete"))).To(Equal(float64(1)))
+		})
+
+		It("should report permissions beyond the enabled features", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				FeatureSecretOnly: true,
+			})
+			preflight := &RBACPreflight{
+				Client: denyingClient(Permission{Resource: "pods", Verb: "delete"}),
+				Config: config.NewStore(c),
+			}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Excess()).To(ContainElement(Permission{Resource: "serviceaccounts", Verb: "patch"}))
+			Expect(preflight.Excess()).NotTo(ContainElement(Permission{Resource: "pods", Verb: "delete"}))
+			Expect(preflight.Excess()).NotTo(ContainElement(Permission{Resource: "secrets", Verb: "create"}))
+			Expect(testutil.ToFloat64(metrics.PermissionExcess.WithLabelValues("serviceaccounts", "patch"))).To(Equal(float64(1)))
+		})
+
+		It("should fail the readiness check, if a required permission is missing", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON: imagePullSecretData,
+			})
+			preflight := &RBACPreflight{
+				Client: denyingClient(Permission{Resource: "serviceaccounts", Verb: "patch"}),
+				Config: config.NewStore(c),
+			}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Checker(nil)).To(MatchError(ContainSubstring("patch serviceaccounts")))
+			Expect(testutil.ToFloat64(metrics.PermissionMissing.WithLabelValues("serviceaccounts", "patch"))).To(Equal(float64(1)))
+		})
+
+		It("should recognize permissions granted by RoleBindings in every managed namespace", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:   imagePullSecretData,
+				ExcludedNamespaces: "kube-*",
+				FeatureDeletePods:  true,
+			})
+			k8sClient := roleBindingClient([]Permission{{Resource: "pods", Verb: "delete"}}, "team-a", "team-b")
+			preflight := &RBACPreflight{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c)}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Config.Load().FeatureDeletePods).To(BeTrue())
+			Expect(preflight.Disabled()).To(BeEmpty())
+		})
+
+		It("should not recognize namespaced permissions without a reader for the namespaces", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				FeatureDeletePods: true,
+			})
+			preflight := &RBACPreflight{
+				Client: roleBindingClient([]Permission{{Resource: "pods", Verb: "delete"}}, "team-a"),
+				Config: config.NewStore(c),
+			}
+
+			Expect(preflight.Run(ctx)).To(Succeed())
+			Expect(preflight.Disabled()).To(ConsistOf("delete-pods"))
+		})
+	})
+})
@@ -19,52 +19,153 @@ package controller
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/teardown"
 	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+	"github.com/tamcore/imagepullsecret-patcher/internal/watchdog"
 )
 
 // SecretReconciler reconciles a Secret object
 type SecretReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Config *config.Config
+	Scheme   *runtime.Scheme
+	Config   *config.Store
+	Recorder record.EventRecorder
+	Notifier *notify.Notifier
+	// Watchdog supervises the watchers of the credentials, if set
+	Watchdog *watchdog.Watchdog
+	// Elected is closed, once this replica became the leader. Credentials served at DockerConfigJSONURL are
+	// polled by every replica, so standbys don't serve stale credentials, but only the leader resyncs.
+	// The replica is considered the leader, if unset.
+	Elected <-chan struct{}
+
+	resyncChannel chan event.GenericEvent
+
+	// pending holds the Secrets enqueued by the last resync, which are not reconciled yet
+	pendingMu sync.Mutex
+	pending   map[types.NamespacedName]struct{}
 }
 
+//+imagepullsecret-patcher:rbac:bundles=secret-only
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	if err == nil {
+		r.markSynced(req.NamespacedName)
+	}
+	return result, err
+}
+
+func (r *SecretReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	c := r.Config.Load()
 	log := log.FromContext(ctx)
 
+	if paused, err := utils.IsPaused(ctx, r.Client, c); err != nil {
+		return ctrl.Result{}, err
+	} else if paused {
+		if req.Name != c.SecretName || utils.IsSourceSecret(c, req.Name, req.Namespace) {
+			return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+		}
+		if drift, err := utils.HasImagePullSecretDrift(ctx, r.Client, c, req.Name, req.Namespace); err != nil {
+			return ctrl.Result{}, err
+		} else if drift {
+			utils.ReportDrift(ctx, "Secret", req.Namespace, req.Name)
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+	}
+
+	// The source Secret is only ever annotated as source of truth, never reconciled as target,
+	// even if it's named like the imagePullSecret
+	if utils.IsSourceSecret(c, req.Name, req.Namespace) {
+		return ctrl.Result{}, utils.MarkSourceSecret(ctx, r.Client, c)
+	}
+
+	// Copies of extra Secrets are reset to their source
+	if utils.IsExtraSecret(c, req.Name) {
+		result, err := utils.ReconcileExtraSecret(ctx, r.Client, c, req.Name, req.Namespace)
+		observeResult("Secret", result)
+		if err != nil {
+			eventlog.Record(eventlog.ActionError, req.Namespace, req.Name, err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Secrets of a previous SecretName are left to the OrphanedSecretCollector,
+	// which finds them by their label
+	if req.Name != c.SecretName {
+		return ctrl.Result{}, r.ensureManagedLabel(ctx, req)
+	}
+
+	// The imagePullSecret was deleted along with its last reference, it must not be recreated
+	if c.FeatureDeleteUnusedSecrets {
+		ns, err := utils.FetchNamespace(ctx, r.Client, req.Namespace)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to fetch namespace: %w", err)
+		}
+		if used, err := utils.IsImagePullSecretUsed(ctx, r.Client, c, ns); err != nil {
+			return ctrl.Result{}, err
+		} else if !used {
+			return ctrl.Result{}, nil
+		}
+	}
+
 	log.Info("Reconciling imagePullSecret in " + req.Namespace)
-	doPatch := false
-	if didPatch, err := utils.ReconcileImagePullSecret(ctx, r.Client, r.Config, req.NamespacedName.Name, req.NamespacedName.Namespace); err != nil {
+	result, err := utils.ReconcileImagePullSecret(ctx, r.Client, c, req.NamespacedName.Name, req.NamespacedName.Namespace)
+	observeResult("Secret", result)
+	r.Notifier.RecordResult(ctx, req.NamespacedName.Namespace, err)
+	utils.RecordSecretCondition(ctx, r.Client, c, req.Namespace, result, err)
+	utils.RecordNamespaceSync(ctx, r.Client, c, req.Namespace, err)
+	if err != nil {
+		eventlog.Record(eventlog.ActionError, req.Namespace, req.Name, err.Error())
+		if utils.IsOwnershipConflict(err) {
+			log.Info("imagePullSecret in namespace '" + req.Namespace + "' is managed by someone else, requeuing after " + ownershipConflictRequeueAfter.String())
+			return ctrl.Result{RequeueAfter: ownershipConflictRequeueAfter}, nil
+		}
 		return ctrl.Result{}, fmt.Errorf("Failed to reconcile imagePullSecret in Namespace '"+req.NamespacedName.Namespace+"': %w", err)
-	} else {
-		doPatch = didPatch
 	}
 
-	if doPatch && r.Config.FeatureDeletePods {
-		if err := utils.CleanupPodsForNamespace(ctx, r.Config, r.Client, req.NamespacedName.Namespace); err != nil {
+	var requeueAfter time.Duration
+	if (result.Changed() || utils.IsPodCleanupPending(req.Namespace, "")) && c.FeatureDeletePods {
+		requeueAfter, err = utils.CleanupPodsForNamespace(ctx, c, r.Client, r.Recorder, req.NamespacedName.Namespace)
+		if requeueAfter == 0 {
+			utils.RecordNamespaceCondition(ctx, r.Client, c, req.Namespace, imagepullsecretv1alpha1.ConditionPodsCleaned, err)
+		}
+		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("Failed to cleanup Pods in unauthorized state: %w", err)
 		}
 	}
 
-	return ctrl.Result{}, nil
-}
+	// Extra Secrets follow the imagePullSecret into the namespace
+	if err := utils.ReconcileExtraSecrets(ctx, r.Client, c, req.Namespace); err != nil {
+		eventlog.Record(eventlog.ActionError, req.Namespace, req.Name, err.Error())
+		return ctrl.Result{}, err
+	}
 
-func secretToObject(secret *corev1.Secret) client.Object {
-	return secret
+	// A delayed Pod cleanup runs, once the Secret is requeued
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -73,6 +174,7 @@ func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	builder := ctrl.NewControllerManagedBy(mgr).
 		Named("SecretController").
+		WithOptions(controllerOptions(r.Config.Load())).
 		For(&corev1.Secret{}).
 		WithEventFilter(predicate.Funcs{
 			CreateFunc: func(e event.CreateEvent) bool {
@@ -80,23 +182,28 @@ func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				if err != nil {
 					return false
 				}
-				return utils.IsManagedSecret(r.Config, ns, e.Object)
+				return utils.IsManagedSecret(r.Config.Load(), ns, e.Object)
 			},
 			UpdateFunc: func(e event.UpdateEvent) bool {
+				// Skip the events caused by our own writes, instead of comparing the Secret once more
+				if utils.IsOwnWrite(e.ObjectNew) {
+					return false
+				}
 				ns, err := utils.FetchNamespace(ctx, r.Client, e.ObjectNew.GetNamespace())
 				if err != nil {
 					return false
 				}
-				return utils.IsManagedSecret(r.Config, ns, e.ObjectNew)
+				return utils.IsManagedSecret(r.Config.Load(), ns, e.ObjectNew)
 			},
 			GenericFunc: func(e event.GenericEvent) bool {
 				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
 				if err != nil {
 					return false
 				}
-				return utils.IsManagedSecret(r.Config, ns, e.Object)
+				return utils.IsManagedSecret(r.Config.Load(), ns, e.Object)
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool {
+				utils.ForgetOwnWrite(e.Object)
 				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
 				if err != nil {
 					return false
@@ -105,48 +212,366 @@ func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 					return false
 				}
 
-				return utils.IsManagedSecret(r.Config, ns, e.Object)
+				return utils.IsManagedSecret(r.Config.Load(), ns, e.Object)
 			},
-		})
-
-	// If DockerConfigJSONPath is defined
-	if r.Config.DockerConfigJSONPath != "" && r.Config.FeatureWatchDockerConfigJSONPath {
-		// Create a GenericEvent channel, to pass reconcile events to the controller
-		secretRconciliationSourceChannel := make(chan event.GenericEvent)
-
-		// Set up a goroutine, which does a basic polling watch on DockerConfigJSONPath
-		go func() {
-			ctx := context.TODO()
-			log.FromContext(ctx).Info("setting up watcher")
-
-			for {
-				// Wait, until DockerConfigJSONPath has changed
-				utils.WaitUntilFileChanges(r.Config.DockerConfigJSONPath)
-
-				// Fetch all Secrets
-				secretList := &corev1.SecretList{}
-				if err := r.Client.List(ctx, secretList); err != nil {
-					log.FromContext(ctx).Error(err, "error listing secrets")
-				}
+		}).
+		WithEventFilter(ignoreOwnChanges)
 
-				for _, d := range secretList.Items {
-					ns, err := utils.FetchNamespace(ctx, r.Client, d.GetNamespace())
-					if err != nil {
-						log.FromContext(ctx).Error(err, "error fetching namespace")
-						continue
-					}
-					// Filter for Secrets that are actually managed
-					if utils.IsManagedSecret(r.Config, ns, secretToObject(&d)) {
-						// Send reconcile event for fetched Secret
-						secretRconciliationSourceChannel <- event.GenericEvent{Object: &d}
-					}
-				}
+	// Create a GenericEvent channel, to pass reconcile events to the controller
+	r.resyncChannel = make(chan event.GenericEvent)
+
+	// If DockerConfigJSONPath is defined, add a basic polling watch on it to the manager,
+	// so it's stopped together with the controllers on shutdown
+	if r.Config.Load().DockerConfigJSONPath != "" && r.Config.Load().FeatureWatchDockerConfigJSONPath {
+		if err := mgr.Add(r.Watchdog.Runnable("dockerconfigjson-path-watcher", r.Config.Load().WatchdogTimeout, r.watchDockerConfigJSONPath)); err != nil {
+			return err
+		}
+	}
+
+	// If credentials are exchanged via OIDC, resync all managed Secrets before they expire
+	if r.Config.Load().OIDCTokenEndpoint != "" {
+		if err := mgr.Add(r.Watchdog.Runnable("oidc-refresher", r.Config.Load().WatchdogTimeout, r.refreshOIDCCredentials)); err != nil {
+			return err
+		}
+	}
+
+	// If credentials are served over HTTP(S), poll them and resync all managed Secrets on changes
+	if r.Config.Load().DockerConfigJSONURL != "" {
+		poller := r.Watchdog.Runnable("dockerconfigjson-url-poller", r.Config.Load().DockerConfigJSONURLInterval+r.Config.Load().WatchdogTimeout, r.pollDockerConfigJSONURL)
+		if err := mgr.Add(everyReplica{poller}); err != nil {
+			return err
+		}
+	}
+
+	// Namespaces annotated for recreation enqueue their imagePullSecret
+	builder = builder.WatchesRawSource(source.Kind(mgr.GetCache(), &corev1.Namespace{},
+		handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, ns *corev1.Namespace) []reconcile.Request {
+			if utils.IsNamespaceExcluded(r.Config.Load(), ns) || !utils.HasAnnotation(ns, r.Config.Load().AnnotationRecreate, "true") {
+				return nil
 			}
-		}()
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: r.Config.Load().SecretName, Namespace: ns.GetName()}}}
+		}),
+	))
+
+	// Changes of the source Secret enqueue all managed Secrets
+	if r.Config.Load().SourceSecret != "" {
+		builder = builder.WatchesRawSource(source.Kind(mgr.GetCache(), &corev1.Secret{},
+			handler.TypedEnqueueRequestsFromMapFunc(r.fanOutSourceSecret),
+			predicate.TypedFuncs[*corev1.Secret]{
+				CreateFunc: func(e event.TypedCreateEvent[*corev1.Secret]) bool {
+					return false
+				},
+				UpdateFunc: func(e event.TypedUpdateEvent[*corev1.Secret]) bool {
+					return r.isSourceSecret(e.ObjectNew) && !reflect.DeepEqual(e.ObjectOld.Data, e.ObjectNew.Data)
+				},
+				GenericFunc: func(e event.TypedGenericEvent[*corev1.Secret]) bool {
+					return false
+				},
+				DeleteFunc: func(e event.TypedDeleteEvent[*corev1.Secret]) bool {
+					return false
+				},
+			},
+		))
 
-		// Attach channel event source to controller
-		builder = builder.WatchesRawSource(source.Channel(secretRconciliationSourceChannel, &handler.EnqueueRequestForObject{}))
+		// The source Secret itself is enqueued until it carries the source annotation
+		builder = builder.WatchesRawSource(source.Kind(mgr.GetCache(), &corev1.Secret{},
+			&handler.TypedEnqueueRequestForObject[*corev1.Secret]{},
+			predicate.TypedFuncs[*corev1.Secret]{
+				CreateFunc: func(e event.TypedCreateEvent[*corev1.Secret]) bool {
+					return r.isUnmarkedSourceSecret(e.Object)
+				},
+				UpdateFunc: func(e event.TypedUpdateEvent[*corev1.Secret]) bool {
+					return r.isUnmarkedSourceSecret(e.ObjectNew)
+				},
+				GenericFunc: func(e event.TypedGenericEvent[*corev1.Secret]) bool {
+					return false
+				},
+				DeleteFunc: func(e event.TypedDeleteEvent[*corev1.Secret]) bool {
+					return false
+				},
+			},
+		))
+	}
+
+	// Changes of the source of an extra Secret enqueue its copies in all namespaces with a managed imagePullSecret
+	if r.Config.Load().ExtraSecrets != "" {
+		builder = builder.WatchesRawSource(source.Kind(mgr.GetCache(), &corev1.Secret{},
+			handler.TypedEnqueueRequestsFromMapFunc(r.fanOutExtraSecret),
+			predicate.TypedFuncs[*corev1.Secret]{
+				CreateFunc: func(e event.TypedCreateEvent[*corev1.Secret]) bool {
+					return r.isExtraSourceSecret(e.Object)
+				},
+				UpdateFunc: func(e event.TypedUpdateEvent[*corev1.Secret]) bool {
+					return r.isExtraSourceSecret(e.ObjectNew) && !reflect.DeepEqual(e.ObjectOld.Data, e.ObjectNew.Data)
+				},
+				GenericFunc: func(e event.TypedGenericEvent[*corev1.Secret]) bool {
+					return false
+				},
+				DeleteFunc: func(e event.TypedDeleteEvent[*corev1.Secret]) bool {
+					return false
+				},
+			},
+		))
 	}
 
-	return builder.Complete(r)
+	// Attach channel event source to controller
+	builder = builder.WatchesRawSource(source.Channel(r.resyncChannel, &handler.EnqueueRequestForObject{}))
+
+	return builder.Complete(teardown.Default.Reconciler(r))
+}
+
+// watchDockerConfigJSONPath resyncs all managed Secrets whenever DockerConfigJSONPath changes,
+// until ctx is cancelled or the file can't be watched anymore
+func (r *SecretReconciler) watchDockerConfigJSONPath(ctx context.Context) error {
+	log.FromContext(ctx).Info("setting up watcher")
+
+	for {
+		// Wait, until DockerConfigJSONPath has changed
+		modTime, err := utils.WaitUntilFileChanges(ctx, r.Config.Load().DockerConfigJSONPath)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.FromContext(ctx).Info("stopping watcher")
+				return nil
+			}
+			// Without the watcher, credentials would never be rotated again, so fail loudly to restart the controller
+			log.FromContext(ctx).Error(err, "error watching file", "path", r.Config.Load().DockerConfigJSONPath)
+			return fmt.Errorf("error watching %s: %w", r.Config.Load().DockerConfigJSONPath, err)
+		}
+		metrics.MarkCredentialsChanged(modTime)
+
+		if err := r.Resync(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "error resyncing secrets")
+		}
+	}
+}
+
+// refreshOIDCCredentials refreshes the credentials exchanged via OIDC, whenever they're due,
+// and resyncs all managed Secrets with them, until ctx is cancelled
+func (r *SecretReconciler) refreshOIDCCredentials(ctx context.Context) error {
+	for {
+		// Until the first exchange happened, or after a failed refresh, check back every minute.
+		// The wait is capped, to keep sending heartbeats to the watchdog.
+		wait := time.Minute
+		if untilRefresh := time.Until(utils.OIDCRefreshAt()); untilRefresh > 0 && untilRefresh < wait {
+			wait = untilRefresh
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil
+		}
+		watchdog.Beat(ctx)
+
+		if refreshAt := utils.OIDCRefreshAt(); refreshAt.IsZero() || time.Now().Before(refreshAt) {
+			continue
+		}
+		// Refresh once, before all namespaces are reconciled with the new credentials
+		if _, err := utils.GetDockerConfigJSONFromOIDC(ctx, r.Config.Load()); err != nil {
+			log.FromContext(ctx).Error(err, "error refreshing OIDC credentials")
+			continue
+		}
+		if err := r.Resync(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "error resyncing secrets")
+		}
+	}
+}
+
+// pollDockerConfigJSONURL fetches DockerConfigJSONURL every DockerConfigJSONURLInterval, and resyncs all
+// managed Secrets, whenever the credentials changed, until ctx is cancelled
+func (r *SecretReconciler) pollDockerConfigJSONURL(ctx context.Context) error {
+	ticker := time.NewTicker(r.Config.Load().DockerConfigJSONURLInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+		watchdog.Beat(ctx)
+
+		c := r.Config.Load()
+		changed, err := utils.RefreshDockerConfigJSONFromURL(ctx, c)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "error fetching credentials", "url", c.DockerConfigJSONURL)
+			continue
+		}
+		// Standbys only keep their credentials up to date, the new leader reconciles all Secrets anyway
+		if !changed || !r.isLeader() {
+			continue
+		}
+		metrics.MarkCredentialsChanged(time.Now())
+		if err := r.Resync(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "error resyncing secrets")
+		}
+	}
+}
+
+// isLeader reports, whether this replica became the leader
+func (r *SecretReconciler) isLeader() bool {
+	if r.Elected == nil {
+		return true
+	}
+	select {
+	case <-r.Elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// everyReplica runs a Runnable on every replica, instead of only on the leader
+type everyReplica struct {
+	manager.Runnable
+}
+
+// NeedLeaderElection runs the Runnable without waiting for the leadership
+func (everyReplica) NeedLeaderElection() bool {
+	return false
+}
+
+// Resync enqueues all managed Secrets for reconciliation
+func (r *SecretReconciler) Resync(ctx context.Context) error {
+	if r.resyncChannel == nil {
+		return fmt.Errorf("SecretController is not set up")
+	}
+
+	secrets, err := r.managedSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	r.markPending(secrets)
+
+	for i := range secrets {
+		// Send reconcile event for fetched Secret
+		select {
+		case r.resyncChannel <- event.GenericEvent{Object: &secrets[i]}:
+		case <-ctx.Done():
+			// Leave a record of the Secrets, which still carry the previous credentials
+			message := fmt.Sprintf("resync interrupted after %d of %d Secrets", i, len(secrets))
+			log.FromContext(ctx).Info(message)
+			eventlog.Record(eventlog.ActionError, "", "", message)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// managedSecrets lists all Secrets managed by the controller
+func (r *SecretReconciler) managedSecrets(ctx context.Context) ([]corev1.Secret, error) {
+	secretList := &corev1.SecretList{}
+	if err := r.Client.List(ctx, secretList, utils.ManagedSecretsSelector); err != nil {
+		return nil, fmt.Errorf("error listing secrets: %w", err)
+	}
+
+	secrets := []corev1.Secret{}
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		ns, err := utils.FetchNamespace(ctx, r.Client, secret.GetNamespace())
+		if err != nil {
+			log.FromContext(ctx).Error(err, "error fetching namespace")
+			continue
+		}
+		// Filter for Secrets that are actually managed
+		if utils.IsManagedSecret(r.Config.Load(), ns, secret) {
+			secrets = append(secrets, *secret)
+		}
+	}
+	return secrets, nil
+}
+
+// isSourceSecret checks whether secret is the source Secret holding the credentials
+func (r *SecretReconciler) isSourceSecret(secret *corev1.Secret) bool {
+	return utils.IsSourceSecret(r.Config.Load(), secret.GetName(), secret.GetNamespace())
+}
+
+// isUnmarkedSourceSecret checks whether secret is the source Secret, but lacks the source annotation
+func (r *SecretReconciler) isUnmarkedSourceSecret(secret *corev1.Secret) bool {
+	return r.isSourceSecret(secret) && !utils.HasAnnotation(secret, r.Config.Load().AnnotationSource, "true")
+}
+
+// fanOutSourceSecret enqueues all managed Secrets, after the source Secret changed
+func (r *SecretReconciler) fanOutSourceSecret(ctx context.Context, _ *corev1.Secret) []reconcile.Request {
+	metrics.SourceSecretChangesTotal.Inc()
+	metrics.MarkCredentialsChanged(time.Now())
+
+	secrets, err := r.managedSecrets(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "error fanning out source Secret")
+		return nil
+	}
+	r.markPending(secrets)
+	log.FromContext(ctx).Info(fmt.Sprintf("Source Secret changed, enqueuing %d managed Secrets", len(secrets)))
+
+	requests := make([]reconcile.Request, 0, len(secrets))
+	for i := range secrets {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&secrets[i])})
+	}
+	return requests
+}
+
+// isExtraSourceSecret checks whether secret is the source of one of the extra Secrets
+func (r *SecretReconciler) isExtraSourceSecret(secret *corev1.Secret) bool {
+	return utils.IsExtraSourceSecret(r.Config.Load(), secret.GetName(), secret.GetNamespace())
+}
+
+// fanOutExtraSecret enqueues the copies of the extra Secret source in all namespaces with a managed imagePullSecret
+func (r *SecretReconciler) fanOutExtraSecret(ctx context.Context, source *corev1.Secret) []reconcile.Request {
+	secrets, err := r.managedSecrets(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "error fanning out extra Secret")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range secrets {
+		if secrets[i].GetName() != r.Config.Load().SecretName {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: source.GetName(), Namespace: secrets[i].GetNamespace()}})
+	}
+	log.FromContext(ctx).Info(fmt.Sprintf("Extra Secret '%s' changed, enqueuing %d copies", source.GetName(), len(requests)))
+	return requests
+}
+
+// markPending tracks secrets as enqueued by a resync, until they're reconciled
+func (r *SecretReconciler) markPending(secrets []corev1.Secret) {
+	r.pendingMu.Lock()
+	defer r.pendingMu.Unlock()
+
+	r.pending = make(map[types.NamespacedName]struct{}, len(secrets))
+	for i := range secrets {
+		r.pending[client.ObjectKeyFromObject(&secrets[i])] = struct{}{}
+	}
+	metrics.ResyncPendingSecrets.Set(float64(len(r.pending)))
+}
+
+// markSynced marks the Secret name as reconciled
+func (r *SecretReconciler) markSynced(name types.NamespacedName) {
+	r.pendingMu.Lock()
+	defer r.pendingMu.Unlock()
+
+	if _, ok := r.pending[name]; ok {
+		delete(r.pending, name)
+		metrics.ResyncPendingSecrets.Set(float64(len(r.pending)))
+	}
+}
+
+// ensureManagedLabel adds the managed-by label to Secrets, that were created before
+// managed Secrets were labeled
+func (r *SecretReconciler) ensureManagedLabel(ctx context.Context, req ctrl.Request) error {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, req.NamespacedName, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if utils.HasLabel(secret, config.AnnotationManagedBy, config.AnnotationAppName) {
+		return nil
+	}
+
+	patchFrom := client.MergeFrom(secret.DeepCopy())
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[config.AnnotationManagedBy] = config.AnnotationAppName
+	return r.Patch(ctx, secret, patchFrom)
 }
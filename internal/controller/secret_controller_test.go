@@ -17,7 +17,16 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var _ = Describe("Secret Controller", func() {
@@ -29,4 +38,123 @@ var _ = Describe("Secret Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When the source Secret changes", func() {
+		ctx := context.Background()
+
+		It("should enqueue all managed Secrets and track them until they're reconciled", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				SourceSecret:    "source-imagepullsecret",
+				SecretNamespace: "testns-source-0",
+			})
+			source := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: c.SourceSecret, Namespace: c.SecretNamespace},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(imagePullSecretData)},
+			}
+			Expect(k8sClient.Create(ctx, source)).Should(Succeed())
+
+			requests := []reconcile.Request{}
+			for _, name := range []string{"testns-source-1", "testns-source-2"} {
+				namespace, _, _, secretNN := makeObjects(name, "default", c.SecretName)
+				Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+				secret, err := utils.ConstructImagePullSecret(ctx, k8sClient, c, name)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+				requests = append(requests, reconcile.Request{NamespacedName: secretNN})
+			}
+
+			secretReconciler := &SecretReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(c),
+			}
+			Expect(secretReconciler.isSourceSecret(source)).To(BeTrue())
+			Expect(secretReconciler.fanOutSourceSecret(ctx, source)).To(ContainElements(requests))
+			Expect(secretReconciler.pending).To(HaveKey(requests[0].NamespacedName))
+
+			_, err := secretReconciler.Reconcile(ctx, requests[0])
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(secretReconciler.pending).To(Not(HaveKey(requests[0].NamespacedName)))
+			Expect(secretReconciler.pending).To(HaveKey(requests[1].NamespacedName))
+		})
+	})
+
+	Context("When the source Secret is named like the imagePullSecret", func() {
+		ctx := context.Background()
+
+		It("should mark the source and never overwrite it while rotating the credentials", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				SecretName:              "rotation-imagepullsecret",
+				SourceSecret:            "rotation-imagepullsecret",
+				SecretNamespace:         "testns-rotation-0",
+				OperatorNamespacePolicy: config.OperatorNamespacePolicyInclude,
+			})
+			sourceNamespace, _, _, sourceNN := makeObjects(c.SecretNamespace, "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, sourceNamespace.DeepCopy())).Should(Succeed())
+			// Copied over by a previous configuration, so it carries the managed-by label
+			source := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      sourceNN.Name,
+					Namespace: sourceNN.Namespace,
+					Labels:    map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(imagePullSecretData)},
+			}
+			Expect(k8sClient.Create(ctx, source)).Should(Succeed())
+
+			targetNamespace, _, _, targetNN := makeObjects("testns-rotation-1", "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, targetNamespace.DeepCopy())).Should(Succeed())
+
+			secretReconciler := &SecretReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: config.NewStore(c),
+			}
+			Expect(secretReconciler.isUnmarkedSourceSecret(source)).To(BeTrue())
+			_, err := secretReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sourceNN})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, sourceNN, source)).Should(Succeed())
+			Expect(source.GetAnnotations()).To(HaveKeyWithValue(c.AnnotationSource, "true"))
+			Expect(secretReconciler.isUnmarkedSourceSecret(source)).To(BeFalse())
+
+			_, err = secretReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: targetNN})
+			Expect(err).To(Not(HaveOccurred()))
+			target := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, targetNN, target)).Should(Succeed())
+			Expect(target.GetAnnotations()).To(Not(HaveKey(c.AnnotationSource)))
+
+			// Rotating the source fans out to the copies only, so it's never reconciled as its own target
+			source.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"YmF6OnF1eA=="}}}`)}
+			Expect(k8sClient.Update(ctx, source)).Should(Succeed())
+			requests := secretReconciler.fanOutSourceSecret(ctx, source)
+			Expect(requests).To(ContainElement(reconcile.Request{NamespacedName: targetNN}))
+			Expect(requests).To(Not(ContainElement(reconcile.Request{NamespacedName: sourceNN})))
+
+			_, err = secretReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sourceNN})
+			Expect(err).To(Not(HaveOccurred()))
+			_, err = secretReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: targetNN})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, sourceNN, source)).Should(Succeed())
+			Expect(k8sClient.Get(ctx, targetNN, target)).Should(Succeed())
+			Expect(target.Data).To(Equal(source.Data))
+		})
+	})
+
+	Context("When watching DockerConfigJSONPath", func() {
+		It("should only stop silently, once it's cancelled", func() {
+			c := config.NewConfig(config.ConfigOptions{DockerConfigJSONPath: "/etc/dockerconfig.json", SecretNamespace: "kube-system"})
+			secretReconciler := &SecretReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: config.NewStore(c)}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(secretReconciler.watchDockerConfigJSONPath(ctx)).To(Succeed())
+
+			// A path, which can't be watched, would stop the rotation of the credentials for good
+			c = config.NewConfig(config.ConfigOptions{DockerConfigJSONPath: "/etc/dockerconfig.json", SecretNamespace: "kube-system"})
+			c.DockerConfigJSONPath = `"/etc/dockerconfig.json"`
+			secretReconciler.Config = config.NewStore(c)
+			Expect(secretReconciler.watchDockerConfigJSONPath(context.Background())).To(HaveOccurred())
+		})
+	})
 })
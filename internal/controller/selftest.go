@@ -0,0 +1,230 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+const (
+	// selfTestServiceAccount is the ServiceAccount patched during the self-test
+	selfTestServiceAccount = "imagepullsecret-patcher-self-test"
+	// selfTestTimeout is the time the self-test may take, before it's considered failed
+	selfTestTimeout = time.Minute
+	// selfTestCleanupTimeout is the time the cleanup may take, even if the self-test timed out
+	selfTestCleanupTimeout = 30 * time.Second
+)
+
+// errSelfTestRunning is reported by the readiness check, until the self-test finished
+var errSelfTestRunning = errors.New("self-test is still running")
+
+// SelfTest reconciles the imagePullSecret and a ServiceAccount in the canary namespace
+// SelfTestNamespace, once the controller became the leader, so broken RBAC or config is
+// detected right after a deployment
+type SelfTest struct {
+	client.Client
+	// APIReader reads the results, bypassing the cache
+	APIReader client.Reader
+	Config    *config.Store
+	// Elected is closed, once the controller became the leader. Until then, the readiness check passes,
+	// so replicas waiting for the lease don't block a rollout.
+	Elected <-chan struct{}
+
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;update;patch
+//+imagepullsecret-patcher:rbac:optin=self-test,groups=core,resources=serviceaccounts,verbs=create;delete
+//+imagepullsecret-patcher:rbac:optin=self-test-namespace,groups=core,resources=namespaces,verbs=create;delete
+
+// NeedLeaderElection ensures only the leader writes to the canary namespace, so replicas
+// never delete the ServiceAccount or namespace while another one is still verifying them
+func (s *SelfTest) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs the self-test once and reports its result via the readiness check and metric
+func (s *SelfTest) Start(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	namespace := s.Config.Load().SelfTestNamespace
+
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	err := s.Run(ctx)
+	s.mu.Lock()
+	s.done = true
+	s.err = err
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Error(err, "Self-test in namespace '"+namespace+"' failed")
+		eventlog.Record(eventlog.ActionError, namespace, "", "self-test failed: "+err.Error())
+		metrics.SelfTestPassed.Set(0)
+		return nil
+	}
+	log.Info("Self-test in namespace '" + namespace + "' passed")
+	metrics.SelfTestPassed.Set(1)
+	return nil
+}
+
+// Checker is a readiness check, which fails from the election of the controller until the self-test passed
+func (s *SelfTest) Checker(_ *http.Request) error {
+	select {
+	case <-s.Elected:
+	default:
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.done {
+		return errSelfTestRunning
+	}
+	return s.err
+}
+
+// Run creates the imagePullSecret and attaches it to a ServiceAccount in the canary namespace,
+// and verifies the result. The ServiceAccount and an imagePullSecret created by the self-test are deleted
+// afterwards. The namespace is created and deleted, if FeatureSelfTestCreateNamespace is set.
+func (s *SelfTest) Run(ctx context.Context) error {
+	c := s.Config.Load()
+	namespace := c.SelfTestNamespace
+
+	ns := &corev1.Namespace{}
+	err := s.APIReader.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	switch {
+	case apierrs.IsNotFound(err) && c.FeatureSelfTestCreateNamespace:
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+				Labels: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+				},
+			},
+		}
+		if err := s.Create(ctx, ns); err != nil {
+			return fmt.Errorf("failed to create namespace '%s': %w", namespace, err)
+		}
+		defer s.cleanup(ctx, ns)
+	case err != nil:
+		return fmt.Errorf("failed to fetch namespace '%s': %w", namespace, err)
+	}
+
+	result, err := utils.ReconcileImagePullSecret(ctx, s.Client, c, c.SecretName, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile imagePullSecret: %w", err)
+	}
+	if result == utils.ResultCreated {
+		defer s.cleanup(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: c.SecretName, Namespace: namespace}})
+	}
+
+	// ServiceAccounts are left to another system in secret-only mode
+	if c.FeatureSecretOnly {
+		return s.verifySecret(ctx, namespace)
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      selfTestServiceAccount,
+			Namespace: namespace,
+		},
+	}
+	if err := s.Create(ctx, serviceAccount); err != nil && !apierrs.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create ServiceAccount: %w", err)
+	}
+	defer s.cleanup(ctx, serviceAccount)
+
+	if err := s.APIReader.Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount); err != nil {
+		return fmt.Errorf("failed to fetch ServiceAccount: %w", err)
+	}
+	serviceAccountReconciler := &ServiceAccountReconciler{Client: s.Client, Config: s.Config}
+	if _, err := serviceAccountReconciler.attachImagePullSecret(ctx, serviceAccount); err != nil {
+		return err
+	}
+
+	return s.verify(ctx, serviceAccount)
+}
+
+// verify ensures the imagePullSecret holds the expected credentials and is attached to serviceAccount
+func (s *SelfTest) verify(ctx context.Context, serviceAccount *corev1.ServiceAccount) error {
+	if err := s.verifySecret(ctx, serviceAccount.GetNamespace()); err != nil {
+		return err
+	}
+
+	if err := s.APIReader.Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount); err != nil {
+		return fmt.Errorf("failed to fetch ServiceAccount: %w", err)
+	}
+	if !utils.HasImagePullSecret(serviceAccount, s.Config.Load().SecretName) {
+		return errors.New("imagePullSecret is not attached to ServiceAccount '" + serviceAccount.GetName() + "'")
+	}
+	return nil
+}
+
+// verifySecret ensures the imagePullSecret in namespace exists and holds the expected credentials
+func (s *SelfTest) verifySecret(ctx context.Context, namespace string) error {
+	c := s.Config.Load()
+	secret := &corev1.Secret{}
+	if err := s.APIReader.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: namespace}, secret); err != nil {
+		return fmt.Errorf("failed to fetch imagePullSecret: %w", err)
+	}
+	// The imagePullSecret is provisioned externally in ServiceAccount-only mode
+	if c.FeatureServiceAccountOnly {
+		return nil
+	}
+	desiredSecret, err := utils.ConstructImagePullSecret(ctx, s.Client, c, namespace)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(secret.Data, desiredSecret.Data) {
+		return errors.New("imagePullSecret does not hold the expected credentials")
+	}
+	return nil
+}
+
+// cleanup deletes obj, which was created for the self-test. It's not bound to the deadline of
+// the self-test, so a self-test, which timed out, doesn't leave the canary objects behind.
+func (s *SelfTest) cleanup(ctx context.Context, obj client.Object) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), selfTestCleanupTimeout)
+	defer cancel()
+
+	if err := s.Delete(ctx, obj); err != nil && !apierrs.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Failed to clean up after self-test")
+	}
+}
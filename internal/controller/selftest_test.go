@@ -0,0 +1,119 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("SelfTest", func() {
+	Context("When running the self-test", func() {
+		ctx := context.Background()
+		elected := make(chan struct{})
+		close(elected)
+
+		It("should pass in an existing canary namespace and clean up its ServiceAccount and imagePullSecret", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				SelfTestNamespace: "testns-selftest-1",
+			})
+			namespace, _, _, secretNN := makeObjects(c.SelfTestNamespace, "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			selfTest := &SelfTest{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c), Elected: elected}
+			Expect(selfTest.Checker(nil)).Should(MatchError(errSelfTestRunning))
+			Expect(selfTest.Start(ctx)).Should(Succeed())
+			Expect(selfTest.Checker(nil)).Should(Succeed())
+
+			err := k8sClient.Get(ctx, secretNN, &corev1.Secret{})
+			Expect(apierrs.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: selfTestServiceAccount, Namespace: c.SelfTestNamespace}, &corev1.ServiceAccount{})
+			Expect(apierrs.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should fail, if the canary namespace does not exist", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				SelfTestNamespace: "testns-selftest-2",
+			})
+
+			selfTest := &SelfTest{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c), Elected: elected}
+			Expect(selfTest.Start(ctx)).Should(Succeed())
+			Expect(selfTest.Checker(nil)).ShouldNot(Succeed())
+		})
+
+		It("should report ready, while waiting for the lease", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				SelfTestNamespace: "testns-selftest-standby",
+			})
+
+			selfTest := &SelfTest{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c), Elected: make(chan struct{})}
+			Expect(selfTest.NeedLeaderElection()).Should(BeTrue())
+			Expect(selfTest.Checker(nil)).Should(Succeed())
+		})
+
+		It("should clean up, even if the self-test timed out", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				SelfTestNamespace: "testns-selftest-4",
+			})
+			namespace, _, _, _ := makeObjects(c.SelfTestNamespace, "default", c.SecretName)
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+			serviceAccount := &corev1.ServiceAccount{}
+			serviceAccount.SetName(selfTestServiceAccount)
+			serviceAccount.SetNamespace(c.SelfTestNamespace)
+			Expect(k8sClient.Create(ctx, serviceAccount)).Should(Succeed())
+
+			timedOut, cancel := context.WithCancel(ctx)
+			cancel()
+			selfTest := &SelfTest{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c)}
+			selfTest.cleanup(timedOut, serviceAccount)
+
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(serviceAccount), &corev1.ServiceAccount{})
+			Expect(apierrs.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("should create and delete the canary namespace, if requested", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:               imagePullSecretData,
+				SecretNamespace:                "kube-system",
+				SelfTestNamespace:              "testns-selftest-3",
+				FeatureSelfTestCreateNamespace: true,
+			})
+
+			selfTest := &SelfTest{Client: k8sClient, APIReader: k8sClient, Config: config.NewStore(c)}
+			Expect(selfTest.Run(ctx)).Should(Succeed())
+
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SelfTestNamespace}, &corev1.Namespace{})
+			Expect(apierrs.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
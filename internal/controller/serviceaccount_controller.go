@@ -18,129 +18,659 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/conflict"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/fairness"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/teardown"
 	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
 )
 
+// defaultServiceAccountRequeueAfter is the interval in which namespaces are checked
+// for their default ServiceAccount, if RequireDefaultServiceAccount is set
+const defaultServiceAccountRequeueAfter = 5 * time.Second
+
+const (
+	// newServiceAccountWindow is the age up to which ServiceAccounts are considered new
+	newServiceAccountWindow = time.Minute
+	// newServiceAccountRequeueAfter is the interval in which Pods of new ServiceAccounts are cleaned up
+	newServiceAccountRequeueAfter = 10 * time.Second
+	// secretQuotaRequeueAfter is the interval in which namespaces with an exhausted ResourceQuota for Secrets are retried
+	secretQuotaRequeueAfter = time.Minute
+	// ownershipConflictRequeueAfter is the interval in which imagePullSecrets claimed by a GitOps tool or owned by
+	// another Secret provider are re-checked with the alert policy, instead of retrying them with backoff
+	ownershipConflictRequeueAfter = 10 * time.Minute
+	// pausedRequeueAfter is the interval in which drift is re-checked, while mutations are paused
+	pausedRequeueAfter = time.Minute
+)
+
 // ServiceAccountReconciler reconciles a ServiceAccount object
 type ServiceAccountReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Config *config.Config
+	Scheme   *runtime.Scheme
+	Config   *config.Store
+	Recorder record.EventRecorder
+	Notifier *notify.Notifier
+	// Breaker defers the periodic reconciles of existing ServiceAccounts during API server incidents,
+	// while new ServiceAccounts are still patched right away
+	Breaker *breaker.Breaker
+	// Sweep reconciles the ServiceAccounts existing at startup instead of their create events, if set
+	Sweep *InitialSweep
+	// APIReader re-reads ServiceAccounts after a conflict, as the cache may not have caught up with the
+	// conflicting write yet. The client is used, if unset.
+	APIReader client.Reader
+
+	// currentSecrets holds the credential hash, the imagePullSecret of a namespace was last reconciled with, by namespace name
+	currentSecretsMu sync.Mutex
+	currentSecrets   map[string]currentSecret
+}
+
+// currentSecret is the credential hash, the imagePullSecret of the namespace with uid was last reconciled with.
+// The UID tells apart a namespace recreated with the same name.
+type currentSecret struct {
+	uid  types.UID
+	hash string
 }
 
+//+imagepullsecret-patcher:rbac:bundles=serviceaccount-only
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;update;patch
+//+imagepullsecret-patcher:rbac:bundles=serviceaccount-only
 //+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
-//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+imagepullsecret-patcher:rbac:bundles=serviceaccount-only
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=create;update;patch;delete
 
 func (r *ServiceAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
+	// Requests without a name stand for all ServiceAccounts of the namespace
+	if req.Name == "" {
+		return r.reconcileNamespace(ctx, req.Namespace)
+	}
 
 	serviceAccount := &corev1.ServiceAccount{}
 	err := r.Get(ctx, req.NamespacedName, serviceAccount)
 	if err != nil {
 		// Error reading the object - requeue the request.
-		log.Error(err, "Failed to get ServiceAccount")
+		log.FromContext(ctx).Error(err, "Failed to get ServiceAccount")
+		return ctrl.Result{}, err
+	}
+	batch, err := r.newServiceAccountBatch(ctx, req.Namespace)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	result, err := r.reconcileServiceAccount(ctx, serviceAccount, batch)
+	r.recordSecretResult(ctx, req.Namespace, batch)
+	return result, err
+}
 
-	// Not a managed SA
-	ns, err := utils.FetchNamespace(ctx, r.Client, serviceAccount.GetNamespace())
+// serviceAccountBatch holds the state shared by the ServiceAccounts of a namespace reconciled in one pass,
+// so the namespace, the paused state and the credentials are only read once per pass
+type serviceAccountBatch struct {
+	c      *config.Config
+	ns     *corev1.Namespace
+	paused bool
+	// hash of the current credentials, unless paused
+	hash string
+	// secretSynced is set, once the imagePullSecret of the namespace was reconciled during the pass
+	secretSynced bool
+	// secretReconciled is set, once reconciling the imagePullSecret was attempted during the pass,
+	// and secretErr holds the error of the last attempt
+	secretReconciled bool
+	secretErr        error
+}
+
+// newServiceAccountBatch reads the state shared by the ServiceAccounts of namespace
+func (r *ServiceAccountReconciler) newServiceAccountBatch(ctx context.Context, namespace string) (*serviceAccountBatch, error) {
+	c := r.Config.Load()
+	ns, err := utils.FetchNamespace(ctx, r.Client, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch namespace: %w", err)
+	}
+	paused, err := utils.IsPaused(ctx, r.Client, c)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to fetch namespace: %w", err)
+		return nil, err
+	}
+	batch := &serviceAccountBatch{c: c, ns: ns, paused: paused}
+	if !paused {
+		batch.hash = r.credentialHash(ctx, c)
+	}
+	return batch, nil
+}
+
+// recordSecretResult reports the result of reconciling the imagePullSecret during batch to the Notifier,
+// so failures are counted once per pass over the namespace, instead of once per ServiceAccount
+func (r *ServiceAccountReconciler) recordSecretResult(ctx context.Context, namespace string, batch *serviceAccountBatch) {
+	if batch.secretReconciled {
+		r.Notifier.RecordResult(ctx, namespace, batch.secretErr)
 	}
-	if !utils.IsServiceAccountManaged(r.Config, ns, serviceAccount) {
+}
+
+// reconcileNamespace reconciles all ServiceAccounts of namespace in one pass, so the imagePullSecret is
+// reconciled once for all of them. The namespace is requeued with the earliest requeue of its ServiceAccounts.
+// The pass stops, once reconciling the imagePullSecret failed, instead of retrying it for every ServiceAccount.
+func (r *ServiceAccountReconciler) reconcileNamespace(ctx context.Context, namespace string) (ctrl.Result, error) {
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, serviceAccounts, client.InNamespace(namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list ServiceAccounts in namespace '%s': %w", namespace, err)
+	}
+	if len(serviceAccounts.Items) == 0 {
 		return ctrl.Result{}, nil
 	}
 
-	// Ensure imagePullSecret exists before we attach it to the ServiceAccount
-	if _, err = utils.ReconcileImagePullSecret(ctx, r.Client, r.Config, r.Config.SecretName, serviceAccount.GetNamespace()); err != nil {
-		return ctrl.Result{}, fmt.Errorf("Failed to reconcile imagePullSecret in Namespace '"+serviceAccount.GetNamespace()+"': %w", err)
+	batch, err := r.newServiceAccountBatch(ctx, namespace)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
+	result := ctrl.Result{}
+	var errs []error
+	for i := range serviceAccounts.Items {
+		serviceAccount := &serviceAccounts.Items[i]
+		saCtx := log.IntoContext(ctx, log.FromContext(ctx).WithValues("ServiceAccount", serviceAccount.GetName()))
+		saResult, err := r.reconcileServiceAccount(saCtx, serviceAccount, batch)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			result = earliestResult(result, saResult)
+		}
+		if batch.secretErr != nil {
+			break
+		}
+	}
+	r.recordSecretResult(ctx, namespace, batch)
+	return result, errors.Join(errs...)
+}
 
-	patchFrom := client.MergeFrom(serviceAccount.DeepCopy())
-	patchedServiceAccount := r.getPatchedServiceAccount(serviceAccount.DeepCopy(), r.Config.SecretName)
+// earliestResult combines the results of two reconciles into the one requeuing first
+func earliestResult(a ctrl.Result, b ctrl.Result) ctrl.Result {
+	result := ctrl.Result{Requeue: a.Requeue || b.Requeue, RequeueAfter: a.RequeueAfter}
+	if b.RequeueAfter > 0 && (result.RequeueAfter == 0 || b.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = b.RequeueAfter
+	}
+	return result
+}
+
+// reconcileServiceAccount attaches the imagePullSecret to serviceAccount, reconciling the imagePullSecret first,
+// unless it was already reconciled during batch
+func (r *ServiceAccountReconciler) reconcileServiceAccount(ctx context.Context, serviceAccount *corev1.ServiceAccount, batch *serviceAccountBatch) (ctrl.Result, error) {
+	c, ns := batch.c, batch.ns
+	log := log.FromContext(ctx)
+
+	// Not a managed SA
+	if !utils.IsServiceAccountManaged(c, ns, serviceAccount) {
+		if c.FeatureDetachServiceAccounts && utils.IsServiceAccountDetachable(c, ns, serviceAccount) {
+			return r.detach(ctx, batch, serviceAccount)
+		}
+		return ctrl.Result{}, nil
+	}
 
-	if !reflect.DeepEqual(serviceAccount.ImagePullSecrets, patchedServiceAccount.ImagePullSecrets) {
-		err = r.Patch(ctx, patchedServiceAccount, patchFrom)
+	// Give namespace provisioning controllers time to settle
+	if remaining := utils.GetNamespaceMinAgeRemaining(c, ns); remaining > 0 {
+		log.Info("Namespace '" + ns.GetName() + "' is too young, requeuing after " + remaining.String())
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	if c.RequireDefaultServiceAccount {
+		if _, err := utils.FetchServiceAccount(ctx, r.Client, ns.GetName(), "default"); err != nil {
+			if apierrs.IsNotFound(err) {
+				log.Info("Default ServiceAccount in namespace '" + ns.GetName() + "' does not exist yet, requeuing")
+				return ctrl.Result{RequeueAfter: defaultServiceAccountRequeueAfter}, nil
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	if batch.paused {
+		drift, err := utils.HasImagePullSecretDrift(ctx, r.Client, c, c.SecretName, serviceAccount.GetNamespace())
 		if err != nil {
-			return ctrl.Result{}, fmt.Errorf("[%s] Failed to patch ImagePullSecret to ServiceAccount '"+serviceAccount.GetName()+"' in namespace '"+serviceAccount.GetNamespace()+"': %w", err)
+			return ctrl.Result{}, err
 		}
-		log.Info("Attached ImagePullSecret to ServiceAccount '" + serviceAccount.GetName() + "' in namespace '" + serviceAccount.GetNamespace() + "'")
+		if drift || !r.includeImagePullSecret(serviceAccount, c.SecretName) {
+			utils.ReportDrift(ctx, "ServiceAccount", serviceAccount.GetNamespace(), serviceAccount.GetName())
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+	}
 
-		if r.Config.FeatureDeletePods {
-			// Run Pod cleanup only if we're freshly attaching the imagePullSecret to the ServiceAccount
-			if err = utils.CleanupPodsForSA(ctx, r.Client, serviceAccount.GetNamespace(), serviceAccount.GetName()); err != nil {
-				return ctrl.Result{}, fmt.Errorf("Failed to cleanup Pods in unauthorized state: %w", err)
+	// With many ServiceAccounts per namespace, the imagePullSecret only needs to be reconciled once per credential change
+	if !batch.secretSynced && !r.isSecretCurrent(ns, batch.hash) {
+		// Ensure imagePullSecret exists before we attach it to the ServiceAccount
+		result, err := utils.ReconcileImagePullSecret(ctx, r.Client, c, c.SecretName, serviceAccount.GetNamespace())
+		observeResult("Secret", result)
+		batch.secretReconciled = true
+		batch.secretErr = err
+		utils.RecordSecretCondition(ctx, r.Client, c, serviceAccount.GetNamespace(), result, err)
+		utils.RecordNamespaceSync(ctx, r.Client, c, serviceAccount.GetNamespace(), err)
+		if err != nil {
+			eventlog.Record(eventlog.ActionError, serviceAccount.GetNamespace(), serviceAccount.GetName(), err.Error())
+			if errors.Is(err, utils.ErrSecretTooLarge) && r.Recorder != nil {
+				r.Recorder.Event(serviceAccount, corev1.EventTypeWarning, "SecretTooLarge", err.Error())
+			}
+			if utils.IsOwnershipConflict(err) {
+				if r.Recorder != nil {
+					reason := "GitOpsConflict"
+					if errors.Is(err, utils.ErrSecretOwnerConflict) {
+						reason = "SecretOwnerConflict"
+					}
+					r.Recorder.Event(serviceAccount, corev1.EventTypeWarning, reason, err.Error())
+				}
+				log.Info("imagePullSecret in namespace '" + serviceAccount.GetNamespace() + "' is managed by someone else, requeuing after " + ownershipConflictRequeueAfter.String())
+				return ctrl.Result{RequeueAfter: ownershipConflictRequeueAfter}, nil
+			}
+			if errors.Is(err, utils.ErrSecretQuotaExceeded) {
+				if r.Recorder != nil {
+					r.Recorder.Event(serviceAccount, corev1.EventTypeWarning, "SecretQuotaExceeded", err.Error())
+				}
+				log.Info("ResourceQuota for Secrets in namespace '" + serviceAccount.GetNamespace() + "' is exhausted, requeuing after " + secretQuotaRequeueAfter.String())
+				return ctrl.Result{RequeueAfter: secretQuotaRequeueAfter}, nil
 			}
-			log.Info("Cleaned up Pods belonging to ServiceAccount " + serviceAccount.GetName())
+			return ctrl.Result{}, fmt.Errorf("Failed to reconcile imagePullSecret in Namespace '"+serviceAccount.GetNamespace()+"': %w", err)
 		}
+		r.markSecretCurrent(ns, batch.hash)
+		batch.secretSynced = true
 	}
 
-	return ctrl.Result{}, nil
+	result, err := r.attachImagePullSecret(ctx, serviceAccount)
+	observeResult("ServiceAccount", result)
+	utils.RecordNamespaceCondition(ctx, r.Client, c, serviceAccount.GetNamespace(), imagepullsecretv1alpha1.ConditionServiceAccountsPatched, err)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	isNew := time.Since(serviceAccount.GetCreationTimestamp().Time) < newServiceAccountWindow
+	cleanupPending := utils.IsPodCleanupPending(serviceAccount.GetNamespace(), serviceAccount.GetName())
+	if result.Changed() {
+		log.Info("Attached ImagePullSecret to ServiceAccount '" + serviceAccount.GetName() + "' in namespace '" + serviceAccount.GetNamespace() + "'")
+		eventlog.Record(eventlog.ActionServiceAccountPatched, serviceAccount.GetNamespace(), serviceAccount.GetName(), "")
+	} else if !isNew && !cleanupPending {
+		// Re-verify the ServiceAccount periodically, in case the imagePullSecret is removed outside our watch
+		return ctrl.Result{RequeueAfter: c.RequeueAfter}, nil
+	}
+
+	if c.FeatureDeletePods {
+		// Run Pod cleanup only if we're freshly attaching the imagePullSecret to the ServiceAccount,
+		// or if the ServiceAccount is new and its Pods might have been admitted before it was patched
+		requeueAfter, err := utils.CleanupPodsForSA(ctx, c, r.Client, r.Recorder, serviceAccount.GetNamespace(), serviceAccount.GetName())
+		if requeueAfter > 0 && err == nil {
+			// Give the kubelet time to observe the imagePullSecret, or wait for the turn of the next critical Pod,
+			// without blocking the worker
+			log.Info("Delaying cleanup of Pods belonging to ServiceAccount " + serviceAccount.GetName() + " by " + requeueAfter.String())
+			return ctrl.Result{RequeueAfter: requeueAfter}, nil
+		}
+		utils.RecordNamespaceCondition(ctx, r.Client, c, serviceAccount.GetNamespace(), imagepullsecretv1alpha1.ConditionPodsCleaned, err)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("Failed to cleanup Pods in unauthorized state: %w", err)
+		}
+		log.Info("Cleaned up Pods belonging to ServiceAccount " + serviceAccount.GetName())
+
+		// Pods admitted right before the patch only fail pulling their image a few seconds later
+		if isNew {
+			return ctrl.Result{RequeueAfter: newServiceAccountRequeueAfter}, nil
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: c.RequeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ServiceAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	ctx := context.TODO()
-	return ctrl.NewControllerManagedBy(mgr).
-		Named("ServiceAccountController").
+	teardown.Default.OnDelete(r.forgetNamespace)
+	isManaged := func(obj client.Object) bool {
+		ns, err := utils.FetchNamespace(ctx, r.Client, obj.GetNamespace())
+		if err != nil {
+			return false
+		}
+		return utils.IsServiceAccountManaged(r.Config.Load(), ns, obj)
+	}
+	isDetachable := func(obj client.Object) bool {
+		serviceAccount, ok := obj.(*corev1.ServiceAccount)
+		if !ok || !r.Config.Load().FeatureDetachServiceAccounts {
+			return false
+		}
+		ns, err := utils.FetchNamespace(ctx, r.Client, obj.GetNamespace())
+		if err != nil {
+			return false
+		}
+		return utils.IsServiceAccountDetachable(r.Config.Load(), ns, serviceAccount)
+	}
+	isNew := func(obj client.Object) bool {
+		return time.Since(obj.GetCreationTimestamp().Time) < newServiceAccountWindow
+	}
+	isSwept := func(obj client.Object) bool {
+		return r.Sweep != nil && r.Sweep.Covers(obj)
+	}
+
+	// Newly created ServiceAccounts get a dedicated controller with its own queue, so they are
+	// patched right away, even if the main queue is busy. Their Pods usually follow within seconds.
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("ServiceAccountCreateController").
+		WithOptions(controllerOptions(r.Config.Load())).
 		For(&corev1.ServiceAccount{}).
-		WithEventFilter(predicate.Funcs{
-			CreateFunc: func(e event.CreateEvent) bool {
-				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
-				if err != nil {
-					return false
-				}
-				return utils.IsServiceAccountManaged(r.Config, ns, e.Object)
-			},
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				ns, err := utils.FetchNamespace(ctx, r.Client, e.ObjectNew.GetNamespace())
-				if err != nil {
-					return false
-				}
-				return utils.IsServiceAccountManaged(r.Config, ns, e.ObjectNew)
-			},
-			GenericFunc: func(e event.GenericEvent) bool {
-				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
-				if err != nil {
-					return false
-				}
-				return utils.IsServiceAccountManaged(r.Config, ns, e.Object)
-			},
-			// Ignore Deletion events
-			DeleteFunc: func(e event.DeleteEvent) bool {
-				return false
-			},
-		}).
-		Complete(r)
+		WithEventFilter(newServiceAccountPredicate(isNew, isSwept, isManaged)).
+		Complete(teardown.Default.Reconciler(r))
+	if err != nil {
+		return err
+	}
+
+	// Events of all ServiceAccounts of a namespace are collapsed into a single request for the namespace,
+	// so configured ServiceAccounts like default,builder,deployer are reconciled together in one pass
+	builder := ctrl.NewControllerManagedBy(mgr).
+		Named("ServiceAccountController").
+		WithOptions(controllerOptions(r.Config.Load())).
+		Watches(&corev1.ServiceAccount{}, handler.EnqueueRequestsFromMapFunc(namespaceRequest)).
+		WithEventFilter(serviceAccountPredicate(isNew, isSwept, isManaged, isDetachable)).
+		WithEventFilter(ignoreOwnChanges)
+
+	// ServiceAccounts failing during the initial sweep are retried with the usual backoff
+	if r.Sweep != nil {
+		builder = builder.WatchesRawSource(source.Channel(r.Sweep.Retries(), &handler.TypedEnqueueRequestForObject[*corev1.ServiceAccount]{}))
+	}
+
+	return builder.Complete(teardown.Default.Reconciler(r.Breaker.Reconciler(r)))
+}
+
+// newServiceAccountPredicate only passes the creation of new, managed ServiceAccounts, which are
+// not covered by the initial sweep, to the dedicated controller
+func newServiceAccountPredicate(isNew, isSwept, isManaged func(client.Object) bool) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isNew(e.Object) && !isSwept(e.Object) && isManaged(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+	}
+}
+
+// serviceAccountPredicate passes all events of managed or detachable ServiceAccounts, but the creation
+// of new ones, which is handled by the dedicated controller, and deletions
+func serviceAccountPredicate(isNew, isSwept, isManaged, isDetachable func(client.Object) bool) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return !isNew(e.Object) && !isSwept(e.Object) && (isManaged(e.Object) || isDetachable(e.Object))
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isManaged(e.ObjectNew) || isDetachable(e.ObjectNew)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return isManaged(e.Object) || isDetachable(e.Object)
+		},
+		// Ignore Deletion events
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+	}
+}
+
+// namespaceRequest maps obj to the request for all ServiceAccounts of its namespace
+func namespaceRequest(_ context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace()}}}
 }
 
 // Check if service account contains imagePullSecret with name equal to secretName
 func (r *ServiceAccountReconciler) includeImagePullSecret(sa *corev1.ServiceAccount, secretName string) bool {
-	for _, imagePullSecret := range sa.ImagePullSecrets {
-		if imagePullSecret.Name == secretName {
-			return true
+	return utils.HasImagePullSecret(sa, secretName)
+}
+
+// attachImagePullSecret adds the imagePullSecret to serviceAccount, if it's missing.
+// Conflicts, e.g. with the token controller updating a new ServiceAccount, are retried
+// with the current version of serviceAccount.
+func (r *ServiceAccountReconciler) attachImagePullSecret(ctx context.Context, serviceAccount *corev1.ServiceAccount) (utils.ReconcileResult, error) {
+	c := r.Config.Load()
+	result := utils.ResultNoOp
+	attempts := 0
+	var corrected *corev1.ServiceAccount
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if attempts > 0 {
+			metrics.ServiceAccountConflictsTotal.Inc()
+			if err := r.apiReader().Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount); err != nil {
+				return err
+			}
+		}
+		attempts++
+
+		patchedServiceAccount := r.getPatchedServiceAccount(serviceAccount.DeepCopy(), c.SecretName)
+		if reflect.DeepEqual(serviceAccount.ImagePullSecrets, patchedServiceAccount.ImagePullSecrets) {
+			result = utils.ResultNoOp
+			return nil
+		}
+		utils.RecordChange(c, patchedServiceAccount, utils.ChangelogActionAttached, c.SecretName, time.Now())
+		if err := r.writeServiceAccount(ctx, serviceAccount, patchedServiceAccount); err != nil {
+			return err
+		}
+		corrected = serviceAccount.DeepCopy()
+		result = utils.ResultPatched
+		return nil
+	})
+	if err != nil {
+		return utils.ResultFailed, fmt.Errorf("Failed to patch ImagePullSecret to ServiceAccount '"+serviceAccount.GetName()+"' in namespace '"+serviceAccount.GetNamespace()+"': %w", err)
+	}
+	// Retried conflicts are counted once, with the state, which was finally corrected
+	if corrected != nil {
+		conflict.Default.Observe(ctx, corrected, "ServiceAccount")
+	}
+	return result, nil
+}
+
+// detach removes the imagePullSecret from serviceAccount, which is no longer managed, and optionally deletes
+// the imagePullSecret, if no other ServiceAccount in the namespace uses it anymore
+func (r *ServiceAccountReconciler) detach(ctx context.Context, batch *serviceAccountBatch, serviceAccount *corev1.ServiceAccount) (ctrl.Result, error) {
+	c, ns := batch.c, batch.ns
+	if batch.paused {
+		utils.ReportDrift(ctx, "ServiceAccount", serviceAccount.GetNamespace(), serviceAccount.GetName())
+		return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+	}
+
+	result, err := r.detachImagePullSecret(ctx, serviceAccount)
+	observeResult("ServiceAccount", result)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.Changed() {
+		log.FromContext(ctx).Info("Detached ImagePullSecret from ServiceAccount '" + serviceAccount.GetName() + "' in namespace '" + serviceAccount.GetNamespace() + "'")
+		eventlog.Record(eventlog.ActionServiceAccountPatched, serviceAccount.GetNamespace(), serviceAccount.GetName(), "detached")
+	}
+
+	if c.FeatureDeleteUnusedSecrets {
+		if err := utils.DeleteUnusedImagePullSecret(ctx, r.Client, c, ns); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// detachImagePullSecret removes the imagePullSecret from serviceAccount, retrying conflicts like attachImagePullSecret
+func (r *ServiceAccountReconciler) detachImagePullSecret(ctx context.Context, serviceAccount *corev1.ServiceAccount) (utils.ReconcileResult, error) {
+	c := r.Config.Load()
+	result := utils.ResultNoOp
+	attempts := 0
+	var corrected *corev1.ServiceAccount
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if attempts > 0 {
+			metrics.ServiceAccountConflictsTotal.Inc()
+			if err := r.apiReader().Get(ctx, client.ObjectKeyFromObject(serviceAccount), serviceAccount); err != nil {
+				return err
+			}
+		}
+		attempts++
+
+		// References added manually by users are never removed
+		if !utils.IsImagePullSecretAttached(c, serviceAccount) {
+			result = utils.ResultNoOp
+			return nil
+		}
+		patchedServiceAccount := serviceAccount.DeepCopy()
+		patchedServiceAccount.ImagePullSecrets = slices.DeleteFunc(patchedServiceAccount.ImagePullSecrets, func(ref corev1.LocalObjectReference) bool {
+			return ref.Name == c.SecretName
+		})
+		utils.SetImagePullSecretAttached(c, patchedServiceAccount, "")
+		utils.RecordChange(c, patchedServiceAccount, utils.ChangelogActionDetached, c.SecretName, time.Now())
+		if err := r.writeServiceAccount(ctx, serviceAccount, patchedServiceAccount); err != nil {
+			return err
 		}
+		corrected = serviceAccount.DeepCopy()
+		result = utils.ResultPatched
+		return nil
+	})
+	if err != nil {
+		return utils.ResultFailed, fmt.Errorf("Failed to remove ImagePullSecret from ServiceAccount '"+serviceAccount.GetName()+"' in namespace '"+serviceAccount.GetNamespace()+"': %w", err)
+	}
+	// Retried conflicts are counted once, with the state, which was finally corrected
+	if corrected != nil {
+		conflict.Default.Observe(ctx, corrected, "ServiceAccount")
+	}
+	return result, nil
+}
+
+// apiReader returns the reader used to re-read ServiceAccounts after a conflict
+func (r *ServiceAccountReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// writeServiceAccount writes the imagePullSecrets of patched with the configured ServiceAccountPatchStrategy.
+// As imagePullSecrets is an atomic list, every strategy writes the whole list. The merge patches therefore
+// carry the resourceVersion of original, like Update does, so they're rejected with a conflict instead of
+// overwriting references added in the meantime. Apply forces the ownership of the list instead.
+func (r *ServiceAccountReconciler) writeServiceAccount(ctx context.Context, original *corev1.ServiceAccount, patched *corev1.ServiceAccount) error {
+	c := r.Config.Load()
+	switch c.ServiceAccountPatchStrategy {
+	case config.PatchStrategyStrategic:
+		return r.Patch(ctx, patched, client.StrategicMergeFrom(original, client.MergeFromWithOptimisticLock{}))
+	case config.PatchStrategyUpdate:
+		return r.Update(ctx, patched)
+	case config.PatchStrategyApply:
+		// Annotations missing from the applied configuration are removed, as the controller owns them
+		annotations := map[string]string{
+			c.AnnotationChangelog: patched.GetAnnotations()[c.AnnotationChangelog],
+		}
+		if secretName, ok := patched.GetAnnotations()[c.AnnotationAttached]; ok {
+			annotations[c.AnnotationAttached] = secretName
+		}
+		applied := &corev1.ServiceAccount{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "ServiceAccount",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        patched.GetName(),
+				Namespace:   patched.GetNamespace(),
+				Annotations: annotations,
+			},
+			ImagePullSecrets: patched.ImagePullSecrets,
+		}
+		return r.Patch(ctx, applied, client.Apply, client.FieldOwner(config.AnnotationAppName), client.ForceOwnership)
+	default:
+		return r.Patch(ctx, patched, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+	}
+}
+
+// credentialHash returns the hash of the current credentials, if the Secret controller corrects drift of the
+// imagePullSecrets. Otherwise, or if the credentials can't be read, it returns an empty string. The hash is
+// cached per revision of the credential source.
+func (r *ServiceAccountReconciler) credentialHash(ctx context.Context, c *config.Config) string {
+	if c.DisableSecretController {
+		return ""
 	}
-	return false
+	hash, err := utils.CachedCredentialHash(ctx, r.Client, c)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// isSecretCurrent checks whether the imagePullSecret of ns was reconciled with the credentials of hash
+func (r *ServiceAccountReconciler) isSecretCurrent(ns *corev1.Namespace, hash string) bool {
+	r.currentSecretsMu.Lock()
+	defer r.currentSecretsMu.Unlock()
+
+	current := r.currentSecrets[ns.GetName()]
+	return hash != "" && current.uid == ns.GetUID() && current.hash == hash
+}
+
+// markSecretCurrent records, that the imagePullSecret of ns was reconciled with the credentials of hash
+func (r *ServiceAccountReconciler) markSecretCurrent(ns *corev1.Namespace, hash string) {
+	r.currentSecretsMu.Lock()
+	defer r.currentSecretsMu.Unlock()
+
+	if hash == "" {
+		return
+	}
+	if r.currentSecrets == nil {
+		r.currentSecrets = map[string]currentSecret{}
+	}
+	r.currentSecrets[ns.GetName()] = currentSecret{uid: ns.GetUID(), hash: hash}
+}
+
+// forgetNamespace drops the credential hash of a deleted namespace
+func (r *ServiceAccountReconciler) forgetNamespace(namespace string) {
+	r.currentSecretsMu.Lock()
+	defer r.currentSecretsMu.Unlock()
+
+	delete(r.currentSecrets, namespace)
+}
+
+// controllerOptions instruments the workqueue of a controller, rate limits it per namespace, and purges requests for namespaces being deleted from it
+func controllerOptions(c *config.Config) controller.Options {
+	return controller.Options{
+		MaxConcurrentReconciles: c.ConcurrentReconciles,
+		NewQueue: func(controllerName string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+			return teardown.Default.Queue(controllerName, fairness.Default.Queue(controllerName, metrics.NewQueue(controllerName, rateLimiter)))
+		},
+	}
+}
+
+// ignoreOwnChanges drops Update events, which only echo the changes made by the controller itself
+var ignoreOwnChanges = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !conflict.IsOwnChange(e.ObjectOld, e.ObjectNew)
+	},
+}
+
+// observeResult counts the result of reconciling an object of kind
+func observeResult(kind string, result utils.ReconcileResult) {
+	metrics.ReconcileResultsTotal.WithLabelValues(kind, string(result)).Inc()
 }
 
 // Append to existing list of imagePullSecret names a new item with name of secretName
 func (r *ServiceAccountReconciler) getPatchedServiceAccount(sa *corev1.ServiceAccount, secretName string) *corev1.ServiceAccount {
 	if !r.includeImagePullSecret(sa, secretName) {
 		sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+		utils.SetImagePullSecretAttached(r.Config.Load(), sa, secretName)
 	}
 	return sa
 }
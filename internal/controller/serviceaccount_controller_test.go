@@ -18,6 +18,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -25,9 +26,16 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -83,6 +91,15 @@ var _ = Describe("ServiceAccount Controller", func() {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "managed-errimagepull",
 					Namespace: serviceAccount.GetNamespace(),
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "apps/v1",
+							Kind:       "ReplicaSet",
+							Name:       "managed",
+							UID:        "managed",
+							Controller: ptr.To(true),
+						},
+					},
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: serviceAccount.GetName(),
@@ -140,7 +157,7 @@ var _ = Describe("ServiceAccount Controller", func() {
 			serviceAccountReconciler := &ServiceAccountReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: config,
+				Config: newConfigStore(config),
 			}
 			_, err = serviceAccountReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: serviceAccountNN,
@@ -198,7 +215,7 @@ var _ = Describe("ServiceAccount Controller", func() {
 			serviceAccountReconciler := &ServiceAccountReconciler{
 				Client: k8sClient,
 				Scheme: k8sClient.Scheme(),
-				Config: config,
+				Config: newConfigStore(config),
 			}
 			_, err = serviceAccountReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: serviceAccountNN,
@@ -213,4 +230,481 @@ var _ = Describe("ServiceAccount Controller", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("When reconciling a new ServiceAccount with a wildcard configuration", func() {
+		ctx := context.Background()
+		config := config.NewConfig(
+			config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				ServiceAccounts:   "*",
+				FeatureDeletePods: true,
+			},
+		)
+
+		newPod := func(name string, namespace string, serviceAccountName string, imagePullSecrets []corev1.LocalObjectReference) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "apps/v1",
+							Kind:       "ReplicaSet",
+							Name:       name,
+							UID:        types.UID(name),
+							Controller: ptr.To(true),
+						},
+					},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: serviceAccountName,
+					ImagePullSecrets:   imagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:  "test",
+							Image: "foo.bar",
+						},
+					},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{
+						{
+							State: corev1.ContainerState{
+								Waiting: &corev1.ContainerStateWaiting{
+									Reason: "ErrImagePull",
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+
+		It("should patch the ServiceAccount and recreate Pods admitted before the patch", func() {
+			namespace, serviceAccount, serviceAccountNN, _ := makeObjects("testns-wildcard-1", "my-chart", config.SecretName)
+
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Creating the ServiceAccount and its Pods within a second")
+			serviceAccount.CreationTimestamp = metav1.Now()
+			Expect(k8sClient.Create(ctx, serviceAccount.DeepCopy())).Should(Succeed())
+			racingPod := newPod("racing", namespace.GetName(), serviceAccount.GetName(), nil)
+			Expect(k8sClient.Create(ctx, racingPod)).Should(Succeed())
+			admittedPod := newPod("admitted", namespace.GetName(), serviceAccount.GetName(), []corev1.LocalObjectReference{{Name: config.SecretName}})
+			Expect(k8sClient.Create(ctx, admittedPod)).Should(Succeed())
+
+			By("Reconciling the ServiceAccount")
+			serviceAccountReconciler := &ServiceAccountReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(config),
+			}
+			result, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: serviceAccountNN,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Checking if the ServiceAccount was patched")
+			foundServiceAccount := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, serviceAccountNN, foundServiceAccount)).Should(Succeed())
+			Expect(foundServiceAccount.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: config.SecretName}))
+
+			By("Checking if the Pod admitted before the patch was cleaned up")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: racingPod.GetName(), Namespace: racingPod.GetNamespace()}, &corev1.Pod{})
+			Expect(err).To(HaveOccurred())
+
+			By("Checking if the Pod admitted with the imagePullSecret was kept")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: admittedPod.GetName(), Namespace: admittedPod.GetNamespace()}, &corev1.Pod{})
+			Expect(err).To(Not(HaveOccurred()))
+		})
+	})
+
+	Context("When reconciling a ServiceAccount while paused", func() {
+		ctx := context.Background()
+		config := config.NewConfig(
+			config.ConfigOptions{
+				DockerConfigJSON: imagePullSecretData,
+				SecretNamespace:  "kube-system",
+				Paused:           true,
+			},
+		)
+
+		It("should neither create the Secret nor patch the ServiceAccount", func() {
+			namespace, serviceAccount, serviceAccountNN, secretNN := makeObjects("testns-paused-1", "default", config.SecretName)
+
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Creating the ServiceAccount to reconcile")
+			Expect(k8sClient.Create(ctx, serviceAccount.DeepCopy())).Should(Succeed())
+
+			By("Reconciling the ServiceAccount")
+			serviceAccountReconciler := &ServiceAccountReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(config),
+			}
+			result, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: serviceAccountNN,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(Equal(pausedRequeueAfter))
+
+			By("Checking if Secret was NOT created in the reconciliation")
+			Expect(k8sClient.Get(ctx, secretNN, &corev1.Secret{})).ShouldNot(Succeed())
+
+			By("Checking if the ServiceAccount was NOT patched")
+			foundServiceAccount := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, serviceAccountNN, foundServiceAccount)).Should(Succeed())
+			Expect(foundServiceAccount.ImagePullSecrets).To(BeEmpty())
+		})
+	})
+
+	Context("When patching a ServiceAccount with a configured patch strategy", func() {
+		ctx := context.Background()
+
+		for i, strategy := range []string{config.PatchStrategyMerge, config.PatchStrategyStrategic, config.PatchStrategyUpdate} {
+			It("should keep existing imagePullSecrets with the "+strategy+" strategy", func() {
+				c := config.NewConfig(config.ConfigOptions{
+					DockerConfigJSON:            imagePullSecretData,
+					SecretNamespace:             "kube-system",
+					ServiceAccountPatchStrategy: strategy,
+				})
+				namespace, serviceAccount, serviceAccountNN, _ := makeObjects(fmt.Sprintf("testns-strategy-%d", i), "default", c.SecretName)
+				serviceAccount.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "existing"}}
+				Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+				Expect(k8sClient.Create(ctx, serviceAccount.DeepCopy())).Should(Succeed())
+
+				serviceAccountReconciler := &ServiceAccountReconciler{
+					Client: k8sClient,
+					Scheme: k8sClient.Scheme(),
+					Config: config.NewStore(c),
+				}
+				Expect(k8sClient.Get(ctx, serviceAccountNN, &serviceAccount)).Should(Succeed())
+				result, err := serviceAccountReconciler.attachImagePullSecret(ctx, &serviceAccount)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(result).To(Equal(utils.ResultPatched))
+
+				foundServiceAccount := &corev1.ServiceAccount{}
+				Expect(k8sClient.Get(ctx, serviceAccountNN, foundServiceAccount)).Should(Succeed())
+				Expect(foundServiceAccount.ImagePullSecrets).To(ConsistOf(
+					corev1.LocalObjectReference{Name: "existing"},
+					corev1.LocalObjectReference{Name: c.SecretName},
+				))
+			})
+		}
+	})
+
+	Context("When patching a ServiceAccount, that is modified concurrently", func() {
+		ctx := context.Background()
+		c := config.NewConfig(config.ConfigOptions{
+			DockerConfigJSON: imagePullSecretData,
+			SecretNamespace:  "kube-system",
+		})
+
+		// newConflictingClient rejects the first conflicts patches of ServiceAccounts with a conflict
+		newConflictingClient := func(serviceAccount *corev1.ServiceAccount, conflicts int) client.Client {
+			return fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(serviceAccount).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, wrapped client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						if conflicts > 0 {
+							conflicts--
+							return apierrs.NewConflict(corev1.Resource("serviceaccounts"), obj.GetName(), errors.New("the object has been modified"))
+						}
+						return wrapped.Patch(ctx, obj, patch, opts...)
+					},
+				}).
+				Build()
+		}
+
+		It("should retry and patch the ServiceAccount", func() {
+			_, serviceAccount, serviceAccountNN, _ := makeObjects("testns-conflict-1", "default", c.SecretName)
+			conflictingClient := newConflictingClient(serviceAccount.DeepCopy(), 2)
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: conflictingClient, Config: config.NewStore(c)}
+
+			Expect(conflictingClient.Get(ctx, serviceAccountNN, &serviceAccount)).Should(Succeed())
+			result, err := serviceAccountReconciler.attachImagePullSecret(ctx, &serviceAccount)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result).To(Equal(utils.ResultPatched))
+
+			foundServiceAccount := &corev1.ServiceAccount{}
+			Expect(conflictingClient.Get(ctx, serviceAccountNN, foundServiceAccount)).Should(Succeed())
+			Expect(foundServiceAccount.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: c.SecretName}))
+		})
+
+		It("should surface persistent conflicts", func() {
+			_, serviceAccount, serviceAccountNN, _ := makeObjects("testns-conflict-2", "default", c.SecretName)
+			conflictingClient := newConflictingClient(serviceAccount.DeepCopy(), 100)
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: conflictingClient, Config: config.NewStore(c)}
+
+			Expect(conflictingClient.Get(ctx, serviceAccountNN, &serviceAccount)).Should(Succeed())
+			result, err := serviceAccountReconciler.attachImagePullSecret(ctx, &serviceAccount)
+			Expect(apierrs.IsConflict(err)).To(BeTrue())
+			Expect(result).To(Equal(utils.ResultFailed))
+		})
+	})
+
+	Context("When reconciling many ServiceAccounts in a namespace", func() {
+		ctx := context.Background()
+
+		It("should only reconcile the imagePullSecret once per credential change", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON: imagePullSecretData,
+				SecretNamespace:  "kube-system",
+				ServiceAccounts:  "*",
+			})
+			namespace, serviceAccount, serviceAccountNN, secretNN := makeObjects("testns-current-1", "default", c.SecretName)
+			namespace.UID = "testns-current-1"
+			otherServiceAccount := serviceAccount.DeepCopy()
+			otherServiceAccount.Name = "other"
+
+			secretGets := 0
+			countingClient := fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(&namespace, &serviceAccount, otherServiceAccount).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, wrapped client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							secretGets++
+						}
+						return wrapped.Get(ctx, key, obj, opts...)
+					},
+				}).
+				Build()
+			configStore := config.NewStore(c)
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: countingClient, Config: configStore}
+
+			_, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceAccountNN})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(secretGets).To(Equal(1))
+
+			By("Reconciling another ServiceAccount of the namespace")
+			_, err = serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(otherServiceAccount)})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(secretGets).To(Equal(1))
+
+			By("Rotating the credentials")
+			rotated := `{"auths":{"registry.example.com":{"auth":"cm90YXRlZA=="}}}`
+			configStore.Update(func(c *config.Config) {
+				c.DockerConfigJSON = rotated
+			})
+			_, err = serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceAccountNN})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(secretGets).To(Equal(2))
+
+			secret := &corev1.Secret{}
+			Expect(countingClient.Get(ctx, secretNN, secret)).Should(Succeed())
+			Expect(string(secret.Data[corev1.DockerConfigJsonKey])).To(Equal(rotated))
+		})
+
+		It("should detach the imagePullSecret from ServiceAccounts, which are no longer managed", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				ServiceAccounts:              "default",
+				FeatureDetachServiceAccounts: true,
+				FeatureDeleteUnusedSecrets:   true,
+			})
+			namespace, serviceAccount, serviceAccountNN, secretNN := makeObjects("testns-detach-1", "builder", c.SecretName)
+			serviceAccount.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "other"}, {Name: c.SecretName}}
+			serviceAccount.Annotations = map[string]string{c.AnnotationAttached: c.SecretName}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        secretNN.Name,
+					Namespace:   secretNN.Namespace,
+					Annotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(&namespace, &serviceAccount, secret).
+				Build()
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: fakeClient, Config: config.NewStore(c)}
+
+			_, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceAccountNN})
+			Expect(err).To(Not(HaveOccurred()))
+
+			detached := &corev1.ServiceAccount{}
+			Expect(fakeClient.Get(ctx, serviceAccountNN, detached)).Should(Succeed())
+			Expect(detached.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: "other"}}))
+			Expect(detached.Annotations).NotTo(HaveKey(c.AnnotationAttached))
+
+			By("Deleting the no longer used imagePullSecret")
+			err = fakeClient.Get(ctx, secretNN, &corev1.Secret{})
+			Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep imagePullSecrets referenced manually, when ServiceAccounts are no longer managed", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				ServiceAccounts:              "default",
+				FeatureDetachServiceAccounts: true,
+			})
+			namespace, serviceAccount, serviceAccountNN, _ := makeObjects("testns-detach-2", "builder", c.SecretName)
+			serviceAccount.ImagePullSecrets = []corev1.LocalObjectReference{{Name: c.SecretName}}
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(&namespace, &serviceAccount).
+				Build()
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: fakeClient, Config: config.NewStore(c)}
+
+			_, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceAccountNN})
+			Expect(err).To(Not(HaveOccurred()))
+
+			kept := &corev1.ServiceAccount{}
+			Expect(fakeClient.Get(ctx, serviceAccountNN, kept)).Should(Succeed())
+			Expect(kept.ImagePullSecrets).To(Equal([]corev1.LocalObjectReference{{Name: c.SecretName}}))
+		})
+	})
+
+	Context("When reconciling all ServiceAccounts of a namespace", func() {
+		ctx := context.Background()
+
+		It("should patch them in one pass, reconciling the imagePullSecret once", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:        imagePullSecretData,
+				SecretNamespace:         "kube-system",
+				ServiceAccounts:         "default,builder,deployer",
+				DisableSecretController: true,
+			})
+			namespace, _, _, secretNN := makeObjects("testns-batch-1", "default", c.SecretName)
+			objects := []client.Object{&namespace}
+			for _, name := range []string{"default", "builder", "deployer", "unmanaged"} {
+				objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.GetName()}})
+			}
+			secretGets := 0
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(objects...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, client client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*corev1.Secret); ok && key == secretNN {
+							secretGets++
+						}
+						return client.Get(ctx, key, obj, opts...)
+					},
+				}).
+				Build()
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: fakeClient, Config: config.NewStore(c)}
+
+			result, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace.GetName()}})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(Equal(c.RequeueAfter))
+			Expect(secretGets).To(Equal(1))
+
+			for _, name := range []string{"default", "builder", "deployer"} {
+				found := &corev1.ServiceAccount{}
+				Expect(fakeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace.GetName()}, found)).Should(Succeed())
+				Expect(found.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: c.SecretName}))
+			}
+			unmanaged := &corev1.ServiceAccount{}
+			Expect(fakeClient.Get(ctx, types.NamespacedName{Name: "unmanaged", Namespace: namespace.GetName()}, unmanaged)).Should(Succeed())
+			Expect(unmanaged.ImagePullSecrets).To(BeEmpty())
+		})
+
+		It("should read the namespace once and stop the pass, once the imagePullSecret failed", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:        imagePullSecretData,
+				SecretNamespace:         "kube-system",
+				ServiceAccounts:         "default,builder,deployer",
+				DisableSecretController: true,
+			})
+			namespace, _, _, _ := makeObjects("testns-batch-2", "default", c.SecretName)
+			objects := []client.Object{&namespace}
+			for _, name := range []string{"default", "builder", "deployer"} {
+				objects = append(objects, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.GetName()}})
+			}
+			namespaceGets, secretCreates := 0, 0
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(k8sClient.Scheme()).
+				WithObjects(objects...).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, client client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*corev1.Namespace); ok && key.Name == namespace.GetName() {
+							namespaceGets++
+						}
+						return client.Get(ctx, key, obj, opts...)
+					},
+					Create: func(ctx context.Context, client client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						if _, ok := obj.(*corev1.Secret); ok {
+							secretCreates++
+							return apierrs.NewInternalError(errors.New("etcd unavailable"))
+						}
+						return client.Create(ctx, obj, opts...)
+					},
+				}).
+				Build()
+			serviceAccountReconciler := &ServiceAccountReconciler{Client: fakeClient, Config: config.NewStore(c)}
+
+			_, err := serviceAccountReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace.GetName()}})
+			Expect(err).To(HaveOccurred())
+			Expect(secretCreates).To(Equal(1))
+			Expect(namespaceGets).To(Equal(1))
+
+			for _, name := range []string{"default", "builder", "deployer"} {
+				found := &corev1.ServiceAccount{}
+				Expect(fakeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace.GetName()}, found)).Should(Succeed())
+				Expect(found.ImagePullSecrets).To(BeEmpty())
+			}
+		})
+	})
+
+	Context("When filtering ServiceAccount events", func() {
+		newServiceAccount := func(name string, age time.Duration) *corev1.ServiceAccount {
+			return &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              name,
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				},
+			}
+		}
+		isNew := func(obj client.Object) bool {
+			return time.Since(obj.GetCreationTimestamp().Time) < newServiceAccountWindow
+		}
+		isSwept := func(obj client.Object) bool {
+			return obj.GetName() == "swept"
+		}
+		isManaged := func(obj client.Object) bool {
+			return obj.GetName() != "unmanaged"
+		}
+		isDetachable := func(obj client.Object) bool {
+			return false
+		}
+		createOnly := newServiceAccountPredicate(isNew, isSwept, isManaged)
+		all := serviceAccountPredicate(isNew, isSwept, isManaged, isDetachable)
+
+		It("should pass the creation of new ServiceAccounts to the dedicated controller only", func() {
+			serviceAccount := newServiceAccount("my-chart", time.Second)
+			Expect(createOnly.Create(event.CreateEvent{Object: serviceAccount})).To(BeTrue())
+			Expect(all.Create(event.CreateEvent{Object: serviceAccount})).To(BeFalse())
+		})
+
+		It("should pass the creation of existing ServiceAccounts to the main controller only", func() {
+			serviceAccount := newServiceAccount("default", time.Hour)
+			Expect(createOnly.Create(event.CreateEvent{Object: serviceAccount})).To(BeFalse())
+			Expect(all.Create(event.CreateEvent{Object: serviceAccount})).To(BeTrue())
+		})
+
+		It("should pass nothing but creations to the dedicated controller", func() {
+			serviceAccount := newServiceAccount("my-chart", time.Second)
+			Expect(createOnly.Update(event.UpdateEvent{ObjectOld: serviceAccount, ObjectNew: serviceAccount})).To(BeFalse())
+			Expect(createOnly.Generic(event.GenericEvent{Object: serviceAccount})).To(BeFalse())
+			Expect(createOnly.Delete(event.DeleteEvent{Object: serviceAccount})).To(BeFalse())
+			Expect(all.Update(event.UpdateEvent{ObjectOld: serviceAccount, ObjectNew: serviceAccount})).To(BeTrue())
+		})
+
+		It("should skip ServiceAccounts covered by the initial sweep or unmanaged", func() {
+			for _, serviceAccount := range []*corev1.ServiceAccount{newServiceAccount("swept", time.Second), newServiceAccount("unmanaged", time.Second)} {
+				Expect(createOnly.Create(event.CreateEvent{Object: serviceAccount})).To(BeFalse())
+				Expect(all.Create(event.CreateEvent{Object: serviceAccount})).To(BeFalse())
+			}
+		})
+	})
 })
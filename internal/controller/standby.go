@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// StandbyVerifier periodically audits the cluster from replicas, which are not the leader, and exports
+// the drift as metrics. It never writes, so it can't race the leader, but detects discrepancies the
+// leader misses, e.g. as its watches are stuck.
+type StandbyVerifier struct {
+	client.Client
+	Config  *config.Store
+	Breaker *breaker.Breaker
+	// Elected is closed, once this replica became the leader
+	Elected <-chan struct{}
+}
+
+// NeedLeaderElection ensures the verifier also runs on replicas, which are not the leader
+func (r *StandbyVerifier) NeedLeaderElection() bool {
+	return false
+}
+
+// Start runs a verification every StandbyVerifyInterval, until this replica becomes the leader
+// or ctx is cancelled
+func (r *StandbyVerifier) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.Config.Load().StandbyVerifyInterval)
+	defer ticker.Stop()
+
+	for {
+		// The first verification waits for an interval, as a single replica becomes the leader right away
+		select {
+		case <-ticker.C:
+		case <-r.Elected:
+			log.FromContext(ctx).Info("Became the leader, stopping standby verification")
+			metrics.StandbyNamespacesOutOfSync.Set(0)
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+
+		if err := r.Verify(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "error verifying namespaces")
+		}
+	}
+}
+
+// Verify audits every namespace through a read-only client and reports the namespaces out of sync. The credentials
+// are never read, as only the leader refreshes them from their source.
+func (r *StandbyVerifier) Verify(ctx context.Context) error {
+	c := r.Config.Load()
+	log := log.FromContext(ctx)
+
+	if r.Breaker.IsOpen() {
+		log.Info("Skipping standby verification, as the API server is overloaded")
+		return nil
+	}
+
+	// Only the leader refreshes the credentials, so the imagePullSecrets are compared against the hash it published
+	readOnlyClient := utils.NewReadOnlyClient(r.Client)
+	publishedHash, err := utils.PublishedCredentialHash(ctx, readOnlyClient, c)
+	if err != nil {
+		metrics.StandbyVerificationsTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("error reading the published credential hash: %w", err)
+	}
+	audits, err := utils.AuditClusterPublished(ctx, readOnlyClient, c, publishedHash)
+	if err != nil {
+		metrics.StandbyVerificationsTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("error auditing namespaces: %w", err)
+	}
+
+	outOfSync := 0
+	for _, audit := range audits {
+		if audit.InSync() {
+			continue
+		}
+		outOfSync++
+		log.Info("Standby found namespace '"+audit.Namespace+"' out of sync", "diff", audit.Diff())
+	}
+	metrics.StandbyNamespacesOutOfSync.Set(float64(outOfSync))
+	if outOfSync > 0 {
+		metrics.StandbyVerificationsTotal.WithLabelValues("out_of_sync").Inc()
+	} else {
+		metrics.StandbyVerificationsTotal.WithLabelValues("in_sync").Inc()
+	}
+
+	return nil
+}
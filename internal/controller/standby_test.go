@@ -0,0 +1,102 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+var _ = Describe("Standby Verifier", func() {
+	Context("When verifying from a replica, which is not the leader", func() {
+		ctx := context.Background()
+
+		It("should report drift without correcting it", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				FeatureSecretInAllNamespaces: true,
+				StandbyVerifyInterval:        time.Minute,
+			})
+			namespace, serviceAccount, _, secretNN := makeObjects("testns-standby-1", "default", c.SecretName)
+			standbyClient := fake.NewClientBuilder().WithObjects(&namespace, &serviceAccount).Build()
+
+			before := testutil.ToFloat64(metrics.StandbyVerificationsTotal.WithLabelValues("out_of_sync"))
+			verifier := &StandbyVerifier{Client: standbyClient, Config: config.NewStore(c)}
+			Expect(verifier.Verify(ctx)).Should(Succeed())
+
+			Expect(testutil.ToFloat64(metrics.StandbyNamespacesOutOfSync)).To(Equal(float64(1)))
+			Expect(testutil.ToFloat64(metrics.StandbyVerificationsTotal.WithLabelValues("out_of_sync"))).To(Equal(before + 1))
+			Expect(standbyClient.Get(ctx, secretNN, &corev1.Secret{})).ShouldNot(Succeed())
+		})
+
+		It("should compare against the credential hash published by the leader", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				OperatorNamespace:            "kube-system",
+				FeatureSecretInAllNamespaces: true,
+				StandbyVerifyInterval:        time.Minute,
+			})
+			// The leader still distributes the credentials, which were current before the source was rotated
+			previous := []byte(`{"auths":{"example.com":{"auth":"cHJldmlvdXM6cHJldmlvdXM="}}}`)
+			sum := sha256.Sum256(previous)
+			operatorNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        c.OperatorNamespace,
+				Annotations: map[string]string{c.AnnotationCredentialHash: hex.EncodeToString(sum[:])},
+			}}
+			namespace, _, _, secretNN := makeObjects("testns-standby-2", "default", c.SecretName)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretNN.Name, Namespace: secretNN.Namespace},
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: previous},
+				Type:       corev1.SecretTypeDockerConfigJson,
+			}
+			standbyClient := fake.NewClientBuilder().WithObjects(operatorNamespace, &namespace, secret).Build()
+
+			verifier := &StandbyVerifier{Client: standbyClient, Config: config.NewStore(c)}
+			Expect(verifier.Verify(ctx)).Should(Succeed())
+			Expect(testutil.ToFloat64(metrics.StandbyNamespacesOutOfSync)).To(BeZero())
+		})
+
+		It("should stop, once the replica became the leader", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:      imagePullSecretData,
+				StandbyVerifyInterval: time.Minute,
+			})
+			metrics.StandbyNamespacesOutOfSync.Set(1)
+			elected := make(chan struct{})
+			close(elected)
+
+			verifier := &StandbyVerifier{Client: fake.NewClientBuilder().Build(), Config: config.NewStore(c), Elected: elected}
+			Expect(verifier.Start(ctx)).Should(Succeed())
+			Expect(testutil.ToFloat64(metrics.StandbyNamespacesOutOfSync)).To(BeZero())
+		})
+	})
+})
@@ -31,6 +31,9 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -55,6 +58,8 @@ var _ = BeforeSuite(func() {
 
 	Expect(clientgoscheme.AddToScheme(scheme)).NotTo(HaveOccurred())
 
+	Expect(imagepullsecretv1alpha1.AddToScheme(scheme)).NotTo(HaveOccurred())
+
 	//+kubebuilder:scaffold:scheme
 
 	k8sClient = fake.NewClientBuilder().WithScheme(scheme).Build()
@@ -62,3 +67,8 @@ var _ = BeforeSuite(func() {
 
 	_ = os.Setenv("POD_NAMESPACE", metav1.NamespaceDefault)
 })
+
+// newConfigStore is used by the specs, that shadow the config package.
+func newConfigStore(c *config.Config) *config.Store {
+	return config.NewStore(c)
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/notify"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+const (
+	// sweepLastNamespaceKey and sweepCompletedKey are the keys of the checkpoint ConfigMap
+	sweepLastNamespaceKey = "lastNamespace"
+	sweepCompletedKey     = "completed"
+	// sweepRetryBuffer is the number of failed requests, which may wait to be handed to the controller
+	sweepRetryBuffer = 1024
+	// sweepRetryInterval and sweepMaxRetryInterval bound the backoff, with which an aborted sweep is restarted
+	sweepRetryInterval    = time.Second
+	sweepMaxRetryInterval = 5 * time.Minute
+)
+
+// InitialSweep reconciles the managed ServiceAccounts of all namespaces once, after the controller became
+// the leader, in chunks of SweepChunkSize namespaces ordered by name. The last namespace of every finished
+// chunk is checkpointed in a ConfigMap, so a new leader resumes a sweep interrupted by a failover, instead
+// of starting over from the first namespace.
+type InitialSweep struct {
+	client.Client
+	// APIReader reads the checkpoint, so ConfigMaps don't have to be cached
+	APIReader client.Reader
+	Config    *config.Store
+	// Reconciler reconciles the ServiceAccounts found by the sweep
+	Reconciler reconcile.Reconciler
+
+	startedAt time.Time
+	done      atomic.Bool
+	retries   chan event.TypedGenericEvent[*corev1.ServiceAccount]
+}
+
+// NewInitialSweep returns an InitialSweep, which covers all ServiceAccounts created until now
+func NewInitialSweep(k8sClient client.Client, apiReader client.Reader, c *config.Store) *InitialSweep {
+	return &InitialSweep{
+		Client:    k8sClient,
+		APIReader: apiReader,
+		Config:    c,
+		startedAt: time.Now(),
+		retries:   make(chan event.TypedGenericEvent[*corev1.ServiceAccount], sweepRetryBuffer),
+	}
+}
+
+//+imagepullsecret-patcher:rbac:bundles=serviceaccount-only
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;create;update
+
+// NeedLeaderElection ensures only the leader sweeps and writes the checkpoint
+func (s *InitialSweep) NeedLeaderElection() bool {
+	return true
+}
+
+// Covers checks whether obj is reconciled by the sweep, so its create event can be dropped
+func (s *InitialSweep) Covers(obj client.Object) bool {
+	return !s.done.Load() && obj.GetCreationTimestamp().Time.Before(s.startedAt)
+}
+
+// Retries returns the ServiceAccounts, whose reconciliation failed during the sweep, so
+// the controller retries them with its usual backoff
+func (s *InitialSweep) Retries() <-chan event.TypedGenericEvent[*corev1.ServiceAccount] {
+	return s.retries
+}
+
+// Start runs the sweep, resuming after the checkpointed namespace of an unfinished sweep.
+// An aborted sweep is restarted from its checkpoint with backoff, instead of stopping the manager.
+// If the leadership is lost, the sweep stops and is resumed by the next leader.
+func (s *InitialSweep) Start(ctx context.Context) error {
+	backoff := wait.Backoff{
+		Duration: sweepRetryInterval,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      sweepMaxRetryInterval,
+	}
+	for {
+		err := s.Run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		retryAfter := backoff.Step()
+		log.FromContext(ctx).Error(err, "error running initial sweep, retrying", "retryAfter", retryAfter)
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Run sweeps all namespaces after the checkpointed one
+func (s *InitialSweep) Run(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	checkpoint, err := s.loadCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	resumeAfter := ""
+	if checkpoint.Data[sweepCompletedKey] != "true" {
+		resumeAfter = checkpoint.Data[sweepLastNamespaceKey]
+	}
+	if resumeAfter != "" {
+		log.Info("Resuming initial sweep after namespace '" + resumeAfter + "'")
+	}
+
+	namespaces, err := s.namespaces(ctx, resumeAfter)
+	if err != nil {
+		return err
+	}
+	chunkSize := s.Config.Load().SweepChunkSize
+	for start := 0; start < len(namespaces); start += chunkSize {
+		chunk := namespaces[start:min(start+chunkSize, len(namespaces))]
+		for _, ns := range chunk {
+			if err := s.sweepNamespace(ctx, ns); err != nil {
+				return err
+			}
+		}
+		if err := s.saveCheckpoint(ctx, checkpoint, chunk[len(chunk)-1], false); err != nil {
+			return err
+		}
+	}
+
+	if err := s.saveCheckpoint(ctx, checkpoint, "", true); err != nil {
+		return err
+	}
+	s.done.Store(true)
+	log.Info("Finished initial sweep", "namespaces", len(namespaces))
+	return nil
+}
+
+// namespaces returns the names of all namespaces after resumeAfter, ordered by name
+func (s *InitialSweep) namespaces(ctx context.Context, resumeAfter string) ([]string, error) {
+	namespaceList := &corev1.NamespaceList{}
+	if err := s.List(ctx, namespaceList); err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+
+	names := []string{}
+	for _, ns := range namespaceList.Items {
+		if ns.GetName() > resumeAfter {
+			names = append(names, ns.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sweepNamespace reconciles all managed ServiceAccounts in the namespace. Failed reconciles
+// are handed to the controller. An error is only returned, if the sweep has to be aborted.
+func (s *InitialSweep) sweepNamespace(ctx context.Context, name string) error {
+	ns, err := utils.FetchNamespace(ctx, s.Client, name)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	c := s.Config.Load()
+	if utils.IsNamespaceExcluded(c, ns) {
+		return nil
+	}
+
+	serviceAccountList := &corev1.ServiceAccountList{}
+	if err := s.List(ctx, serviceAccountList, client.InNamespace(name)); err != nil {
+		return fmt.Errorf("error listing ServiceAccounts in namespace '%s': %w", name, err)
+	}
+	for i := range serviceAccountList.Items {
+		serviceAccount := &serviceAccountList.Items[i]
+		if !utils.IsServiceAccountManaged(c, ns, serviceAccount) {
+			continue
+		}
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: name, Name: serviceAccount.GetName()}}
+		if _, err := s.Reconciler.Reconcile(ctx, req); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.FromContext(ctx).Error(err, "error sweeping ServiceAccount, handing it to the controller", "namespace", name, "name", serviceAccount.GetName())
+			select {
+			case s.retries <- event.TypedGenericEvent[*corev1.ServiceAccount]{Object: serviceAccount}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// checkpointName returns the name of the ConfigMap, which is unique per profile
+func (s *InitialSweep) checkpointName() string {
+	return utils.SweepCheckpointName(s.Config.Load())
+}
+
+// loadCheckpoint fetches the checkpoint ConfigMap, or returns an empty one, if it does not exist yet
+func (s *InitialSweep) loadCheckpoint(ctx context.Context) (*corev1.ConfigMap, error) {
+	checkpoint := &corev1.ConfigMap{}
+	err := s.APIReader.Get(ctx, types.NamespacedName{Namespace: s.Config.Load().SecretNamespace, Name: s.checkpointName()}, checkpoint)
+	if apierrs.IsNotFound(err) {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.checkpointName(),
+				Namespace: s.Config.Load().SecretNamespace,
+				Labels: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sweep checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint records lastNamespace as swept, or the whole sweep as completed
+func (s *InitialSweep) saveCheckpoint(ctx context.Context, checkpoint *corev1.ConfigMap, lastNamespace string, completed bool) error {
+	checkpoint.Data = map[string]string{
+		sweepLastNamespaceKey: lastNamespace,
+		sweepCompletedKey:     fmt.Sprint(completed),
+	}
+
+	var err error
+	if checkpoint.GetResourceVersion() == "" {
+		err = s.Create(ctx, checkpoint)
+	} else {
+		err = s.Update(ctx, checkpoint)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving sweep checkpoint: %w", err)
+	}
+	return nil
+}
+
+// SweepOnce reconciles the managed ServiceAccounts of every namespace once, unless FeatureSecretOnly is set,
+// and the imagePullSecret if FeatureSecretInAllNamespaces is set, without watches or leader election, e.g. from a CronJob.
+// It returns the number of failed reconciles.
+func SweepOnce(ctx context.Context, k8sClient client.Client, c *config.Config, notifier *notify.Notifier) (int, error) {
+	log := log.FromContext(ctx)
+	configStore := config.NewStore(c)
+	serviceAccountReconciler := &ServiceAccountReconciler{Client: k8sClient, Config: configStore, Notifier: notifier}
+	namespaceReconciler := &NamespaceReconciler{Client: k8sClient, Config: configStore, Notifier: notifier}
+
+	namespaceList := &corev1.NamespaceList{}
+	if err := k8sClient.List(ctx, namespaceList); err != nil {
+		return 0, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	sort.Slice(namespaceList.Items, func(i, j int) bool {
+		return namespaceList.Items[i].GetName() < namespaceList.Items[j].GetName()
+	})
+
+	failed := 0
+	sweep := func(r reconcile.Reconciler, req ctrl.Request) {
+		result, err := r.Reconcile(ctx, req)
+		if err != nil {
+			log.Error(err, "error sweeping", "namespace", req.Namespace, "name", req.Name)
+			failed++
+		} else if result.RequeueAfter > 0 && result.RequeueAfter != c.RequeueAfter {
+			// Unlike the periodic re-verification, these are left to the next sweep, e.g. while paused
+			log.Info("Not finished, leaving it to the next sweep", "namespace", req.Namespace, "name", req.Name)
+		}
+	}
+	for i := range namespaceList.Items {
+		ns := &namespaceList.Items[i]
+		if utils.IsNamespaceExcluded(c, ns) {
+			continue
+		}
+		if c.FeatureSecretInAllNamespaces {
+			sweep(namespaceReconciler, ctrl.Request{NamespacedName: types.NamespacedName{Name: ns.GetName()}})
+		}
+		if c.FeatureSecretOnly {
+			continue
+		}
+
+		serviceAccountList := &corev1.ServiceAccountList{}
+		if err := k8sClient.List(ctx, serviceAccountList, client.InNamespace(ns.GetName())); err != nil {
+			return failed, fmt.Errorf("error listing ServiceAccounts in namespace '%s': %w", ns.GetName(), err)
+		}
+		for j := range serviceAccountList.Items {
+			if utils.IsServiceAccountManaged(c, ns, &serviceAccountList.Items[j]) {
+				sweep(serviceAccountReconciler, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&serviceAccountList.Items[j])})
+			}
+		}
+	}
+	log.Info("Finished sweep", "namespaces", len(namespaceList.Items), "failed", failed)
+	return failed, nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/suggest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// recordingReconciler records the swept requests and fails for the requests in failures
+type recordingReconciler struct {
+	mu       sync.Mutex
+	requests []string
+	failures map[string]bool
+}
+
+func (r *recordingReconciler) Reconcile(_ context.Context, req reconcile.Request) (reconcile.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Namespace)
+	if r.failures[req.Namespace] {
+		return reconcile.Result{}, errors.New("failed")
+	}
+	return reconcile.Result{}, nil
+}
+
+var _ = Describe("InitialSweep", func() {
+	Context("When sweeping namespaces", func() {
+		ctx := context.Background()
+		c := config.NewConfig(config.ConfigOptions{
+			DockerConfigJSON:       imagePullSecretData,
+			SecretNamespace:        "kube-system",
+			FeatureSweepCheckpoint: true,
+			SweepChunkSize:         2,
+		})
+		newSweep := func(reconciler reconcile.Reconciler) *InitialSweep {
+			sweepClient := fake.NewClientBuilder().WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-c"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-b"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kube-system"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-a"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-b"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-c"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "sweep-c"}},
+			).Build()
+			sweep := NewInitialSweep(sweepClient, sweepClient, config.NewStore(c))
+			sweep.Reconciler = reconciler
+			return sweep
+		}
+		checkpointNN := types.NamespacedName{Name: "imagepullsecret-patcher-sweep-default", Namespace: "kube-system"}
+
+		It("should sweep the managed ServiceAccounts of all namespaces in order and complete the checkpoint", func() {
+			reconciler := &recordingReconciler{}
+			sweep := newSweep(reconciler)
+			serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Now()}}
+			Expect(sweep.Covers(serviceAccount)).Should(BeFalse())
+			serviceAccount.CreationTimestamp = metav1.NewTime(sweep.startedAt.Add(-1))
+			Expect(sweep.Covers(serviceAccount)).Should(BeTrue())
+
+			Expect(sweep.Start(ctx)).Should(Succeed())
+			Expect(reconciler.requests).Should(Equal([]string{"sweep-a", "sweep-b", "sweep-c"}))
+			Expect(sweep.Covers(serviceAccount)).Should(BeFalse())
+
+			checkpoint := &corev1.ConfigMap{}
+			Expect(sweep.Get(ctx, checkpointNN, checkpoint)).Should(Succeed())
+			Expect(checkpoint.Data).Should(HaveKeyWithValue(sweepCompletedKey, "true"))
+		})
+
+		It("should resume after the checkpointed namespace of an interrupted sweep", func() {
+			reconciler := &recordingReconciler{}
+			sweep := newSweep(reconciler)
+			Expect(sweep.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: checkpointNN.Name, Namespace: checkpointNN.Namespace},
+				Data: map[string]string{
+					sweepLastNamespaceKey: "sweep-b",
+					sweepCompletedKey:     "false",
+				},
+			})).Should(Succeed())
+
+			Expect(sweep.Start(ctx)).Should(Succeed())
+			Expect(reconciler.requests).Should(Equal([]string{"sweep-c"}))
+		})
+
+		It("should start over, if the last sweep completed", func() {
+			reconciler := &recordingReconciler{}
+			sweep := newSweep(reconciler)
+			Expect(sweep.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: checkpointNN.Name, Namespace: checkpointNN.Namespace},
+				Data: map[string]string{
+					sweepLastNamespaceKey: "",
+					sweepCompletedKey:     "true",
+				},
+			})).Should(Succeed())
+
+			Expect(sweep.Start(ctx)).Should(Succeed())
+			Expect(reconciler.requests).Should(Equal([]string{"sweep-a", "sweep-b", "sweep-c"}))
+		})
+
+		It("should hand failed ServiceAccounts to the controller", func() {
+			reconciler := &recordingReconciler{failures: map[string]bool{"sweep-b": true}}
+			sweep := newSweep(reconciler)
+
+			Expect(sweep.Start(ctx)).Should(Succeed())
+			Expect(reconciler.requests).Should(Equal([]string{"sweep-a", "sweep-b", "sweep-c"}))
+			var retry event.TypedGenericEvent[*corev1.ServiceAccount]
+			Expect(sweep.Retries()).Should(Receive(&retry))
+			Expect(retry.Object.GetNamespace()).Should(Equal("sweep-b"))
+			Expect(sweep.Retries()).ShouldNot(Receive())
+		})
+
+		It("should restart an aborted sweep with backoff instead of returning the error", func() {
+			reconciler := &recordingReconciler{}
+			sweep := newSweep(reconciler)
+			var failures atomic.Int32
+			sweep.APIReader = interceptor.NewClient(sweep.Client.(client.WithWatch), interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if failures.Add(1) == 1 {
+						return errors.New("unavailable")
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			})
+
+			Expect(sweep.Start(ctx)).Should(Succeed())
+			Expect(failures.Load()).Should(BeNumerically(">", 1))
+			Expect(reconciler.requests).Should(Equal([]string{"sweep-a", "sweep-b", "sweep-c"}))
+		})
+
+		It("should stop retrying, if the leadership is lost", func() {
+			sweep := newSweep(&recordingReconciler{})
+			sweep.APIReader = interceptor.NewClient(sweep.Client.(client.WithWatch), interceptor.Funcs{
+				Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+					return errors.New("unavailable")
+				},
+			})
+			stopCtx, cancel := context.WithTimeout(ctx, 2*sweepRetryInterval)
+			defer cancel()
+
+			Expect(sweep.Start(stopCtx)).Should(Succeed())
+		})
+	})
+})
+
+var _ = Describe("SweepOnce", func() {
+	Context("When sweeping every namespace once", func() {
+		ctx := context.Background()
+
+		It("should attach the imagePullSecret to the managed ServiceAccounts and create it in all namespaces, if requested", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				FeatureSecretInAllNamespaces: true,
+			})
+			sweepClient := fake.NewClientBuilder().WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-once-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-once-b"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "kube-system"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-once-a"}},
+			).Build()
+
+			failed, err := SweepOnce(ctx, sweepClient, c, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(failed).Should(Equal(0))
+
+			serviceAccount := &corev1.ServiceAccount{}
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: "default", Namespace: "sweep-once-a"}, serviceAccount)).Should(Succeed())
+			Expect(serviceAccount.ImagePullSecrets).Should(ContainElement(corev1.LocalObjectReference{Name: c.SecretName}))
+			for _, namespace := range []string{"sweep-once-a", "sweep-once-b"} {
+				Expect(sweepClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: namespace}, &corev1.Secret{})).Should(Succeed())
+			}
+
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: "default", Namespace: "kube-system"}, serviceAccount)).Should(Succeed())
+			Expect(serviceAccount.ImagePullSecrets).Should(BeEmpty())
+		})
+
+		It("should only create the imagePullSecret in all namespaces in secret-only mode", func() {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:  imagePullSecretData,
+				SecretNamespace:   "kube-system",
+				FeatureSecretOnly: true,
+			})
+			sweepClient := fake.NewClientBuilder().WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-once-a"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-once-a"}},
+			).Build()
+
+			failed, err := SweepOnce(ctx, sweepClient, c, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(failed).Should(Equal(0))
+
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: "sweep-once-a"}, &corev1.Secret{})).Should(Succeed())
+			serviceAccount := &corev1.ServiceAccount{}
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: "default", Namespace: "sweep-once-a"}, serviceAccount)).Should(Succeed())
+			Expect(serviceAccount.ImagePullSecrets).Should(BeEmpty())
+		})
+
+		It("should only write suggestions in suggestion mode and leave the cluster unchanged", func() {
+			dir := GinkgoT().TempDir()
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:             imagePullSecretData,
+				SecretNamespace:              "kube-system",
+				FeatureSecretInAllNamespaces: true,
+				SuggestionOutput:             dir,
+			})
+			sweepClient := fake.NewClientBuilder().WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sweep-once-a"}},
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sweep-once-a"}},
+			).Build()
+
+			failed, err := SweepOnce(ctx, suggest.NewClient(sweepClient, c), c, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(failed).Should(Equal(0))
+
+			Expect(filepath.Join(dir, "sweep-once-a.secret."+c.SecretName+".json")).Should(BeAnExistingFile())
+			Expect(filepath.Join(dir, "sweep-once-a.serviceaccount.default.json")).Should(BeAnExistingFile())
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: "sweep-once-a"}, &corev1.Secret{})).ShouldNot(Succeed())
+			serviceAccount := &corev1.ServiceAccount{}
+			Expect(sweepClient.Get(ctx, types.NamespacedName{Name: "default", Namespace: "sweep-once-a"}, serviceAccount)).Should(Succeed())
+			Expect(serviceAccount.ImagePullSecrets).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,215 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/breaker"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/teardown"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// WorkloadReconciler attaches the imagePullSecret to the Pod template of workloads,
+// for clusters where mutating ServiceAccounts is not allowed
+type WorkloadReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config *config.Store
+	// Breaker defers reconciles during API server incidents
+	Breaker *breaker.Breaker
+	// Kind of the reconciled workloads. One of Deployment, StatefulSet or CronJob
+	Kind string
+}
+
+//+imagepullsecret-patcher:rbac:bundles=patch-workloads
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;update;patch
+//+imagepullsecret-patcher:rbac:bundles=patch-workloads
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	c := r.Config.Load()
+	log := log.FromContext(ctx)
+
+	workload, err := newWorkload(r.Kind)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err = r.Get(ctx, req.NamespacedName, workload); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	ns, err := utils.FetchNamespace(ctx, r.Client, workload.GetNamespace())
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to fetch namespace: %w", err)
+	}
+	if !utils.IsWorkloadManaged(c, ns, workload) {
+		return ctrl.Result{}, nil
+	}
+
+	if paused, err := utils.IsPaused(ctx, r.Client, c); err != nil {
+		return ctrl.Result{}, err
+	} else if paused {
+		drift, err := utils.HasImagePullSecretDrift(ctx, r.Client, c, c.SecretName, workload.GetNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if drift || !hasImagePullSecret(getPodSpec(workload), c.SecretName) {
+			utils.ReportDrift(ctx, r.Kind, workload.GetNamespace(), workload.GetName())
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueAfter}, nil
+	}
+
+	// Ensure imagePullSecret exists before we attach it to the workload
+	result, err := utils.ReconcileImagePullSecret(ctx, r.Client, c, c.SecretName, workload.GetNamespace())
+	observeResult("Secret", result)
+	utils.RecordSecretCondition(ctx, r.Client, c, workload.GetNamespace(), result, err)
+	utils.RecordNamespaceSync(ctx, r.Client, c, workload.GetNamespace(), err)
+	if utils.IsOwnershipConflict(err) {
+		log.Info("imagePullSecret in namespace '" + workload.GetNamespace() + "' is managed by someone else, requeuing after " + ownershipConflictRequeueAfter.String())
+		return ctrl.Result{RequeueAfter: ownershipConflictRequeueAfter}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("Failed to reconcile imagePullSecret in Namespace '"+workload.GetNamespace()+"': %w", err)
+	}
+
+	result, err = r.attachImagePullSecret(ctx, workload)
+	observeResult(r.Kind, result)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.Changed() {
+		log.Info("Attached ImagePullSecret to " + r.Kind + " '" + workload.GetName() + "' in namespace '" + workload.GetNamespace() + "'")
+		eventlog.Record(eventlog.ActionWorkloadPatched, workload.GetNamespace(), workload.GetName(), r.Kind)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// attachImagePullSecret adds the imagePullSecret to the Pod template of workload, if it's missing
+func (r *WorkloadReconciler) attachImagePullSecret(ctx context.Context, workload client.Object) (utils.ReconcileResult, error) {
+	c := r.Config.Load()
+	patchFrom := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	podSpec := getPodSpec(workload)
+	if hasImagePullSecret(podSpec, c.SecretName) {
+		return utils.ResultNoOp, nil
+	}
+	podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, corev1.LocalObjectReference{Name: c.SecretName})
+
+	if err := r.Patch(ctx, workload, patchFrom); err != nil {
+		return utils.ResultFailed, fmt.Errorf("Failed to patch ImagePullSecret to "+r.Kind+" '"+workload.GetName()+"' in namespace '"+workload.GetNamespace()+"': %w", err)
+	}
+	return utils.ResultPatched, nil
+}
+
+// newWorkload returns an empty object of the workload kind
+func newWorkload(kind string) (client.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}, nil
+	case "CronJob":
+		return &batchv1.CronJob{}, nil
+	}
+	return nil, fmt.Errorf("unsupported workload kind '%s'", kind)
+}
+
+// getPodSpec returns the Pod template spec of the workload
+func getPodSpec(workload client.Object) *corev1.PodSpec {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		return &w.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &w.Spec.Template.Spec
+	case *batchv1.CronJob:
+		return &w.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+// hasImagePullSecret checks whether the Pod template references the imagePullSecret
+func hasImagePullSecret(podSpec *corev1.PodSpec, secretName string) bool {
+	for _, imagePullSecret := range podSpec.ImagePullSecrets {
+		if imagePullSecret.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WorkloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.TODO()
+	workload, err := newWorkload(r.Kind)
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(r.Kind + "Controller").
+		WithOptions(controllerOptions(r.Config.Load())).
+		For(workload).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
+				if err != nil {
+					return false
+				}
+				return utils.IsWorkloadManaged(r.Config.Load(), ns, e.Object)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				ns, err := utils.FetchNamespace(ctx, r.Client, e.ObjectNew.GetNamespace())
+				if err != nil {
+					return false
+				}
+				return utils.IsWorkloadManaged(r.Config.Load(), ns, e.ObjectNew)
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				ns, err := utils.FetchNamespace(ctx, r.Client, e.Object.GetNamespace())
+				if err != nil {
+					return false
+				}
+				return utils.IsWorkloadManaged(r.Config.Load(), ns, e.Object)
+			},
+			// Ignore Deletion events
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+		}).
+		WithEventFilter(ignoreOwnChanges).
+		Complete(teardown.Default.Reconciler(r.Breaker.Reconciler(r)))
+}
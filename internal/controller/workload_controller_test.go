@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Workload Controller", func() {
+	Context("When reconciling a Deployment", func() {
+		var err error
+		ctx := context.Background()
+		config := config.NewConfig(
+			config.ConfigOptions{
+				DockerConfigJSON:      imagePullSecretData,
+				SecretNamespace:       "kube-system",
+				FeaturePatchWorkloads: true,
+				WorkloadSelector:      "team=platform",
+			},
+		)
+
+		It("should attach the Secret to the Pod template of matching Deployments only", func() {
+			namespace, _, _, secretNN := makeObjects("testns-workload-1", "default", config.SecretName)
+
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace.DeepCopy())).Should(Succeed())
+
+			By("Creating the Deployments to reconcile")
+			matching := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "matching",
+					Namespace: namespace.GetName(),
+					Labels: map[string]string{
+						"team": "platform",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, matching)).Should(Succeed())
+			unrelated := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unrelated",
+					Namespace: namespace.GetName(),
+				},
+			}
+			Expect(k8sClient.Create(ctx, unrelated)).Should(Succeed())
+
+			By("Reconciling the Deployments")
+			workloadReconciler := &WorkloadReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Config: newConfigStore(config),
+				Kind:   "Deployment",
+			}
+			for _, deployment := range []*appsv1.Deployment{matching, unrelated} {
+				_, err = workloadReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: deployment.GetName(), Namespace: deployment.GetNamespace()},
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			By("Checking if Secret was successfully created in the reconciliation")
+			Expect(k8sClient.Get(ctx, secretNN, &corev1.Secret{})).Should(Succeed())
+
+			By("Checking if the Secret was attached to the matching Deployment")
+			found := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: matching.GetName(), Namespace: matching.GetNamespace()}, found)).Should(Succeed())
+			Expect(found.Spec.Template.Spec.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: config.SecretName}))
+
+			By("Checking if the Secret was NOT attached to the unrelated Deployment")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: unrelated.GetName(), Namespace: unrelated.GetNamespace()}, found)).Should(Succeed())
+			Expect(found.Spec.Template.Spec.ImagePullSecrets).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,223 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package devcluster provides an in-memory cluster for local development, so the reconcile logic can be
+// exercised and demoed without a live cluster or envtest binaries.
+package devcluster
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Load reads the objects of the multi-document YAML fixture at path, e.g. Namespaces and ServiceAccounts.
+// Every kind has to be registered in scheme.
+func Load(path string, scheme *runtime.Scheme) ([]client.Object, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening fixture: %w", err)
+	}
+	defer file.Close()
+
+	return Decode(file, scheme)
+}
+
+// Decode reads the objects of a multi-document YAML stream. Empty documents are skipped.
+func Decode(r io.Reader, scheme *runtime.Scheme) ([]client.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	objects := []client.Object{}
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading fixture: %w", err)
+		}
+		if len(document) == 0 || isEmpty(document) {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(document, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding fixture: %w", err)
+		}
+		object, ok := obj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("error decoding fixture: %T is not an object", obj)
+		}
+		objects = append(objects, object)
+	}
+}
+
+// NewClient returns a fake client holding objects, which behaves like an API server for the controllers
+func NewClient(scheme *runtime.Scheme, objects ...client.Object) client.WithWatch {
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+// isEmpty checks whether a YAML document only holds whitespace and comments
+func isEmpty(document []byte) bool {
+	json, err := utilyaml.ToJSON(document)
+	return err == nil && string(json) == "null"
+}
+
+// Snapshot holds the state of the Namespaces, ServiceAccounts and Secrets of a cluster as indented JSON,
+// keyed by kind, namespace and name. The data of Secrets is replaced with its hash.
+type Snapshot map[string]string
+
+// TakeSnapshot records the Namespaces, ServiceAccounts and Secrets of k8sClient
+func TakeSnapshot(ctx context.Context, k8sClient client.Client) (Snapshot, error) {
+	snapshot := Snapshot{}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := k8sClient.List(ctx, namespaces); err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	for i := range namespaces.Items {
+		if err := snapshot.add("Namespace", &namespaces.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := k8sClient.List(ctx, serviceAccounts); err != nil {
+		return nil, fmt.Errorf("error listing ServiceAccounts: %w", err)
+	}
+	for i := range serviceAccounts.Items {
+		if err := snapshot.add("ServiceAccount", &serviceAccounts.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+	secrets := &corev1.SecretList{}
+	if err := k8sClient.List(ctx, secrets); err != nil {
+		return nil, fmt.Errorf("error listing Secrets: %w", err)
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		// Unlike Data, StringData is readable in the diff
+		hashes := map[string]string{}
+		for key, value := range secret.Data {
+			sum := sha256.Sum256(value)
+			hashes[key] = "sha256:" + hex.EncodeToString(sum[:])[:12]
+		}
+		secret.Data = nil
+		secret.StringData = hashes
+		if err := snapshot.add("Secret", secret); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// add records obj of kind without its bookkeeping fields
+func (s Snapshot) add(kind string, obj client.Object) error {
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	content, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s '%s': %w", kind, obj.GetName(), err)
+	}
+	key := kind + " " + obj.GetName()
+	if obj.GetNamespace() != "" {
+		key = kind + " " + obj.GetNamespace() + "/" + obj.GetName()
+	}
+	s[key] = string(content)
+	return nil
+}
+
+// WriteDiff writes the objects created, deleted and changed from before to after to w,
+// the changes as line diff. It returns the number of objects, which differ.
+func WriteDiff(w io.Writer, before Snapshot, after Snapshot) int {
+	keys := []string{}
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changed := 0
+	for _, key := range keys {
+		old, existed := before[key]
+		current, exists := after[key]
+		switch {
+		case !existed:
+			fmt.Fprintf(w, "+ %s created\n", key)
+		case !exists:
+			fmt.Fprintf(w, "- %s deleted\n", key)
+		case old != current:
+			fmt.Fprintf(w, "~ %s changed\n", key)
+			writeLineDiff(w, strings.Split(old, "\n"), strings.Split(current, "\n"))
+		default:
+			continue
+		}
+		changed++
+	}
+	return changed
+}
+
+// writeLineDiff writes the lines removed from a and added in b, based on their longest common subsequence
+func writeLineDiff(w io.Writer, a []string, b []string) {
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || common[i][j+1] >= common[i+1][j]):
+			fmt.Fprintf(w, "    + %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(w, "    - %s\n", a[i])
+			i++
+		}
+	}
+}
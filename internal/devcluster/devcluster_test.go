@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devcluster
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const fixture = `
+# Namespaces of the demo
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: builder
+  namespace: team-a
+`
+
+func Test_Decode(t *testing.T) {
+	objects, err := Decode(strings.NewReader(fixture), scheme.Scheme)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("Decode() returned %d objects, want 2", len(objects))
+	}
+	if _, ok := objects[0].(*corev1.Namespace); !ok {
+		t.Errorf("Decode() returned %T, want *v1.Namespace", objects[0])
+	}
+
+	k8sClient := NewClient(scheme.Scheme, objects...)
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "builder"}, serviceAccount); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+}
+
+func Test_Decode_UnknownKind(t *testing.T) {
+	_, err := Decode(strings.NewReader("apiVersion: example.com/v1\nkind: Unknown\nmetadata:\n  name: x\n"), scheme.Scheme)
+	if err == nil {
+		t.Error("Decode() succeeded, want an error for an unregistered kind")
+	}
+}
+
+func Test_WriteDiff(t *testing.T) {
+	ctx := context.Background()
+	k8sClient := NewClient(scheme.Scheme,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "builder", Namespace: "team-a"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"}},
+	)
+	before, err := TakeSnapshot(ctx, k8sClient)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "team-a"},
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("top-secret")},
+	}
+	if err := k8sClient.Create(ctx, secret); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	builder := &corev1.ServiceAccount{}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "builder"}, builder); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	builder.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "pull-secret"}}
+	if err := k8sClient.Update(ctx, builder); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	deployer := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "team-a"}}
+	if err := k8sClient.Delete(ctx, deployer); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	after, err := TakeSnapshot(ctx, k8sClient)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	out := &bytes.Buffer{}
+	if changed := WriteDiff(out, before, after); changed != 3 {
+		t.Errorf("WriteDiff() = %d, want 3", changed)
+	}
+	for _, want := range []string{
+		"+ Secret team-a/pull-secret created",
+		"~ ServiceAccount team-a/builder changed",
+		`    +       "name": "pull-secret"`,
+		"- ServiceAccount team-a/deployer deleted",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteDiff() = %q, want it to contain %q", out.String(), want)
+		}
+	}
+	if strings.Contains(out.String(), "Namespace team-a") {
+		t.Errorf("WriteDiff() = %q, want the unchanged Namespace to be omitted", out.String())
+	}
+	if !strings.Contains(after["Secret team-a/pull-secret"], `"sha256:`) {
+		t.Errorf("TakeSnapshot() = %q, want the data of the Secret to be hashed", after["Secret team-a/pull-secret"])
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventlog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+)
+
+// Actions recorded in the event log
+const (
+	ActionSecretCreated         = "SecretCreated"
+	ActionSecretUpdated         = "SecretUpdated"
+	ActionSecretDeleted         = "SecretDeleted"
+	ActionServiceAccountPatched = "ServiceAccountPatched"
+	ActionWorkloadPatched       = "WorkloadPatched"
+	ActionPodDeleted            = "PodDeleted"
+	ActionError                 = "Error"
+)
+
+// Entry is a single significant action of the controller
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	// Hash identifies the written data, e.g. the credentials of a Secret
+	Hash string `json:"hash,omitempty"`
+}
+
+// Log is a ring buffer, which keeps the last entries in memory
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	sink    func(Entry)
+}
+
+// Default is the event log recorded to by the controllers
+var Default = New(100)
+
+// New returns a Log keeping the last size entries
+func New(size int) *Log {
+	if size < 1 {
+		size = 1
+	}
+	return &Log{entries: make([]Entry, size)}
+}
+
+// SetSink passes every entry recorded from now on to sink, e.g. to persist it. sink is called without
+// holding the lock of the log, so it may block to slow down the callers, until the entry is persisted.
+func (l *Log) SetSink(sink func(Entry)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sink = sink
+}
+
+// Record adds an entry to the log, overwriting the oldest one if it's full
+func (l *Log) Record(action string, namespace string, name string, message string) {
+	l.RecordHash(action, namespace, name, message, "")
+}
+
+// RecordHash adds an entry with the hash of the written data to the log
+func (l *Log) RecordHash(action string, namespace string, name string, message string, hash string) {
+	entry := Entry{
+		Time:      time.Now(),
+		Action:    action,
+		Namespace: namespace,
+		Name:      name,
+		Message:   redact.String(message),
+		Hash:      hash,
+	}
+
+	l.mu.Lock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	sink := l.sink
+	l.mu.Unlock()
+
+	if sink != nil {
+		sink(entry)
+	}
+}
+
+// Entries returns all entries, oldest first
+func (l *Log) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]Entry{}, l.entries[:l.next]...)
+	}
+	return append(append([]Entry{}, l.entries[l.next:]...), l.entries[:l.next]...)
+}
+
+// Record adds an entry to the Default log
+func Record(action string, namespace string, name string, message string) {
+	Default.Record(action, namespace, name, message)
+}
+
+// RecordHash adds an entry with the hash of the written data to the Default log
+func RecordHash(action string, namespace string, name string, message string, hash string) {
+	Default.RecordHash(action, namespace, name, message, hash)
+}
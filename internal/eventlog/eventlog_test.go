@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Log(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		records []string
+		want    []string
+	}{
+		{
+			name:    "Empty log. Should return no entries.",
+			size:    3,
+			records: nil,
+			want:    []string{},
+		},
+		{
+			name:    "Log not full. Should return all entries.",
+			size:    3,
+			records: []string{"a", "b"},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "Log wrapped around. Should return the last entries, oldest first.",
+			size:    3,
+			records: []string{"a", "b", "c", "d", "e"},
+			want:    []string{"c", "d", "e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := New(tt.size)
+			for _, name := range tt.records {
+				log.Record(ActionSecretCreated, "default", name, "")
+			}
+			got := []string{}
+			for _, entry := range log.Entries() {
+				got = append(got, entry.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fairness
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// pruneInterval is how often limiters of idle namespaces are forgotten
+const pruneInterval = time.Minute
+
+// Limiter rate limits the requests added to the workqueues per namespace, so a namespace
+// generating a storm of events can't starve the reconciles of other namespaces
+type Limiter struct {
+	qps   rate.Limit
+	burst int
+
+	mu         sync.Mutex
+	namespaces map[string]*rate.Limiter
+	prunedAt   time.Time
+	now        func() time.Time
+}
+
+// Default is the Limiter used by the controllers. It doesn't limit anything, until replaced.
+var Default = New(0, 0)
+
+// New returns a Limiter, allowing qps requests per second per namespace, with bursts of up to burst
+// requests. If qps is not positive, requests are not limited.
+func New(qps int, burst int) *Limiter {
+	return &Limiter{
+		qps:        rate.Limit(qps),
+		burst:      burst,
+		namespaces: map[string]*rate.Limiter{},
+		now:        time.Now,
+	}
+}
+
+// Delay reserves a request for namespace and returns how long it has to wait
+func (l *Limiter) Delay(namespace string) time.Duration {
+	if l.qps <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	limiter, ok := l.namespaces[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(l.qps, l.burst)
+		l.namespaces[namespace] = limiter
+	}
+	return limiter.ReserveN(now, 1).DelayFrom(now)
+}
+
+// prune forgets the limiters of namespaces, which were idle long enough to refill their bursts. The caller must hold mu.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.prunedAt) < pruneInterval {
+		return
+	}
+	l.prunedAt = now
+	for namespace, limiter := range l.namespaces {
+		if limiter.TokensAt(now) >= float64(l.burst) {
+			delete(l.namespaces, namespace)
+		}
+	}
+}
+
+// queue delays requests of namespaces exceeding their rate, instead of handing them to the workers right away
+type queue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	controller string
+	limiter    *Limiter
+
+	mu      sync.Mutex
+	delayed map[reconcile.Request]struct{}
+}
+
+// Queue wraps next, so requests are rate limited per namespace. Requeues after errors or
+// with RequeueAfter are not limited, as they are already delayed.
+func (l *Limiter) Queue(controllerName string, next workqueue.TypedRateLimitingInterface[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+	return &queue{
+		TypedRateLimitingInterface: next,
+		controller:                 controllerName,
+		limiter:                    l,
+		delayed:                    map[reconcile.Request]struct{}{},
+	}
+}
+
+func (q *queue) Add(item reconcile.Request) {
+	q.mu.Lock()
+	if _, ok := q.delayed[item]; ok {
+		// Already waiting for its turn, there's no need to reserve another one
+		q.mu.Unlock()
+		return
+	}
+	delay := q.limiter.Delay(namespaceOf(item))
+	if delay > 0 {
+		q.delayed[item] = struct{}{}
+	}
+	q.mu.Unlock()
+
+	if delay == 0 {
+		q.TypedRateLimitingInterface.Add(item)
+		return
+	}
+	metrics.NamespaceRateLimitedTotal.WithLabelValues(q.controller).Inc()
+	q.TypedRateLimitingInterface.AddAfter(item, delay)
+}
+
+func (q *queue) Get() (reconcile.Request, bool) {
+	item, shutdown := q.TypedRateLimitingInterface.Get()
+	if !shutdown {
+		q.mu.Lock()
+		delete(q.delayed, item)
+		q.mu.Unlock()
+	}
+	return item, shutdown
+}
+
+// namespaceOf returns the namespace of item, or its name for cluster-scoped objects, e.g. Namespaces
+func namespaceOf(item reconcile.Request) string {
+	if item.Namespace == "" {
+		return item.Name
+	}
+	return item.Namespace
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fairness
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func request(namespace string, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+}
+
+func Test_Limiter_Delay(t *testing.T) {
+	now := time.Now()
+	limiter := New(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if delay := limiter.Delay("noisy"); delay != 0 {
+			t.Errorf("request %d within the burst should not be delayed, got %s", i, delay)
+		}
+	}
+	if delay := limiter.Delay("noisy"); delay != time.Second {
+		t.Errorf("request exceeding the burst should be delayed by 1s, got %s", delay)
+	}
+	if delay := limiter.Delay("quiet"); delay != 0 {
+		t.Errorf("request of another namespace should not be delayed, got %s", delay)
+	}
+
+	now = now.Add(2 * pruneInterval)
+	limiter.Delay("quiet")
+	if _, ok := limiter.namespaces["noisy"]; ok {
+		t.Errorf("limiter of an idle namespace should be pruned")
+	}
+}
+
+func Test_Limiter_Disabled(t *testing.T) {
+	limiter := New(-1, 0)
+	for i := 0; i < 10; i++ {
+		if delay := limiter.Delay("noisy"); delay != 0 {
+			t.Errorf("request should not be delayed by a disabled limiter, got %s", delay)
+		}
+	}
+}
+
+func Test_Queue(t *testing.T) {
+	limiter := New(1, 1)
+	q := limiter.Queue("test", workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]()))
+	defer q.ShutDown()
+
+	q.Add(request("noisy", "first"))
+	q.Add(request("noisy", "second"))
+	q.Add(request("noisy", "second"))
+	q.Add(request("quiet", "first"))
+
+	if q.Len() != 2 {
+		t.Errorf("only the requests within the burst should be queued right away, got %d", q.Len())
+	}
+	if delayed := len(q.(*queue).delayed); delayed != 1 {
+		t.Errorf("the delayed request should be tracked once, got %d", delayed)
+	}
+
+	for i := 0; i < 3; i++ {
+		item, _ := q.Get()
+		q.Done(item)
+	}
+	if delayed := len(q.(*queue).delayed); delayed != 0 {
+		t.Errorf("the delayed request should no longer be tracked, once handed to a worker, got %d", delayed)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// Window is a maintenance window, which opens at every time matching Schedule and stays open for Duration
+type Window struct {
+	Schedule *Schedule
+	Duration time.Duration
+}
+
+// Windows are the maintenance windows, in which Pods may be deleted. A nil Windows is always open.
+type Windows struct {
+	windows  []Window
+	location *time.Location
+}
+
+// ParseWindows parses a semicolon-separated list of maintenance windows, each a cron expression followed by
+// its duration, e.g. `0 22 * * 1-5 8h; 0 0 * * 0,6 24h`. The cron expressions are evaluated in timezone.
+// It returns nil, if spec is empty.
+func ParseWindows(spec string, timezone string) (*Windows, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+
+	windows := &Windows{location: location}
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		duration, err := time.ParseDuration(fields[len(fields)-1])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration in maintenance window %q", strings.TrimSpace(entry))
+		}
+		schedule, err := ParseSchedule(strings.Join(fields[:len(fields)-1], " "))
+		if err != nil {
+			return nil, err
+		}
+		windows.windows = append(windows.windows, Window{Schedule: schedule, Duration: duration})
+	}
+	return windows, nil
+}
+
+// Open checks whether t is within one of the windows
+func (w *Windows) Open(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	t = t.In(w.location)
+	for _, window := range w.windows {
+		// The window is open, if it started after t-Duration, but not after t
+		if start := window.Schedule.Next(t.Add(-window.Duration)); !start.IsZero() && !start.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t, if one of the windows is open at t, or the time the next one opens. It returns the
+// zero time, if none of them opens within the next five years.
+func (w *Windows) NextOpen(t time.Time) time.Time {
+	if w.Open(t) {
+		return t
+	}
+	next := time.Time{}
+	for _, window := range w.windows {
+		if start := window.Schedule.Next(t.In(w.location)); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next
+}
+
+// Default is the queue of Pod cleanups deferred until the next maintenance window
+var Default = NewQueue()
+
+// Queue collects the namespaces, whose Pod cleanup was deferred. Each namespace is queued once,
+// as a single cleanup of the namespace covers the Pods of all its ServiceAccounts.
+type Queue struct {
+	mu         sync.Mutex
+	namespaces map[string]struct{}
+}
+
+// NewQueue returns an empty Queue
+func NewQueue() *Queue {
+	return &Queue{namespaces: map[string]struct{}{}}
+}
+
+// Add queues the Pod cleanup of namespace
+func (q *Queue) Add(namespace string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.namespaces[namespace] = struct{}{}
+	metrics.PodCleanupsDeferred.Set(float64(len(q.namespaces)))
+}
+
+// Len returns the number of queued namespaces
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.namespaces)
+}
+
+// Drain removes all namespaces from the queue and returns them, sorted
+func (q *Queue) Drain() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	namespaces := make([]string, 0, len(q.namespaces))
+	for namespace := range q.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	q.namespaces = map[string]struct{}{}
+	metrics.PodCleanupsDeferred.Set(0)
+	return namespaces
+}
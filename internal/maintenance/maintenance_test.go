@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{"Empty. Should be always open.", "", "UTC", true, false},
+		{"Two windows. Should be valid.", "0 22 * * 1-5 8h; 0 0 * * 0,6 24h", "Europe/Berlin", false, false},
+		{"Missing duration. Should be invalid.", "0 22 * * *", "UTC", false, true},
+		{"Negative duration. Should be invalid.", "0 22 * * * -1h", "UTC", false, true},
+		{"Unknown timezone. Should be invalid.", "0 22 * * * 8h", "Mars/Olympus_Mons", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWindows(tt.spec, tt.timezone)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("ParseWindows() = %v, want nil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_Windows_Open(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Weeknights from 22:00 to 06:00, Berlin time
+	windows, err := ParseWindows("0 22 * * 1-5 8h", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		at           time.Time
+		wantOpen     bool
+		wantNextOpen time.Time
+	}{
+		{"Wednesday noon. Should be closed until 22:00.", time.Date(2024, 5, 1, 12, 0, 0, 0, berlin), false, time.Date(2024, 5, 1, 22, 0, 0, 0, berlin)},
+		{"Wednesday 22:00. Should be open.", time.Date(2024, 5, 1, 22, 0, 0, 0, berlin), true, time.Date(2024, 5, 1, 22, 0, 0, 0, berlin)},
+		{"Thursday 05:59 in UTC. Should be open.", time.Date(2024, 5, 2, 3, 59, 0, 0, time.UTC), true, time.Date(2024, 5, 2, 3, 59, 0, 0, time.UTC)},
+		{"Thursday 06:00. Should be closed.", time.Date(2024, 5, 2, 6, 0, 0, 0, berlin), false, time.Date(2024, 5, 2, 22, 0, 0, 0, berlin)},
+		{"Saturday night. Should be closed until Monday.", time.Date(2024, 5, 4, 23, 0, 0, 0, berlin), false, time.Date(2024, 5, 6, 22, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windows.Open(tt.at); got != tt.wantOpen {
+				t.Errorf("Open() = %v, want %v", got, tt.wantOpen)
+			}
+			if got := windows.NextOpen(tt.at); !got.Equal(tt.wantNextOpen) {
+				t.Errorf("NextOpen() = %v, want %v", got, tt.wantNextOpen)
+			}
+		})
+	}
+
+	var always *Windows
+	if !always.Open(time.Now()) {
+		t.Errorf("Open() = false without windows, want true")
+	}
+}
+
+func Test_Queue(t *testing.T) {
+	queue := NewQueue()
+	queue.Add("team-b")
+	queue.Add("team-a")
+	queue.Add("team-b")
+	if got := queue.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if got := queue.Drain(); !reflect.DeepEqual(got, []string{"team-a", "team-b"}) {
+		t.Errorf("Drain() = %v, want [team-a team-b]", got)
+	}
+	if got := queue.Len(); got != 0 {
+		t.Errorf("Len() = %d after Drain(), want 0", got)
+	}
+}
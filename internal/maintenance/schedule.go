@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookahead bounds the search for the next time matching a Schedule, e.g. for February 30th
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+// field is the set of values a field of a Schedule matches, and whether it's unrestricted
+type field struct {
+	values map[int]bool
+	any    bool
+}
+
+// Schedule is a cron expression of the five fields minute, hour, day of month, month and day of week.
+// Each field is `*`, a value, a range `a-b`, a step `*/n` or `a-b/n`, or a comma-separated list of those.
+// Days of week range from 0 (Sunday) to 7 (Sunday again). Like cron, a time matches, if either the day of month
+// or the day of week matches, if both are restricted.
+type Schedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek field
+}
+
+// ParseSchedule parses the cron expression expr
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in %q, got %d", expr, len(fields))
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	parsed := [5]field{}
+	for i, f := range fields {
+		var err error
+		if parsed[i], err = parseField(f, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("invalid field %q in %q: %w", f, expr, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if parsed[4].values[7] {
+		parsed[4].values[0] = true
+	}
+	return &Schedule{minute: parsed[0], hour: parsed[1], dayOfMonth: parsed[2], month: parsed[3], dayOfWeek: parsed[4]}, nil
+}
+
+// parseField parses a comma-separated list of values, ranges and steps within min and max
+func parseField(f string, min int, max int) (field, error) {
+	// Like cron, a field starting with `*` counts as unrestricted, even with a step
+	result := field{values: map[int]bool{}, any: strings.HasPrefix(f, "*")}
+	for _, part := range strings.Split(f, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return field{}, fmt.Errorf("invalid step %q", stepExpr)
+			}
+		}
+
+		first, last := min, max
+		if rangeExpr != "*" {
+			lowExpr, highExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if first, err = strconv.Atoi(lowExpr); err != nil {
+				return field{}, fmt.Errorf("invalid value %q", lowExpr)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(highExpr); err != nil {
+					return field{}, fmt.Errorf("invalid value %q", highExpr)
+				}
+			} else if hasStep {
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return field{}, fmt.Errorf("%q out of range %d-%d", rangeExpr, min, max)
+		}
+		for value := first; value <= last; value += step {
+			result.values[value] = true
+		}
+	}
+	return result, nil
+}
+
+// matchesDay checks whether the day of t matches the day of month and day of week of s
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.dayOfMonth.values[t.Day()]
+	dayOfWeek := s.dayOfWeek.values[int(t.Weekday())]
+	if s.dayOfMonth.any || s.dayOfWeek.any {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first minute after t matching s, in the location of t. It returns the zero time, if there's
+// none within the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxLookahead)
+	for t.Before(limit) {
+		switch {
+		case !s.month.values[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour.values[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute.values[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
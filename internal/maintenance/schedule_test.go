@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"Every minute. Should be valid.", "* * * * *", false},
+		{"Lists, ranges and steps. Should be valid.", "0,30 22-23/1 */2 1-12 1-5", false},
+		{"Sunday as 7. Should be valid.", "0 0 * * 7", false},
+		{"Four fields. Should be invalid.", "0 22 * *", true},
+		{"Minute out of range. Should be invalid.", "60 22 * * *", true},
+		{"Reversed range. Should be invalid.", "0 23-22 * * *", true},
+		{"Zero step. Should be invalid.", "*/0 * * * *", true},
+		{"Named day. Should be invalid.", "0 22 * * MON", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSchedule(tt.expr); (err != nil) != tt.wantErr {
+				t.Errorf("ParseSchedule(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Schedule_Next(t *testing.T) {
+	// 2024-05-01 is a Wednesday
+	from := time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"Every minute. Should return the next minute.", "* * * * *", time.Date(2024, 5, 1, 12, 35, 0, 0, time.UTC)},
+		{"Nightly. Should return tonight.", "0 22 * * *", time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)},
+		{"Earlier hour. Should return tomorrow.", "30 3 * * *", time.Date(2024, 5, 2, 3, 30, 0, 0, time.UTC)},
+		{"Weekends. Should return Saturday.", "0 0 * * 0,6", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC)},
+		{"Sunday as 7. Should return Sunday.", "0 0 * * 7", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"Every 15 minutes. Should return the next quarter.", "*/15 * * * *", time.Date(2024, 5, 1, 12, 45, 0, 0, time.UTC)},
+		{"First of the month or Mondays. Should return Monday.", "0 0 1 * 1", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{"New year. Should return next year.", "0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"February 30th. Should return none.", "0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
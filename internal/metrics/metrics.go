@@ -0,0 +1,473 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "imagepullsecret_patcher"
+
+var (
+	// PodsDeletedTotal counts Pods deleted during cleanup, by namespace and waiting reason
+	PodsDeletedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pods_deleted_total",
+			Help:      "Number of Pods deleted to pick up the imagePullSecret.",
+		},
+		[]string{"namespace", "reason"},
+	)
+	// SecretSizeExceededTotal counts imagePullSecrets, which could not be written as they exceed the size limit of Secrets
+	SecretSizeExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "secret_size_exceeded_total",
+			Help:      "Number of imagePullSecrets not written, as they exceed the size limit of Secrets.",
+		},
+		[]string{"namespace"},
+	)
+	// SecretQuotaExceededTotal counts imagePullSecrets, which were not created as the ResourceQuota of the namespace is exhausted
+	SecretQuotaExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "secret_quota_exceeded_total",
+			Help:      "Number of imagePullSecrets not created, as the ResourceQuota for Secrets in the namespace is exhausted.",
+		},
+		[]string{"namespace"},
+	)
+	// DriftDetectedTotal counts drift, which was not corrected as the controller is paused
+	DriftDetectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drift_detected_total",
+			Help:      "Number of objects found out of sync, which were not corrected as the controller is paused.",
+		},
+		[]string{"namespace", "kind"},
+	)
+	// ServerCapability is 1 for every version-dependent API the cluster supports, and 0 otherwise
+	ServerCapability = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "server_capability",
+			Help:      "Whether the cluster supports a version-dependent API the controller relies on (1) or not (0).",
+		},
+		[]string{"capability"},
+	)
+	// PodCleanupsDeferred is the number of namespaces, whose Pod cleanup is deferred until the next maintenance window
+	PodCleanupsDeferred = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pod_cleanups_deferred",
+			Help:      "Number of namespaces, whose Pod cleanup is deferred until the next maintenance window.",
+		},
+	)
+	// StandbyNamespacesOutOfSync is the number of namespaces found out of sync by the last standby verification
+	StandbyNamespacesOutOfSync = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "standby_namespaces_out_of_sync",
+			Help:      "Number of namespaces found out of sync by the last verification of a replica, which is not the leader.",
+		},
+	)
+	// StandbyVerificationsTotal counts the verifications of standby replicas, by result
+	StandbyVerificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "standby_verifications_total",
+			Help:      "Number of read-only audits of replicas, which are not the leader, by result.",
+		},
+		[]string{"result"},
+	)
+	// CredentialSourceFailuresTotal counts failed reads of chained credential sources
+	CredentialSourceFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "credential_source_failures_total",
+			Help:      "Number of failed reads of a chained credential source, causing a failover to the next one.",
+		},
+		[]string{"source"},
+	)
+	// CredentialSourceActive is 1 for the chained credential source, which was used last
+	CredentialSourceActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "credential_source_active",
+			Help:      "Whether the chained credential source was used for the last read.",
+		},
+		[]string{"source"},
+	)
+	// SignatureVerificationFailuresTotal counts credentials rejected, as their signature could not be verified
+	SignatureVerificationFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "signature_verification_failures_total",
+			Help:      "Number of credential reads rejected, as their signature could not be verified.",
+		},
+		[]string{"source"},
+	)
+	// CredentialVerificationFailuresTotal counts rotated credentials rejected by a registry, by registry
+	CredentialVerificationFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "credential_verification_failures_total",
+			Help:      "Number of rotated credentials rejected by a registry, which were held back from the rollout.",
+		},
+		[]string{"registry"},
+	)
+	// CredentialsHeldBack is 1, while rotated credentials are held back, as a registry rejected them
+	CredentialsHeldBack = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "credentials_held_back",
+			Help:      "Whether rotated credentials are held back, as a registry rejected them, and the last known good ones are distributed instead.",
+		},
+	)
+	// ReconcileResultsTotal counts the outcome of reconciling Secrets, ServiceAccounts and workloads
+	ReconcileResultsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconcile_results_total",
+			Help:      "Number of reconciled objects by kind and result (Created, Patched, NoOp, SkippedExcluded, Failed).",
+		},
+		[]string{"kind", "result"},
+	)
+	// CircuitBreakerOpen is 1, while non-critical work is skipped due to API server errors
+	CircuitBreakerOpen = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_open",
+			Help:      "Whether non-critical work is skipped, as the API server answers with 429 or 5xx.",
+		},
+	)
+	// CircuitBreakerDeferredTotal counts non-critical reconciles deferred, while the circuit breaker is open
+	CircuitBreakerDeferredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "circuit_breaker_deferred_total",
+			Help:      "Number of non-critical reconciles deferred, as the API server answers with 429 or 5xx.",
+		},
+	)
+	// TeardownPurgedTotal counts requests dropped, as their namespace is being deleted
+	TeardownPurgedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "teardown_purged_total",
+			Help:      "Number of queued requests dropped by a controller, as their namespace is being deleted.",
+		},
+		[]string{"controller"},
+	)
+	// NamespaceRateLimitedTotal counts requests delayed by a controller, as their namespace exceeded its rate limit
+	NamespaceRateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "namespace_rate_limited_total",
+			Help:      "Number of requests delayed by a controller, as their namespace exceeded its rate limit.",
+		},
+		[]string{"controller"},
+	)
+	// SelfTestPassed is 1, if the self-test in the canary namespace passed, and 0 if it failed
+	SelfTestPassed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "self_test_passed",
+			Help:      "Whether the self-test in the canary namespace passed at startup.",
+		},
+	)
+	// SourceSecretChangesTotal counts the changes of the source Secret, which were fanned out to all managed Secrets
+	SourceSecretChangesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "source_secret_changes_total",
+			Help:      "Number of changes of the source Secret, which were fanned out to all managed Secrets.",
+		},
+	)
+	// ResyncPendingSecrets is the number of Secrets enqueued by the last resync, which are not reconciled yet
+	ResyncPendingSecrets = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "resync_pending_secrets",
+			Help:      "Number of managed Secrets enqueued by the last resync, which are not reconciled yet.",
+		},
+	)
+	// GitOpsConflictsTotal counts reconciles of imagePullSecrets, which are claimed by a GitOps tool as well
+	GitOpsConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "gitops_conflicts_total",
+			Help:      "Number of reconciles of imagePullSecrets, which are claimed by ArgoCD or Flux as well.",
+		},
+		[]string{"tool"},
+	)
+	// ConflictDetectedTotal counts fights with other actors, which modified a managed object back repeatedly
+	ConflictDetectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "conflict_detected_total",
+			Help:      "Number of fights detected with another field manager, which modified a managed object back repeatedly.",
+		},
+		[]string{"kind"},
+	)
+	// SecretOwnerConflictsTotal counts reconciles of imagePullSecrets, which are owned by another Secret provider
+	SecretOwnerConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "secret_owner_conflicts_total",
+			Help:      "Number of reconciles of imagePullSecrets, which are owned by the External Secrets Operator or Sealed Secrets.",
+		},
+		[]string{"owner"},
+	)
+	// ServiceAccountConflictsTotal counts conflicts, which were retried while patching ServiceAccounts
+	ServiceAccountConflictsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "serviceaccount_conflicts_total",
+			Help:      "Number of conflicts, which were retried while patching ServiceAccounts.",
+		},
+	)
+	// OrphanedSecrets is the number of orphaned managed Secrets found during the last collection
+	OrphanedSecrets = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "orphaned_secrets",
+			Help:      "Number of managed Secrets not matching the configured SecretName, found during the last collection.",
+		},
+	)
+	// NamespacesExcluded is the number of namespaces intentionally excluded by the configuration, by reason
+	NamespacesExcluded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "namespaces_excluded_total",
+			Help:      "Number of namespaces intentionally excluded by the configuration, by reason.",
+		},
+		[]string{"reason"},
+	)
+	// PermissionMissing is 1 for every permission, which the RBAC preflight found missing
+	PermissionMissing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rbac_permission_missing",
+			Help:      "Whether a permission required by the controller or one of its features is missing, as found by the RBAC preflight at startup.",
+		},
+		[]string{"resource", "verb"},
+	)
+	// PermissionExcess is 1 for every permission, which is granted, but not required by the enabled features
+	PermissionExcess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rbac_permission_excess",
+			Help:      "Whether a permission is granted, although none of the enabled features requires it, as found by the RBAC preflight at startup.",
+		},
+		[]string{"resource", "verb"},
+	)
+	// ImagePullFailuresTotal counts Pods, which started failing to pull their image, by namespace and waiting reason
+	ImagePullFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "image_pull_failures_total",
+			Help:      "Number of Pods observed to start failing to pull their image, by the waiting reason first observed.",
+		},
+		[]string{"namespace", "reason"},
+	)
+	// PodsImagePullFailing is the number of Pods currently failing to pull their image, by namespace
+	PodsImagePullFailing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pods_image_pull_failing",
+			Help:      "Number of Pods currently in ErrImagePull or ImagePullBackOff.",
+		},
+		[]string{"namespace"},
+	)
+	// AuditSinkFailuresTotal counts audit records, which could not be written to the audit sink
+	AuditSinkFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_sink_failures_total",
+			Help:      "Number of audit records, which could not be written to the audit sink, even after retries.",
+		},
+	)
+	// PropagationDurationSeconds observes the time from a change of the source credentials, until the imagePullSecret in a namespace is updated
+	PropagationDurationSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "propagation_duration_seconds",
+			Help:      "Time from a change of the source credentials, until the imagePullSecret in a namespace is updated.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		},
+	)
+)
+
+// namespaceExclusions holds the reason of every excluded namespace, to keep NamespacesExcluded consistent
+var (
+	namespaceExclusionsMu sync.Mutex
+	namespaceExclusions   = map[string]string{}
+)
+
+// SetNamespaceExclusion records why namespace is excluded. An empty reason marks it as no longer excluded.
+func SetNamespaceExclusion(namespace string, reason string) {
+	namespaceExclusionsMu.Lock()
+	defer namespaceExclusionsMu.Unlock()
+
+	previous, ok := namespaceExclusions[namespace]
+	if ok && previous == reason {
+		return
+	}
+	if ok {
+		NamespacesExcluded.WithLabelValues(previous).Dec()
+		delete(namespaceExclusions, namespace)
+	}
+	if reason != "" {
+		NamespacesExcluded.WithLabelValues(reason).Inc()
+		namespaceExclusions[namespace] = reason
+	}
+}
+
+// SetPermissionMissing records, if the permission for verb on resource is missing
+func SetPermissionMissing(resource string, verb string, missing bool) {
+	if missing {
+		PermissionMissing.WithLabelValues(resource, verb).Set(1)
+		return
+	}
+	PermissionMissing.WithLabelValues(resource, verb).Set(0)
+}
+
+// SetServerCapability records, if the cluster supports capability
+func SetServerCapability(capability string, supported bool) {
+	if supported {
+		ServerCapability.WithLabelValues(capability).Set(1)
+		return
+	}
+	ServerCapability.WithLabelValues(capability).Set(0)
+}
+
+// SetPermissionExcess records, if the permission for verb on resource is granted without being required
+func SetPermissionExcess(resource string, verb string, excess bool) {
+	if excess {
+		PermissionExcess.WithLabelValues(resource, verb).Set(1)
+		return
+	}
+	PermissionExcess.WithLabelValues(resource, verb).Set(0)
+}
+
+// imagePullFailures holds the namespace of every Pod failing to pull its image, to keep PodsImagePullFailing consistent
+var (
+	imagePullFailuresMu sync.Mutex
+	imagePullFailures   = map[string]string{}
+)
+
+// SetImagePullFailure records, that the Pod key in namespace fails to pull its image with reason.
+// An empty reason marks it as no longer failing.
+func SetImagePullFailure(key string, namespace string, reason string) {
+	imagePullFailuresMu.Lock()
+	defer imagePullFailuresMu.Unlock()
+
+	_, failing := imagePullFailures[key]
+	switch {
+	case reason != "" && !failing:
+		ImagePullFailuresTotal.WithLabelValues(namespace, reason).Inc()
+		PodsImagePullFailing.WithLabelValues(namespace).Inc()
+		imagePullFailures[key] = namespace
+	case reason == "" && failing:
+		PodsImagePullFailing.WithLabelValues(namespace).Dec()
+		delete(imagePullFailures, key)
+	}
+}
+
+// credentialsChangedAt holds the unix nano timestamp of the last change of the source credentials
+var credentialsChangedAt atomic.Int64
+
+// MarkCredentialsChanged records the time the source credentials changed
+func MarkCredentialsChanged(t time.Time) {
+	credentialsChangedAt.Store(t.UnixNano())
+}
+
+// ObservePropagation observes the time since the source credentials changed, with the
+// namespace as exemplar. Nothing is observed, if no change was recorded yet.
+func ObservePropagation(namespace string) {
+	changedAt := credentialsChangedAt.Load()
+	if changedAt == 0 {
+		return
+	}
+	duration := time.Since(time.Unix(0, changedAt)).Seconds()
+	PropagationDurationSeconds.(prometheus.ExemplarObserver).ObserveWithExemplar(duration, prometheus.Labels{"namespace": namespace})
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		PodsDeletedTotal,
+		SecretSizeExceededTotal,
+		PropagationDurationSeconds,
+		OrphanedSecrets,
+		SecretQuotaExceededTotal,
+		DriftDetectedTotal,
+		StandbyNamespacesOutOfSync,
+		StandbyVerificationsTotal,
+		ServerCapability,
+		PodCleanupsDeferred,
+		CredentialSourceFailuresTotal,
+		CredentialSourceActive,
+		SignatureVerificationFailuresTotal,
+		CredentialVerificationFailuresTotal,
+		CredentialsHeldBack,
+		CircuitBreakerOpen,
+		CircuitBreakerDeferredTotal,
+		ReconcileResultsTotal,
+		WorkqueueAddsTotal,
+		TeardownPurgedTotal,
+		NamespaceRateLimitedTotal,
+		SelfTestPassed,
+		SourceSecretChangesTotal,
+		ResyncPendingSecrets,
+		GitOpsConflictsTotal,
+		SecretOwnerConflictsTotal,
+		ServiceAccountConflictsTotal,
+		ConflictDetectedTotal,
+		NamespacesExcluded,
+		PermissionMissing,
+		PermissionExcess,
+		ImagePullFailuresTotal,
+		PodsImagePullFailing,
+		AuditSinkFailuresTotal,
+		RuntimeGOMAXPROCS,
+		RuntimeGOMEMLIMITBytes,
+		CgroupMemoryLimitBytes,
+		CgroupCPULimitCores,
+		queueCollector{},
+	)
+}
+
+// SetActiveCredentialSource marks source as the only active chained credential source
+func SetActiveCredentialSource(source string) {
+	CredentialSourceActive.Reset()
+	CredentialSourceActive.WithLabelValues(source).Set(1)
+}
+
+// OpenMetricsHandler serves the registry in the OpenMetrics format, which, unlike the
+// default metrics endpoint, includes exemplars
+func OpenMetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling:     promhttp.HTTPErrorOnError,
+		EnableOpenMetrics: true,
+	})
+}
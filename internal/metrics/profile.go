@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// labelProfile is the label, every metric is labeled with the profile of the controller
+const labelProfile = "profile"
+
+// SetProfile labels every metric of the registry, including the ones of controller-runtime,
+// with profile. This allows to tell several deployments of the controller apart, which
+// are scraped by the same Prometheus. It must be called before the metrics server is created.
+func SetProfile(profile string) {
+	metrics.Registry = &profileRegistry{
+		RegistererGatherer: metrics.Registry,
+		profile:            profile,
+	}
+}
+
+// profileRegistry adds the profile label to all metrics gathered from the wrapped registry
+type profileRegistry struct {
+	metrics.RegistererGatherer
+	profile string
+}
+
+func (r *profileRegistry) Gather() ([]*dto.MetricFamily, error) {
+	families, err := r.RegistererGatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			metric.Label = append(metric.Label, &dto.LabelPair{
+				Name:  proto.String(labelProfile),
+				Value: proto.String(r.profile),
+			})
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+	return families, err
+}
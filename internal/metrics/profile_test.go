@@ -0,0 +1,42 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_profileRegistry(t *testing.T) {
+	registry := &profileRegistry{RegistererGatherer: prometheus.NewRegistry(), profile: "staging"}
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total"}, []string{"namespace"})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("default").Inc()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) != 1 || len(families[0].Metric) != 1 {
+		t.Fatalf("Gather() = %v, want a single metric", families)
+	}
+	labels := families[0].Metric[0].Label
+	if len(labels) != 2 || labels[0].GetName() != "namespace" || labels[1].GetName() != "profile" || labels[1].GetValue() != "staging" {
+		t.Errorf("Gather() labels = %v, want namespace and profile=staging", labels)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/KimMachineGun/automemlimit/memlimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cgroupRoot is where the cgroup filesystem is mounted
+var cgroupRoot = "/sys/fs/cgroup"
+
+var (
+	// RuntimeGOMAXPROCS is GOMAXPROCS, as set at startup by automaxprocs or the environment
+	RuntimeGOMAXPROCS = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "runtime_gomaxprocs",
+			Help:      "GOMAXPROCS in effect after startup.",
+		},
+	)
+	// RuntimeGOMEMLIMITBytes is GOMEMLIMIT, as set at startup by automemlimit or the environment
+	RuntimeGOMEMLIMITBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "runtime_gomemlimit_bytes",
+			Help:      "GOMEMLIMIT in effect after startup, 0 if there is none.",
+		},
+	)
+	// CgroupMemoryLimitBytes is the memory limit of the cgroup of the controller
+	CgroupMemoryLimitBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cgroup_memory_limit_bytes",
+			Help:      "Memory limit of the cgroup, 0 if there is none or it is unknown.",
+		},
+	)
+	// CgroupCPULimitCores is the CPU quota of the cgroup of the controller in cores
+	CgroupCPULimitCores = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cgroup_cpu_limit_cores",
+			Help:      "CPU quota of the cgroup in cores, 0 if there is none or it is unknown.",
+		},
+	)
+)
+
+// ObserveRuntimeLimits publishes GOMAXPROCS and GOMEMLIMIT as well as the limits of the
+// cgroup they were derived from. It must be called after both were set at startup.
+func ObserveRuntimeLimits() {
+	RuntimeGOMAXPROCS.Set(float64(runtime.GOMAXPROCS(0)))
+
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		RuntimeGOMEMLIMITBytes.Set(float64(limit))
+	} else {
+		RuntimeGOMEMLIMITBytes.Set(0)
+	}
+
+	if limit, err := memlimit.FromCgroup(); err == nil {
+		CgroupMemoryLimitBytes.Set(float64(limit))
+	} else {
+		CgroupMemoryLimitBytes.Set(0)
+	}
+
+	CgroupCPULimitCores.Set(cgroupCPULimit(cgroupRoot))
+}
+
+// cgroupCPULimit reads the CPU quota in cores from cgroup v2, falling back to cgroup v1.
+// It returns 0, if there is no quota or it cannot be read.
+func cgroupCPULimit(root string) float64 {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	if data, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0
+		}
+		return cpuQuota(fields[0], fields[1])
+	}
+
+	// cgroup v1: a quota of -1 means unlimited
+	quota, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuQuota divides quota by period, both in microseconds
+func cpuQuota(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
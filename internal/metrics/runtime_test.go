@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_cgroupCPULimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  float64
+	}{
+		{
+			name:  "v2 quota",
+			files: map[string]string{"cpu.max": "150000 100000\n"},
+			want:  1.5,
+		},
+		{
+			name:  "v2 unlimited",
+			files: map[string]string{"cpu.max": "max 100000\n"},
+			want:  0,
+		},
+		{
+			name:  "v1 quota",
+			files: map[string]string{"cpu/cpu.cfs_quota_us": "50000\n", "cpu/cpu.cfs_period_us": "100000\n"},
+			want:  0.5,
+		},
+		{
+			name:  "v1 unlimited",
+			files: map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"},
+			want:  0,
+		},
+		{
+			name: "no cgroup",
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := cgroupCPULimit(root); got != tt.want {
+				t.Errorf("cgroupCPULimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
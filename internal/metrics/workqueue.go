@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	// WorkqueueAddsTotal counts the requests added to the workqueue of a controller
+	WorkqueueAddsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workqueue_adds_total",
+			Help:      "Number of requests added to the workqueue of a controller.",
+		},
+		[]string{"controller"},
+	)
+
+	workqueueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "workqueue_depth"),
+		"Number of requests waiting in the workqueue of a controller.",
+		[]string{"controller"}, nil,
+	)
+	activeReconcilesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "active_reconciles"),
+		"Number of requests currently reconciled by the workers of a controller.",
+		[]string{"controller"}, nil,
+	)
+	longestRunningReconcileDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "longest_running_reconcile_seconds"),
+		"Time the longest running reconcile of a controller has been running.",
+		[]string{"controller"}, nil,
+	)
+
+	// queues holds the instrumented workqueue of every controller by name
+	queues sync.Map
+)
+
+// queue wraps the workqueue of a controller, to observe its depth, adds and running reconciles.
+// A request is running between Get and Done.
+type queue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	controller string
+
+	mu         sync.Mutex
+	processing map[reconcile.Request]time.Time
+}
+
+// NewQueue returns an instrumented workqueue. It matches controller.Options.NewQueue.
+func NewQueue(controllerName string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+	q := &queue{
+		TypedRateLimitingInterface: workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter, workqueue.TypedRateLimitingQueueConfig[reconcile.Request]{
+			Name: controllerName,
+		}),
+		controller: controllerName,
+		processing: map[reconcile.Request]time.Time{},
+	}
+	queues.Store(controllerName, q)
+	return q
+}
+
+func (q *queue) Add(item reconcile.Request) {
+	WorkqueueAddsTotal.WithLabelValues(q.controller).Inc()
+	q.TypedRateLimitingInterface.Add(item)
+}
+
+func (q *queue) AddAfter(item reconcile.Request, duration time.Duration) {
+	WorkqueueAddsTotal.WithLabelValues(q.controller).Inc()
+	q.TypedRateLimitingInterface.AddAfter(item, duration)
+}
+
+func (q *queue) AddRateLimited(item reconcile.Request) {
+	WorkqueueAddsTotal.WithLabelValues(q.controller).Inc()
+	q.TypedRateLimitingInterface.AddRateLimited(item)
+}
+
+func (q *queue) Get() (reconcile.Request, bool) {
+	item, shutdown := q.TypedRateLimitingInterface.Get()
+	if !shutdown {
+		q.mu.Lock()
+		q.processing[item] = time.Now()
+		q.mu.Unlock()
+	}
+	return item, shutdown
+}
+
+func (q *queue) Done(item reconcile.Request) {
+	q.mu.Lock()
+	delete(q.processing, item)
+	q.mu.Unlock()
+	q.TypedRateLimitingInterface.Done(item)
+}
+
+// running returns the number of running reconciles, and how long the longest one has been running
+func (q *queue) running() (int, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	longest := time.Duration(0)
+	for _, startedAt := range q.processing {
+		longest = max(longest, time.Since(startedAt))
+	}
+	return len(q.processing), longest
+}
+
+// queueCollector reports the depth and running reconciles of all instrumented workqueues on scrape
+type queueCollector struct{}
+
+func (queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workqueueDepthDesc
+	ch <- activeReconcilesDesc
+	ch <- longestRunningReconcileDesc
+}
+
+func (queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues.Range(func(_, value any) bool {
+		q := value.(*queue)
+		active, longest := q.running()
+		ch <- prometheus.MustNewConstMetric(workqueueDepthDesc, prometheus.GaugeValue, float64(q.Len()), q.controller)
+		ch <- prometheus.MustNewConstMetric(activeReconcilesDesc, prometheus.GaugeValue, float64(active), q.controller)
+		ch <- prometheus.MustNewConstMetric(longestRunningReconcileDesc, prometheus.GaugeValue, longest.Seconds(), q.controller)
+		return true
+	})
+}
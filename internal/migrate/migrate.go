@@ -0,0 +1,294 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// pageSize is the number of objects listed at once, after which the progress is reported
+const pageSize = 500
+
+// Migration rewrites the markers of a previous scheme on a single object. Apply mutates obj in place and reports,
+// whether it changed anything. It must be idempotent, i.e. never change an object it has already migrated.
+type Migration struct {
+	Name  string
+	Kinds []string
+	Apply func(c *config.Config, obj client.Object) bool
+}
+
+// kinds are the kinds of objects walked, in order
+var kinds = []struct {
+	kind    string
+	newList func() client.ObjectList
+}{
+	{"Namespace", func() client.ObjectList { return &corev1.NamespaceList{} }},
+	{"Secret", func() client.ObjectList { return &corev1.SecretList{} }},
+	{"ServiceAccount", func() client.ObjectList { return &corev1.ServiceAccountList{} }},
+}
+
+// Migrations returns the migrations to the marker scheme of c, in the order they're applied. Annotations and labels
+// of fromDomain are renamed to the annotation domain of c first, so the following migrations find them.
+func Migrations(c *config.Config, fromDomain string) []Migration {
+	migrations := []Migration{}
+	if fromDomain = strings.TrimSuffix(fromDomain, "/"); fromDomain != "" && fromDomain != c.AnnotationDomain {
+		migrations = append(migrations, Migration{
+			Name:  "annotation-domain",
+			Kinds: []string{"Namespace", "Secret", "ServiceAccount"},
+			Apply: func(c *config.Config, obj client.Object) bool {
+				renamedAnnotations := renameAnnotations(c, obj, fromDomain)
+				return renameLabels(c, obj, fromDomain) || renamedAnnotations
+			},
+		})
+	}
+	return append(migrations,
+		// Secrets created before labels were introduced only carry the managed-by annotation
+		Migration{
+			Name:  "secret-labels",
+			Kinds: []string{"Secret"},
+			Apply: labelSecret,
+		},
+		// ServiceAccounts patched before the attached annotation was introduced are only recognized by their changelog
+		Migration{
+			Name:  "serviceaccount-attached",
+			Kinds: []string{"ServiceAccount"},
+			Apply: recordAttached,
+		},
+	)
+}
+
+// renameAnnotations moves the annotations of fromDomain to their keys in c. Values already set with the new
+// key take precedence.
+func renameAnnotations(c *config.Config, obj client.Object, fromDomain string) bool {
+	annotations := obj.GetAnnotations()
+	changed := false
+	for name, key := range config.DomainAnnotations(c) {
+		oldKey := fromDomain + "/" + name
+		value, ok := annotations[oldKey]
+		if !ok || oldKey == key {
+			continue
+		}
+		if _, exists := annotations[key]; !exists {
+			annotations[key] = value
+		}
+		delete(annotations, oldKey)
+		changed = true
+	}
+	if changed {
+		obj.SetAnnotations(annotations)
+	}
+	return changed
+}
+
+// renameLabels moves the labels of fromDomain to their keys in c. Values already set with the new key take precedence.
+func renameLabels(c *config.Config, obj client.Object, fromDomain string) bool {
+	labels := obj.GetLabels()
+	changed := false
+	for name, key := range config.DomainLabels(c) {
+		oldKey := fromDomain + "/" + name
+		value, ok := labels[oldKey]
+		if !ok || oldKey == key {
+			continue
+		}
+		if _, exists := labels[key]; !exists {
+			labels[key] = value
+		}
+		delete(labels, oldKey)
+		changed = true
+	}
+	if changed {
+		obj.SetLabels(labels)
+	}
+	return changed
+}
+
+// labelSecret sets the managed-by and profile labels on the Secrets of the profile of c, which are named like its
+// imagePullSecret. Secrets without a profile label may belong to any profile, so labeling one by another name would
+// attribute it to the profile of c for good, and let its orphan collection delete it.
+func labelSecret(c *config.Config, obj client.Object) bool {
+	if obj.GetName() != c.SecretName || !utils.IsProfileSecret(c, obj) || utils.IsSourceOfTruth(c, obj) {
+		return false
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	changed := false
+	if labels[config.AnnotationManagedBy] != config.AnnotationAppName {
+		labels[config.AnnotationManagedBy] = config.AnnotationAppName
+		changed = true
+	}
+	if _, ok := labels[c.LabelProfile]; !ok {
+		labels[c.LabelProfile] = utils.ProfileName(c)
+		changed = true
+	}
+	if changed {
+		obj.SetLabels(labels)
+	}
+	return changed
+}
+
+// recordAttached sets the attached annotation on ServiceAccounts, which the imagePullSecret was attached to
+// according to their changelog
+func recordAttached(c *config.Config, obj client.Object) bool {
+	serviceAccount, ok := obj.(*corev1.ServiceAccount)
+	if !ok {
+		return false
+	}
+	if _, ok := serviceAccount.GetAnnotations()[c.AnnotationAttached]; ok {
+		return false
+	}
+	if !utils.IsImagePullSecretAttached(c, serviceAccount) {
+		return false
+	}
+	utils.SetImagePullSecretAttached(c, serviceAccount, c.SecretName)
+	return true
+}
+
+// Summary counts the objects walked by Run
+type Summary struct {
+	Scanned  int
+	Migrated int
+	Failed   int
+	// Applied counts the objects changed by each migration
+	Applied map[string]int
+}
+
+// Run walks all namespaces, Secrets and ServiceAccounts of the cluster and applies migrations to them. Every
+// migrated object and the progress of every page is written to w. With dryRun, nothing is patched. Objects
+// failing to be patched are reported and counted, but don't abort the walk.
+func Run(ctx context.Context, k8sClient client.Client, c *config.Config, migrations []Migration, dryRun bool, w io.Writer) (Summary, error) {
+	summary := Summary{Applied: map[string]int{}}
+	for _, kind := range kinds {
+		applicable := []Migration{}
+		for _, migration := range migrations {
+			if slices.Contains(migration.Kinds, kind.kind) {
+				applicable = append(applicable, migration)
+			}
+		}
+		if len(applicable) == 0 {
+			continue
+		}
+		if err := walk(ctx, k8sClient, c, kind.kind, kind.newList, applicable, dryRun, w, &summary); err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// walk applies migrations to all objects of kind, page by page
+func walk(ctx context.Context, k8sClient client.Client, c *config.Config, kind string, newList func() client.ObjectList,
+	migrations []Migration, dryRun bool, w io.Writer, summary *Summary) error {
+	scanned, migrated := 0, 0
+	continueToken := ""
+	for {
+		list := newList()
+		if err := k8sClient.List(ctx, list, client.Limit(pageSize), client.Continue(continueToken)); err != nil {
+			return fmt.Errorf("error listing %ss: %w", kind, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return fmt.Errorf("error listing %ss: %w", kind, err)
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			scanned++
+			result := apply(c, obj, migrations)
+			if len(result.names) == 0 {
+				continue
+			}
+			if !dryRun {
+				if err := k8sClient.Patch(ctx, obj, result.patch); err != nil {
+					summary.Failed++
+					fmt.Fprintf(w, "%s %s: failed to migrate: %v\n", kind, objectName(obj), err)
+					continue
+				}
+			}
+			migrated++
+			for _, name := range result.names {
+				summary.Applied[name]++
+			}
+			fmt.Fprintf(w, "%s %s: %s\n", kind, objectName(obj), strings.Join(result.names, ", "))
+		}
+		fmt.Fprintf(w, "%s: %d scanned, %d migrated\n", kind, scanned, migrated)
+
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			break
+		}
+	}
+	summary.Scanned += scanned
+	summary.Migrated += migrated
+	return nil
+}
+
+// applied records the names of the migrations applied to an object, and the patch of their changes
+type applied struct {
+	names []string
+	patch client.Patch
+}
+
+// apply applies migrations to obj in place
+func apply(c *config.Config, obj client.Object, migrations []Migration) applied {
+	result := applied{patch: client.MergeFrom(obj.DeepCopyObject().(client.Object))}
+	for _, migration := range migrations {
+		if migration.Apply(c, obj) {
+			result.names = append(result.names, migration.Name)
+		}
+	}
+	return result
+}
+
+// objectName returns the namespace/name of obj, or the name of cluster-scoped objects
+func objectName(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// WriteSummary writes the summary of a migration to w, and reports whether all objects were migrated
+func WriteSummary(w io.Writer, summary Summary, dryRun bool) bool {
+	names := make([]string, 0, len(summary.Applied))
+	for name := range summary.Applied {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %d objects\n", name, summary.Applied[name])
+	}
+	if dryRun {
+		fmt.Fprintf(w, "%d of %d objects to migrate (dry run)\n", summary.Migrated, summary.Scanned)
+	} else {
+		fmt.Fprintf(w, "%d of %d objects migrated, %d failed\n", summary.Migrated, summary.Scanned, summary.Failed)
+	}
+	return summary.Failed == 0
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+func Test_Migrations(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: "xx",
+		SecretNamespace:  "kube-system",
+		AnnotationDomain: "example.com",
+	})
+	tests := []struct {
+		name            string
+		kind            string
+		obj             client.Object
+		wantApplied     []string
+		wantAnnotations map[string]string
+		wantLabels      map[string]string
+	}{
+		{
+			name: "Namespace with annotations of the previous domain. Should be renamed.",
+			kind: "Namespace",
+			obj: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name: "default",
+				Annotations: map[string]string{
+					"pborn.eu/imagepullsecret-patcher-exclude": "true",
+					"pborn.eu/imagepullsecret-last-sync":       "2024-05-01T12:00:00Z",
+					"pborn.eu/unrelated":                       "kept",
+				},
+			}},
+			wantApplied: []string{"annotation-domain"},
+			wantAnnotations: map[string]string{
+				"example.com/imagepullsecret-patcher-exclude": "true",
+				"example.com/imagepullsecret-last-sync":       "2024-05-01T12:00:00Z",
+				"pborn.eu/unrelated":                          "kept",
+			},
+		},
+		{
+			name: "Namespace with annotations of both domains. Should keep the current value.",
+			kind: "Namespace",
+			obj: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name: "default",
+				Annotations: map[string]string{
+					"pborn.eu/imagepullsecret-patcher-exclude":    "true",
+					"example.com/imagepullsecret-patcher-exclude": "false",
+				},
+			}},
+			wantApplied: []string{"annotation-domain"},
+			wantAnnotations: map[string]string{
+				"example.com/imagepullsecret-patcher-exclude": "false",
+			},
+		},
+		{
+			name: "Secret with the managed-by annotation only. Should be labeled.",
+			kind: "Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:        c.SecretName,
+				Namespace:   "default",
+				Annotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			}},
+			wantApplied:     []string{"secret-labels"},
+			wantAnnotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			wantLabels: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.LabelProfile:             config.DefaultProfile,
+			},
+		},
+		{
+			name: "Secret with the profile label of the previous domain. Should be renamed.",
+			kind: "Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      c.SecretName,
+				Namespace: "default",
+				Labels: map[string]string{
+					config.AnnotationManagedBy:                 config.AnnotationAppName,
+					"pborn.eu/imagepullsecret-patcher-profile": config.DefaultProfile,
+				},
+			}},
+			wantApplied: []string{"annotation-domain"},
+			wantLabels: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.LabelProfile:             config.DefaultProfile,
+			},
+		},
+		{
+			name: "Secret of another profile with the managed-by annotation only. Should be left alone.",
+			kind: "Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:        "other-profile-secret",
+				Namespace:   "default",
+				Annotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			}},
+			wantAnnotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+		},
+		{
+			name: "Secret annotated as source. Should be left alone.",
+			kind: "Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:      c.SecretName,
+				Namespace: "default",
+				Annotations: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+					c.AnnotationSource:         "true",
+				},
+			}},
+			wantAnnotations: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.AnnotationSource:         "true",
+			},
+		},
+		{
+			name:        "Unrelated Secret. Should be left alone.",
+			kind:        "Secret",
+			obj:         &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+			wantApplied: nil,
+		},
+		{
+			name: "ServiceAccount recorded in its changelog with the previous domain. Should be renamed and annotated as attached.",
+			kind: "ServiceAccount",
+			obj: &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default",
+					Namespace: "default",
+					Annotations: map[string]string{
+						"pborn.eu/imagepullsecret-patcher-changelog": `[{"time":"2024-05-01T12:00:00Z","action":"attached","secretName":"` + c.SecretName + `"}]`,
+					},
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			},
+			wantApplied: []string{"annotation-domain", "serviceaccount-attached"},
+			wantAnnotations: map[string]string{
+				c.AnnotationChangelog: `[{"time":"2024-05-01T12:00:00Z","action":"attached","secretName":"` + c.SecretName + `"}]`,
+				c.AnnotationAttached:  c.SecretName,
+			},
+		},
+		{
+			name: "ServiceAccount referencing the imagePullSecret manually. Should be left alone.",
+			kind: "ServiceAccount",
+			obj: &corev1.ServiceAccount{
+				ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "default"},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			},
+			wantApplied: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applicable := []Migration{}
+			for _, migration := range Migrations(c, "pborn.eu/") {
+				if slices.Contains(migration.Kinds, tt.kind) {
+					applicable = append(applicable, migration)
+				}
+			}
+			got := apply(c, tt.obj, applicable)
+			if strings.Join(got.names, ",") != strings.Join(tt.wantApplied, ",") {
+				t.Errorf("apply() = %v, want %v", got.names, tt.wantApplied)
+			}
+			if len(tt.wantApplied) == 0 {
+				return
+			}
+			if !equalMaps(tt.obj.GetAnnotations(), tt.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", tt.obj.GetAnnotations(), tt.wantAnnotations)
+			}
+			if !equalMaps(tt.obj.GetLabels(), tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", tt.obj.GetLabels(), tt.wantLabels)
+			}
+			// Migrations are idempotent
+			if again := apply(c, tt.obj, applicable); len(again.names) != 0 {
+				t.Errorf("apply() = %v on a migrated object, want none", again.names)
+			}
+		})
+	}
+}
+
+func Test_Run(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        c.SecretName,
+		Namespace:   "default",
+		Annotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+	}}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	k8sClient := fake.NewClientBuilder().WithObjects(secret, namespace).Build()
+	ctx := context.TODO()
+
+	out := &bytes.Buffer{}
+	summary, err := Run(ctx, k8sClient, c, Migrations(c, ""), true, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Namespaces are only walked, if a migration applies to them
+	if summary.Scanned != 1 || summary.Migrated != 1 {
+		t.Errorf("Run() with dry run = %+v, want 1 scanned and 1 migrated", summary)
+	}
+	if !strings.Contains(out.String(), "Secret default/"+c.SecretName+": secret-labels") {
+		t.Errorf("Run() with dry run printed %q, want the migrated Secret", out.String())
+	}
+	got := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), got); err != nil {
+		t.Fatal(err)
+	}
+	if utils.HasLabel(got, config.AnnotationManagedBy, config.AnnotationAppName) {
+		t.Errorf("Run() with dry run patched the Secret")
+	}
+
+	summary, err = Run(ctx, k8sClient, c, Migrations(c, ""), false, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Migrated != 1 || summary.Failed != 0 || summary.Applied["secret-labels"] != 1 {
+		t.Errorf("Run() = %+v, want 1 migrated", summary)
+	}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), got); err != nil {
+		t.Fatal(err)
+	}
+	if !utils.HasLabel(got, config.AnnotationManagedBy, config.AnnotationAppName) {
+		t.Errorf("Run() didn't label the Secret")
+	}
+
+	// A second run finds nothing left to migrate
+	out.Reset()
+	summary, err = Run(ctx, k8sClient, c, Migrations(c, ""), false, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Migrated != 0 {
+		t.Errorf("Run() = %+v on migrated objects, want none migrated", summary)
+	}
+	if !WriteSummary(out, summary, false) || !strings.Contains(out.String(), "0 of 1 objects migrated, 0 failed") {
+		t.Errorf("WriteSummary() printed %q", out.String())
+	}
+}
+
+func equalMaps(got map[string]string, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for key, value := range want {
+		if got[key] != value {
+			return false
+		}
+	}
+	return true
+}
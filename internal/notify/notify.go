@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// Notifier posts to a webhook, once a namespace failed to sync NotifyFailureThreshold
+// consecutive times, or the source credentials became invalid.
+// A nil Notifier discards all results.
+type Notifier struct {
+	// Config is read on every result, so NotifyWebhookURL and NotifyFailureThreshold can be updated at runtime
+	Config *config.Store
+
+	client             *http.Client
+	mu                 sync.Mutex
+	failures           map[string]int
+	credentialsInvalid bool
+	// successes counts the syncs since the credentials were reported invalid, so a single
+	// namespace, which didn't need them, doesn't mark them as valid again
+	successes int
+}
+
+// Message is the JSON payload posted to the webhook. Text makes it compatible with Slack.
+type Message struct {
+	Text      string `json:"text"`
+	Reason    string `json:"reason"`
+	Namespace string `json:"namespace,omitempty"`
+	Failures  int    `json:"failures,omitempty"`
+	Error     string `json:"error"`
+}
+
+// NewNotifier returns a Notifier, or nil if no NotifyWebhookURL is configured
+func NewNotifier(c *config.Store) *Notifier {
+	if c.Load().NotifyWebhookURL == "" {
+		return nil
+	}
+	return &Notifier{
+		Config:   c,
+		client:   &http.Client{Timeout: 10 * time.Second, Transport: outbound.Transport},
+		failures: map[string]int{},
+	}
+}
+
+// RecordResult tracks the result of syncing the imagePullSecret in namespace. It must be called once
+// per reconcile of the namespace, not for every ServiceAccount.
+func (n *Notifier) RecordResult(ctx context.Context, namespace string, err error) {
+	if n == nil {
+		return
+	}
+
+	threshold := n.Config.Load().NotifyFailureThreshold
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err == nil {
+		delete(n.failures, namespace)
+		// The credentials are considered valid again after Threshold successful syncs in a row
+		if n.credentialsInvalid {
+			n.successes++
+			if n.successes >= threshold {
+				n.credentialsInvalid = false
+				n.successes = 0
+			}
+		}
+		return
+	}
+
+	// Notifications leave the cluster, so they must never contain credential material
+	err = redact.Error(err)
+	if errors.Is(err, utils.ErrCredentialsInvalid) {
+		n.successes = 0
+		// Notify only once, until the credentials are valid again
+		if !n.credentialsInvalid {
+			n.credentialsInvalid = true
+			n.send(ctx, Message{
+				Text:   fmt.Sprintf("imagepullsecret-patcher: source credentials are invalid: %v", err),
+				Reason: "CredentialsInvalid",
+				Error:  err.Error(),
+			})
+		}
+		return
+	}
+
+	n.failures[namespace]++
+	if n.failures[namespace] == threshold {
+		n.send(ctx, Message{
+			Text:      fmt.Sprintf("imagepullsecret-patcher: namespace '%s' failed to sync %d consecutive times: %v", namespace, threshold, err),
+			Reason:    "SyncFailed",
+			Namespace: namespace,
+			Failures:  threshold,
+			Error:     err.Error(),
+		})
+	}
+}
+
+// Forget drops the failures counted for namespace, e.g. once it's deleted
+func (n *Notifier) Forget(namespace string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.failures, namespace)
+}
+
+// RecordCredentialsRejected notifies, that rotated credentials were rejected by a registry,
+// and the last known good ones are distributed instead
+func (n *Notifier) RecordCredentialsRejected(ctx context.Context, err error) {
+	if n == nil {
+		return
+	}
+
+	err = redact.Error(err)
+	n.send(ctx, Message{
+		Text:   fmt.Sprintf("imagepullsecret-patcher: rotated credentials are held back, as they were rejected: %v", err),
+		Reason: "CredentialsRejected",
+		Error:  err.Error(),
+	})
+}
+
+// send posts the message in the background, so reconciles aren't blocked by the webhook
+func (n *Notifier) send(ctx context.Context, message Message) {
+	log := log.FromContext(ctx)
+	url := n.Config.Load().NotifyWebhookURL
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		log.Error(err, "failed to encode notification")
+		return
+	}
+	go func() {
+		resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Error(err, "failed to send notification")
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Error(fmt.Errorf("unexpected status %s", resp.Status), "failed to send notification")
+		}
+	}()
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+func newTestNotifier(t *testing.T) (*Notifier, chan Message) {
+	messages := make(chan Message, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := Message{}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("failed to decode notification: %v", err)
+		}
+		messages <- message
+	}))
+	t.Cleanup(server.Close)
+
+	return NewNotifier(config.NewStore(config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:       "xx",
+		SecretNamespace:        "kube-system",
+		NotifyWebhookURL:       server.URL,
+		NotifyFailureThreshold: 3,
+	}))), messages
+}
+
+func expectMessage(t *testing.T, messages chan Message, reason string) {
+	select {
+	case message := <-messages:
+		if message.Reason != reason {
+			t.Errorf("reason = %v, want %v", message.Reason, reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %s notification", reason)
+	}
+}
+
+func expectNoMessage(t *testing.T, messages chan Message) {
+	select {
+	case message := <-messages:
+		t.Errorf("unexpected notification %v", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func Test_RecordResult_SyncFailed(t *testing.T) {
+	notifier, messages := newTestNotifier(t)
+	ctx := context.TODO()
+	err := errors.New("connection refused")
+
+	notifier.RecordResult(ctx, "default", err)
+	notifier.RecordResult(ctx, "default", err)
+	notifier.RecordResult(ctx, "default", nil)
+	notifier.RecordResult(ctx, "default", err)
+	notifier.RecordResult(ctx, "default", err)
+	expectNoMessage(t, messages)
+
+	notifier.RecordResult(ctx, "default", err)
+	expectMessage(t, messages, "SyncFailed")
+
+	notifier.RecordResult(ctx, "default", err)
+	expectNoMessage(t, messages)
+}
+
+func Test_RecordResult_CredentialsInvalid(t *testing.T) {
+	notifier, messages := newTestNotifier(t)
+	ctx := context.TODO()
+	err := fmt.Errorf("%w: no such file", utils.ErrCredentialsInvalid)
+
+	notifier.RecordResult(ctx, "default", err)
+	expectMessage(t, messages, "CredentialsInvalid")
+
+	notifier.RecordResult(ctx, "other", err)
+	expectNoMessage(t, messages)
+
+	// A single success, e.g. of a namespace not needing the credentials, doesn't mark them as valid
+	notifier.RecordResult(ctx, "default", nil)
+	notifier.RecordResult(ctx, "default", err)
+	expectNoMessage(t, messages)
+
+	for i := 0; i < 3; i++ {
+		notifier.RecordResult(ctx, "default", nil)
+	}
+	notifier.RecordResult(ctx, "default", err)
+	expectMessage(t, messages, "CredentialsInvalid")
+}
+
+func Test_Forget(t *testing.T) {
+	notifier, messages := newTestNotifier(t)
+	ctx := context.TODO()
+	err := errors.New("connection refused")
+
+	notifier.RecordResult(ctx, "default", err)
+	notifier.RecordResult(ctx, "default", err)
+	notifier.Forget("default")
+	if _, ok := notifier.failures["default"]; ok {
+		t.Errorf("failures of deleted namespace were kept")
+	}
+
+	// A recreated namespace starts counting from scratch
+	notifier.RecordResult(ctx, "default", err)
+	expectNoMessage(t, messages)
+}
+
+func Test_RecordResult_Disabled(t *testing.T) {
+	var notifier *Notifier
+	notifier.RecordResult(context.TODO(), "default", errors.New("connection refused"))
+	notifier.Forget("default")
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package outbound provides the transport for the outbound calls of the controller, e.g. to credential
+// providers, registries and webhooks. It honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY, and trusts a
+// custom CA bundle in addition to the system CAs, for clusters egressing through a TLS-intercepting proxy.
+package outbound
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Transport is the http.RoundTripper for all outbound calls. It picks up changes of the CA bundle
+// on the next request, so a rotated bundle doesn't require a restart.
+var Transport http.RoundTripper = defaultTransport
+
+var defaultTransport = &transport{}
+
+type transport struct {
+	mu           sync.Mutex
+	caBundleFile string
+	modTime      time.Time
+	current      *http.Transport
+}
+
+// SetCABundleFile configures the PEM file of CAs, which are trusted in addition to the system CAs.
+// The bundle is loaded right away, so an invalid one is reported at startup.
+func SetCABundleFile(path string) error {
+	defaultTransport.mu.Lock()
+	defer defaultTransport.mu.Unlock()
+
+	defaultTransport.caBundleFile = path
+	defaultTransport.current = nil
+	_, err := defaultTransport.load()
+	return err
+}
+
+// RootCAs returns the system CAs together with the ones of the CA bundle
+func RootCAs() (*x509.CertPool, error) {
+	defaultTransport.mu.Lock()
+	defer defaultTransport.mu.Unlock()
+
+	current, err := defaultTransport.load()
+	if err != nil {
+		return nil, err
+	}
+	if current.TLSClientConfig.RootCAs == nil {
+		return systemCertPool(), nil
+	}
+	return current.TLSClientConfig.RootCAs.Clone(), nil
+}
+
+// NewTransport returns a copy of the current transport, whose TLS config can be customized further
+func NewTransport() (*http.Transport, error) {
+	defaultTransport.mu.Lock()
+	defer defaultTransport.mu.Unlock()
+
+	current, err := defaultTransport.load()
+	if err != nil {
+		return nil, err
+	}
+	return current.Clone(), nil
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	current, err := t.load()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return current.RoundTrip(req)
+}
+
+// load returns the transport for the current CA bundle, and rebuilds it, once the bundle changed.
+// The caller must hold mu.
+func (t *transport) load() (*http.Transport, error) {
+	var modTime time.Time
+	if t.caBundleFile != "" {
+		info, err := os.Stat(t.caBundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		modTime = info.ModTime()
+	}
+	if t.current != nil && modTime.Equal(t.modTime) {
+		return t.current, nil
+	}
+
+	next := http.DefaultTransport.(*http.Transport).Clone()
+	next.Proxy = http.ProxyFromEnvironment
+	next.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.caBundleFile != "" {
+		bundle, err := os.ReadFile(t.caBundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := systemCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle '%s'", t.caBundleFile)
+		}
+		next.TLSClientConfig.RootCAs = pool
+	}
+
+	if t.current != nil {
+		t.current.CloseIdleConnections()
+	}
+	t.current = next
+	t.modTime = modTime
+	return next, nil
+}
+
+// systemCertPool returns the system CAs, or an empty pool, if they're not available
+func systemCertPool() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return x509.NewCertPool()
+	}
+	return pool
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Placeholder replaces credential material
+const Placeholder = "[REDACTED]"
+
+// minSecretLength prevents short values from redacting unrelated text
+const minSecretLength = 8
+
+// credentialFields matches the values of credential fields of a .dockerconfigjson or token response,
+// even if they're embedded in an escaped string
+var credentialFields = regexp.MustCompile(`(?i)(\\?"(?:auth|password|identitytoken|registrytoken|access_token)\\?"\s*:\s*\\?")[^"\\]*`)
+
+var secrets = struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+	// current holds the value last replaced per key, e.g. the credentials currently distributed
+	current map[string]string
+}{values: map[string]struct{}{}, current: map[string]string{}}
+
+// Register marks values as credential material, which is redacted wherever it appears
+func Register(values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	for _, value := range values {
+		if len(value) >= minSecretLength {
+			secrets.values[value] = struct{}{}
+		}
+	}
+}
+
+// Replace marks value as the current credential material of key, which is redacted wherever it appears.
+// The value previously replaced for key is forgotten, so rotated credentials don't accumulate.
+func Replace(key string, value string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	if len(value) < minSecretLength {
+		delete(secrets.current, key)
+		return
+	}
+	secrets.current[key] = value
+}
+
+// String redacts all registered values and the values of credential fields in s
+func String(s string) string {
+	secrets.mu.RLock()
+	for value := range secrets.values {
+		s = strings.ReplaceAll(s, value, Placeholder)
+	}
+	for _, value := range secrets.current {
+		s = strings.ReplaceAll(s, value, Placeholder)
+	}
+	secrets.mu.RUnlock()
+
+	return credentialFields.ReplaceAllString(s, "${1}"+Placeholder)
+}
+
+// Error returns err with a redacted message. errors.Is and errors.As still see the original error.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return String(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// NewCore wraps core, so messages and string or error fields are redacted before they're written.
+// It can be passed to zap.WrapCore.
+func NewCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = String(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = String(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field.Interface = Error(err)
+			}
+		case zapcore.StringerType:
+			field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: String(stringer(field))}
+		case zapcore.ReflectType:
+			field = redactReflected(field)
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+// redactReflected redacts the JSON representation of a reflected field. If redacting breaks the JSON,
+// e.g. because a registered value spanned several JSON values, the field is written as string instead.
+func redactReflected(field zapcore.Field) zapcore.Field {
+	b, err := json.Marshal(field.Interface)
+	if err != nil {
+		return zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: String(fmt.Sprintf("%+v", field.Interface))}
+	}
+	redacted := String(string(b))
+	if !json.Valid([]byte(redacted)) {
+		return zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: redacted}
+	}
+	field.Interface = json.RawMessage(redacted)
+	return field
+}
+
+func stringer(field zapcore.Field) string {
+	if s, ok := field.Interface.(interface{ String() string }); ok {
+		return s.String()
+	}
+	return ""
+}
+
+// NewRecorder wraps recorder, so the messages of all Events are redacted
+func NewRecorder(recorder record.EventRecorder) record.EventRecorder {
+	return &redactingRecorder{recorder: recorder}
+}
+
+type redactingRecorder struct {
+	recorder record.EventRecorder
+}
+
+func (r *redactingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.recorder.Event(object, eventtype, reason, String(message))
+}
+
+func (r *redactingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Event(object, eventtype, reason, String(fmt.Sprintf(messageFmt, args...)))
+}
+
+func (r *redactingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", String(fmt.Sprintf(messageFmt, args...)))
+}
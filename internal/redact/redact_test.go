@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	testSecret           = "c3VwZXJzZWNyZXRwYXNzd29yZA=="
+	testDockerConfigJSON = `{"auths":{"registry.example.com":{"username":"user","password":"hunter22","auth":"dXNlcjpodW50ZXIyMg=="}}}`
+)
+
+func Test_String(t *testing.T) {
+	Register(testSecret)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "Registered value. Should be redacted.",
+			input: "failed to parse " + testSecret,
+		},
+		{
+			name:  "Credential fields of a dockerconfigjson. Should be redacted.",
+			input: "invalid credentials: " + testDockerConfigJSON,
+			want:  []string{"registry.example.com", `"username":"user"`},
+		},
+		{
+			name:  "Credential fields in an escaped string. Should be redacted.",
+			input: fmt.Sprintf("%q", testDockerConfigJSON),
+			want:  []string{"registry.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := String(tt.input)
+			assertNoCredentials(t, got)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("String() = %q, should still contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_Error(t *testing.T) {
+	Register(testSecret)
+	errSentinel := errors.New("sentinel")
+
+	err := Error(fmt.Errorf("%w: %s", errSentinel, testSecret))
+	assertNoCredentials(t, err.Error())
+	if !errors.Is(err, errSentinel) {
+		t.Errorf("Error() should keep the wrapped error")
+	}
+	if Error(nil) != nil {
+		t.Errorf("Error(nil) should be nil")
+	}
+}
+
+func Test_NewCore(t *testing.T) {
+	Register(testSecret)
+
+	var buf bytes.Buffer
+	core := zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(&buf),
+		zapcore.DebugLevel,
+	)
+	logger := zap.New(core, zap.WrapCore(NewCore))
+
+	logger.Info("read credentials " + testSecret)
+	logger.With(zap.String("dockerconfigjson", testDockerConfigJSON)).Info("constructed secret")
+	logger.Error("failed to sync", zap.Error(fmt.Errorf("invalid: %s", testDockerConfigJSON)))
+	logger.Info("stringer", zap.Stringer("secret", stringerFunc(testSecret)))
+	logger.Info("reflected", zap.Any("secret", map[string]string{"token": testSecret, "config": testDockerConfigJSON}))
+
+	if buf.Len() == 0 {
+		t.Fatalf("nothing was logged")
+	}
+	assertNoCredentials(t, buf.String())
+}
+
+func Test_Replace(t *testing.T) {
+	const (
+		previous = "cHJldmlvdXNwYXNzd29yZA=="
+		rotated  = "cm90YXRlZHBhc3N3b3Jk"
+	)
+	Replace("test", previous)
+	if got := String(previous); got != Placeholder {
+		t.Errorf("String() = %q, want %q", got, Placeholder)
+	}
+
+	Replace("test", rotated)
+	if got := String(rotated); got != Placeholder {
+		t.Errorf("String() = %q, want %q", got, Placeholder)
+	}
+	if got := String(previous); got != previous {
+		t.Errorf("String() = %q, the replaced value should be forgotten", got)
+	}
+}
+
+type stringerFunc string
+
+func (s stringerFunc) String() string {
+	return string(s)
+}
+
+func assertNoCredentials(t *testing.T, s string) {
+	t.Helper()
+	for _, secret := range []string{testSecret, "hunter22", "dXNlcjpodW50ZXIyMg=="} {
+		if strings.Contains(s, secret) {
+			t.Errorf("%q contains credential %q", s, secret)
+		}
+	}
+}
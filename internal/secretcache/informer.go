@@ -0,0 +1,211 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secretcache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// informer multiplexes the informer of the managed Secrets, keyed by managedKey, and the one of the pinned
+// namespace, keyed by its name. Handlers and indexers are added to the informers of the namespaces pinned later
+// on as well.
+type informer struct {
+	mu        sync.Mutex
+	informers map[string]cache.Informer
+	handlers  []*registration
+	indexers  []toolscache.Indexers
+}
+
+// registration is the handler added to the informers of all namespaces
+type registration struct {
+	handler      toolscache.ResourceEventHandler
+	resyncPeriod *time.Duration
+
+	mu      sync.Mutex
+	handles map[string]toolscache.ResourceEventHandlerRegistration
+}
+
+var _ cache.Informer = &informer{}
+
+func newInformer() *informer {
+	return &informer{informers: map[string]cache.Informer{}}
+}
+
+// add adds the informer of namespace and registers all handlers and indexers with it
+func (i *informer) add(namespace string, namespaceInformer cache.Informer) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, indexers := range i.indexers {
+		if err := namespaceInformer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	for _, r := range i.handlers {
+		if err := r.register(namespace, namespaceInformer); err != nil {
+			return err
+		}
+	}
+	i.informers[namespace] = namespaceInformer
+	return nil
+}
+
+// remove removes the informer of namespace, which is stopped by its cache
+func (i *informer) remove(namespace string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.informers, namespace)
+	for _, r := range i.handlers {
+		r.mu.Lock()
+		delete(r.handles, namespace)
+		r.mu.Unlock()
+	}
+}
+
+// AddEventHandler adds handler to the informers of all namespaces
+func (i *informer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	return i.addEventHandler(&registration{handler: handler})
+}
+
+// AddEventHandlerWithResyncPeriod adds handler with resyncPeriod to the informers of all namespaces
+func (i *informer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) (toolscache.ResourceEventHandlerRegistration, error) {
+	return i.addEventHandler(&registration{handler: handler, resyncPeriod: &resyncPeriod})
+}
+
+func (i *informer) addEventHandler(r *registration) (toolscache.ResourceEventHandlerRegistration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	r.handles = map[string]toolscache.ResourceEventHandlerRegistration{}
+	for namespace, namespaceInformer := range i.informers {
+		if err := r.register(namespace, namespaceInformer); err != nil {
+			return nil, err
+		}
+	}
+	i.handlers = append(i.handlers, r)
+	return r, nil
+}
+
+// RemoveEventHandler removes the handler of handle from the informers of all namespaces
+func (i *informer) RemoveEventHandler(handle toolscache.ResourceEventHandlerRegistration) error {
+	r, ok := handle.(*registration)
+	if !ok {
+		return fmt.Errorf("registration was not returned by the informer of Secrets")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for index, handler := range i.handlers {
+		if handler == r {
+			i.handlers = append(i.handlers[:index], i.handlers[index+1:]...)
+			break
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for namespace, namespaceHandle := range r.handles {
+		if namespaceInformer, ok := i.informers[namespace]; ok {
+			if err := namespaceInformer.RemoveEventHandler(namespaceHandle); err != nil {
+				return err
+			}
+		}
+	}
+	r.handles = map[string]toolscache.ResourceEventHandlerRegistration{}
+	return nil
+}
+
+// AddIndexers adds indexers to the informers of all namespaces
+func (i *informer) AddIndexers(indexers toolscache.Indexers) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, namespaceInformer := range i.informers {
+		if err := namespaceInformer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	i.indexers = append(i.indexers, indexers)
+	return nil
+}
+
+// HasSynced reports, if the informers of all namespaces have synced
+func (i *informer) HasSynced() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, namespaceInformer := range i.informers {
+		if !namespaceInformer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsStopped reports, if the informers of all namespaces are stopped. Without any namespace, it isn't.
+func (i *informer) IsStopped() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.informers) == 0 {
+		return false
+	}
+	for _, namespaceInformer := range i.informers {
+		if !namespaceInformer.IsStopped() {
+			return false
+		}
+	}
+	return true
+}
+
+// register adds the handler to the informer of namespace
+func (r *registration) register(namespace string, namespaceInformer cache.Informer) error {
+	var handle toolscache.ResourceEventHandlerRegistration
+	var err error
+	if r.resyncPeriod != nil {
+		handle, err = namespaceInformer.AddEventHandlerWithResyncPeriod(r.handler, *r.resyncPeriod)
+	} else {
+		handle, err = namespaceInformer.AddEventHandler(r.handler)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handles[namespace] = handle
+	return nil
+}
+
+// HasSynced reports, if the handler has been called for the initial state of the informers of all namespaces
+func (r *registration) HasSynced() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, handle := range r.handles {
+		if handle != nil && !handle.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
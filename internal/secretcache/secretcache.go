@@ -0,0 +1,385 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secretcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+var secretGVK = corev1.SchemeGroupVersion.WithKind("Secret")
+
+// managedKey is the key of the informer of the managed Secrets in the multiplexed informer, which no namespace
+// can collide with
+const managedKey = ""
+
+var (
+	// managedSelector selects the Secrets managed by the controller
+	managedSelector = labels.SelectorFromSet(labels.Set{config.AnnotationManagedBy: config.AnnotationAppName})
+	// unmanagedSelector selects every other Secret, including the ones without the label
+	unmanagedSelector = labels.NewSelector().Add(newRequirement(config.AnnotationManagedBy, selection.NotEquals, config.AnnotationAppName))
+)
+
+// Cache serves the Secrets managed by the controller from a single cluster-wide watch, which selects them by the
+// managed-by label, and the other Secrets of the pinned namespace, e.g. the source Secret in the secret namespace,
+// from a cache of that namespace. So the churn of all other Secrets of the cluster isn't watched. Every other
+// kind is served from the cluster-wide cache. Reads of Secrets, which aren't cached, are passed to the API server.
+type Cache struct {
+	// Cache is the cluster-wide cache of every kind, whose Secrets are limited to the managed ones
+	cache.Cache
+	apiReader client.Reader
+	// newPinnedCache creates the cache of the Secrets in a namespace, which aren't managed by the controller
+	newPinnedCache func(namespace string) (cache.Cache, error)
+	informer       *informer
+
+	mu  sync.Mutex
+	ctx context.Context
+	// pin returns the namespace, whose Secrets are all watched
+	pin     func() string
+	pinned  *pinnedCache
+	indexes []index
+}
+
+// pinnedCache is the cache of the Secrets in the pinned namespace, which aren't managed by the controller
+type pinnedCache struct {
+	cache.Cache
+	namespace string
+	cancel    context.CancelFunc
+}
+
+// index is a field index of Secrets, which is added to the pinned cache as well
+type index struct {
+	field   string
+	extract client.IndexerFunc
+}
+
+var _ cache.Cache = &Cache{}
+
+// New returns a Cache, which watches the managed Secrets, and the other Secrets of a namespace, once it is
+// pinned with Pin. It can be passed to the manager as cache.NewCacheFunc.
+func New(restConfig *rest.Config, opts cache.Options) (*Cache, error) {
+	// The label selector is added to the per-object options of Secrets, keeping the ones of every kind
+	managedOpts := opts
+	managedOpts.ByObject = withSecretSelector(opts.ByObject, managedSelector)
+	clusterCache, err := cache.New(restConfig, managedOpts)
+	if err != nil {
+		return nil, err
+	}
+	apiReader, err := client.New(restConfig, client.Options{Scheme: opts.Scheme, Mapper: opts.Mapper, HTTPClient: opts.HTTPClient})
+	if err != nil {
+		return nil, err
+	}
+
+	pinnedOpts := opts
+	pinnedOpts.ByObject = withSecretSelector(opts.ByObject, unmanagedSelector)
+	c := &Cache{
+		Cache:     clusterCache,
+		apiReader: apiReader,
+		newPinnedCache: func(namespace string) (cache.Cache, error) {
+			namespaceOpts := pinnedOpts
+			namespaceOpts.DefaultNamespaces = map[string]cache.Config{namespace: {}}
+			return cache.New(restConfig, namespaceOpts)
+		},
+		informer: newInformer(),
+	}
+	// Don't block on the initial sync, which only happens once the cache is started
+	managedInformer, err := clusterCache.GetInformer(context.TODO(), &corev1.Secret{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch managed Secrets: %w", err)
+	}
+	if err := c.informer.add(managedKey, managedInformer); err != nil {
+		return nil, fmt.Errorf("failed to watch managed Secrets: %w", err)
+	}
+	return c, nil
+}
+
+// newRequirement returns the label requirement of key and value, which are known to be valid
+func newRequirement(key string, op selection.Operator, value string) labels.Requirement {
+	requirement, err := labels.NewRequirement(key, op, []string{value})
+	if err != nil {
+		panic(err)
+	}
+	return *requirement
+}
+
+// withSecretSelector returns a copy of byObject, in which the label selector of Secrets is restricted by selector
+func withSecretSelector(byObject map[client.Object]cache.ByObject, selector labels.Selector) map[client.Object]cache.ByObject {
+	scoped := make(map[client.Object]cache.ByObject, len(byObject)+1)
+	var secret client.Object = &corev1.Secret{}
+	secretOpts := cache.ByObject{}
+	for obj, objOpts := range byObject {
+		if _, ok := obj.(*corev1.Secret); ok {
+			secret, secretOpts = obj, objOpts
+			continue
+		}
+		scoped[obj] = objOpts
+	}
+	if secretOpts.Label != nil {
+		requirements, _ := selector.Requirements()
+		selector = secretOpts.Label.DeepCopySelector().Add(requirements...)
+	}
+	secretOpts.Label = selector
+	scoped[secret] = secretOpts
+	return scoped
+}
+
+// isSecret reports, if obj is a Secret or a list of Secrets
+func isSecret(obj runtime.Object) bool {
+	switch obj.(type) {
+	case *corev1.Secret, *corev1.SecretList:
+		return true
+	}
+	return false
+}
+
+// isNotStarted reports, if err is returned by the pinned cache, which isn't started yet
+func isNotStarted(err error) bool {
+	notStarted := &cache.ErrCacheNotStarted{}
+	return errors.As(err, &notStarted)
+}
+
+// pinnedNamespace returns the started cache of the Secrets in namespace, or nil, if it isn't pinned
+func (c *Cache) pinnedNamespace(namespace string) cache.Cache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ctx == nil || c.pinned == nil || c.pinned.namespace != namespace {
+		return nil
+	}
+	return c.pinned.Cache
+}
+
+// Get reads managed Secrets from the cluster-wide cache, and the other Secrets of the pinned namespace from its
+// cache. Any other Secret, e.g. one named like the imagePullSecret, but owned by someone else, is read from the
+// API server. That includes unlabeled Secrets of earlier versions, until they're labeled on their first reconcile.
+func (c *Cache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if !isSecret(obj) {
+		return c.Cache.Get(ctx, key, obj, opts...)
+	}
+	if err := c.Cache.Get(ctx, key, obj, opts...); !apierrs.IsNotFound(err) {
+		return err
+	}
+	if pinnedCache := c.pinnedNamespace(key.Namespace); pinnedCache != nil {
+		err := pinnedCache.Get(ctx, key, obj, opts...)
+		if !isNotStarted(err) {
+			return err
+		}
+	}
+	return c.apiReader.Get(ctx, key, obj, opts...)
+}
+
+// List lists Secrets selected by the managed-by label from the cluster-wide cache. Lists of the pinned namespace
+// merge them with its other Secrets. Any other list is passed to the API server.
+func (c *Cache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if !isSecret(list) {
+		return c.Cache.List(ctx, list, opts...)
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.LabelSelector != nil {
+		if value, ok := listOpts.LabelSelector.RequiresExactMatch(config.AnnotationManagedBy); ok && value == config.AnnotationAppName {
+			return c.Cache.List(ctx, list, opts...)
+		}
+	}
+	pinnedCache := c.pinnedNamespace(listOpts.Namespace)
+	if listOpts.Namespace == "" || pinnedCache == nil {
+		return c.apiReader.List(ctx, list, opts...)
+	}
+
+	managedList := &corev1.SecretList{}
+	if err := c.Cache.List(ctx, managedList, opts...); err != nil {
+		return err
+	}
+	pinnedList := &corev1.SecretList{}
+	if err := pinnedCache.List(ctx, pinnedList, opts...); isNotStarted(err) {
+		return c.apiReader.List(ctx, list, opts...)
+	} else if err != nil {
+		return err
+	}
+	list.(*corev1.SecretList).Items = append(managedList.Items, pinnedList.Items...)
+	return nil
+}
+
+// GetInformer returns an informer of the managed Secrets and the other Secrets of the pinned namespace
+func (c *Cache) GetInformer(ctx context.Context, obj client.Object, opts ...cache.InformerGetOption) (cache.Informer, error) {
+	if !isSecret(obj) {
+		return c.Cache.GetInformer(ctx, obj, opts...)
+	}
+	return c.informer, nil
+}
+
+// GetInformerForKind returns an informer of the managed Secrets and the other Secrets of the pinned namespace
+func (c *Cache) GetInformerForKind(ctx context.Context, gvk schema.GroupVersionKind, opts ...cache.InformerGetOption) (cache.Informer, error) {
+	if gvk != secretGVK {
+		return c.Cache.GetInformerForKind(ctx, gvk, opts...)
+	}
+	return c.informer, nil
+}
+
+// RemoveInformer removes informers of every kind, but Secrets
+func (c *Cache) RemoveInformer(ctx context.Context, obj client.Object) error {
+	if !isSecret(obj) {
+		return c.Cache.RemoveInformer(ctx, obj)
+	}
+	return fmt.Errorf("the informer of Secrets can't be removed")
+}
+
+// IndexField adds the index of Secrets to the cluster-wide and the pinned cache, including the ones pinned later on
+func (c *Cache) IndexField(ctx context.Context, obj client.Object, field string, extract client.IndexerFunc) error {
+	if err := c.Cache.IndexField(ctx, obj, field, extract); err != nil || !isSecret(obj) {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pinned != nil {
+		if err := c.pinned.IndexField(ctx, obj, field, extract); err != nil {
+			return fmt.Errorf("failed to index Secrets in namespace '%s': %w", c.pinned.namespace, err)
+		}
+	}
+	c.indexes = append(c.indexes, index{field: field, extract: extract})
+	return nil
+}
+
+// Start runs the cluster-wide and the pinned cache, until ctx is cancelled
+func (c *Cache) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.ctx = ctx
+	if c.pinned != nil {
+		c.start(c.pinned)
+	}
+	c.mu.Unlock()
+
+	return c.Cache.Start(ctx)
+}
+
+// WaitForCacheSync waits for the cluster-wide and the pinned cache
+func (c *Cache) WaitForCacheSync(ctx context.Context) bool {
+	if !c.Cache.WaitForCacheSync(ctx) {
+		return false
+	}
+
+	c.mu.Lock()
+	var pinnedCache cache.Cache
+	if c.pinned != nil {
+		pinnedCache = c.pinned.Cache
+	}
+	c.mu.Unlock()
+
+	return pinnedCache == nil || pinnedCache.WaitForCacheSync(ctx)
+}
+
+// Pin watches all Secrets in the namespace returned by namespace, e.g. the secret namespace. namespace is called
+// again on every namespace event, see NamespaceHandler, so the pin follows updates of the config.
+func (c *Cache) Pin(namespace func() string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pin = namespace
+	return c.repin()
+}
+
+// repin replaces the pinned cache, once the pinned namespace changed. c.mu must be held.
+func (c *Cache) repin() error {
+	namespace := c.pin()
+	if c.pinned != nil && c.pinned.namespace == namespace {
+		return nil
+	}
+
+	secretCache, err := c.newPinnedCache(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create cache of Secrets in namespace '%s': %w", namespace, err)
+	}
+	for _, index := range c.indexes {
+		if err := secretCache.IndexField(context.TODO(), &corev1.Secret{}, index.field, index.extract); err != nil {
+			return fmt.Errorf("failed to index Secrets in namespace '%s': %w", namespace, err)
+		}
+	}
+	// Don't block on the initial sync, which only happens once the cache is started
+	informer, err := secretCache.GetInformer(context.TODO(), &corev1.Secret{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		return fmt.Errorf("failed to watch Secrets in namespace '%s': %w", namespace, err)
+	}
+
+	if c.pinned != nil {
+		c.informer.remove(c.pinned.namespace)
+		if c.pinned.cancel != nil {
+			c.pinned.cancel()
+		}
+		c.pinned = nil
+	}
+	if err := c.informer.add(namespace, informer); err != nil {
+		return fmt.Errorf("failed to watch Secrets in namespace '%s': %w", namespace, err)
+	}
+	c.pinned = &pinnedCache{Cache: secretCache, namespace: namespace}
+	if c.ctx != nil {
+		c.start(c.pinned)
+	}
+	return nil
+}
+
+// start runs the pinned cache, until it is replaced or the Cache is stopped. c.mu must be held.
+func (c *Cache) start(pinned *pinnedCache) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	pinned.cancel = cancel
+	go func() {
+		if err := pinned.Start(ctx); err != nil {
+			log.FromContext(c.ctx).Error(err, "error watching Secrets in namespace '"+pinned.namespace+"'")
+		}
+	}()
+}
+
+// NamespaceHandler pins the namespace returned by the function passed to Pin again on every namespace event,
+// so the pinned cache follows updates of the config
+func (c *Cache) NamespaceHandler() toolscache.ResourceEventHandler {
+	observe := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.pin == nil {
+			return
+		}
+		if err := c.repin(); err != nil {
+			log.Log.Error(err, "unable to watch Secrets")
+		}
+	}
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			observe()
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			observe()
+		},
+	}
+}
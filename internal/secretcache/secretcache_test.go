@@ -0,0 +1,281 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secretcache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// readerCache serves reads from a client and informers from FakeInformers
+type readerCache struct {
+	*informertest.FakeInformers
+	reader client.Reader
+}
+
+func (c *readerCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.reader.Get(ctx, key, obj, opts...)
+}
+
+func (c *readerCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+func newSecret(namespace string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: namespace}}
+}
+
+func newManagedSecret(namespace string) *corev1.Secret {
+	secret := newSecret(namespace)
+	secret.Labels = map[string]string{config.AnnotationManagedBy: config.AnnotationAppName}
+	return secret
+}
+
+// newTestCache returns a Cache, whose cluster-wide cache holds the managed Secret in team-a, whose pinned cache
+// holds the Secret in kube-system, while the API server holds the one in team-b
+func newTestCache() *Cache {
+	pinnedReader := fake.NewClientBuilder().WithObjects(newSecret("kube-system")).Build()
+	c := &Cache{
+		Cache: &readerCache{
+			FakeInformers: &informertest.FakeInformers{},
+			reader:        fake.NewClientBuilder().WithObjects(newManagedSecret("team-a")).Build(),
+		},
+		apiReader: fake.NewClientBuilder().WithObjects(newSecret("team-b")).Build(),
+		newPinnedCache: func(namespace string) (cache.Cache, error) {
+			return &readerCache{FakeInformers: &informertest.FakeInformers{}, reader: pinnedReader}, nil
+		},
+		informer: newInformer(),
+	}
+	if err := c.informer.add(managedKey, &controllertest.FakeInformer{Synced: true}); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func Test_Cache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	c := newTestCache()
+	get := func(namespace string) error {
+		return c.Get(ctx, types.NamespacedName{Name: "pull-secret", Namespace: namespace}, &corev1.Secret{})
+	}
+
+	if err := c.Pin(func() string { return "kube-system" }); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if err := get("kube-system"); !apierrs.IsNotFound(err) {
+		t.Errorf("Get() before Start() error = %v, want the read to be passed to the API server", err)
+	}
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for _, namespace := range []string{"team-a", "team-b", "kube-system"} {
+		if err := get(namespace); err != nil {
+			t.Errorf("Get() in namespace '%s' error = %v", namespace, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []client.ListOption
+		want []string
+	}{
+		{
+			name: "Managed Secrets. Should be listed from the cluster-wide cache.",
+			opts: []client.ListOption{client.MatchingLabels{config.AnnotationManagedBy: config.AnnotationAppName}},
+			want: []string{"team-a"},
+		},
+		{
+			name: "All Secrets of the pinned namespace. Should be listed from its cache.",
+			opts: []client.ListOption{client.InNamespace("kube-system")},
+			want: []string{"kube-system"},
+		},
+		{
+			name: "All Secrets of the cluster. Should be listed from the API server.",
+			want: []string{"team-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secretList := &corev1.SecretList{}
+			if err := c.List(ctx, secretList, tt.opts...); err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			got := []string{}
+			for _, secret := range secretList.Items {
+				got = append(got, secret.GetNamespace())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Cache_APIReads(t *testing.T) {
+	ctx := context.TODO()
+	c := newTestCache()
+	apiReads := 0
+	c.apiReader = fake.NewClientBuilder().WithObjects(newSecret("team-c")).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, reader client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			apiReads++
+			return reader.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+	get := func(namespace string) error {
+		return c.Get(ctx, types.NamespacedName{Name: "pull-secret", Namespace: namespace}, &corev1.Secret{})
+	}
+
+	if err := get("team-a"); err != nil || apiReads != 0 {
+		t.Errorf("Get() of a managed Secret error = %v, API reads = %d, want it to be served from the cache", err, apiReads)
+	}
+	// A Secret without the managed-by label, e.g. one of an earlier version, is read from the API server,
+	// until the controller labeled it on its first reconcile
+	if err := get("team-c"); err != nil || apiReads != 1 {
+		t.Errorf("Get() of an unlabeled Secret error = %v, API reads = %d, want 1", err, apiReads)
+	}
+	if err := c.Cache.(*readerCache).reader.(client.Client).Create(ctx, newManagedSecret("team-c")); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for range 3 {
+		if err := get("team-c"); err != nil {
+			t.Errorf("Get() of a labeled Secret error = %v", err)
+		}
+	}
+	if apiReads != 1 {
+		t.Errorf("Get() of a labeled Secret caused %d API reads, want it to be served from the cache", apiReads-1)
+	}
+}
+
+func Test_NamespaceHandler(t *testing.T) {
+	c := newTestCache()
+	handler := c.NamespaceHandler()
+	pinned := func() string {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.pinned == nil {
+			return ""
+		}
+		return c.pinned.namespace
+	}
+
+	// Nothing is pinned, until Pin is called
+	handler.OnAdd(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, false)
+	if got := pinned(); got != "" {
+		t.Errorf("OnAdd() pinned %q, before Pin() was called", got)
+	}
+
+	secretNamespace := "kube-system"
+	if err := c.Pin(func() string { return secretNamespace }); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	// The pin follows the config, once the secret namespace is changed
+	secretNamespace = "kube-credentials"
+	handler.OnUpdate(nil, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	if got := pinned(); got != "kube-credentials" {
+		t.Errorf("OnUpdate() pinned %q, want kube-credentials", got)
+	}
+	c.informer.mu.Lock()
+	_, stale := c.informer.informers["kube-system"]
+	c.informer.mu.Unlock()
+	if stale {
+		t.Error("OnUpdate() kept the informer of the previously pinned namespace")
+	}
+}
+
+func Test_withSecretSelector(t *testing.T) {
+	transform := func(obj interface{}) (interface{}, error) { return obj, nil }
+	teamSelector := labels.SelectorFromSet(labels.Set{"team": "a"})
+	byObject := map[client.Object]cache.ByObject{
+		&corev1.Pod{}:    {Transform: transform},
+		&corev1.Secret{}: {Label: teamSelector, Transform: transform},
+	}
+
+	scoped := withSecretSelector(byObject, managedSelector)
+	if len(scoped) != 2 {
+		t.Fatalf("withSecretSelector() = %v, want the options of Pods and Secrets", scoped)
+	}
+	for obj, objOpts := range scoped {
+		if objOpts.Transform == nil {
+			t.Errorf("withSecretSelector() dropped the transform of %T", obj)
+		}
+		if _, ok := obj.(*corev1.Secret); !ok {
+			continue
+		}
+		if got, want := objOpts.Label.String(), "app.kubernetes.io/managed-by=imagepullsecret-patcher,team=a"; got != want {
+			t.Errorf("withSecretSelector() selects Secrets by %q, want %q", got, want)
+		}
+	}
+	if got := teamSelector.String(); got != "team=a" {
+		t.Errorf("withSecretSelector() modified the passed selector to %q", got)
+	}
+}
+
+func Test_informer(t *testing.T) {
+	i := newInformer()
+	teamA := &controllertest.FakeInformer{Synced: true}
+	if err := i.add("team-a", teamA); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+
+	var received []string
+	registration, err := i.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			received = append(received, obj.(*corev1.Secret).GetNamespace())
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddEventHandler() error = %v", err)
+	}
+	// Handlers are registered with the informers of namespaces watched later on as well
+	teamB := &controllertest.FakeInformer{}
+	if err := i.add("team-b", teamB); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	teamA.Add(newSecret("team-a"))
+	teamB.Add(newSecret("team-b"))
+	if want := []string{"team-a", "team-b"}; !reflect.DeepEqual(received, want) {
+		t.Errorf("handler received %v, want %v", received, want)
+	}
+
+	if i.HasSynced() {
+		t.Error("HasSynced() = true, while the informer of team-b didn't sync")
+	}
+	i.remove("team-b")
+	if !i.HasSynced() || !registration.HasSynced() {
+		t.Error("HasSynced() = false, after the informer, which didn't sync, was removed")
+	}
+	if err := i.RemoveEventHandler(registration); err != nil {
+		t.Errorf("RemoveEventHandler() error = %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package suggest records the changes the controller would make as JSON Patches, instead of applying them,
+// so teams practicing strict GitOps can apply them through their own pipeline.
+package suggest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gomodules.xyz/jsonpatch/v2"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/utils"
+)
+
+// configMapPrefix selects a ConfigMap in the secret namespace as target, instead of a directory
+const configMapPrefix = "configmap:"
+
+const (
+	// OperationCreate, OperationPatch and OperationDelete name the suggested operations
+	OperationCreate = "create"
+	OperationPatch  = "patch"
+	OperationDelete = "delete"
+)
+
+// Suggestion is a change the controller would have made to an object
+type Suggestion struct {
+	Operation   string `json:"operation"`
+	APIVersion  string `json:"apiVersion"`
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	Subresource string `json:"subresource,omitempty"`
+	// Object is the object to create
+	Object map[string]interface{} `json:"object,omitempty"`
+	// Patch is the JSON Patch (RFC 6902) to apply to the object
+	Patch []jsonpatch.Operation `json:"patch,omitempty"`
+	// OmittedData lists the keys of the data of a Secret, which are left out of Object and Patch, so credentials
+	// never end up in a repository. They have to be filled in from the secret management of the pipeline.
+	OmittedData []string `json:"omittedData,omitempty"`
+}
+
+// key returns a name for the suggestion, which is stable across reconciliations of the same object
+func (s Suggestion) key() string {
+	parts := []string{s.Namespace, strings.ToLower(s.Kind), s.Name}
+	if s.Namespace == "" {
+		parts[0] = "_cluster"
+	}
+	if s.Subresource != "" {
+		parts = append(parts, s.Subresource)
+	}
+	return strings.Join(parts, ".") + ".json"
+}
+
+// NewClientFunc returns a client.NewClientFunc for the manager, whose clients record mutations as suggestions
+// to SuggestionOutput instead of performing them. Reads and the internal bookkeeping of the controller, i.e.
+// access reviews and the checkpoint of the initial sweep, are passed through.
+func NewClientFunc(c *config.Config) client.NewClientFunc {
+	return func(restConfig *rest.Config, options client.Options) (client.Client, error) {
+		wrapped, err := client.New(restConfig, options)
+		if err != nil {
+			return nil, err
+		}
+		return NewClient(wrapped, c), nil
+	}
+}
+
+// Client records mutations of the wrapped client as suggestions
+type Client struct {
+	client.Client
+
+	// target is the directory, or the ConfigMap in namespace, the suggestions are written to
+	target    string
+	namespace string
+	// checkpoint is the name of the ConfigMap of the initial sweep in namespace
+	checkpoint string
+	// mu serializes writes to the ConfigMap
+	mu sync.Mutex
+}
+
+// NewClient returns a Client wrapping k8sClient, which writes to SuggestionOutput
+func NewClient(k8sClient client.Client, c *config.Config) *Client {
+	return &Client{
+		Client:     k8sClient,
+		target:     c.SuggestionOutput,
+		namespace:  c.SecretNamespace,
+		checkpoint: utils.SweepCheckpointName(c),
+	}
+}
+
+// Create records obj as object to create
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.isBookkeeping(obj) {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	object, err := toMap(obj)
+	if err != nil {
+		return err
+	}
+	return c.suggest(ctx, obj, Suggestion{Operation: OperationCreate, Object: object, OmittedData: secretDataKeys(obj, nil)})
+}
+
+// Update records the difference between obj and its current state as patch
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.isBookkeeping(obj) {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	return c.suggestPatch(ctx, obj, "")
+}
+
+// Patch records the difference between obj, which has to hold the patched object, and its current state as patch
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if c.isBookkeeping(obj) {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	return c.suggestPatch(ctx, obj, "")
+}
+
+// Delete records the deletion of obj
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if c.isBookkeeping(obj) {
+		return c.Client.Delete(ctx, obj, opts...)
+	}
+	return c.suggest(ctx, obj, Suggestion{Operation: OperationDelete})
+}
+
+// DeleteAllOf is not used by the controller, so there is no suggestion for it
+func (c *Client) DeleteAllOf(_ context.Context, obj client.Object, _ ...client.DeleteAllOfOption) error {
+	return fmt.Errorf("DeleteAllOf of %T is not supported in suggestion mode", obj)
+}
+
+// Status returns a writer, which records changes of the status subresource
+func (c *Client) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+// SubResource returns a client, which records changes of subResource
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	return &subResourceClient{SubResourceClient: c.Client.SubResource(subResource), client: c, subResource: subResource}
+}
+
+// subResourceClient records changes of a subresource
+type subResourceClient struct {
+	client.SubResourceClient
+	client      *Client
+	subResource string
+}
+
+func (s *subResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, _ ...client.SubResourceCreateOption) error {
+	object, err := toMap(subResource)
+	if err != nil {
+		return err
+	}
+	return s.client.suggest(ctx, obj, Suggestion{Operation: OperationCreate, Subresource: s.subResource, Object: object})
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, _ ...client.SubResourceUpdateOption) error {
+	return s.client.suggestPatch(ctx, obj, s.subResource)
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+	return s.client.suggestPatch(ctx, obj, s.subResource)
+}
+
+// suggestPatch records the difference between obj and its current state as patch. Unchanged objects are skipped.
+func (c *Client) suggestPatch(ctx context.Context, obj client.Object, subResource string) error {
+	current := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+		return err
+	}
+	from, err := toJSON(current)
+	if err != nil {
+		return err
+	}
+	to, err := toJSON(obj)
+	if err != nil {
+		return err
+	}
+	patch, err := jsonpatch.CreatePatch(from, to)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON Patch: %w", err)
+	}
+	omitted := secretDataKeys(obj, current)
+	if len(patch) == 0 && len(omitted) == 0 {
+		return nil
+	}
+	return c.suggest(ctx, obj, Suggestion{Operation: OperationPatch, Subresource: subResource, Patch: patch, OmittedData: omitted})
+}
+
+// suggest completes suggestion with the reference of obj and writes it to the target
+func (c *Client) suggest(ctx context.Context, obj client.Object, suggestion Suggestion) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	suggestion.APIVersion = gvk.GroupVersion().String()
+	suggestion.Kind = gvk.Kind
+	suggestion.Namespace = obj.GetNamespace()
+	suggestion.Name = obj.GetName()
+	if suggestion.Object != nil && suggestion.Subresource == "" {
+		suggestion.Object["apiVersion"] = suggestion.APIVersion
+		suggestion.Object["kind"] = suggestion.Kind
+	}
+
+	data, err := json.MarshalIndent(suggestion, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if strings.HasPrefix(c.target, configMapPrefix) {
+		err = c.writeConfigMap(ctx, strings.TrimPrefix(c.target, configMapPrefix), suggestion.key(), data)
+	} else {
+		err = writeFile(filepath.Join(c.target, suggestion.key()), data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write suggestion: %w", err)
+	}
+	log.FromContext(ctx).Info("Suggested to "+suggestion.Operation+" "+suggestion.Kind+" '"+suggestion.Name+"' in namespace '"+suggestion.Namespace+"'", "suggestion", suggestion.key())
+	return nil
+}
+
+// writeConfigMap stores data as key of the ConfigMap name, creating it if needed
+func (c *Client) writeConfigMap(ctx context.Context, name string, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	configMap := &corev1.ConfigMap{}
+	err := c.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: c.namespace}, configMap)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err != nil {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.namespace,
+				Labels:    map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			},
+			Data: map[string]string{key: string(data)},
+		}
+		return c.Client.Create(ctx, configMap)
+	}
+	if configMap.Data[key] == string(data) {
+		return nil
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[key] = string(data)
+	return c.Client.Update(ctx, configMap)
+}
+
+// writeFile replaces path with data atomically, so a pipeline never reads a partial suggestion
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".suggestion-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// isBookkeeping checks whether obj is internal state of the controller, i.e. access reviews and the
+// checkpoint of the initial sweep, which is written even in suggestion mode
+func (c *Client) isBookkeeping(obj client.Object) bool {
+	switch obj.(type) {
+	case *authorizationv1.SelfSubjectAccessReview:
+		return true
+	case *corev1.ConfigMap:
+		return obj.GetNamespace() == c.namespace && obj.GetName() == c.checkpoint
+	}
+	return false
+}
+
+// secretDataKeys returns the keys of the data of obj, if it's a Secret, which differ from current.
+// Without current, all keys are returned.
+func secretDataKeys(obj client.Object, current client.Object) []string {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+	currentData := map[string][]byte{}
+	if currentSecret, ok := current.(*corev1.Secret); ok {
+		currentData = currentSecret.Data
+	}
+
+	var keys []string
+	for key, value := range secret.Data {
+		if currentValue, ok := currentData[key]; !ok || !bytes.Equal(value, currentValue) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range currentData {
+		if _, ok := secret.Data[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toJSON marshals obj without server-populated metadata. The data of Secrets is left out, see OmittedData.
+func toJSON(obj client.Object) ([]byte, error) {
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	if secret, ok := obj.(*corev1.Secret); ok {
+		secret.Data = nil
+		secret.StringData = nil
+	}
+	return json.Marshal(obj)
+}
+
+// toMap converts obj into its unstructured form, like toJSON
+func toMap(obj client.Object) (map[string]interface{}, error) {
+	data, err := toJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
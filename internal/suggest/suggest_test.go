@@ -0,0 +1,194 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package suggest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func newClient(t *testing.T, target string, objects ...client.Object) (*Client, client.Client) {
+	t.Helper()
+	wrapped := fake.NewClientBuilder().WithObjects(objects...).Build()
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", SuggestionOutput: target})
+	return NewClient(wrapped, c), wrapped
+}
+
+func readSuggestion(t *testing.T, path string) Suggestion {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read suggestion: %v", err)
+	}
+	suggestion := Suggestion{}
+	if err := json.Unmarshal(data, &suggestion); err != nil {
+		t.Fatalf("failed to decode suggestion: %v", err)
+	}
+	return suggestion
+}
+
+func Test_Client_Patch(t *testing.T) {
+	dir := t.TempDir()
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "team-a"}}
+	k8sClient, wrapped := newClient(t, dir, serviceAccount)
+	ctx := context.TODO()
+
+	patched := serviceAccount.DeepCopy()
+	patched.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "global-imagepullsecret"}}
+	if err := k8sClient.Patch(ctx, patched, client.MergeFrom(serviceAccount)); err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+
+	suggestion := readSuggestion(t, filepath.Join(dir, "team-a.serviceaccount.default.json"))
+	if suggestion.Operation != OperationPatch || suggestion.Kind != "ServiceAccount" || suggestion.APIVersion != "v1" {
+		t.Errorf("suggestion = %+v, want a patch of the ServiceAccount", suggestion)
+	}
+	if len(suggestion.Patch) != 1 || suggestion.Patch[0].Operation != "add" || suggestion.Patch[0].Path != "/imagePullSecrets" {
+		t.Errorf("patch = %+v, want the imagePullSecrets to be added", suggestion.Patch)
+	}
+
+	current := &corev1.ServiceAccount{}
+	if err := wrapped.Get(ctx, client.ObjectKeyFromObject(serviceAccount), current); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(current.ImagePullSecrets) != 0 {
+		t.Errorf("ServiceAccount was patched, want it unchanged")
+	}
+
+	// Unchanged objects aren't suggested
+	if err := k8sClient.Update(ctx, current); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d suggestions, want 1", len(entries))
+	}
+}
+
+func Test_Client_Create(t *testing.T) {
+	dir := t.TempDir()
+	k8sClient, _ := newClient(t, dir)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "global-imagepullsecret", Namespace: "team-a"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+	if err := k8sClient.Create(context.TODO(), secret); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "team-a.secret.global-imagepullsecret.json"))
+	if err != nil {
+		t.Fatalf("failed to read suggestion: %v", err)
+	}
+	if strings.Contains(string(data), `{"auths":{}}`) || strings.Contains(string(data), "eyJhdXRocyI6e319") {
+		t.Errorf("suggestion contains the credentials: %s", data)
+	}
+	suggestion := readSuggestion(t, filepath.Join(dir, "team-a.secret.global-imagepullsecret.json"))
+	if suggestion.Operation != OperationCreate || suggestion.Object["kind"] != "Secret" {
+		t.Errorf("suggestion = %+v, want the Secret to be created", suggestion)
+	}
+	if _, ok := suggestion.Object["data"]; ok {
+		t.Errorf("object = %v, want the data to be left out", suggestion.Object)
+	}
+	if len(suggestion.OmittedData) != 1 || suggestion.OmittedData[0] != corev1.DockerConfigJsonKey {
+		t.Errorf("omittedData = %v, want [%s]", suggestion.OmittedData, corev1.DockerConfigJsonKey)
+	}
+}
+
+func Test_Client_Patch_SecretData(t *testing.T) {
+	dir := t.TempDir()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "global-imagepullsecret", Namespace: "team-a"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+	k8sClient, _ := newClient(t, dir, secret)
+
+	rotated := secret.DeepCopy()
+	rotated.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{"registry.example.com":{}}}`)
+	if err := k8sClient.Patch(context.TODO(), rotated, client.MergeFrom(secret)); err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+
+	suggestion := readSuggestion(t, filepath.Join(dir, "team-a.secret.global-imagepullsecret.json"))
+	if suggestion.Operation != OperationPatch || len(suggestion.Patch) != 0 {
+		t.Errorf("suggestion = %+v, want a patch without operations", suggestion)
+	}
+	if len(suggestion.OmittedData) != 1 || suggestion.OmittedData[0] != corev1.DockerConfigJsonKey {
+		t.Errorf("omittedData = %v, want [%s]", suggestion.OmittedData, corev1.DockerConfigJsonKey)
+	}
+}
+
+func Test_Client_ConfigMap(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}}
+	k8sClient, wrapped := newClient(t, "configmap:suggestions", pod)
+	ctx := context.TODO()
+
+	if err := k8sClient.Delete(ctx, pod); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := wrapped.Get(ctx, types.NamespacedName{Name: "suggestions", Namespace: "kube-system"}, configMap); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !strings.Contains(configMap.Data["team-a.pod.app.json"], `"operation": "delete"`) {
+		t.Errorf("ConfigMap = %v, want the deletion of the Pod", configMap.Data)
+	}
+	if err := wrapped.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+		t.Errorf("Pod was deleted, want it to be kept: %v", err)
+	}
+}
+
+func Test_Client_Bookkeeping(t *testing.T) {
+	dir := t.TempDir()
+	k8sClient, wrapped := newClient(t, dir)
+	ctx := context.TODO()
+
+	checkpoint := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "imagepullsecret-patcher-sweep-default", Namespace: "kube-system"}}
+	if err := k8sClient.Create(ctx, checkpoint); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := wrapped.Get(ctx, client.ObjectKeyFromObject(checkpoint), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("checkpoint wasn't created: %v", err)
+	}
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "kube-system"}}
+	if err := k8sClient.Create(ctx, configMap); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := wrapped.Get(ctx, client.ObjectKeyFromObject(configMap), &corev1.ConfigMap{}); err == nil {
+		t.Errorf("ConfigMap was created, want it to be suggested")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kube-system.configmap.settings.json")); err != nil {
+		t.Errorf("ConfigMap wasn't suggested: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teardown
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// retention is how long a deleted namespace is remembered, so requests still queued for it are dropped
+const retention = 10 * time.Minute
+
+// Tracker keeps track of namespaces being deleted, and cancels the reconciles running inside them
+type Tracker struct {
+	mu          sync.Mutex
+	terminating map[string]time.Time
+	cancels     map[string]map[*context.CancelFunc]struct{}
+	onDelete    []func(namespace string)
+	now         func() time.Time
+}
+
+// Default is the Tracker used by the controllers
+var Default = New()
+
+// New returns an empty Tracker
+func New() *Tracker {
+	return &Tracker{
+		terminating: map[string]time.Time{},
+		cancels:     map[string]map[*context.CancelFunc]struct{}{},
+		now:         time.Now,
+	}
+}
+
+// MarkTerminating cancels all reconciles running in namespace, and drops its queued requests from now on
+func (t *Tracker) MarkTerminating(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	if _, ok := t.terminating[namespace]; !ok {
+		t.terminating[namespace] = t.now()
+	}
+	for cancel := range t.cancels[namespace] {
+		(*cancel)()
+	}
+}
+
+// OnDelete registers fn to be called, once a namespace is deleted, e.g. to forget the state kept for it
+func (t *Tracker) OnDelete(fn func(namespace string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onDelete = append(t.onDelete, fn)
+}
+
+// Unmark forgets about namespace, e.g. after it's been recreated
+func (t *Tracker) Unmark(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.terminating, namespace)
+}
+
+// IsTerminating returns whether namespace is being deleted
+func (t *Tracker) IsTerminating(namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.terminating[namespace]
+	return ok
+}
+
+// WithNamespace returns a context, which is canceled once namespace is being deleted
+func (t *Tracker) WithNamespace(ctx context.Context, namespace string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if namespace == "" {
+		return ctx, cancel
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.terminating[namespace]; ok {
+		cancel()
+		return ctx, cancel
+	}
+	if t.cancels[namespace] == nil {
+		t.cancels[namespace] = map[*context.CancelFunc]struct{}{}
+	}
+	t.cancels[namespace][&cancel] = struct{}{}
+
+	return ctx, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.cancels[namespace], &cancel)
+		if len(t.cancels[namespace]) == 0 {
+			delete(t.cancels, namespace)
+		}
+		cancel()
+	}
+}
+
+// prune forgets namespaces deleted longer than retention ago. The caller must hold mu.
+func (t *Tracker) prune() {
+	for namespace, since := range t.terminating {
+		if t.now().Sub(since) > retention {
+			delete(t.terminating, namespace)
+		}
+	}
+}
+
+// ResourceEventHandler marks namespaces as terminating, once their deletion starts
+func (t *Tracker) ResourceEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				t.observe(ns)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				t.observe(ns)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				t.MarkTerminating(ns.GetName())
+				t.deleted(ns.GetName())
+			}
+		},
+	}
+}
+
+// deleted calls the functions registered with OnDelete for namespace
+func (t *Tracker) deleted(namespace string) {
+	t.mu.Lock()
+	onDelete := append([]func(string){}, t.onDelete...)
+	t.mu.Unlock()
+
+	for _, fn := range onDelete {
+		fn(namespace)
+	}
+}
+
+func (t *Tracker) observe(ns *corev1.Namespace) {
+	if !ns.GetDeletionTimestamp().IsZero() || ns.Status.Phase == corev1.NamespaceTerminating {
+		t.MarkTerminating(ns.GetName())
+	} else {
+		t.Unmark(ns.GetName())
+	}
+}
+
+// Reconciler wraps a reconciler, so its reconciles are canceled once their namespace is being deleted,
+// and their errors don't cause retries from then on
+func (t *Tracker) Reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		ctx, cancel := t.WithNamespace(ctx, req.Namespace)
+		defer cancel()
+
+		result, err := r.Reconcile(ctx, req)
+		if t.IsTerminating(req.Namespace) {
+			if err != nil {
+				log.FromContext(ctx).Info("Namespace '" + req.Namespace + "' is being deleted, dropping request: " + err.Error())
+			}
+			return reconcile.Result{}, nil
+		}
+		return result, err
+	})
+}
+
+// queue drops requests for namespaces being deleted, instead of handing them to the workers
+type queue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	controller string
+	tracker    *Tracker
+}
+
+// Queue wraps next, so requests for namespaces being deleted are purged
+func (t *Tracker) Queue(controllerName string, next workqueue.TypedRateLimitingInterface[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+	return &queue{TypedRateLimitingInterface: next, controller: controllerName, tracker: t}
+}
+
+func (q *queue) Get() (reconcile.Request, bool) {
+	for {
+		item, shutdown := q.TypedRateLimitingInterface.Get()
+		if shutdown || !q.tracker.IsTerminating(item.Namespace) {
+			return item, shutdown
+		}
+		metrics.TeardownPurgedTotal.WithLabelValues(q.controller).Inc()
+		q.TypedRateLimitingInterface.Forget(item)
+		q.TypedRateLimitingInterface.Done(item)
+	}
+}
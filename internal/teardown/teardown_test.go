@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teardown
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func request(namespace string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "default"}}
+}
+
+func Test_Tracker_WithNamespace(t *testing.T) {
+	tracker := New()
+
+	ctx, cancel := tracker.WithNamespace(context.Background(), "doomed")
+	defer cancel()
+	otherCtx, otherCancel := tracker.WithNamespace(context.Background(), "other")
+	defer otherCancel()
+
+	tracker.MarkTerminating("doomed")
+	if ctx.Err() == nil {
+		t.Errorf("context of the deleted namespace should be canceled")
+	}
+	if otherCtx.Err() != nil {
+		t.Errorf("context of another namespace should not be canceled")
+	}
+
+	ctx, cancel = tracker.WithNamespace(context.Background(), "doomed")
+	defer cancel()
+	if ctx.Err() == nil {
+		t.Errorf("new context of the deleted namespace should be canceled right away")
+	}
+
+	tracker.Unmark("doomed")
+	if tracker.IsTerminating("doomed") {
+		t.Errorf("recreated namespace should not be terminating")
+	}
+}
+
+func Test_Tracker_Prune(t *testing.T) {
+	now := time.Now()
+	tracker := New()
+	tracker.now = func() time.Time { return now }
+
+	tracker.MarkTerminating("doomed")
+	now = now.Add(retention + time.Second)
+	tracker.MarkTerminating("other")
+
+	if tracker.IsTerminating("doomed") {
+		t.Errorf("namespace deleted longer than retention ago should be forgotten")
+	}
+	if !tracker.IsTerminating("other") {
+		t.Errorf("namespace deleted recently should be terminating")
+	}
+}
+
+func Test_Tracker_OnDelete(t *testing.T) {
+	tracker := New()
+	deleted := []string{}
+	tracker.OnDelete(func(namespace string) {
+		deleted = append(deleted, namespace)
+	})
+
+	handler := tracker.ResourceEventHandler()
+	doomed := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "doomed"}}
+	handler.OnUpdate(doomed, doomed)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "doomed", Obj: doomed})
+
+	if len(deleted) != 1 || deleted[0] != "doomed" {
+		t.Errorf("deleted = %v, want [doomed]", deleted)
+	}
+	if !tracker.IsTerminating("doomed") {
+		t.Errorf("deleted namespace should be terminating")
+	}
+}
+
+func Test_Tracker_Queue(t *testing.T) {
+	tracker := New()
+	q := tracker.Queue("test", workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]()))
+	defer q.ShutDown()
+
+	q.Add(request("doomed"))
+	q.Add(request("alive"))
+	tracker.MarkTerminating("doomed")
+
+	item, shutdown := q.Get()
+	if shutdown {
+		t.Fatalf("queue should not be shut down")
+	}
+	if item != request("alive") {
+		t.Errorf("Get() = %v, want request of the namespace not being deleted", item)
+	}
+	q.Done(item)
+	if q.Len() != 0 {
+		t.Errorf("request of the deleted namespace should be purged")
+	}
+}
+
+func Test_Tracker_Reconciler(t *testing.T) {
+	errNotFound := errors.New("not found")
+	tracker := New()
+	r := tracker.Reconciler(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if req.Namespace == "doomed" {
+			tracker.MarkTerminating("doomed")
+			if ctx.Err() == nil {
+				t.Errorf("reconcile in the deleted namespace should be canceled")
+			}
+		}
+		return reconcile.Result{RequeueAfter: time.Minute}, errNotFound
+	}))
+
+	tests := []struct {
+		name      string
+		namespace string
+		wantErr   bool
+	}{
+		{
+			name:      "Namespace is deleted during reconcile. Should drop the error.",
+			namespace: "doomed",
+			wantErr:   false,
+		},
+		{
+			name:      "Namespace is not deleted. Should return the error.",
+			namespace: "alive",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Reconcile(context.Background(), request(tt.namespace))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result.RequeueAfter != 0 {
+				t.Errorf("Reconcile() should not requeue requests of deleted namespaces")
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+)
+
+// NamespaceAudit describes the state of the imagePullSecret in a namespace
+type NamespaceAudit struct {
+	Namespace                    string   `json:"namespace"`
+	Excluded                     bool     `json:"excluded"`
+	SecretExpected               bool     `json:"secretExpected"`
+	SecretExists                 bool     `json:"secretExists"`
+	SecretUpToDate               bool     `json:"secretUpToDate"`
+	SecretHash                   string   `json:"secretHash,omitempty"`
+	ExpectedSecretHash           string   `json:"expectedSecretHash,omitempty"`
+	ServiceAccountsMissingSecret []string `json:"serviceAccountsMissingSecret,omitempty"`
+}
+
+// InSync reports whether the namespace matches the expected state
+func (a NamespaceAudit) InSync() bool {
+	if a.Excluded {
+		return true
+	}
+	return (!a.SecretExpected || a.SecretExists) &&
+		(!a.SecretExists || a.SecretUpToDate) &&
+		len(a.ServiceAccountsMissingSecret) == 0
+}
+
+// Diff describes, how the namespace differs from the expected state
+func (a NamespaceAudit) Diff() []string {
+	diff := []string{}
+	if a.Excluded {
+		return diff
+	}
+	if a.SecretExpected && !a.SecretExists {
+		diff = append(diff, "Secret is missing")
+	}
+	if a.SecretExists && !a.SecretUpToDate && a.ExpectedSecretHash == "" {
+		diff = append(diff, "Secret has no credential hash recorded")
+	} else if a.SecretExists && !a.SecretUpToDate {
+		diff = append(diff, fmt.Sprintf("Secret data hash is %s, expected %s", a.SecretHash, a.ExpectedSecretHash))
+	}
+	if len(a.ServiceAccountsMissingSecret) > 0 {
+		diff = append(diff, "ServiceAccounts missing the imagePullSecret: "+strings.Join(a.ServiceAccountsMissingSecret, ", "))
+	}
+	return diff
+}
+
+// AuditNamespace compares the imagePullSecret and the managed ServiceAccounts
+// in the namespace against the expected state
+func AuditNamespace(ctx context.Context, k8sClient client.Client, c *config.Config, ns *corev1.Namespace) (NamespaceAudit, error) {
+	return auditNamespace(ctx, k8sClient, c, ns, nil)
+}
+
+// auditNamespace audits the namespace like AuditNamespace. If publishedHash is set, the imagePullSecret is
+// compared against it, instead of the credentials read from their source. An empty publishedHash compares
+// the imagePullSecret against the hash recorded by the controller, when it last wrote it.
+func auditNamespace(ctx context.Context, k8sClient client.Client, c *config.Config, ns *corev1.Namespace, publishedHash *string) (NamespaceAudit, error) {
+	audit := NamespaceAudit{
+		Namespace: ns.GetName(),
+		Excluded:  IsNamespaceExcluded(c, ns),
+	}
+	if audit.Excluded {
+		return audit, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: ns.GetName()}, secret)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return audit, fmt.Errorf("failed to fetch Secret: %w", err)
+	}
+	if err == nil && c.FeatureServiceAccountOnly {
+		// The imagePullSecret is provisioned externally, so its data is not compared
+		audit.SecretExists = true
+		audit.SecretUpToDate = true
+	} else if err == nil && (HasMintedCredentials(c) || (publishedHash != nil && *publishedHash == "")) {
+		// Constructing the desired Secret would mint new credentials, so the data is compared against
+		// the hash recorded by the controller, when it last wrote the Secret
+		audit.SecretExists = true
+		audit.SecretHash = secretDataHash(secret)
+		if recorded := secret.GetAnnotations()[c.AnnotationCredentialHash]; recorded != "" {
+			audit.SecretUpToDate = dockerConfigJSONHash(secret.Data[corev1.DockerConfigJsonKey]) == recorded
+			audit.ExpectedSecretHash = recorded[:min(len(recorded), len(audit.SecretHash))]
+		}
+	} else if err == nil && publishedHash != nil {
+		audit.SecretExists = true
+		audit.SecretHash = secretDataHash(secret)
+		audit.SecretUpToDate = dockerConfigJSONHash(secret.Data[corev1.DockerConfigJsonKey]) == *publishedHash
+		audit.ExpectedSecretHash = (*publishedHash)[:min(len(*publishedHash), len(audit.SecretHash))]
+	} else if err == nil {
+		audit.SecretExists = true
+		desiredSecret, err := ConstructImagePullSecret(ctx, k8sClient, c, ns.GetName())
+		if err != nil {
+			return audit, err
+		}
+		audit.SecretUpToDate = reflect.DeepEqual(secret.Data, desiredSecret.Data)
+		audit.SecretHash = secretDataHash(secret)
+		audit.ExpectedSecretHash = secretDataHash(desiredSecret)
+	}
+	audit.SecretExpected = c.FeatureSecretInAllNamespaces
+	// ServiceAccounts are left to another system in secret-only mode
+	if c.FeatureSecretOnly {
+		return audit, nil
+	}
+
+	serviceAccountList := &corev1.ServiceAccountList{}
+	if err := k8sClient.List(ctx, serviceAccountList, client.InNamespace(ns.GetName())); err != nil {
+		return audit, fmt.Errorf("failed to list ServiceAccounts: %w", err)
+	}
+	for i := range serviceAccountList.Items {
+		sa := &serviceAccountList.Items[i]
+		if !IsServiceAccountManaged(c, ns, sa) {
+			continue
+		}
+		audit.SecretExpected = true
+		if !HasImagePullSecret(sa, c.SecretName) {
+			audit.ServiceAccountsMissingSecret = append(audit.ServiceAccountsMissingSecret, sa.GetName())
+		}
+	}
+
+	return audit, nil
+}
+
+// AuditCluster audits every namespace of the cluster
+func AuditCluster(ctx context.Context, k8sClient client.Client, c *config.Config) ([]NamespaceAudit, error) {
+	return auditCluster(ctx, k8sClient, c, nil)
+}
+
+// AuditClusterPublished audits every namespace of the cluster against the credential hash published by
+// the leader, see PublishedCredentialHash, so the credentials are never read from their source
+func AuditClusterPublished(ctx context.Context, k8sClient client.Client, c *config.Config, publishedHash string) ([]NamespaceAudit, error) {
+	return auditCluster(ctx, k8sClient, c, &publishedHash)
+}
+
+// auditCluster audits every namespace of the cluster, see auditNamespace
+func auditCluster(ctx context.Context, k8sClient client.Client, c *config.Config, publishedHash *string) ([]NamespaceAudit, error) {
+	namespaceList := &corev1.NamespaceList{}
+	if err := k8sClient.List(ctx, namespaceList); err != nil {
+		return nil, fmt.Errorf("failed to list Namespaces: %w", err)
+	}
+
+	audits := []NamespaceAudit{}
+	for i := range namespaceList.Items {
+		audit, err := auditNamespace(ctx, k8sClient, c, &namespaceList.Items[i], publishedHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to audit Namespace '%s': %w", namespaceList.Items[i].GetName(), err)
+		}
+		audits = append(audits, audit)
+	}
+	return audits, nil
+}
+
+// WriteAuditSummary writes the diff of every namespace, that is out of sync, to w
+// and returns whether all namespaces are in sync
+func WriteAuditSummary(w io.Writer, audits []NamespaceAudit) bool {
+	outOfSync := 0
+	for _, audit := range audits {
+		if audit.InSync() {
+			continue
+		}
+		outOfSync++
+		for _, diff := range audit.Diff() {
+			fmt.Fprintf(w, "%s: %s\n", audit.Namespace, diff)
+		}
+	}
+	fmt.Fprintf(w, "%d of %d namespaces out of sync\n", outOfSync, len(audits))
+	return outOfSync == 0
+}
+
+// HasMintedCredentials checks whether reading the credentials issues new ones, like the OIDC token exchange,
+// or renders them per namespace, so audits compare against the recorded credential hash instead
+func HasMintedCredentials(c *config.Config) bool {
+	return c.OIDCTokenEndpoint != "" || c.FeatureTemplateDockerConfigJSON
+}
+
+//+imagepullsecret-patcher:rbac:bundles=secret-only;serviceaccount-only
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;patch
+
+// PublishCredentialHash records the hash of the current credentials on the namespace of the controller, so
+// standby replicas compare the imagePullSecrets against the credentials of the leader, instead of reading
+// them from a source, which only the leader refreshes. Minted credentials have no single hash to publish.
+func PublishCredentialHash(ctx context.Context, k8sClient client.Client, c *config.Config) error {
+	if c.OperatorNamespace == "" || HasMintedCredentials(c) {
+		return nil
+	}
+	hash, err := CachedCredentialHash(ctx, k8sClient, c)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials: %w", redact.Error(err))
+	}
+	ns, err := FetchNamespace(ctx, k8sClient, c.OperatorNamespace)
+	if err != nil {
+		return err
+	}
+	if ns.GetAnnotations()[c.AnnotationCredentialHash] == hash {
+		return nil
+	}
+
+	patchFrom := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[c.AnnotationCredentialHash] = hash
+	if err := k8sClient.Patch(ctx, ns, patchFrom); err != nil {
+		return fmt.Errorf("failed to publish credential hash: %w", err)
+	}
+	return nil
+}
+
+// PublishedCredentialHash returns the hash of the credentials published by the leader, see PublishCredentialHash,
+// or an empty string, if none was published
+func PublishedCredentialHash(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	if c.OperatorNamespace == "" || HasMintedCredentials(c) {
+		return "", nil
+	}
+	ns, err := FetchNamespace(ctx, k8sClient, c.OperatorNamespace)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return ns.GetAnnotations()[c.AnnotationCredentialHash], nil
+}
+
+// secretDataHash returns a short hash of the .dockerconfigjson of secret
+func secretDataHash(secret *corev1.Secret) string {
+	return dockerConfigJSONHash(secret.Data[corev1.DockerConfigJsonKey])[:12]
+}
+
+// dockerConfigJSONHash returns the hash of dockerConfigJSON, which is recorded in the credential-hash annotation
+func dockerConfigJSONHash(dockerConfigJSON []byte) string {
+	sum := sha256.Sum256(dockerConfigJSON)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_WriteAuditSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		audits []NamespaceAudit
+		want   string
+		inSync bool
+	}{
+		{
+			name: "All namespaces in sync. Should only write the count.",
+			audits: []NamespaceAudit{
+				{Namespace: "default", SecretExpected: true, SecretExists: true, SecretUpToDate: true},
+				{Namespace: "kube-system", Excluded: true},
+				{Namespace: "empty"},
+			},
+			want:   "0 of 3 namespaces out of sync\n",
+			inSync: true,
+		},
+		{
+			name: "Namespaces out of sync. Should write their diff.",
+			audits: []NamespaceAudit{
+				{Namespace: "default", SecretExpected: true, SecretExists: true, SecretUpToDate: true},
+				{Namespace: "missing", SecretExpected: true},
+				{
+					Namespace:                    "stale",
+					SecretExpected:               true,
+					SecretExists:                 true,
+					SecretHash:                   "aaaaaaaaaaaa",
+					ExpectedSecretHash:           "bbbbbbbbbbbb",
+					ServiceAccountsMissingSecret: []string{"default", "builder"},
+				},
+				{Namespace: "unrecorded", SecretExpected: true, SecretExists: true, SecretHash: "aaaaaaaaaaaa"},
+			},
+			want: "missing: Secret is missing\n" +
+				"stale: Secret data hash is aaaaaaaaaaaa, expected bbbbbbbbbbbb\n" +
+				"stale: ServiceAccounts missing the imagePullSecret: default, builder\n" +
+				"unrecorded: Secret has no credential hash recorded\n" +
+				"3 of 4 namespaces out of sync\n",
+			inSync: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if got := WriteAuditSummary(w, tt.audits); got != tt.inSync {
+				t.Errorf("WriteAuditSummary() = %v, want %v", got, tt.inSync)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("WriteAuditSummary() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_AuditNamespace_MintedCredentials(t *testing.T) {
+	exchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		http.Error(w, "unexpected token exchange", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := config.NewConfig(config.ConfigOptions{
+		OIDCTokenEndpoint: server.URL,
+		OIDCRegistry:      "registry.example.com",
+		SecretNamespace:   "kube-system",
+	})
+	dockerConfigJSON := []byte(`{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`)
+	newSecret := func(namespace string, data []byte, recordedHash string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        c.SecretName,
+				Namespace:   namespace,
+				Annotations: map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			},
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: data},
+			Type: corev1.SecretTypeDockerConfigJson,
+		}
+		if recordedHash != "" {
+			secret.Annotations[c.AnnotationCredentialHash] = recordedHash
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		upToDate bool
+	}{
+		{
+			name:     "Secret matches its recorded hash. Should be up to date.",
+			secret:   newSecret("default", dockerConfigJSON, dockerConfigJSONHash(dockerConfigJSON)),
+			upToDate: true,
+		},
+		{
+			name:     "Secret was modified after it was written. Should be out of date.",
+			secret:   newSecret("default", []byte(`{"auths":{}}`), dockerConfigJSONHash(dockerConfigJSON)),
+			upToDate: false,
+		},
+		{
+			name:     "Secret has no recorded hash. Should be out of date.",
+			secret:   newSecret("default", dockerConfigJSON, ""),
+			upToDate: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.secret.GetNamespace()}}
+			k8sClient := fake.NewClientBuilder().WithObjects(ns, tt.secret).Build()
+
+			audit, err := AuditNamespace(context.TODO(), k8sClient, c, ns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !audit.SecretExists || audit.SecretUpToDate != tt.upToDate {
+				t.Errorf("AuditNamespace() = %+v, want SecretUpToDate %v", audit, tt.upToDate)
+			}
+		})
+	}
+	if exchanges != 0 {
+		t.Errorf("AuditNamespace() exchanged tokens %d times, want 0", exchanges)
+	}
+}
+
+func Test_AuditClusterPublished(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:  `{"auths":{"registry.example.com":{"auth":"bmV3Om5ldw=="}}}`,
+		SecretNamespace:   "kube-system",
+		OperatorNamespace: "kube-system",
+	})
+	// The leader published the previous credentials, which a standby can't refresh from their source
+	published := []byte(`{"auths":{"registry.example.com":{"auth":"b2xkOm9sZA=="}}}`)
+	newSecret := func(data []byte, recordedHash string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.SecretName,
+				Namespace: "default",
+				Annotations: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+					c.AnnotationCredentialHash: recordedHash,
+				},
+			},
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: data},
+			Type: corev1.SecretTypeDockerConfigJson,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		publishedHash string
+		secret        *corev1.Secret
+		upToDate      bool
+	}{
+		{
+			name:          "Secret matches the published hash. Should be up to date.",
+			publishedHash: dockerConfigJSONHash(published),
+			secret:        newSecret(published, dockerConfigJSONHash(published)),
+			upToDate:      true,
+		},
+		{
+			name:          "Secret matches the credentials of the source, but not the published hash. Should be out of date.",
+			publishedHash: dockerConfigJSONHash(published),
+			secret:        newSecret([]byte(c.DockerConfigJSON), dockerConfigJSONHash([]byte(c.DockerConfigJSON))),
+			upToDate:      false,
+		},
+		{
+			name:          "No hash published yet. Should compare against the recorded hash.",
+			publishedHash: "",
+			secret:        newSecret(published, dockerConfigJSONHash(published)),
+			upToDate:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.secret.GetNamespace()}}
+			k8sClient := fake.NewClientBuilder().WithObjects(ns, tt.secret).Build()
+
+			audits, err := AuditClusterPublished(context.TODO(), k8sClient, c, tt.publishedHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(audits) != 1 || !audits[0].SecretExists || audits[0].SecretUpToDate != tt.upToDate {
+				t.Errorf("AuditClusterPublished() = %+v, want SecretUpToDate %v", audits, tt.upToDate)
+			}
+		})
+	}
+}
+
+func Test_PublishCredentialHash(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:  `{"auths":{"registry.example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+		SecretNamespace:   "kube-system",
+		OperatorNamespace: "kube-system",
+	})
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: c.OperatorNamespace}}
+	k8sClient := fake.NewClientBuilder().WithObjects(ns).Build()
+
+	if hash, err := PublishedCredentialHash(context.TODO(), k8sClient, c); err != nil || hash != "" {
+		t.Fatalf("PublishedCredentialHash() = %q, %v, want nothing published", hash, err)
+	}
+	if err := PublishCredentialHash(context.TODO(), k8sClient, c); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := PublishedCredentialHash(context.TODO(), k8sClient, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dockerConfigJSONHash([]byte(c.DockerConfigJSON)); hash != want {
+		t.Errorf("PublishedCredentialHash() = %q, want %q", hash, want)
+	}
+}
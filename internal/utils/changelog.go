@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// changelogSize is the number of changes kept in the changelog annotation
+const changelogSize = 5
+
+const (
+	// ChangelogActionAttached means the imagePullSecret was attached to the ServiceAccount
+	ChangelogActionAttached = "attached"
+	// ChangelogActionDetached means the imagePullSecret was removed from the ServiceAccount, as it's no longer managed
+	ChangelogActionDetached = "detached"
+)
+
+// ChangelogEntry describes a single change of an object by the controller
+type ChangelogEntry struct {
+	Time       string `json:"time"`
+	Action     string `json:"action"`
+	SecretName string `json:"secretName"`
+}
+
+// Changelog returns the changes recorded in the changelog annotation of obj, oldest first.
+// A malformed annotation is treated as empty.
+func Changelog(c *config.Config, obj client.Object) []ChangelogEntry {
+	value, ok := obj.GetAnnotations()[c.AnnotationChangelog]
+	if !ok {
+		return nil
+	}
+	entries := []ChangelogEntry{}
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+// RecordChange appends a change to the changelog annotation of obj, so namespace owners can see
+// when and what the controller changed. Only the latest changelogSize changes are kept.
+func RecordChange(c *config.Config, obj client.Object, action string, secretName string, at time.Time) {
+	entries := append(Changelog(c, obj), ChangelogEntry{
+		Time:       at.UTC().Format(time.RFC3339),
+		Action:     action,
+		SecretName: secretName,
+	})
+	if len(entries) > changelogSize {
+		entries = entries[len(entries)-changelogSize:]
+	}
+	value, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[c.AnnotationChangelog] = string(value)
+	obj.SetAnnotations(annotations)
+}
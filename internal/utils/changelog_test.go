@@ -0,0 +1,73 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_RecordChange(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantLen     int
+	}{
+		{
+			name:    "No changelog yet. Should create it.",
+			wantLen: 1,
+		},
+		{
+			name:        "Malformed changelog. Should start over.",
+			annotations: map[string]string{c.AnnotationChangelog: "not json"},
+			wantLen:     1,
+		},
+		{
+			name: "Full changelog. Should drop the oldest change.",
+			annotations: map[string]string{
+				c.AnnotationChangelog: `[{"time":"1"},{"time":"2"},{"time":"3"},{"time":"4"},{"time":"5"}]`,
+			},
+			wantLen: changelogSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: tt.annotations}}
+			RecordChange(c, sa, ChangelogActionAttached, c.SecretName, at)
+
+			entries := Changelog(c, sa)
+			if len(entries) != tt.wantLen {
+				t.Fatalf("Changelog() has %d entries, want %d", len(entries), tt.wantLen)
+			}
+			want := ChangelogEntry{Time: "2024-05-01T12:00:00Z", Action: ChangelogActionAttached, SecretName: c.SecretName}
+			if got := entries[len(entries)-1]; got != want {
+				t.Errorf("latest entry = %v, want %v", got, want)
+			}
+			if len(entries) == changelogSize && entries[0].Time != "2" {
+				t.Errorf("oldest entry = %v, want the second one", entries[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+)
+
+// credentialBuffer holds decoded credentials in memory, guarded by a lock, and keeps them out of printed values.
+// Only its own copy is overwritten with zeros, whenever the credentials are replaced. The strings returned by Value
+// flow into the config and the data of Secrets, and stay on the heap until they're garbage collected.
+type credentialBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	// generation is incremented, whenever the credentials change
+	generation uint64
+}
+
+// Set replaces the credentials with a copy of data, clearing its copy of the previous ones, and reports whether they changed
+func (b *credentialBuffer) Set(data []byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := string(data) != string(b.data)
+	clear(b.data)
+	b.data = append([]byte(nil), data...)
+	if changed {
+		b.generation++
+	}
+	return changed
+}
+
+// Generation returns a number, which changes whenever the credentials change
+func (b *credentialBuffer) Generation() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.generation
+}
+
+// Value returns a copy of the credentials, or an empty string if none are set
+func (b *credentialBuffer) Value() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return string(b.data)
+}
+
+// String redacts the credentials, so they're never printed by accident, e.g. as part of an error
+func (b *credentialBuffer) String() string {
+	return redact.Placeholder
+}
+
+// IsEmpty reports whether no credentials are set
+func (b *credentialBuffer) IsEmpty() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.data) == 0
+}
+
+// Reset clears and drops the credentials
+func (b *credentialBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	clear(b.data)
+	b.data = nil
+	b.generation++
+}
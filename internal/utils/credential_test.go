@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_credentialBuffer(t *testing.T) {
+	buffer := &credentialBuffer{}
+	if !buffer.IsEmpty() {
+		t.Fatalf("IsEmpty() = false, want true")
+	}
+
+	if changed := buffer.Set([]byte("first-credentials")); !changed {
+		t.Errorf("Set() = false, want true")
+	}
+	previous := buffer.data
+	if changed := buffer.Set([]byte("first-credentials")); changed {
+		t.Errorf("Set() = true for unchanged credentials, want false")
+	}
+	for _, b := range previous {
+		if b != 0 {
+			t.Fatalf("Set() didn't zero the replaced credentials: %q", previous)
+		}
+	}
+
+	if got := buffer.Value(); got != "first-credentials" {
+		t.Errorf("Value() = %v, want first-credentials", got)
+	}
+	if got := fmt.Sprintf("%v", buffer); got != "[REDACTED]" {
+		t.Errorf("Sprintf() = %v, want [REDACTED]", got)
+	}
+
+	previous = buffer.data
+	buffer.Reset()
+	if !buffer.IsEmpty() || buffer.Value() != "" {
+		t.Errorf("Reset() didn't drop the credentials")
+	}
+	for _, b := range previous {
+		if b != 0 {
+			t.Fatalf("Reset() didn't zero the credentials: %q", previous)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+const (
+	ageHeader      = "age-encryption.org/v1"
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+)
+
+// sopsValueRegexp matches a value encrypted by SOPS
+var sopsValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// sopsMetadata is the part of the sops metadata required for decryption with age
+type sopsMetadata struct {
+	Age []struct {
+		Recipient string `json:"recipient"`
+		Enc       string `json:"enc"`
+	} `json:"age"`
+	LastModified     string `json:"lastmodified"`
+	MAC              string `json:"mac"`
+	MACOnlyEncrypted bool   `json:"mac_only_encrypted"`
+}
+
+// DecryptDockerConfigJSON decrypts the content of DockerConfigJSONPath, if it is age or SOPS
+// encrypted. SOPS files have to be in JSON format and encrypted for an age recipient.
+// Unencrypted content is returned as is.
+func DecryptDockerConfigJSON(c *config.Config, content []byte) ([]byte, error) {
+	// Only leading whitespace may be trimmed, as binary age files could end with it
+	trimmed := bytes.TrimLeft(content, " \t\r\n")
+	isAge := bytes.HasPrefix(trimmed, []byte(ageHeader)) || bytes.HasPrefix(trimmed, []byte(ageArmorHeader))
+
+	var sopsFile map[string]interface{}
+	isSOPS := !isAge && json.Unmarshal(trimmed, &sopsFile) == nil && isSOPSMetadata(sopsFile["sops"])
+
+	if !isAge && !isSOPS {
+		return content, nil
+	}
+
+	identities, err := getAgeIdentities(c)
+	if err != nil {
+		return nil, err
+	}
+	if isAge {
+		return decryptAge(trimmed, identities)
+	}
+	return decryptSOPS(trimmed, sopsFile, identities)
+}
+
+// isSOPSMetadata checks whether the top-level "sops" key holds the metadata written by SOPS,
+// which always includes the MAC
+func isSOPSMetadata(metadata interface{}) bool {
+	m, ok := metadata.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m["mac"]
+	return ok
+}
+
+// getAgeIdentities parses the age identities from AgeKey or AgeKeyFile
+func getAgeIdentities(c *config.Config) ([]age.Identity, error) {
+	keys := c.AgeKey
+	if keys == "" && c.AgeKeyFile != "" {
+		keyFile, err := NormalizePath(c.AgeKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read age key file: %w", err)
+		}
+		keys = string(b)
+	}
+	if keys == "" {
+		return nil, fmt.Errorf("credentials are encrypted, but neither `CONFIG_AGE_KEY` nor `CONFIG_AGE_KEYFILE` defined")
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(keys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age key: %w", err)
+	}
+	return identities, nil
+}
+
+func decryptAge(content []byte, identities []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(content, []byte(ageArmorHeader)) {
+		src = armor.NewReader(src)
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age file: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+// decryptSOPS decrypts the SOPS file, which is parsed as sopsFile from content.
+// The file is rejected, if its MAC doesn't match the decrypted values.
+func decryptSOPS(content []byte, sopsFile map[string]interface{}, identities []age.Identity) ([]byte, error) {
+	b, err := json.Marshal(sopsFile["sops"])
+	if err != nil {
+		return nil, err
+	}
+	metadata := sopsMetadata{}
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse sops metadata: %w", err)
+	}
+
+	// The data key is encrypted for every age recipient, try all of them
+	var dataKey []byte
+	for _, recipient := range metadata.Age {
+		if dataKey, err = decryptAge([]byte(strings.TrimSpace(recipient.Enc)), identities); err == nil {
+			break
+		}
+	}
+	if dataKey == nil {
+		return nil, fmt.Errorf("failed to decrypt sops data key with any age recipient")
+	}
+
+	if err := verifySOPSMAC(content, metadata, dataKey); err != nil {
+		return nil, err
+	}
+
+	delete(sopsFile, "sops")
+	decrypted, err := decryptSOPSTree(sopsFile, dataKey, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(decrypted)
+}
+
+// verifySOPSMAC checks the MAC of the SOPS file, which is encrypted with the data key and authenticated
+// with the time of the last modification. SOPS computes it as the SHA512 of all values in the order of the
+// document, so it has to be computed from content instead of the unordered sopsFile.
+func verifySOPSMAC(content []byte, metadata sopsMetadata, dataKey []byte) error {
+	lastModified, err := time.Parse(time.RFC3339, metadata.LastModified)
+	if err != nil {
+		return fmt.Errorf("failed to parse sops lastmodified: %w", err)
+	}
+	mac, err := decryptSOPSValue(metadata.MAC, dataKey, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt sops mac: %w", err)
+	}
+
+	hash := sha512.New()
+	dec := json.NewDecoder(bytes.NewReader(content))
+	err = walkSOPSValues(dec, "", true, func(value interface{}, path string) error {
+		encrypted := false
+		if s, ok := value.(string); ok && sopsValueRegexp.MatchString(s) {
+			encrypted = true
+			decrypted, err := decryptSOPSValue(s, dataKey, path)
+			if err != nil {
+				return err
+			}
+			value = decrypted
+		}
+		if encrypted || !metadata.MACOnlyEncrypted {
+			hash.Write(sopsValueBytes(value))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The MAC is stored as upper case hex. Compare it in constant time, to not leak how much of it matches.
+	macString, _ := mac.(string)
+	expected, err := hex.DecodeString(macString)
+	if err != nil || subtle.ConstantTimeCompare(expected, hash.Sum(nil)) != 1 {
+		return fmt.Errorf("sops mac mismatch, the file was modified after encryption")
+	}
+	return nil
+}
+
+// walkSOPSValues calls visit for every value of the JSON document read by dec, in order, with the path
+// of keys leading to it like decryptSOPSTree. The top-level "sops" key is skipped.
+func walkSOPSValues(dec *json.Decoder, path string, top bool, visit func(value interface{}, path string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to parse sops file: %w", err)
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return fmt.Errorf("failed to parse sops file: %w", err)
+				}
+				if top && key == "sops" {
+					var metadata json.RawMessage
+					if err := dec.Decode(&metadata); err != nil {
+						return fmt.Errorf("failed to parse sops file: %w", err)
+					}
+					continue
+				}
+				if err := walkSOPSValues(dec, path+fmt.Sprint(key)+":", false, visit); err != nil {
+					return err
+				}
+			}
+		case '[':
+			for dec.More() {
+				if err := walkSOPSValues(dec, path, false, visit); err != nil {
+					return err
+				}
+			}
+		}
+		// Consume the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("failed to parse sops file: %w", err)
+		}
+		return nil
+	}
+	return visit(tok, path)
+}
+
+// sopsValueBytes returns the representation of value, which SOPS hashes for the MAC
+func sopsValueBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case int:
+		return []byte(strconv.Itoa(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	}
+	return nil
+}
+
+// decryptSOPSTree decrypts all values of the tree. SOPS authenticates every value
+// with the path of keys leading to it, joined and terminated by colons.
+func decryptSOPSTree(node interface{}, dataKey []byte, path string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			decrypted, err := decryptSOPSTree(v, dataKey, path+k+":")
+			if err != nil {
+				return nil, err
+			}
+			n[k] = decrypted
+		}
+		return n, nil
+	case []interface{}:
+		for i, v := range n {
+			decrypted, err := decryptSOPSTree(v, dataKey, path)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = decrypted
+		}
+		return n, nil
+	case string:
+		return decryptSOPSValue(n, dataKey, path)
+	}
+	return node, nil
+}
+
+func decryptSOPSValue(value string, dataKey []byte, additionalData string) (interface{}, error) {
+	matches := sopsValueRegexp.FindStringSubmatch(value)
+	if matches == nil {
+		// Not encrypted, e.g. because of unencrypted_suffix
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(matches[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sops value: %w", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sops iv: %w", err)
+	}
+	tag, err := base64.StdEncoding.DecodeString(matches[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sops tag: %w", err)
+	}
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops value: %w", err)
+	}
+
+	switch matches[4] {
+	case "str":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	}
+	return nil, fmt.Errorf("unsupported sops value type %s", matches[4])
+}
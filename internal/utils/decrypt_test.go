@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+const testDockerConfigJSON = `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`
+
+func encryptAge(t *testing.T, recipient age.Recipient, plaintext []byte, armored bool) []byte {
+	buf := &bytes.Buffer{}
+	var dst io.Writer = buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(buf)
+		dst = armorWriter
+	}
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if armored {
+		if err := armorWriter.Close(); err != nil {
+			t.Fatalf("failed to armor: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func encryptSOPSValue(t *testing.T, dataKey []byte, value string, additionalData string) string {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	iv := make([]byte, 32)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatalf("failed to create iv: %v", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		t.Fatalf("failed to create gcm: %v", err)
+	}
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return "ENC[AES256_GCM,data:" + base64.StdEncoding.EncodeToString(data) +
+		",iv:" + base64.StdEncoding.EncodeToString(iv) +
+		",tag:" + base64.StdEncoding.EncodeToString(tag) + ",type:str]"
+}
+
+func Test_DecryptDockerConfigJSON(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate age identity: %v", err)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatalf("failed to generate data key: %v", err)
+	}
+	newSOPSFile := func(macValue string) []byte {
+		lastModified := "2024-01-01T00:00:00Z"
+		mac := sha512.Sum512([]byte(macValue))
+		sopsFile, err := json.Marshal(map[string]interface{}{
+			"auths": map[string]interface{}{
+				"example.com": map[string]interface{}{
+					"auth": encryptSOPSValue(t, dataKey, "Zm9vOmJhcg==", "auths:example.com:auth:"),
+				},
+			},
+			"sops": map[string]interface{}{
+				"age": []map[string]string{
+					{
+						"recipient": identity.Recipient().String(),
+						"enc":       string(encryptAge(t, identity.Recipient(), dataKey, True)),
+					},
+				},
+				"lastmodified": lastModified,
+				"mac":          encryptSOPSValue(t, dataKey, fmt.Sprintf("%X", mac), lastModified),
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to marshal sops file: %v", err)
+		}
+		return sopsFile
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		ageKey  string
+		wantErr bool
+	}{
+		{
+			"Unencrypted content. Should be returned as is.",
+			[]byte(testDockerConfigJSON),
+			"",
+			False,
+		},
+		{
+			"Binary age file. Should be decrypted.",
+			encryptAge(t, identity.Recipient(), []byte(testDockerConfigJSON), False),
+			identity.String(),
+			False,
+		},
+		{
+			"Armored age file. Should be decrypted.",
+			encryptAge(t, identity.Recipient(), []byte(testDockerConfigJSON), True),
+			identity.String(),
+			False,
+		},
+		{
+			"SOPS file. Should be decrypted.",
+			newSOPSFile("Zm9vOmJhcg=="),
+			identity.String(),
+			False,
+		},
+		{
+			"SOPS file with mismatching MAC. Should error.",
+			newSOPSFile("dGFtcGVyZWQ="),
+			identity.String(),
+			True,
+		},
+		{
+			"Age file without key. Should error.",
+			encryptAge(t, identity.Recipient(), []byte(testDockerConfigJSON), False),
+			"",
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.NewConfig(config.ConfigOptions{DockerConfigJSONPath: "/dev/null", SecretNamespace: "kube-system", AgeKey: tt.ageKey})
+			got, err := DecryptDockerConfigJSON(c, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecryptDockerConfigJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != testDockerConfigJSON {
+				t.Errorf("DecryptDockerConfigJSON() = %s, want %s", got, testDockerConfigJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+)
+
+// IsServiceAccountDetachable checks whether serviceAccount references the imagePullSecret, but is no longer
+// managed, e.g. as ServiceAccounts shrank. Excluded namespaces and ServiceAccounts are never touched.
+func IsServiceAccountDetachable(c *config.Config, namespace client.Object, serviceAccount *corev1.ServiceAccount) bool {
+	if IsNamespaceExcluded(c, namespace) || IsServiceAccountExcluded(c, serviceAccount) {
+		return false
+	}
+	return !IsServiceAccountManaged(c, namespace, serviceAccount) && IsImagePullSecretAttached(c, serviceAccount)
+}
+
+// IsImagePullSecretAttached checks whether the controller added the imagePullSecret to serviceAccount, as opposed
+// to a user referencing it manually. ServiceAccounts patched before the attached annotation was introduced are
+// recognized by their changelog.
+func IsImagePullSecretAttached(c *config.Config, serviceAccount *corev1.ServiceAccount) bool {
+	if !HasImagePullSecret(serviceAccount, c.SecretName) {
+		return false
+	}
+	if secretName, ok := serviceAccount.GetAnnotations()[c.AnnotationAttached]; ok {
+		return secretName == c.SecretName
+	}
+	for _, entry := range Changelog(c, serviceAccount) {
+		if entry.Action == ChangelogActionAttached && entry.SecretName == c.SecretName {
+			return true
+		}
+	}
+	return false
+}
+
+// SetImagePullSecretAttached records on serviceAccount, that the controller added the imagePullSecret secretName,
+// or removes the record, if secretName is empty
+func SetImagePullSecretAttached(c *config.Config, serviceAccount *corev1.ServiceAccount, secretName string) {
+	annotations := serviceAccount.GetAnnotations()
+	if secretName == "" {
+		delete(annotations, c.AnnotationAttached)
+		serviceAccount.SetAnnotations(annotations)
+		return
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[c.AnnotationAttached] = secretName
+	serviceAccount.SetAnnotations(annotations)
+}
+
+// IsImagePullSecretUsed checks whether a ServiceAccount in namespace references the imagePullSecret, or is
+// managed and therefore going to reference it
+func IsImagePullSecretUsed(ctx context.Context, k8sClient client.Client, c *config.Config, namespace client.Object) (bool, error) {
+	if c.FeatureSecretInAllNamespaces {
+		return true, nil
+	}
+
+	serviceAccountList := &corev1.ServiceAccountList{}
+	if err := k8sClient.List(ctx, serviceAccountList, client.InNamespace(namespace.GetName())); err != nil {
+		return false, fmt.Errorf("error listing ServiceAccounts in namespace '%s': %w", namespace.GetName(), err)
+	}
+	for i := range serviceAccountList.Items {
+		serviceAccount := &serviceAccountList.Items[i]
+		if HasImagePullSecret(serviceAccount, c.SecretName) || IsServiceAccountManaged(c, namespace, serviceAccount) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteUnusedImagePullSecret deletes the imagePullSecret in namespace, once no ServiceAccount uses it anymore.
+// Secrets not created by the controller or carrying an exclude annotation are kept.
+func DeleteUnusedImagePullSecret(ctx context.Context, k8sClient client.Client, c *config.Config, namespace client.Object) error {
+	used, err := IsImagePullSecretUsed(ctx, k8sClient, c, namespace)
+	if err != nil || used {
+		return err
+	}
+
+	secret := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: namespace.GetName()}, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !IsProfileSecret(c, secret) || HasExcludeAnnotation(c, secret) {
+		return nil
+	}
+	if err := k8sClient.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("Failed to delete unused Secret '%s' in namespace '%s': %w", secret.GetName(), secret.GetNamespace(), err)
+	}
+	log.FromContext(ctx).Info("Deleted unused Secret '" + secret.GetName() + "' in namespace '" + secret.GetNamespace() + "'")
+	eventlog.Record(eventlog.ActionSecretDeleted, secret.GetNamespace(), secret.GetName(), "unused")
+	return nil
+}
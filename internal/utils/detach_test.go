@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_IsImagePullSecretAttached(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+
+	tests := []struct {
+		name             string
+		annotations      map[string]string
+		imagePullSecrets []corev1.LocalObjectReference
+		want             bool
+	}{
+		{
+			name:             "Attached annotation names the imagePullSecret. Should be attached.",
+			annotations:      map[string]string{c.AnnotationAttached: c.SecretName},
+			imagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			want:             true,
+		},
+		{
+			name:             "Reference added manually. Should not be attached.",
+			imagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			want:             false,
+		},
+		{
+			name:             "Attached annotation names a previous SecretName. Should not be attached.",
+			annotations:      map[string]string{c.AnnotationAttached: "previous"},
+			imagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			want:             false,
+		},
+		{
+			name:             "Patched before the attached annotation existed. Should be attached by its changelog.",
+			annotations:      map[string]string{c.AnnotationChangelog: `[{"action":"attached","secretName":"` + c.SecretName + `"}]`},
+			imagePullSecrets: []corev1.LocalObjectReference{{Name: c.SecretName}},
+			want:             true,
+		},
+		{
+			name:        "Reference removed by a user. Should not be attached.",
+			annotations: map[string]string{c.AnnotationAttached: c.SecretName},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{
+				ObjectMeta:       metav1.ObjectMeta{Name: "default", Annotations: tt.annotations},
+				ImagePullSecrets: tt.imagePullSecrets,
+			}
+			if got := IsImagePullSecretAttached(c, sa); got != tt.want {
+				t.Errorf("IsImagePullSecretAttached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// dockerConfigEntry is a single registry entry of a .dockerconfigjson or .dockercfg
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// dockerConfigJSON is the content of a .dockerconfigjson
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+// GetDockerConfigJSONFromSecret fetches the source Secret and returns its content as .dockerconfigjson
+func GetDockerConfigJSONFromSecret(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, name string) (string, error) {
+	secret := &corev1.Secret{}
+	if err := k8sClient.Get(ctx,
+		types.NamespacedName{
+			Name:      name,
+			Namespace: namespace,
+		},
+		secret,
+	); err != nil {
+		return "", fmt.Errorf("failed to fetch source Secret: %w", err)
+	}
+	return ConvertToDockerConfigJSON(c, secret)
+}
+
+// ConvertToDockerConfigJSON converts Secrets of type kubernetes.io/dockerconfigjson,
+// kubernetes.io/dockercfg and kubernetes.io/basic-auth to the content of a .dockerconfigjson.
+// For kubernetes.io/basic-auth, the registry is read from the AnnotationRegistry annotation.
+func ConvertToDockerConfigJSON(c *config.Config, secret *corev1.Secret) (string, error) {
+	switch secret.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		data, ok := secret.Data[corev1.DockerConfigJsonKey]
+		if !ok {
+			return "", fmt.Errorf("Secret '%s' is missing key %s", secret.GetName(), corev1.DockerConfigJsonKey)
+		}
+		return string(data), nil
+
+	case corev1.SecretTypeDockercfg:
+		data, ok := secret.Data[corev1.DockerConfigKey]
+		if !ok {
+			return "", fmt.Errorf("Secret '%s' is missing key %s", secret.GetName(), corev1.DockerConfigKey)
+		}
+		auths := map[string]dockerConfigEntry{}
+		if err := json.Unmarshal(data, &auths); err != nil {
+			return "", fmt.Errorf("failed to parse %s of Secret '%s': %w", corev1.DockerConfigKey, secret.GetName(), err)
+		}
+		return marshalDockerConfigJSON(auths)
+
+	case corev1.SecretTypeBasicAuth:
+		registry := secret.GetAnnotations()[c.AnnotationRegistry]
+		if registry == "" {
+			return "", fmt.Errorf("Secret '%s' is missing annotation %s", secret.GetName(), c.AnnotationRegistry)
+		}
+		username := string(secret.Data[corev1.BasicAuthUsernameKey])
+		password := string(secret.Data[corev1.BasicAuthPasswordKey])
+		return marshalDockerConfigJSON(map[string]dockerConfigEntry{
+			registry: {
+				Username: username,
+				Password: password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		})
+	}
+	return "", fmt.Errorf("Secret '%s' has unsupported type %s", secret.GetName(), secret.Type)
+}
+
+func marshalDockerConfigJSON(auths map[string]dockerConfigEntry) (string, error) {
+	b, err := json.Marshal(dockerConfigJSON{Auths: auths})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// dockerConfigJSONTemplateData is passed to templated dockerConfigJSONs
+type dockerConfigJSONTemplateData struct {
+	Namespace string
+}
+
+// RenderDockerConfigJSON renders the dockerConfigJSON as Go template for the namespace,
+// for registries issuing credentials per project. Besides `{{ .Namespace }}`, the
+// function `b64enc` is available to construct the auth field.
+func RenderDockerConfigJSON(dockerConfigJSON string, namespace string) (string, error) {
+	tmpl, err := template.New("dockerconfigjson").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"b64enc": func(s string) string {
+				return base64.StdEncoding.EncodeToString([]byte(s))
+			},
+		}).
+		Parse(dockerConfigJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse dockerConfigJSON template: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, dockerConfigJSONTemplateData{Namespace: namespace}); err != nil {
+		return "", fmt.Errorf("failed to render dockerConfigJSON template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// CredentialHash returns a hash of the current dockerConfigJSON, which changes whenever the credentials are rotated
+func CredentialHash(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	dockerConfigJSON, err := GetDockerConfigJSON(ctx, k8sClient, c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(dockerConfigJSON))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// credentialHashCache holds the CredentialHash of the last revision of the credential source
+var credentialHashCache struct {
+	mu       sync.Mutex
+	config   *config.Config
+	revision string
+	hash     string
+}
+
+// CachedCredentialHash returns the CredentialHash, which is only computed again, once the revision of the
+// credential source changed, so the credentials aren't read and decrypted on every reconcile. Sources without
+// a revision, e.g. an OIDC token due for a refresh, are read every time.
+func CachedCredentialHash(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	revision, ok := credentialRevision(ctx, k8sClient, c)
+	if ok {
+		credentialHashCache.mu.Lock()
+		if credentialHashCache.config == c && credentialHashCache.revision == revision {
+			hash := credentialHashCache.hash
+			credentialHashCache.mu.Unlock()
+			return hash, nil
+		}
+		credentialHashCache.mu.Unlock()
+	}
+
+	hash, err := CredentialHash(ctx, k8sClient, c)
+	if err != nil || !ok {
+		return hash, err
+	}
+	// A change after the revision was read only leads to another computation on the next call
+	credentialHashCache.mu.Lock()
+	defer credentialHashCache.mu.Unlock()
+	credentialHashCache.config = c
+	credentialHashCache.revision = revision
+	credentialHashCache.hash = hash
+	return hash, nil
+}
+
+// credentialRevision returns a cheap revision of the configured credential sources, and whether they have one
+func credentialRevision(ctx context.Context, k8sClient client.Client, c *config.Config) (string, bool) {
+	sources := []string{credentialSource(c)}
+	if c.CredentialSources != "" {
+		sources = strings.Split(c.CredentialSources, ",")
+	}
+
+	revisions := make([]string, 0, len(sources))
+	for _, source := range sources {
+		revision, ok := sourceRevision(ctx, k8sClient, c, strings.TrimSpace(source))
+		if !ok {
+			return "", false
+		}
+		revisions = append(revisions, revision)
+	}
+	return strings.Join(revisions, ","), true
+}
+
+// sourceRevision returns a revision of a single credential source, which changes whenever its content changes
+func sourceRevision(ctx context.Context, k8sClient client.Client, c *config.Config, source string) (string, bool) {
+	switch source {
+	case config.CredentialSourceEnv:
+		return source, true
+	case config.CredentialSourceFile:
+		filename, err := NormalizePath(c.DockerConfigJSONPath)
+		if err != nil {
+			return "", false
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%s:%d:%d", source, info.ModTime().UnixNano(), info.Size()), true
+	case config.CredentialSourceSecret:
+		secret := &corev1.Secret{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SourceSecret, Namespace: c.SecretNamespace}, secret); err != nil {
+			return "", false
+		}
+		return source + ":" + secret.GetResourceVersion(), true
+	case config.CredentialSourceOIDC:
+		refreshAt := OIDCRefreshAt()
+		if !time.Now().Before(refreshAt) {
+			return "", false
+		}
+		return source + ":" + refreshAt.String(), true
+	case config.CredentialSourceURL:
+		if urlCredentials.dockerConfigJSON.IsEmpty() {
+			return "", false
+		}
+		return fmt.Sprintf("%s:%d", source, urlCredentials.dockerConfigJSON.Generation()), true
+	}
+	return "", false
+}
+
+// GetDockerConfigJSONFromChain returns the dockerConfigJSON of the first healthy source
+// in CredentialSources, so an outage of one source doesn't break provisioning
+func GetDockerConfigJSONFromChain(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	var errs []error
+	for _, source := range strings.Split(c.CredentialSources, ",") {
+		source = strings.TrimSpace(source)
+		dockerConfigJSON, err := getDockerConfigJSONFromSource(ctx, k8sClient, c, source)
+		if err == nil {
+			metrics.SetActiveCredentialSource(source)
+			return dockerConfigJSON, nil
+		}
+		metrics.CredentialSourceFailuresTotal.WithLabelValues(source).Inc()
+		errs = append(errs, fmt.Errorf("credential source %s: %w", source, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// getDockerConfigJSONFromSource reads the dockerConfigJSON of a single source and checks its health
+func getDockerConfigJSONFromSource(ctx context.Context, k8sClient client.Client, c *config.Config, source string) (string, error) {
+	dockerConfigJSON, err := readCredentialSource(ctx, k8sClient, c, source)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(dockerConfigJSON) == "" {
+		return "", fmt.Errorf("dockerConfigJSON is empty")
+	}
+	// Templates only become valid JSON once rendered
+	if !c.FeatureTemplateDockerConfigJSON && !json.Valid([]byte(dockerConfigJSON)) {
+		return "", fmt.Errorf("dockerConfigJSON is not valid JSON")
+	}
+	// Unsigned credentials are unhealthy, so the chain falls back on a signed source
+	if err := VerifyCredentialSource(ctx, k8sClient, c, source, dockerConfigJSON); err != nil {
+		return "", err
+	}
+	return dockerConfigJSON, nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ConvertToDockerConfigJSON(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	tests := []struct {
+		name    string
+		secret  *corev1.Secret
+		want    string
+		wantErr bool
+	}{
+		{
+			"dockerconfigjson Secret. Should be returned as is.",
+			&corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`),
+				},
+			},
+			`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+			False,
+		},
+		{
+			"dockercfg Secret. Should be wrapped in auths.",
+			&corev1.Secret{
+				Type: corev1.SecretTypeDockercfg,
+				Data: map[string][]byte{
+					corev1.DockerConfigKey: []byte(`{"example.com":{"auth":"Zm9vOmJhcg=="}}`),
+				},
+			},
+			`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+			False,
+		},
+		{
+			"basic-auth Secret with registry annotation. Should be converted.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						c.AnnotationRegistry: "example.com",
+					},
+				},
+				Type: corev1.SecretTypeBasicAuth,
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("foo"),
+					corev1.BasicAuthPasswordKey: []byte("bar"),
+				},
+			},
+			`{"auths":{"example.com":{"username":"foo","password":"bar","auth":"Zm9vOmJhcg=="}}}`,
+			False,
+		},
+		{
+			"basic-auth Secret without registry annotation. Should error.",
+			&corev1.Secret{
+				Type: corev1.SecretTypeBasicAuth,
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("foo"),
+					corev1.BasicAuthPasswordKey: []byte("bar"),
+				},
+			},
+			"",
+			True,
+		},
+		{
+			"Opaque Secret. Should error.",
+			&corev1.Secret{
+				Type: corev1.SecretTypeOpaque,
+			},
+			"",
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertToDockerConfigJSON(c, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConvertToDockerConfigJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertToDockerConfigJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_RenderDockerConfigJSON(t *testing.T) {
+	tests := []struct {
+		name             string
+		dockerConfigJSON string
+		want             string
+		wantErr          bool
+	}{
+		{
+			"Plain dockerConfigJSON. Should be returned as is.",
+			`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+			`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+			False,
+		},
+		{
+			"Templated dockerConfigJSON. Should be rendered for the namespace.",
+			`{"auths":{"example.com/{{ .Namespace }}":{"auth":"{{ printf "%s:%s" .Namespace "bar" | b64enc }}"}}}`,
+			`{"auths":{"example.com/foo":{"auth":"Zm9vOmJhcg=="}}}`,
+			False,
+		},
+		{
+			"Template with unknown field. Should error.",
+			`{"auths":{"example.com/{{ .Project }}":{}}}`,
+			"",
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderDockerConfigJSON(tt.dockerConfigJSON, "foo")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RenderDockerConfigJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderDockerConfigJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_GetDockerConfigJSONFromChain(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "kube-system"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"secret.example.com":{}}}`)},
+	}
+	tests := []struct {
+		name    string
+		options config.ConfigOptions
+		objects []client.Object
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "First source healthy. Should use it.",
+			options: config.ConfigOptions{
+				CredentialSources: "secret,env",
+				SourceSecret:      "source",
+				DockerConfigJSON:  `{"auths":{"env.example.com":{}}}`,
+			},
+			objects: []client.Object{sourceSecret},
+			want:    `{"auths":{"secret.example.com":{}}}`,
+		},
+		{
+			name: "First source missing. Should fall back on the next one.",
+			options: config.ConfigOptions{
+				CredentialSources: "secret,env",
+				SourceSecret:      "source",
+				DockerConfigJSON:  `{"auths":{"env.example.com":{}}}`,
+			},
+			want: `{"auths":{"env.example.com":{}}}`,
+		},
+		{
+			name: "All sources unhealthy. Should fail.",
+			options: config.ConfigOptions{
+				CredentialSources:    "file,env",
+				DockerConfigJSONPath: "/does/not/exist",
+				DockerConfigJSON:     `not json`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.SecretNamespace = "kube-system"
+			k8sClient := fake.NewClientBuilder().WithObjects(tt.objects...).Build()
+			got, err := GetDockerConfigJSONFromChain(context.TODO(), k8sClient, config.NewConfig(tt.options))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDockerConfigJSONFromChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetDockerConfigJSONFromChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_CachedCredentialHash(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), ".dockerconfigjson")
+	if err := os.WriteFile(path, []byte(`{"auths":{"a.example.com":{}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.Config{DockerConfigJSONPath: path}
+	k8sClient := fake.NewClientBuilder().Build()
+
+	hash, err := CachedCredentialHash(ctx, k8sClient, c)
+	if err != nil {
+		t.Fatalf("CachedCredentialHash() error = %v", err)
+	}
+
+	// A rewrite, which keeps the size and the modification time, is not noticed
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"auths":{"b.example.com":{}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := CachedCredentialHash(ctx, k8sClient, c); got != hash {
+		t.Errorf("CachedCredentialHash() = %v, want the cached %v for an unchanged revision", got, hash)
+	}
+
+	if err := os.Chtimes(path, info.ModTime().Add(time.Second), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	want, err := CredentialHash(ctx, k8sClient, c)
+	if err != nil {
+		t.Fatalf("CredentialHash() error = %v", err)
+	}
+	if got, _ := CachedCredentialHash(ctx, k8sClient, c); got != want || got == hash {
+		t.Errorf("CachedCredentialHash() = %v, want %v after the file changed", got, want)
+	}
+}
+
+func Test_GetDockerConfigJSONFromFile(t *testing.T) {
+	fileReadRetryInterval = 50 * time.Millisecond
+	tests := []struct {
+		name     string
+		content  string
+		rotated  string
+		template bool
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:    "Valid file. Should be returned.",
+			content: testDockerConfigJSON,
+			want:    testDockerConfigJSON,
+		},
+		{
+			name:    "Empty file. Should fail.",
+			content: "",
+			wantErr: true,
+		},
+		{
+			name:    "Partially written file. Should fail.",
+			content: `{"auths":{"exa`,
+			wantErr: true,
+		},
+		{
+			name:    "Partially written file, completed while retrying. Should return the completed file.",
+			content: `{"auths":{"exa`,
+			rotated: testDockerConfigJSON,
+			want:    testDockerConfigJSON,
+		},
+		{
+			name:     "Template. Should be returned without validation.",
+			content:  `{"auths":{"{{ .Namespace }}.example.com":{}}`,
+			template: true,
+			want:     `{"auths":{"{{ .Namespace }}.example.com":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".dockerconfigjson")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.rotated != "" {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					_ = os.WriteFile(path, []byte(tt.rotated), 0o600)
+				}()
+			}
+			c := &config.Config{DockerConfigJSONPath: path, FeatureTemplateDockerConfigJSON: tt.template}
+			got, err := GetDockerConfigJSONFromFile(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDockerConfigJSONFromFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetDockerConfigJSONFromFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
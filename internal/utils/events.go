@@ -0,0 +1,55 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"maps"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// NewProfileRecorder returns a recorder, which annotates every Event with the profile of c.
+// Consumers of Events can tell several deployments of the controller apart by it.
+func NewProfileRecorder(recorder record.EventRecorder, c *config.Config) record.EventRecorder {
+	return &profileRecorder{recorder: recorder, labelProfile: c.LabelProfile, profile: ProfileName(c)}
+}
+
+type profileRecorder struct {
+	recorder     record.EventRecorder
+	labelProfile string
+	profile      string
+}
+
+func (r *profileRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+func (r *profileRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+func (r *profileRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	annotations = maps.Clone(annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[r.labelProfile] = r.profile
+	r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+}
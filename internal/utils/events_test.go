@@ -0,0 +1,41 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_NewProfileRecorder(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(1)
+	fakeRecorder.IncludeObject = true
+	c := &config.Config{Profile: "staging", LabelProfile: "example.com/imagepullsecret-patcher-profile"}
+	recorder := NewProfileRecorder(fakeRecorder, c)
+
+	recorder.Eventf(&corev1.Secret{}, corev1.EventTypeNormal, "Created", "Created %s", "secret")
+
+	got := <-fakeRecorder.Events
+	want := "Normal Created Created secret involvedObject{kind=,apiVersion=} map[" + c.LabelProfile + ":staging]"
+	if got != want {
+		t.Errorf("NewProfileRecorder() event = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// Reasons, why a namespace is intentionally excluded by the configuration
+const (
+	// ExclusionReasonGlob means the name matches CONFIG_EXCLUDED_NAMESPACES
+	ExclusionReasonGlob = "glob"
+	// ExclusionReasonSelector means the value of CONFIG_NAMESPACE_LABEL is excluded, or not included
+	ExclusionReasonSelector = "selector"
+	// ExclusionReasonAnnotation means the namespace carries the exclude annotation
+	ExclusionReasonAnnotation = "annotation"
+	// ExclusionReasonOperator means the namespace of the controller is excluded by CONFIG_OPERATOR_NAMESPACE_POLICY
+	ExclusionReasonOperator = "operator"
+	// ExclusionReasonPaused means the namespace was paused through the admin API
+	ExclusionReasonPaused = "paused"
+)
+
+// ExclusionReasons lists all reasons, a namespace can be excluded for
+var ExclusionReasons = []string{ExclusionReasonGlob, ExclusionReasonSelector, ExclusionReasonAnnotation, ExclusionReasonOperator, ExclusionReasonPaused}
+
+// ExcludedNamespaces lists the names of all intentionally excluded namespaces by reason
+func ExcludedNamespaces(ctx context.Context, k8sClient client.Client, c *config.Config) (map[string][]string, error) {
+	namespaceList := &corev1.NamespaceList{}
+	if err := k8sClient.List(ctx, namespaceList); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	excluded := map[string][]string{}
+	for _, reason := range ExclusionReasons {
+		excluded[reason] = []string{}
+	}
+	for i := range namespaceList.Items {
+		if reason := NamespaceExclusionReason(c, &namespaceList.Items[i]); reason != "" {
+			excluded[reason] = append(excluded[reason], namespaceList.Items[i].GetName())
+		}
+	}
+	return excluded, nil
+}
+
+// NamespaceExclusionHandler keeps the imagepullsecret_patcher_namespaces_excluded_total metric up to date
+// with the namespaces in the cache. The config is read on every event, so updates of it are picked up.
+func NamespaceExclusionHandler(c *config.Store) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				metrics.SetNamespaceExclusion(ns.GetName(), NamespaceExclusionReason(c.Load(), ns))
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				metrics.SetNamespaceExclusion(ns.GetName(), NamespaceExclusionReason(c.Load(), ns))
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				metrics.SetNamespaceExclusion(ns.GetName(), "")
+			}
+		},
+	}
+}
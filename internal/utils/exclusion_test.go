@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+func Test_NamespaceExclusionReason(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:             "xx",
+		SecretNamespace:              "kube-system",
+		NamespaceLabel:               "tenant",
+		ExcludedNamespaceLabelValues: "acme*",
+	})
+	tests := []struct {
+		name      string
+		namespace *corev1.Namespace
+		want      string
+	}{
+		{
+			name:      "Name matches excluded glob. Should be excluded by glob.",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-public"}},
+			want:      ExclusionReasonGlob,
+		},
+		{
+			name: "Label value matches excluded glob. Should be excluded by selector.",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "default",
+				Labels: map[string]string{"tenant": "acme-prod"},
+			}},
+			want: ExclusionReasonSelector,
+		},
+		{
+			name: "Exclude annotation set. Should be excluded by annotation.",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "default",
+				Annotations: map[string]string{c.ExcludeAnnotation: "true"},
+			}},
+			want: ExclusionReasonAnnotation,
+		},
+		{
+			name: "Paused through the admin API. Should be excluded as paused.",
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "default",
+				Annotations: map[string]string{c.AnnotationAdminPaused: "true"},
+			}},
+			want: ExclusionReasonPaused,
+		},
+		{
+			name: "Namespace terminating. Should not be excluded intentionally.",
+			namespace: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NamespaceExclusionReason(c, tt.namespace); got != tt.want {
+				t.Errorf("NamespaceExclusionReason() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NamespaceExclusionReason_OperatorNamespace(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		annotations map[string]string
+		want        string
+	}{
+		{
+			name:   "Policy auto. Should be excluded by glob.",
+			policy: config.OperatorNamespacePolicyAuto,
+			want:   ExclusionReasonGlob,
+		},
+		{
+			name:   "Policy include. Should not be excluded.",
+			policy: config.OperatorNamespacePolicyInclude,
+			want:   "",
+		},
+		{
+			name:        "Policy include, exclude annotation set. Should be excluded by annotation.",
+			policy:      config.OperatorNamespacePolicyInclude,
+			annotations: map[string]string{"pborn.eu/imagepullsecret-patcher-exclude": "true"},
+			want:        ExclusionReasonAnnotation,
+		},
+		{
+			name:   "Policy exclude. Should be excluded as namespace of the controller.",
+			policy: config.OperatorNamespacePolicyExclude,
+			want:   ExclusionReasonOperator,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.NewConfig(config.ConfigOptions{
+				DockerConfigJSON:        "xx",
+				OperatorNamespace:       "kube-system",
+				SecretNamespace:         "kube-credentials",
+				OperatorNamespacePolicy: tt.policy,
+			})
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Annotations: tt.annotations}}
+			if got := NamespaceExclusionReason(c, namespace); got != tt.want {
+				t.Errorf("NamespaceExclusionReason() = %v, want %v", got, tt.want)
+			}
+			// The policy applies to the namespace of the controller, not the secret namespace
+			for _, name := range []string{"kube-public", c.SecretNamespace} {
+				other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				if got := NamespaceExclusionReason(c, other); got != ExclusionReasonGlob {
+					t.Errorf("NamespaceExclusionReason() of %s = %v, want %v", name, got, ExclusionReasonGlob)
+				}
+			}
+		})
+	}
+}
+
+func Test_NamespaceExclusionHandler(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	handler := NamespaceExclusionHandler(config.NewStore(c))
+	glob := metrics.NamespacesExcluded.WithLabelValues(ExclusionReasonGlob)
+	annotation := metrics.NamespacesExcluded.WithLabelValues(ExclusionReasonAnnotation)
+	globBefore, annotationBefore := testutil.ToFloat64(glob), testutil.ToFloat64(annotation)
+
+	kubeSystem := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-exclusion-test"}}
+	tenant := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "exclusion-test"}}
+	handler.OnAdd(kubeSystem, true)
+	handler.OnAdd(tenant, true)
+	if got := testutil.ToFloat64(glob) - globBefore; got != 1 {
+		t.Errorf("glob = %v, want 1", got)
+	}
+
+	excludedTenant := tenant.DeepCopy()
+	excludedTenant.Annotations = map[string]string{c.ExcludeAnnotation: "true"}
+	handler.OnUpdate(tenant, excludedTenant)
+	handler.OnUpdate(excludedTenant, excludedTenant)
+	if got := testutil.ToFloat64(annotation) - annotationBefore; got != 1 {
+		t.Errorf("annotation = %v, want 1", got)
+	}
+
+	handler.OnDelete(kubeSystem)
+	handler.OnUpdate(excludedTenant, tenant)
+	if got := testutil.ToFloat64(glob) - globBefore; got != 0 {
+		t.Errorf("glob = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(annotation) - annotationBefore; got != 0 {
+		t.Errorf("annotation = %v, want 0", got)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// ExplanationRule is a rule of the configuration, as evaluated against an object
+type ExplanationRule struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// ServiceAccountExplanation describes, why the controller manages a ServiceAccount or not, and what it would do
+type ServiceAccountExplanation struct {
+	Namespace      string            `json:"namespace"`
+	ServiceAccount string            `json:"serviceAccount"`
+	Managed        bool              `json:"managed"`
+	Rules          []ExplanationRule `json:"rules"`
+	Actions        []string          `json:"actions"`
+}
+
+// ExplainServiceAccount evaluates c against serviceAccount in ns. Whether it's managed is decided by
+// IsServiceAccountManaged, the rules only explain that decision.
+func ExplainServiceAccount(ctx context.Context, k8sClient client.Client, c *config.Config, ns *corev1.Namespace, serviceAccount *corev1.ServiceAccount) (ServiceAccountExplanation, error) {
+	namespaceReason := NamespaceExclusionReason(c, ns)
+	if namespaceReason == "" && ns.Status.Phase == corev1.NamespaceTerminating {
+		namespaceReason = "terminating"
+	}
+	var excludedBy []string
+	for _, key := range ExcludeAnnotations(c) {
+		if HasAnnotation(serviceAccount, key, "true") {
+			excludedBy = append(excludedBy, key)
+		}
+	}
+
+	explanation := ServiceAccountExplanation{
+		Namespace:      ns.GetName(),
+		ServiceAccount: serviceAccount.GetName(),
+		Managed:        IsServiceAccountManaged(c, ns, serviceAccount),
+		Rules: []ExplanationRule{
+			{Rule: "namespace excluded", Matched: IsNamespaceExcluded(c, ns), Detail: namespaceReason},
+			{Rule: "serviceaccount excluded by annotation", Matched: IsServiceAccountExcluded(c, serviceAccount), Detail: strings.Join(excludedBy, ", ")},
+			{Rule: "serviceaccount listed in CONFIG_SERVICEACCOUNTS", Matched: IsStringInList(serviceAccount.GetName(), c.ServiceAccounts), Detail: c.ServiceAccounts},
+		},
+	}
+
+	paused, err := IsPaused(ctx, k8sClient, c)
+	if err != nil {
+		return explanation, err
+	}
+	explanation.Actions = serviceAccountActions(c, ns, serviceAccount, explanation.Managed, paused)
+	return explanation, nil
+}
+
+// serviceAccountActions lists, what reconciling serviceAccount would do
+func serviceAccountActions(c *config.Config, ns *corev1.Namespace, serviceAccount *corev1.ServiceAccount, managed bool, paused bool) []string {
+	if paused {
+		return []string{"none, as the controller is paused"}
+	}
+	if !managed {
+		if c.FeatureDetachServiceAccounts && IsServiceAccountDetachable(c, ns, serviceAccount) {
+			return []string{"detach imagePullSecret " + c.SecretName}
+		}
+		return []string{"none"}
+	}
+	if remaining := GetNamespaceMinAgeRemaining(c, ns); remaining > 0 {
+		return []string{"wait " + remaining.Round(time.Second).String() + ", until the namespace reached CONFIG_NAMESPACE_MIN_AGE"}
+	}
+
+	var actions []string
+	if !c.FeatureServiceAccountOnly {
+		actions = append(actions, "create or update imagePullSecret "+c.SecretName)
+	}
+	switch {
+	case c.FeatureSecretOnly:
+		actions = append(actions, "leave the ServiceAccount untouched, as CONFIG_MANAGE_SERVICEACCOUNTS is false")
+	case HasImagePullSecret(serviceAccount, c.SecretName):
+		actions = append(actions, "keep the ServiceAccount, as it references imagePullSecret "+c.SecretName+" already")
+	default:
+		actions = append(actions, "attach imagePullSecret "+c.SecretName)
+	}
+	if c.FeatureDeletePods && !IsPodDeletionDisabled(c, ns) {
+		actions = append(actions, "delete Pods of the ServiceAccount, which fail to pull their image")
+	}
+	return actions
+}
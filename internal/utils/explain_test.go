@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ExplainServiceAccount(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureDeletePods: true})
+	excludeAnnotation := ExcludeAnnotations(c)[0]
+
+	tests := []struct {
+		name           string
+		namespace      string
+		serviceAccount *corev1.ServiceAccount
+		wantManaged    bool
+		wantMatched    []bool
+		wantActions    []string
+	}{
+		{
+			name:           "managed",
+			namespace:      "default",
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			wantManaged:    true,
+			wantMatched:    []bool{false, false, true},
+			wantActions: []string{
+				"create or update imagePullSecret " + c.SecretName,
+				"attach imagePullSecret " + c.SecretName,
+				"delete Pods of the ServiceAccount, which fail to pull their image",
+			},
+		},
+		{
+			name:           "excluded namespace",
+			namespace:      "kube-system",
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			wantMatched:    []bool{true, false, true},
+			wantActions:    []string{"none"},
+		},
+		{
+			name:      "excluded serviceaccount",
+			namespace: "default",
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+				Name:        "default",
+				Annotations: map[string]string{excludeAnnotation: "true"},
+			}},
+			wantMatched: []bool{false, true, true},
+			wantActions: []string{"none"},
+		},
+		{
+			name:           "unlisted serviceaccount",
+			namespace:      "default",
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "builder"}},
+			wantMatched:    []bool{false, false, false},
+			wantActions:    []string{"none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace}}
+			tt.serviceAccount.Namespace = tt.namespace
+
+			got, err := ExplainServiceAccount(context.TODO(), fake.NewClientBuilder().Build(), c, ns, tt.serviceAccount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Managed != tt.wantManaged {
+				t.Errorf("ExplainServiceAccount() managed = %v, want %v", got.Managed, tt.wantManaged)
+			}
+			var matched []bool
+			for _, rule := range got.Rules {
+				matched = append(matched, rule.Matched)
+			}
+			if !reflect.DeepEqual(matched, tt.wantMatched) {
+				t.Errorf("ExplainServiceAccount() matched rules = %v, want %v", matched, tt.wantMatched)
+			}
+			if !reflect.DeepEqual(got.Actions, tt.wantActions) {
+				t.Errorf("ExplainServiceAccount() actions = %v, want %v", got.Actions, tt.wantActions)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+)
+
+// ExtraSecretNames returns the names of the Secrets in the secret namespace, which are copied
+// to every namespace alongside the imagePullSecret
+func ExtraSecretNames(c *config.Config) []string {
+	names := []string{}
+	for _, name := range strings.Split(c.ExtraSecrets, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// IsExtraSecret checks whether name is one of the ExtraSecrets
+func IsExtraSecret(c *config.Config, name string) bool {
+	for _, extraSecret := range ExtraSecretNames(c) {
+		if name == extraSecret {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExtraSourceSecret checks whether the Secret is the source of one of the ExtraSecrets
+func IsExtraSourceSecret(c *config.Config, name string, namespace string) bool {
+	return namespace == c.SecretNamespace && IsExtraSecret(c, name)
+}
+
+// ReconcileExtraSecrets copies all ExtraSecrets to namespace
+func ReconcileExtraSecrets(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string) error {
+	for _, name := range ExtraSecretNames(c) {
+		if _, err := ReconcileExtraSecret(ctx, k8sClient, c, name, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileExtraSecret creates or patches the copy of the extra Secret name in namespace to match its source in
+// the secret namespace. Secrets of the same name, which aren't managed by the controller, are never overwritten.
+func ReconcileExtraSecret(ctx context.Context, k8sClient client.Client, c *config.Config, name string, namespace string) (ReconcileResult, error) {
+	if namespace == c.SecretNamespace {
+		return ResultNoOp, nil
+	}
+
+	source := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: c.SecretNamespace}, source); err != nil {
+		return ResultFailed, fmt.Errorf("failed to fetch extra Secret '%s' in namespace '%s': %w", name, c.SecretNamespace, err)
+	}
+	desiredSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+			},
+			Labels: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.LabelProfile:             ProfileName(c),
+			},
+		},
+		Data: source.Data,
+		Type: source.Type,
+	}
+
+	secret := &corev1.Secret{}
+	err := k8sClient.Get(ctx, client.ObjectKeyFromObject(desiredSecret), secret)
+	if apierrs.IsNotFound(err) {
+		if err := k8sClient.Create(ctx, desiredSecret); err != nil {
+			return ResultFailed, fmt.Errorf("failed to create extra Secret '%s' in namespace '%s': %w", name, namespace, err)
+		}
+		RecordOwnWrite(desiredSecret)
+		eventlog.RecordHash(eventlog.ActionSecretCreated, namespace, name, "extra", secretDataHash(desiredSecret))
+		return ResultCreated, nil
+	}
+	if err != nil {
+		return ResultFailed, fmt.Errorf("failed to fetch extra Secret '%s' in namespace '%s': %w", name, namespace, err)
+	}
+
+	if !IsProfileSecret(c, secret) || HasExcludeAnnotation(c, secret) || IsSourceOfTruth(c, secret) {
+		return ResultSkippedExcluded, nil
+	}
+	if secret.Type != desiredSecret.Type {
+		return ResultFailed, fmt.Errorf("extra Secret '%s' in namespace '%s' is of type %s instead of %s", name, namespace, secret.Type, desiredSecret.Type)
+	}
+	if reflect.DeepEqual(secret.Data, desiredSecret.Data) {
+		return ResultNoOp, nil
+	}
+
+	patchFrom := client.MergeFrom(secret.DeepCopy())
+	secret.Data = desiredSecret.Data
+	if err := k8sClient.Patch(ctx, secret, patchFrom); err != nil {
+		return ResultFailed, fmt.Errorf("failed to patch extra Secret '%s' in namespace '%s': %w", name, namespace, err)
+	}
+	RecordOwnWrite(secret)
+	eventlog.RecordHash(eventlog.ActionSecretUpdated, namespace, name, "extra", secretDataHash(secret))
+	return ResultPatched, nil
+}
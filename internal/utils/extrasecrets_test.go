@@ -0,0 +1,96 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ReconcileExtraSecret(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", ExtraSecrets: "ca-bundle,mirror-config"})
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "kube-system"},
+		Data:       map[string][]byte{"ca.crt": []byte("v1")},
+		Type:       corev1.SecretTypeOpaque,
+	}
+	foreign := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca-bundle", Namespace: "team-b"},
+		Data:       map[string][]byte{"ca.crt": []byte("own")},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(source, foreign).Build()
+	ctx := context.TODO()
+
+	steps := []struct {
+		name      string
+		namespace string
+		data      string
+		want      ReconcileResult
+		wantData  string
+	}{
+		{"copy to a new namespace", "team-a", "v1", ResultCreated, "v1"},
+		{"copy is current", "team-a", "v1", ResultNoOp, "v1"},
+		{"source changed", "team-a", "v2", ResultPatched, "v2"},
+		{"foreign Secret of the same name", "team-b", "v2", ResultSkippedExcluded, "own"},
+		{"secret namespace", "kube-system", "v2", ResultNoOp, "v2"},
+	}
+	for _, step := range steps {
+		source.Data = map[string][]byte{"ca.crt": []byte(step.data)}
+		if err := k8sClient.Update(ctx, source); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReconcileExtraSecret(ctx, k8sClient, c, "ca-bundle", step.namespace)
+		if err != nil {
+			t.Fatalf("%s: ReconcileExtraSecret() error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: ReconcileExtraSecret() = %v, want %v", step.name, got, step.want)
+		}
+		secret := &corev1.Secret{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: "ca-bundle", Namespace: step.namespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		if string(secret.Data["ca.crt"]) != step.wantData {
+			t.Errorf("%s: data = %q, want %q", step.name, secret.Data["ca.crt"], step.wantData)
+		}
+	}
+
+	// A missing source fails the reconciliation
+	if _, err := ReconcileExtraSecret(ctx, k8sClient, c, "mirror-config", "team-a"); err == nil {
+		t.Errorf("ReconcileExtraSecret() error = nil, want an error for a missing source")
+	}
+
+	// Copies aren't orphaned, as long as they're configured
+	copied := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: "ca-bundle", Namespace: "team-a"}, copied); err != nil {
+		t.Fatal(err)
+	}
+	if IsOrphanedSecret(c, copied) {
+		t.Errorf("IsOrphanedSecret() = true, want false for a copy of an extra Secret")
+	}
+	if !IsOrphanedSecret(config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"}), copied) {
+		t.Errorf("IsOrphanedSecret() = false, want true, once the extra Secret is no longer configured")
+	}
+}
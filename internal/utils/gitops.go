@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrGitOpsConflict indicates that the imagePullSecret is claimed by a GitOps tool as well
+var ErrGitOpsConflict = errors.New("imagePullSecret is claimed by a GitOps tool")
+
+// GitOps tools, which may claim an imagePullSecret
+const (
+	GitOpsToolArgoCD = "argocd"
+	GitOpsToolFlux   = "flux"
+)
+
+// gitOpsMarker is a label or annotation, which is set by a GitOps tool on the objects it manages
+type gitOpsMarker struct {
+	key  string
+	tool string
+}
+
+// gitOpsLabels and gitOpsAnnotations are set by the GitOps tools on the objects they manage.
+// They're checked in order, so the same tool is reported for an object on every reconcile.
+var (
+	gitOpsLabels = []gitOpsMarker{
+		{"argocd.argoproj.io/instance", GitOpsToolArgoCD},
+		{"kustomize.toolkit.fluxcd.io/name", GitOpsToolFlux},
+		{"helm.toolkit.fluxcd.io/name", GitOpsToolFlux},
+	}
+	gitOpsAnnotations = []gitOpsMarker{
+		{"argocd.argoproj.io/tracking-id", GitOpsToolArgoCD},
+	}
+)
+
+// GitOpsTool returns the GitOps tool, which claims obj, or an empty string
+func GitOpsTool(obj client.Object) string {
+	for _, marker := range gitOpsAnnotations {
+		if _, ok := obj.GetAnnotations()[marker.key]; ok {
+			return marker.tool
+		}
+	}
+	for _, marker := range gitOpsLabels {
+		if _, ok := obj.GetLabels()[marker.key]; ok {
+			return marker.tool
+		}
+	}
+	return ""
+}
+
+// IsOwnershipConflict checks whether err reports an imagePullSecret, which is claimed by a GitOps tool
+// or owned by another Secret provider, and which isn't reconciled according to the alert policy
+func IsOwnershipConflict(err error) bool {
+	return errors.Is(err, ErrGitOpsConflict) || errors.Is(err, ErrSecretOwnerConflict)
+}
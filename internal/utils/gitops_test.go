@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ReconcileImagePullSecret_GitOps(t *testing.T) {
+	dockerConfigJSON := `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`
+	newConfig := func(policy string) *config.Config {
+		return config.NewConfig(config.ConfigOptions{DockerConfigJSON: dockerConfigJSON, SecretNamespace: "kube-system", GitOpsConflictPolicy: policy})
+	}
+	upToDate, err := ConstructImagePullSecret(context.TODO(), fake.NewClientBuilder().Build(), newConfig(""), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argoCD := upToDate.DeepCopy()
+	argoCD.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	argoCD.Annotations["argocd.argoproj.io/tracking-id"] = "registry:/Secret:default/" + argoCD.GetName()
+	flux := argoCD.DeepCopy()
+	delete(flux.Annotations, "argocd.argoproj.io/tracking-id")
+	flux.Labels["kustomize.toolkit.fluxcd.io/name"] = "registry"
+
+	tests := []struct {
+		name     string
+		policy   string
+		secret   *corev1.Secret
+		wantTool string
+		want     ReconcileResult
+		wantErr  error
+	}{
+		{
+			name:     "Secret not claimed. Should be left untouched.",
+			policy:   config.GitOpsConflictPolicySkip,
+			secret:   upToDate,
+			wantTool: "",
+			want:     ResultNoOp,
+		},
+		{
+			name:     "Secret claimed by ArgoCD, adopt policy. Should be patched.",
+			policy:   config.GitOpsConflictPolicyAdopt,
+			secret:   argoCD,
+			wantTool: GitOpsToolArgoCD,
+			want:     ResultPatched,
+		},
+		{
+			name:     "Secret claimed by Flux, skip policy. Should be skipped.",
+			policy:   config.GitOpsConflictPolicySkip,
+			secret:   flux,
+			wantTool: GitOpsToolFlux,
+			want:     ResultSkippedExcluded,
+		},
+		{
+			name:     "Secret claimed by ArgoCD, alert policy. Should fail with ErrGitOpsConflict.",
+			policy:   config.GitOpsConflictPolicyAlert,
+			secret:   argoCD,
+			wantTool: GitOpsToolArgoCD,
+			want:     ResultFailed,
+			wantErr:  ErrGitOpsConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GitOpsTool(tt.secret); got != tt.wantTool {
+				t.Errorf("GitOpsTool() = %v, want %v", got, tt.wantTool)
+			}
+
+			c := newConfig(tt.policy)
+			k8sClient := fake.NewClientBuilder().WithObjects(tt.secret.DeepCopy()).Build()
+			got, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, "default")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReconcileImagePullSecret() error = %v, want %v", err, tt.wantErr)
+			}
+			if IsOwnershipConflict(err) != (tt.wantErr != nil) {
+				t.Errorf("IsOwnershipConflict(%v) = %v, want %v", err, IsOwnershipConflict(err), tt.wantErr != nil)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileImagePullSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+)
+
+// lastKnownGoodPrefix prefixes the name of the Secret holding the last known good credentials
+const lastKnownGoodPrefix = "imagepullsecret-patcher-last-known-good-"
+
+// lastKnownGood persists the last credentials read successfully in a Secret in the secret namespace,
+// so they survive restarts of the controller
+type lastKnownGood struct {
+	mu          sync.Mutex
+	credentials credentialBuffer
+}
+
+var defaultLastKnownGood = &lastKnownGood{}
+
+// LastKnownGoodName returns the name of the Secret holding the last known good credentials, which is unique per profile
+func LastKnownGoodName(c *config.Config) string {
+	return lastKnownGoodPrefix + ProfileName(c)
+}
+
+// withLastKnownGood falls back on the last known good credentials, if reading dockerConfigJSON failed with err.
+// The credentials are only persisted by PersistLastKnownGood, so read-only callers never write them.
+func withLastKnownGood(ctx context.Context, k8sClient client.Client, c *config.Config, dockerConfigJSON string, err error) (string, error) {
+	if !c.FeatureLastKnownGood || err == nil {
+		return dockerConfigJSON, err
+	}
+
+	lastGood, loadErr := defaultLastKnownGood.Load(ctx, k8sClient, c)
+	if loadErr != nil {
+		log.FromContext(ctx).Error(loadErr, "unable to load last known good credentials")
+		return "", err
+	}
+	if lastGood == "" {
+		return "", err
+	}
+	log.FromContext(ctx).Info("Credential source is broken, serving the last known good credentials", "error", redact.Error(err).Error())
+	return lastGood, nil
+}
+
+// PersistLastKnownGood reads the credentials from their source and persists them, if FeatureLastKnownGood
+// is set. While the source is broken, nothing is persisted. It must only be called by the leader.
+func PersistLastKnownGood(ctx context.Context, k8sClient client.Client, c *config.Config) error {
+	if !c.FeatureLastKnownGood {
+		return nil
+	}
+	dockerConfigJSON, err := getDockerConfigJSON(ctx, k8sClient, c)
+	if err == nil {
+		dockerConfigJSON, err = verifyRotatedCredentials(ctx, c, dockerConfigJSON)
+	}
+	if err != nil {
+		return nil
+	}
+	return defaultLastKnownGood.Store(ctx, k8sClient, c, dockerConfigJSON)
+}
+
+// Store persists dockerConfigJSON, unless it is persisted already
+func (l *lastKnownGood) Store(ctx context.Context, k8sClient client.Client, c *config.Config, dockerConfigJSON string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if dockerConfigJSON == l.credentials.Value() {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: LastKnownGoodName(c), Namespace: c.SecretNamespace}, secret)
+	if apierrs.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      LastKnownGoodName(c),
+				Namespace: c.SecretNamespace,
+				// Not labeled as managed, as it's no imagePullSecret
+				Labels: map[string]string{
+					c.LabelProfile: ProfileName(c),
+				},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+		}
+	} else if err != nil {
+		return fmt.Errorf("error fetching last known good credentials: %w", err)
+	}
+
+	if string(secret.Data[corev1.DockerConfigJsonKey]) != dockerConfigJSON {
+		secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: []byte(dockerConfigJSON)}
+		if secret.GetResourceVersion() == "" {
+			err = k8sClient.Create(ctx, secret)
+		} else {
+			err = k8sClient.Update(ctx, secret)
+		}
+		if err != nil {
+			return fmt.Errorf("error saving last known good credentials: %w", err)
+		}
+	}
+	l.credentials.Set([]byte(dockerConfigJSON))
+	return nil
+}
+
+// Load returns the last known good credentials, or an empty string, if none were persisted yet
+func (l *lastKnownGood) Load(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.credentials.IsEmpty() {
+		return l.credentials.Value(), nil
+	}
+
+	secret := &corev1.Secret{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: LastKnownGoodName(c), Namespace: c.SecretNamespace}, secret)
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching last known good credentials: %w", err)
+	}
+	l.credentials.Set(secret.Data[corev1.DockerConfigJsonKey])
+	return l.credentials.Value(), nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_GetDockerConfigJSON_LastKnownGood(t *testing.T) {
+	persisted := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "imagepullsecret-patcher-last-known-good-default", Namespace: "kube-system"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"persisted.example.com":{}}}`)},
+	}
+	tests := []struct {
+		name    string
+		c       *config.Config
+		objects []client.Object
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Source healthy. Should return it, without persisting it.",
+			c:       &config.Config{DockerConfigJSON: testDockerConfigJSON},
+			objects: []client.Object{persisted.DeepCopy()},
+			want:    testDockerConfigJSON,
+		},
+		{
+			name:    "Source broken. Should return the persisted credentials.",
+			c:       &config.Config{DockerConfigJSONPath: "/does/not/exist"},
+			objects: []client.Object{persisted.DeepCopy()},
+			want:    `{"auths":{"persisted.example.com":{}}}`,
+		},
+		{
+			name:    "Source broken, nothing persisted. Should fail.",
+			c:       &config.Config{DockerConfigJSONPath: "/does/not/exist"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultLastKnownGood = &lastKnownGood{}
+			tt.c.SecretNamespace = "kube-system"
+			tt.c.FeatureLastKnownGood = true
+			k8sClient := fake.NewClientBuilder().WithObjects(tt.objects...).Build()
+
+			got, err := GetDockerConfigJSON(context.TODO(), k8sClient, tt.c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetDockerConfigJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetDockerConfigJSON() = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// Reads never write, only PersistLastKnownGood does
+			secret := &corev1.Secret{}
+			if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: LastKnownGoodName(tt.c), Namespace: "kube-system"}, secret); err != nil {
+				t.Fatalf("Get() last known good error = %v", err)
+			}
+			if string(secret.Data[corev1.DockerConfigJsonKey]) != `{"auths":{"persisted.example.com":{}}}` {
+				t.Errorf("last known good = %s, want it unchanged", secret.Data[corev1.DockerConfigJsonKey])
+			}
+		})
+	}
+}
+
+func Test_PersistLastKnownGood(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *config.Config
+		want string
+	}{
+		{
+			name: "Source healthy. Should persist it.",
+			c:    &config.Config{DockerConfigJSON: testDockerConfigJSON, FeatureLastKnownGood: true},
+			want: testDockerConfigJSON,
+		},
+		{
+			name: "Source broken. Should persist nothing.",
+			c:    &config.Config{DockerConfigJSONPath: "/does/not/exist", FeatureLastKnownGood: true},
+		},
+		{
+			name: "Feature disabled. Should persist nothing.",
+			c:    &config.Config{DockerConfigJSON: testDockerConfigJSON},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultLastKnownGood = &lastKnownGood{}
+			tt.c.SecretNamespace = "kube-system"
+			k8sClient := fake.NewClientBuilder().Build()
+
+			if err := PersistLastKnownGood(context.TODO(), k8sClient, tt.c); err != nil {
+				t.Fatalf("PersistLastKnownGood() error = %v", err)
+			}
+
+			secret := &corev1.Secret{}
+			err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: LastKnownGoodName(tt.c), Namespace: "kube-system"}, secret)
+			if tt.want == "" {
+				if !apierrs.IsNotFound(err) {
+					t.Errorf("Get() last known good error = %v, want NotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() last known good error = %v", err)
+			}
+			if string(secret.Data[corev1.DockerConfigJsonKey]) != tt.want {
+				t.Errorf("last known good = %s, want %s", secret.Data[corev1.DockerConfigJsonKey], tt.want)
+			}
+		})
+	}
+}
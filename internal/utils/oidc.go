@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+)
+
+const (
+	// oidcRefreshMargin is the time before expiry, at which registry credentials are refreshed.
+	// It's capped at half the token lifetime, so short-lived tokens aren't exchanged on every read.
+	oidcRefreshMargin = 5 * time.Minute
+	// oidcDefaultLifetime is assumed, if the token endpoint doesn't return expires_in
+	oidcDefaultLifetime = time.Hour
+)
+
+// oidcTokenResponse is the response of an OAuth 2.0 token exchange (RFC 8693)
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oidcCredentials caches the last exchanged registry token, until it's due for a refresh.
+// exchangeMu serializes exchanges, so mu is never held during the request to the token endpoint.
+var oidcCredentials struct {
+	exchangeMu sync.Mutex
+	mu         sync.Mutex
+	token      credentialBuffer
+	refreshAt  time.Time
+}
+
+var oidcHTTPClient = &http.Client{Timeout: 30 * time.Second, Transport: outbound.Transport}
+
+// GetDockerConfigJSONFromOIDC exchanges the projected ServiceAccount token at OIDCTokenPath
+// with the OIDCTokenEndpoint for a registry token, and returns it as .dockerconfigjson for OIDCRegistry.
+// The registry token is cached, until it's due for a refresh.
+func GetDockerConfigJSONFromOIDC(ctx context.Context, c *config.Config) (string, error) {
+	token, ok := cachedOIDCToken()
+	if !ok {
+		var err error
+		if token, err = refreshOIDCToken(ctx, c); err != nil {
+			return "", err
+		}
+	}
+
+	return marshalDockerConfigJSON(map[string]dockerConfigEntry{
+		c.OIDCRegistry: {
+			Username: c.OIDCUsername,
+			Password: token,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(c.OIDCUsername + ":" + token)),
+		},
+	})
+}
+
+// OIDCRefreshAt returns when the cached registry token is due for a refresh,
+// or the zero time if none was exchanged yet
+func OIDCRefreshAt() time.Time {
+	oidcCredentials.mu.Lock()
+	defer oidcCredentials.mu.Unlock()
+
+	if oidcCredentials.token.IsEmpty() {
+		return time.Time{}
+	}
+	return oidcCredentials.refreshAt
+}
+
+// cachedOIDCToken returns the cached registry token, unless none was exchanged yet or it's due for a refresh
+func cachedOIDCToken() (string, bool) {
+	oidcCredentials.mu.Lock()
+	defer oidcCredentials.mu.Unlock()
+
+	if oidcCredentials.token.IsEmpty() || !time.Now().Before(oidcCredentials.refreshAt) {
+		return "", false
+	}
+	return oidcCredentials.token.Value(), true
+}
+
+// refreshOIDCToken exchanges a new registry token, unless a concurrent caller already did while it waited
+func refreshOIDCToken(ctx context.Context, c *config.Config) (string, error) {
+	oidcCredentials.exchangeMu.Lock()
+	defer oidcCredentials.exchangeMu.Unlock()
+
+	if token, ok := cachedOIDCToken(); ok {
+		return token, nil
+	}
+
+	token, lifetime, err := exchangeOIDCToken(ctx, c)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange ServiceAccount token: %w", err)
+	}
+
+	oidcCredentials.mu.Lock()
+	defer oidcCredentials.mu.Unlock()
+	oidcCredentials.token.Set([]byte(token))
+	oidcCredentials.refreshAt = time.Now().Add(lifetime - min(oidcRefreshMargin, lifetime/2))
+	return token, nil
+}
+
+// exchangeOIDCToken posts the projected ServiceAccount token to OIDCTokenEndpoint,
+// and returns the registry token together with its lifetime
+func exchangeOIDCToken(ctx context.Context, c *config.Config) (string, time.Duration, error) {
+	tokenPath, err := NormalizePath(c.OIDCTokenPath)
+	if err != nil {
+		return "", 0, err
+	}
+	subjectToken, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":        {strings.TrimSpace(string(subjectToken))},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.OIDCTokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	defer clear(body)
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	tokenResponse := oidcTokenResponse{}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+
+	lifetime := oidcDefaultLifetime
+	if tokenResponse.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenResponse.ExpiresIn) * time.Second
+	}
+	return tokenResponse.AccessToken, lifetime, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_GetDockerConfigJSONFromOIDC(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("projected-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		status       int
+		response     string
+		tokenPath    string
+		want         string
+		wantErr      bool
+		wantExchange int
+		wantRefresh  time.Duration
+	}{
+		{
+			name:         "Token exchanged. Should return dockerConfigJSON for the registry.",
+			status:       http.StatusOK,
+			response:     `{"access_token":"registry-token","expires_in":3600}`,
+			tokenPath:    tokenPath,
+			want:         `{"auths":{"registry.example.com":{"username":"oauth2accesstoken","password":"registry-token","auth":"b2F1dGgyYWNjZXNzdG9rZW46cmVnaXN0cnktdG9rZW4="}}}`,
+			wantExchange: 1,
+			wantRefresh:  55 * time.Minute,
+		},
+		{
+			name:         "Token expires within the refresh margin. Should refresh halfway through its lifetime, not on every read.",
+			status:       http.StatusOK,
+			response:     `{"access_token":"registry-token","expires_in":60}`,
+			tokenPath:    tokenPath,
+			want:         `{"auths":{"registry.example.com":{"username":"oauth2accesstoken","password":"registry-token","auth":"b2F1dGgyYWNjZXNzdG9rZW46cmVnaXN0cnktdG9rZW4="}}}`,
+			wantExchange: 1,
+			wantRefresh:  30 * time.Second,
+		},
+		{
+			name:         "Token endpoint rejects the token. Should return error.",
+			status:       http.StatusUnauthorized,
+			response:     `{"error":"invalid_grant"}`,
+			tokenPath:    tokenPath,
+			wantErr:      true,
+			wantExchange: 2,
+		},
+		{
+			name:         "Response without access_token. Should return error.",
+			status:       http.StatusOK,
+			response:     `{"expires_in":3600}`,
+			tokenPath:    tokenPath,
+			wantErr:      true,
+			wantExchange: 2,
+		},
+		{
+			name:         "Projected token missing. Should return error.",
+			tokenPath:    filepath.Join(t.TempDir(), "missing"),
+			wantErr:      true,
+			wantExchange: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oidcCredentials.token.Reset()
+			oidcCredentials.refreshAt = time.Time{}
+
+			exchanges := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				exchanges++
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("subject_token") != "projected-token" {
+					http.Error(w, "unexpected subject_token", http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			c := &config.Config{
+				OIDCTokenEndpoint: server.URL,
+				OIDCTokenPath:     tt.tokenPath,
+				OIDCRegistry:      "registry.example.com",
+				OIDCUsername:      "oauth2accesstoken",
+			}
+			for range 2 {
+				got, err := GetDockerConfigJSONFromOIDC(context.Background(), c)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("GetDockerConfigJSONFromOIDC() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("GetDockerConfigJSONFromOIDC() = %v, want %v", got, tt.want)
+				}
+			}
+			if exchanges != tt.wantExchange {
+				t.Errorf("GetDockerConfigJSONFromOIDC() exchanged %d times, want %d", exchanges, tt.wantExchange)
+			}
+			if tt.wantRefresh > 0 {
+				if untilRefresh := time.Until(OIDCRefreshAt()); untilRefresh > tt.wantRefresh || untilRefresh < tt.wantRefresh-5*time.Second {
+					t.Errorf("OIDCRefreshAt() is due in %v, want %v", untilRefresh, tt.wantRefresh)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ownWrites remembers the resourceVersion of each managed Secret, as last written by the controller.
+// Events carrying it were caused by the controller itself, and don't need to be reconciled again.
+type ownWrites struct {
+	mu       sync.Mutex
+	versions map[types.NamespacedName]string
+}
+
+var defaultOwnWrites = &ownWrites{versions: map[types.NamespacedName]string{}}
+
+// RecordOwnWrite remembers the resourceVersion of obj, right after the controller wrote it
+func RecordOwnWrite(obj client.Object) {
+	if obj.GetResourceVersion() == "" {
+		return
+	}
+	defaultOwnWrites.mu.Lock()
+	defer defaultOwnWrites.mu.Unlock()
+	defaultOwnWrites.versions[client.ObjectKeyFromObject(obj)] = obj.GetResourceVersion()
+}
+
+// IsOwnWrite returns whether obj is in the state last written by the controller
+func IsOwnWrite(obj client.Object) bool {
+	if obj.GetResourceVersion() == "" {
+		return false
+	}
+	defaultOwnWrites.mu.Lock()
+	defer defaultOwnWrites.mu.Unlock()
+	return defaultOwnWrites.versions[client.ObjectKeyFromObject(obj)] == obj.GetResourceVersion()
+}
+
+// ForgetOwnWrite forgets the resourceVersion of obj, e.g. after it was deleted
+func ForgetOwnWrite(obj client.Object) {
+	defaultOwnWrites.mu.Lock()
+	defer defaultOwnWrites.mu.Unlock()
+	delete(defaultOwnWrites.versions, client.ObjectKeyFromObject(obj))
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_IsOwnWrite(t *testing.T) {
+	ctx := context.TODO()
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+		SecretNamespace:  "kube-system",
+	})
+	k8sClient := fake.NewClientBuilder().Build()
+	key := types.NamespacedName{Name: c.SecretName, Namespace: "own-writes"}
+
+	get := func() *corev1.Secret {
+		secret := &corev1.Secret{}
+		if err := k8sClient.Get(ctx, key, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	if _, err := ReconcileImagePullSecret(ctx, k8sClient, c, key.Name, key.Namespace); err != nil {
+		t.Fatal(err)
+	}
+	if !IsOwnWrite(get()) {
+		t.Errorf("IsOwnWrite() should be true for the created Secret")
+	}
+
+	secret := get()
+	secret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	if err := k8sClient.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if IsOwnWrite(get()) {
+		t.Errorf("IsOwnWrite() should be false for a Secret modified by someone else")
+	}
+
+	if result, err := ReconcileImagePullSecret(ctx, k8sClient, c, key.Name, key.Namespace); err != nil {
+		t.Fatal(err)
+	} else if result != ResultPatched {
+		t.Errorf("ReconcileImagePullSecret() = %v, want %v", result, ResultPatched)
+	}
+	if !IsOwnWrite(get()) {
+		t.Errorf("IsOwnWrite() should be true for the patched Secret")
+	}
+
+	ForgetOwnWrite(get())
+	if IsOwnWrite(get()) {
+		t.Errorf("IsOwnWrite() should be false for a forgotten Secret")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// NormalizePath cleans up a user supplied file path for the OS the controller runs on.
+// On Windows forward slashes as well as MSYS style drive prefixes like /c/ are converted,
+// so paths copied from a shell work. Paths with surrounding whitespace or quotes are
+// rejected, instead of guessing which file was meant.
+func NormalizePath(p string) (string, error) {
+	return normalizePath(p, runtime.GOOS)
+}
+
+func normalizePath(p string, goos string) (string, error) {
+	if p != strings.TrimSpace(p) {
+		return "", fmt.Errorf("path %q has surrounding whitespace", p)
+	}
+	if strings.ContainsAny(p, `"`) || strings.HasPrefix(p, "'") || strings.HasSuffix(p, "'") {
+		return "", fmt.Errorf("path %q is quoted", p)
+	}
+	if p == "" {
+		return "", nil
+	}
+	if goos != "windows" {
+		return path.Clean(p), nil
+	}
+
+	p = strings.ReplaceAll(p, `\`, "/")
+	// Keep the leading double slash of UNC paths, which path.Clean would collapse
+	prefix := ""
+	if strings.HasPrefix(p, "//") {
+		prefix, p = "/", p[1:]
+	} else if len(p) >= 3 && p[0] == '/' && p[2] == '/' && isDriveLetter(p[1]) {
+		p = strings.ToUpper(p[1:2]) + ":" + p[2:]
+	} else if len(p) == 2 && p[0] == '/' && isDriveLetter(p[1]) {
+		p = strings.ToUpper(p[1:2]) + ":/"
+	}
+	return strings.ReplaceAll(prefix+path.Clean(p), "/", `\`), nil
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// fileVersion identifies the content of a file, without reading it
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+	target  string
+}
+
+// getFileVersion returns the version of filename. Symlinks are resolved, as Kubernetes
+// updates mounted Secrets and ConfigMaps by swapping a symlink, which doesn't
+// necessarily change the modification time.
+func getFileVersion(filename string) (fileVersion, error) {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	stat, err := os.Stat(target)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: stat.ModTime(), size: stat.Size(), target: target}, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func Test_normalizePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		goos    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Empty path. Should stay empty.",
+			path: "",
+			goos: "linux",
+			want: "",
+		},
+		{
+			name: "Linux path. Should be cleaned.",
+			path: "/etc//imagepullsecret-patcher/../secrets/./config.json",
+			goos: "linux",
+			want: "/etc/secrets/config.json",
+		},
+		{
+			name:    "Linux path with whitespace. Should be rejected.",
+			path:    ` /etc/secrets/config.json `,
+			goos:    "linux",
+			wantErr: true,
+		},
+		{
+			name:    "Linux path with quotes. Should be rejected.",
+			path:    `"/etc/secrets/config.json"`,
+			goos:    "linux",
+			wantErr: true,
+		},
+		{
+			name: "Relative Linux path. Should stay relative.",
+			path: "./secrets/config.json",
+			goos: "linux",
+			want: "secrets/config.json",
+		},
+		{
+			name: "Windows path. Should be cleaned.",
+			path: `C:\secrets\\imagepullsecret-patcher\..\config.json`,
+			goos: "windows",
+			want: `C:\secrets\config.json`,
+		},
+		{
+			name: "Windows path with forward slashes. Should use backslashes.",
+			path: "C:/secrets/config.json",
+			goos: "windows",
+			want: `C:\secrets\config.json`,
+		},
+		{
+			name:    "Windows path with quotes. Should be rejected.",
+			path:    `'C:\Program Files\secrets\config.json'`,
+			goos:    "windows",
+			wantErr: true,
+		},
+		{
+			name: "Windows path with inner whitespace. Should be kept.",
+			path: `C:\Program Files\secrets\config.json`,
+			goos: "windows",
+			want: `C:\Program Files\secrets\config.json`,
+		},
+		{
+			name: "MSYS style path. Should be converted to a drive letter.",
+			path: "/c/Users/dev/config.json",
+			goos: "windows",
+			want: `C:\Users\dev\config.json`,
+		},
+		{
+			name: "UNC path. Should keep the leading double backslash.",
+			path: `\\fileserver\secrets\config.json`,
+			goos: "windows",
+			want: `\\fileserver\secrets\config.json`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizePath(tt.path, tt.goos)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getFileVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires elevated privileges on Windows")
+	}
+
+	// Mimic the layout of a Secret mounted by the kubelet
+	dir := t.TempDir()
+	for _, version := range []string{"..2024_01_01", "..2024_01_02"} {
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, version, "config.json"), []byte(`{"auths":{}}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("..2024_01_01", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "config.json"), filepath.Join(dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "config.json")
+
+	before, err := getFileVersion(filename)
+	if err != nil {
+		t.Fatalf("getFileVersion() error = %v", err)
+	}
+
+	// Swap the ..data symlink, like the kubelet does on update
+	if err := os.Symlink("..2024_01_02", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := getFileVersion(filename)
+	if err != nil {
+		t.Fatalf("getFileVersion() error = %v", err)
+	}
+	if before == after {
+		t.Errorf("getFileVersion() = %v after swapping the symlink, want a different version", after)
+	}
+
+	if _, err := getFileVersion(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("getFileVersion() of a missing file, want error")
+	}
+}
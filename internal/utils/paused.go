@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// operatorNamespacePaused caches the paused annotation of the namespace of the controller, so IsPaused
+// doesn't fetch it on every reconcile. It's only trusted, once PausedHandler observed the namespace.
+var operatorNamespacePaused struct {
+	observed atomic.Bool
+	paused   atomic.Bool
+}
+
+// PausedHandler keeps track of the paused annotation on the namespace of the controller, with the
+// namespaces in the cache
+func PausedHandler(store *config.Store) cache.ResourceEventHandler {
+	observe := func(obj interface{}) {
+		c := store.Load()
+		if ns, ok := obj.(*corev1.Namespace); ok && ns.GetName() == c.OperatorNamespace {
+			operatorNamespacePaused.paused.Store(HasAnnotation(ns, c.AnnotationPaused, "true"))
+			operatorNamespacePaused.observed.Store(true)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: observe,
+		UpdateFunc: func(_, obj interface{}) {
+			observe(obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(*corev1.Namespace); ok && ns.GetName() == store.Load().OperatorNamespace {
+				operatorNamespacePaused.paused.Store(false)
+			}
+		},
+	}
+}
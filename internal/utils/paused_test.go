@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_PausedHandler(t *testing.T) {
+	t.Cleanup(func() {
+		operatorNamespacePaused.observed.Store(false)
+		operatorNamespacePaused.paused.Store(false)
+	})
+
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "registry", OperatorNamespace: "kube-system"})
+	handler := PausedHandler(config.NewStore(c))
+	// Without any namespace, IsPaused can only answer from the handler
+	k8sClient := fake.NewClientBuilder().Build()
+	isPaused := func() bool {
+		paused, err := IsPaused(context.TODO(), k8sClient, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paused
+	}
+
+	running := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}
+	paused := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Annotations: map[string]string{c.AnnotationPaused: "true"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "registry", Annotations: map[string]string{c.AnnotationPaused: "true"}}}
+
+	handler.OnAdd(other, true)
+	if isPaused() {
+		t.Errorf("IsPaused() = true after another namespace was paused, want false")
+	}
+	handler.OnAdd(running, true)
+	if isPaused() {
+		t.Errorf("IsPaused() = true, want false")
+	}
+	handler.OnUpdate(running, paused)
+	if !isPaused() {
+		t.Errorf("IsPaused() = false after the annotation was set, want true")
+	}
+	handler.OnDelete(paused)
+	if isPaused() {
+		t.Errorf("IsPaused() = true after the namespace was deleted, want false")
+	}
+}
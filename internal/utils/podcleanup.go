@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/capabilities"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
+)
+
+// qosRanks orders the QoS classes of Pods from the least to the most important
+var qosRanks = map[corev1.PodQOSClass]int{
+	corev1.PodQOSBestEffort: 0,
+	corev1.PodQOSBurstable:  1,
+	corev1.PodQOSGuaranteed: 2,
+}
+
+// criticalPodThrottle spaces out the deletions of critical Pods across all namespaces
+var criticalPodThrottle = &throttle{}
+
+// deferPodCleanup queues the Pod cleanup of namespace, if none of the maintenance windows is open, and marks
+// the namespace with the pod-cleanup-deferred annotation, so a restart or another leader picks it up again.
+// It reports whether the cleanup was deferred.
+func deferPodCleanup(ctx context.Context, c *config.Config, k8sClient client.Client, namespace string) bool {
+	windows, err := maintenance.ParseWindows(c.PodCleanupWindows, c.PodCleanupWindowTimezone)
+	now := time.Now()
+	if err != nil || windows.Open(now) {
+		return false
+	}
+	maintenance.Default.Add(namespace)
+	if err := SetPodCleanupDeferred(ctx, k8sClient, c, namespace, true); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record deferred Pod cleanup in namespace '"+namespace+"'")
+	}
+	log.FromContext(ctx).Info("Deferring Pod cleanup in namespace '"+namespace+"' until the next maintenance window",
+		"opens", windows.NextOpen(now))
+	return true
+}
+
+//+imagepullsecret-patcher:rbac:bundles=delete-pods
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;patch
+
+// SetPodCleanupDeferred sets the pod-cleanup-deferred annotation on namespace, or removes it, once the deferred
+// cleanup ran
+func SetPodCleanupDeferred(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, deferred bool) error {
+	ns, err := FetchNamespace(ctx, k8sClient, namespace)
+	if err != nil {
+		return err
+	}
+	if HasAnnotation(ns, c.AnnotationPodCleanupDeferred, "true") == deferred {
+		return nil
+	}
+
+	patchFrom := client.MergeFrom(ns.DeepCopy())
+	if deferred {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[c.AnnotationPodCleanupDeferred] = "true"
+	} else {
+		delete(ns.Annotations, c.AnnotationPodCleanupDeferred)
+	}
+	if err := k8sClient.Patch(ctx, ns, patchFrom); err != nil {
+		return fmt.Errorf("failed to patch namespace: %w", err)
+	}
+	return nil
+}
+
+// RestoreDeferredPodCleanups queues the Pod cleanups of the namespaces marked with the pod-cleanup-deferred
+// annotation, which were deferred before a restart or by a previous leader
+func RestoreDeferredPodCleanups(ctx context.Context, k8sClient client.Client, c *config.Config, queue *maintenance.Queue) error {
+	namespaceList := &corev1.NamespaceList{}
+	if err := k8sClient.List(ctx, namespaceList); err != nil {
+		return fmt.Errorf("failed to list Namespaces: %w", err)
+	}
+	for i := range namespaceList.Items {
+		if HasAnnotation(&namespaceList.Items[i], c.AnnotationPodCleanupDeferred, "true") {
+			queue.Add(namespaceList.Items[i].GetName())
+		}
+	}
+	return nil
+}
+
+// evictPod evicts pod via the eviction API, which respects PodDisruptionBudgets. The Eviction is created
+// in the group version served by the cluster, as older clusters only serve policy/v1beta1.
+func evictPod(ctx context.Context, k8sClient client.Client, pod *corev1.Pod) error {
+	objectMeta := metav1.ObjectMeta{Name: pod.GetName(), Namespace: pod.GetNamespace()}
+	var eviction client.Object = &policyv1.Eviction{ObjectMeta: objectMeta}
+	if capabilities.Default.EvictionGroupVersion == policyv1beta1.SchemeGroupVersion.String() {
+		eviction = &policyv1beta1.Eviction{ObjectMeta: objectMeta}
+	}
+	return k8sClient.SubResource("eviction").Create(ctx, pod, eviction)
+}
+
+// IsPodCritical checks whether pod has at least PodCleanupCriticalPriority, or runs in a namespace
+// matching PodCleanupCriticalSelector. Critical Pods are deleted last and one at a time.
+func IsPodCritical(c *config.Config, namespace client.Object, pod *corev1.Pod) bool {
+	if c.PodCleanupCriticalPriority > 0 && podPriority(pod) >= int32(c.PodCleanupCriticalPriority) {
+		return true
+	}
+	if c.PodCleanupCriticalSelector == "" {
+		return false
+	}
+	selector, err := labels.Parse(c.PodCleanupCriticalSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(namespace.GetLabels()))
+}
+
+// sortPodsForCleanup orders pods from the least to the most important by priority and QoS class,
+// so Pods of non-production workloads are recycled first during mass credential fixes
+func sortPodsForCleanup(pods []*corev1.Pod) {
+	sort.SliceStable(pods, func(i, j int) bool {
+		if podPriority(pods[i]) != podPriority(pods[j]) {
+			return podPriority(pods[i]) < podPriority(pods[j])
+		}
+		return qosRanks[pods[i].Status.QOSClass] < qosRanks[pods[j].Status.QOSClass]
+	})
+}
+
+// podPriority returns the priority of pod, resolved from its PriorityClass on admission
+func podPriority(pod *corev1.Pod) int32 {
+	if pod.Spec.Priority == nil {
+		return 0
+	}
+	return *pod.Spec.Priority
+}
+
+// throttle lets callers pass at most once per interval
+type throttle struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Reserve lets the caller pass, if the previous one passed at least interval ago, and reserves the
+// following interval for it. Otherwise it returns how long the caller has to wait, without blocking.
+func (t *throttle) Reserve(interval time.Duration) time.Duration {
+	return t.reserve(interval, time.Now())
+}
+
+// reserve implements Reserve at now
+func (t *throttle) reserve(interval time.Duration, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wait := t.next.Sub(now); wait > 0 {
+		return wait
+	}
+	t.next = now.Add(interval)
+	return 0
+}
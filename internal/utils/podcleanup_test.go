@@ -0,0 +1,234 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/capabilities"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/maintenance"
+)
+
+func Test_sortPodsForCleanup(t *testing.T) {
+	pod := func(name string, priority *int32, qos corev1.PodQOSClass) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PodSpec{Priority: priority},
+			Status:     corev1.PodStatus{QOSClass: qos},
+		}
+	}
+	pods := []*corev1.Pod{
+		pod("production", ptr.To[int32](1000), corev1.PodQOSGuaranteed),
+		pod("guaranteed", nil, corev1.PodQOSGuaranteed),
+		pod("besteffort", nil, corev1.PodQOSBestEffort),
+		pod("preemptible", ptr.To[int32](-10), corev1.PodQOSGuaranteed),
+	}
+
+	sortPodsForCleanup(pods)
+
+	want := []string{"preemptible", "besteffort", "guaranteed", "production"}
+	for i, name := range want {
+		if pods[i].Name != name {
+			t.Errorf("pods[%d] = %s, want %s", i, pods[i].Name, name)
+		}
+	}
+}
+
+func Test_IsPodCritical(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  config.ConfigOptions
+		nsLabels map[string]string
+		priority *int32
+		want     bool
+	}{
+		{
+			name:     "Nothing configured. Should not be critical.",
+			priority: ptr.To[int32](2000000000),
+			want:     false,
+		},
+		{
+			name:     "Priority above the threshold. Should be critical.",
+			options:  config.ConfigOptions{PodCleanupCriticalPriority: 1000},
+			priority: ptr.To[int32](1000),
+			want:     true,
+		},
+		{
+			name:     "Priority below the threshold. Should not be critical.",
+			options:  config.ConfigOptions{PodCleanupCriticalPriority: 1000},
+			priority: ptr.To[int32](999),
+			want:     false,
+		},
+		{
+			name:     "Namespace matches the selector. Should be critical.",
+			options:  config.ConfigOptions{PodCleanupCriticalSelector: "env=production"},
+			nsLabels: map[string]string{"env": "production"},
+			want:     true,
+		},
+		{
+			name:     "Namespace doesn't match the selector. Should not be critical.",
+			options:  config.ConfigOptions{PodCleanupCriticalSelector: "env=production"},
+			nsLabels: map[string]string{"env": "staging"},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.DockerConfigJSON = "xx"
+			tt.options.SecretNamespace = "kube-system"
+			c := config.NewConfig(tt.options)
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: tt.nsLabels}}
+			pod := &corev1.Pod{Spec: corev1.PodSpec{Priority: tt.priority}}
+			if got := IsPodCritical(c, ns, pod); got != tt.want {
+				t.Errorf("IsPodCritical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_throttle(t *testing.T) {
+	throttle := &throttle{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	interval := 10 * time.Second
+
+	if wait := throttle.reserve(interval, now); wait != 0 {
+		t.Errorf("reserve() of the first caller = %v, want 0", wait)
+	}
+	if wait := throttle.reserve(interval, now.Add(4*time.Second)); wait != 6*time.Second {
+		t.Errorf("reserve() within the interval = %v, want %v", wait, 6*time.Second)
+	}
+	// Waiting callers don't push back the next turn
+	if wait := throttle.reserve(interval, now.Add(interval)); wait != 0 {
+		t.Errorf("reserve() after the interval = %v, want 0", wait)
+	}
+	if wait := throttle.reserve(interval, now.Add(interval+time.Second)); wait != interval-time.Second {
+		t.Errorf("reserve() within the next interval = %v, want %v", wait, interval-time.Second)
+	}
+}
+
+func Test_evictPod(t *testing.T) {
+	defer func() { capabilities.Default = capabilities.Latest }()
+
+	tests := []struct {
+		name                 string
+		evictionGroupVersion string
+		want                 client.Object
+	}{
+		{name: "policy/v1", evictionGroupVersion: "policy/v1", want: &policyv1.Eviction{}},
+		{name: "policy/v1beta1 on older clusters", evictionGroupVersion: "policy/v1beta1", want: &policyv1beta1.Eviction{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capabilities.Default.EvictionGroupVersion = tt.evictionGroupVersion
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}}
+			var got client.Object
+			k8sClient := fake.NewClientBuilder().WithObjects(pod).WithInterceptorFuncs(interceptor.Funcs{
+				SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+					got = subResource
+					return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+				},
+			}).Build()
+
+			if err := evictPod(context.TODO(), k8sClient, pod); err != nil {
+				t.Fatalf("evictPod() error = %v", err)
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Errorf("evictPod() created %T, want %T", got, tt.want)
+			}
+			if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: "app", Namespace: "team-a"}, &corev1.Pod{}); err == nil {
+				t.Error("evictPod() didn't evict the Pod")
+			}
+		})
+	}
+}
+
+func Test_deferPodCleanup(t *testing.T) {
+	maintenance.Default = maintenance.NewQueue()
+	defer func() { maintenance.Default = maintenance.NewQueue() }()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "app",
+			Namespace:       "team-a",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "app", Controller: ptr.To(true)}},
+		},
+		Spec: corev1.PodSpec{ServiceAccountName: "default"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+			}},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		pod,
+	).Build()
+
+	// February 30th never comes, so the window is always closed
+	closed := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:  "xx",
+		SecretNamespace:   "kube-system",
+		PodCleanupWindows: "0 0 30 2 * 1h",
+	})
+	if _, err := CleanupPodsForSA(context.TODO(), closed, k8sClient, nil, "team-a", "default"); err != nil {
+		t.Fatalf("CleanupPodsForSA() error = %v", err)
+	}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+		t.Errorf("Pod was deleted outside of the maintenance windows: %v", err)
+	}
+	if got := maintenance.Default.Drain(); !reflect.DeepEqual(got, []string{"team-a"}) {
+		t.Errorf("deferred namespaces = %v, want [team-a]", got)
+	}
+	// The queue is lost on a restart, so it is restored from the namespaces
+	restored := maintenance.NewQueue()
+	if err := RestoreDeferredPodCleanups(context.TODO(), k8sClient, closed, restored); err != nil {
+		t.Fatalf("RestoreDeferredPodCleanups() error = %v", err)
+	}
+	if got := restored.Drain(); !reflect.DeepEqual(got, []string{"team-a"}) {
+		t.Errorf("restored namespaces = %v, want [team-a]", got)
+	}
+
+	open := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON:  "xx",
+		SecretNamespace:   "kube-system",
+		PodCleanupWindows: "* * * * * 1m",
+	})
+	if _, err := CleanupPodsForSA(context.TODO(), open, k8sClient, nil, "team-a", "default"); err != nil {
+		t.Fatalf("CleanupPodsForSA() error = %v", err)
+	}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{}); err == nil {
+		t.Errorf("Pod was kept within the maintenance window")
+	}
+	if got := maintenance.Default.Len(); got != 0 {
+		t.Errorf("deferred namespaces = %d, want 0", got)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// PodCleanupContext holds what a PodCleanupPolicy needs to decide on the deletion of a Pod
+type PodCleanupContext struct {
+	// Config is the configuration of the controller
+	Config *config.Config
+	// Namespace is the namespace of the Pod
+	Namespace client.Object
+	// Reason is the waiting reason of the container failing to pull its image, e.g. ImagePullBackOff
+	Reason string
+	// Image is the image of the container failing to pull it
+	Image string
+}
+
+// PodCleanupPolicy decides whether a Pod, which is failing to pull its image, is deleted to pick up
+// the imagePullSecret. A Pod is only deleted, if every registered policy agrees.
+type PodCleanupPolicy interface {
+	// Name identifies the policy in the logs
+	Name() string
+	// ShouldDelete returns whether pod may be deleted and, if not, why, e.g. "due to its owner"
+	ShouldDelete(pod *corev1.Pod, pcc PodCleanupContext) (bool, string)
+}
+
+var (
+	podCleanupPoliciesMu sync.RWMutex
+	// podCleanupPolicies are consulted in order, the built-in ones first
+	podCleanupPolicies = []PodCleanupPolicy{
+		reasonPolicy{},
+		registryPolicy{},
+		ownerKindPolicy{},
+		daemonSetBackoffPolicy{},
+		agePolicy{},
+	}
+)
+
+// RegisterPodCleanupPolicy adds policy to the policies consulted before a Pod is deleted. Custom cleanup
+// rules are compiled in by registering them from the init function of their package.
+func RegisterPodCleanupPolicy(policy PodCleanupPolicy) {
+	podCleanupPoliciesMu.Lock()
+	defer podCleanupPoliciesMu.Unlock()
+	podCleanupPolicies = append(podCleanupPolicies, policy)
+}
+
+// ShouldDeletePod consults all registered policies, returning the name of the first
+// one, which vetoes the deletion of pod, and why
+func ShouldDeletePod(pod *corev1.Pod, pcc PodCleanupContext) (bool, string, string) {
+	podCleanupPoliciesMu.RLock()
+	defer podCleanupPoliciesMu.RUnlock()
+	for _, policy := range podCleanupPolicies {
+		if ok, why := policy.ShouldDelete(pod, pcc); !ok {
+			return false, policy.Name(), why
+		}
+	}
+	return true, "", ""
+}
+
+// reasonPolicy only allows the deletion of Pods waiting for one of the PodCleanupReasons
+type reasonPolicy struct{}
+
+func (reasonPolicy) Name() string { return "reason" }
+
+func (reasonPolicy) ShouldDelete(_ *corev1.Pod, pcc PodCleanupContext) (bool, string) {
+	if !IsStringInList(pcc.Reason, pcc.Config.PodCleanupReasons) {
+		return false, "as " + pcc.Reason + " is not one of the pod cleanup reasons"
+	}
+	return true, ""
+}
+
+// registryPolicy only allows the deletion of Pods failing to pull from one of the PodCleanupRegistries
+type registryPolicy struct{}
+
+func (registryPolicy) Name() string { return "registry" }
+
+func (registryPolicy) ShouldDelete(_ *corev1.Pod, pcc PodCleanupContext) (bool, string) {
+	if pcc.Config.PodCleanupRegistries == "" {
+		return true, ""
+	}
+	if registry := imageRegistry(pcc.Image); !IsStringInList(registry, pcc.Config.PodCleanupRegistries) {
+		return false, "as its image is pulled from " + registry
+	}
+	return true, ""
+}
+
+// ownerKindPolicy only allows the deletion of Pods, whose owner will recreate them
+type ownerKindPolicy struct{}
+
+func (ownerKindPolicy) Name() string { return "owner-kind" }
+
+func (ownerKindPolicy) ShouldDelete(pod *corev1.Pod, pcc PodCleanupContext) (bool, string) {
+	if !IsPodOwnerAllowed(pcc.Config, pod) {
+		return false, "due to its owner"
+	}
+	return true, ""
+}
+
+// daemonSetBackoffPolicy holds back the deletion of DaemonSet Pods for DaemonSetPodCleanupBackoff
+type daemonSetBackoffPolicy struct{}
+
+func (daemonSetBackoffPolicy) Name() string { return "daemonset-backoff" }
+
+func (daemonSetBackoffPolicy) ShouldDelete(pod *corev1.Pod, pcc PodCleanupContext) (bool, string) {
+	if IsDaemonSetPodInBackoff(pcc.Config, pod) {
+		return false, "as it is a DaemonSet Pod younger than " + pcc.Config.DaemonSetPodCleanupBackoff.String()
+	}
+	return true, ""
+}
+
+// agePolicy holds back the deletion of Pods younger than PodCleanupMinAge
+type agePolicy struct{}
+
+func (agePolicy) Name() string { return "age" }
+
+func (agePolicy) ShouldDelete(pod *corev1.Pod, pcc PodCleanupContext) (bool, string) {
+	if time.Since(pod.CreationTimestamp.Time) < pcc.Config.PodCleanupMinAge {
+		return false, "as it is younger than " + pcc.Config.PodCleanupMinAge.String()
+	}
+	return true, ""
+}
+
+// imageRegistry returns the registry of an image reference, e.g. docker.io for nginx:latest
+func imageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		return "docker.io"
+	}
+	return first
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ShouldDeletePod(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    config.ConfigOptions
+		ownerKind  string
+		age        time.Duration
+		reason     string
+		image      string
+		wantPolicy string
+	}{
+		{
+			name:      "ReplicaSet Pod in ImagePullBackOff",
+			ownerKind: "ReplicaSet",
+			reason:    "ImagePullBackOff",
+			image:     "registry.example.com/app:1.0",
+		},
+		{
+			name:       "reason not listed",
+			options:    config.ConfigOptions{PodCleanupReasons: "ImagePullBackOff"},
+			ownerKind:  "ReplicaSet",
+			reason:     "ErrImagePull",
+			image:      "registry.example.com/app:1.0",
+			wantPolicy: "reason",
+		},
+		{
+			name:      "registry matched by glob",
+			options:   config.ConfigOptions{PodCleanupRegistries: "*.example.com"},
+			ownerKind: "ReplicaSet",
+			reason:    "ImagePullBackOff",
+			image:     "registry.example.com/app:1.0",
+		},
+		{
+			name:       "registry not listed",
+			options:    config.ConfigOptions{PodCleanupRegistries: "registry.example.com"},
+			ownerKind:  "ReplicaSet",
+			reason:     "ImagePullBackOff",
+			image:      "nginx:latest",
+			wantPolicy: "registry",
+		},
+		{
+			name:       "bare Pod",
+			reason:     "ImagePullBackOff",
+			image:      "registry.example.com/app:1.0",
+			wantPolicy: "owner-kind",
+		},
+		{
+			name:       "young DaemonSet Pod",
+			options:    config.ConfigOptions{PodCleanupOwnerKinds: "DaemonSet"},
+			ownerKind:  "DaemonSet",
+			reason:     "ImagePullBackOff",
+			image:      "registry.example.com/app:1.0",
+			wantPolicy: "daemonset-backoff",
+		},
+		{
+			name:       "Pod younger than the min age",
+			options:    config.ConfigOptions{PodCleanupMinAge: time.Minute},
+			ownerKind:  "ReplicaSet",
+			age:        time.Second,
+			reason:     "ImagePullBackOff",
+			image:      "registry.example.com/app:1.0",
+			wantPolicy: "age",
+		},
+		{
+			name:      "Pod older than the min age",
+			options:   config.ConfigOptions{PodCleanupMinAge: time.Minute},
+			ownerKind: "ReplicaSet",
+			age:       time.Hour,
+			reason:    "ImagePullBackOff",
+			image:     "registry.example.com/app:1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.DockerConfigJSON = "xx"
+			tt.options.SecretNamespace = "kube-system"
+			c := config.NewConfig(tt.options)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "pod",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-tt.age)),
+				},
+			}
+			if tt.ownerKind != "" {
+				pod.OwnerReferences = []metav1.OwnerReference{{Kind: tt.ownerKind, Name: "owner", Controller: &True}}
+			}
+
+			ok, policy, _ := ShouldDeletePod(pod, PodCleanupContext{Config: c, Reason: tt.reason, Image: tt.image})
+			if ok != (tt.wantPolicy == "") || policy != tt.wantPolicy {
+				t.Errorf("ShouldDeletePod() = %v, %q, want vetoed by %q", ok, policy, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+// namespacePolicy is a custom policy, which vetoes deletions in the namespace "protected"
+type namespacePolicy struct{}
+
+func (namespacePolicy) Name() string { return "protected-namespace" }
+
+func (namespacePolicy) ShouldDelete(pod *corev1.Pod, _ PodCleanupContext) (bool, string) {
+	return pod.Namespace != "protected", "as its namespace is protected"
+}
+
+func Test_RegisterPodCleanupPolicy(t *testing.T) {
+	builtin := podCleanupPolicies
+	defer func() { podCleanupPolicies = builtin }()
+	RegisterPodCleanupPolicy(namespacePolicy{})
+
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureDeletePodsAnyOwner: true})
+	pcc := PodCleanupContext{Config: c, Reason: "ImagePullBackOff", Image: "nginx"}
+	for namespace, want := range map[string]bool{"default": true, "protected": false} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: namespace}}
+		if ok, policy, _ := ShouldDeletePod(pod, pcc); ok != want {
+			t.Errorf("ShouldDeletePod() in %s = %v (%s), want %v", namespace, ok, policy, want)
+		}
+	}
+}
+
+func Test_imageRegistry(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                                "docker.io",
+		"library/nginx:latest":                 "docker.io",
+		"registry.example.com/team/app:1.0":    "registry.example.com",
+		"registry.example.com:5000/app@sha256": "registry.example.com:5000",
+		"localhost/app":                        "localhost",
+	}
+	for image, want := range tests {
+		if got := imageRegistry(image); got != want {
+			t.Errorf("imageRegistry(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
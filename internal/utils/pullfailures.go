@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// ImagePullFailureHandler keeps the imagepullsecret_patcher_image_pull_failures_total and
+// imagepullsecret_patcher_pods_image_pull_failing metrics up to date with the Pods in the cache
+func ImagePullFailureHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				observeImagePullFailure(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				observeImagePullFailure(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				metrics.SetImagePullFailure(pod.GetNamespace()+"/"+pod.GetName(), pod.GetNamespace(), "")
+			}
+		},
+	}
+}
+
+func observeImagePullFailure(pod *corev1.Pod) {
+	reason, _ := GetImagePullFailureReason(pod)
+	metrics.SetImagePullFailure(pod.GetNamespace()+"/"+pod.GetName(), pod.GetNamespace(), reason)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+func Test_ImagePullFailureHandler(t *testing.T) {
+	handler := ImagePullFailureHandler()
+	failures := metrics.ImagePullFailuresTotal.WithLabelValues("pull-failures-test", "ErrImagePull")
+	failing := metrics.PodsImagePullFailing.WithLabelValues("pull-failures-test")
+
+	withReason := func(pod *corev1.Pod, reason string) *corev1.Pod {
+		pod = pod.DeepCopy()
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}},
+		}
+		return pod
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "pull-failures-test"}}
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "pull-failures-test"}}
+
+	handler.OnAdd(withReason(pod, "ContainerCreating"), true)
+	handler.OnAdd(withReason(other, "ErrImagePull"), true)
+	handler.OnUpdate(pod, withReason(pod, "ErrImagePull"))
+	// Backing off is the same failure, and not counted again
+	handler.OnUpdate(pod, withReason(pod, "ImagePullBackOff"))
+	if got := testutil.ToFloat64(failures); got != 2 {
+		t.Errorf("image_pull_failures_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(failing); got != 2 {
+		t.Errorf("pods_image_pull_failing = %v, want 2", got)
+	}
+
+	handler.OnUpdate(pod, pod)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "pull-failures-test/other", Obj: other})
+	if got := testutil.ToFloat64(failing); got != 0 {
+		t.Errorf("pods_image_pull_failing = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(failures); got != 2 {
+		t.Errorf("image_pull_failures_total = %v, want 2", got)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrReadOnly is returned by every write of a client created by NewReadOnlyClient
+var ErrReadOnly = errors.New("client is read-only")
+
+// readOnlyClient rejects all writes, so code shared with the controllers can be run by replicas,
+// which must not mutate the cluster
+type readOnlyClient struct {
+	client.Client
+}
+
+// NewReadOnlyClient wraps k8sClient, so reads are passed through and writes fail with ErrReadOnly
+func NewReadOnlyClient(k8sClient client.Client) client.Client {
+	return &readOnlyClient{Client: k8sClient}
+}
+
+func (c *readOnlyClient) Create(context.Context, client.Object, ...client.CreateOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) Delete(context.Context, client.Object, ...client.DeleteOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) DeleteAllOf(context.Context, client.Object, ...client.DeleteAllOfOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) Status() client.SubResourceWriter {
+	return readOnlySubResourceWriter{}
+}
+
+func (c *readOnlyClient) SubResource(subResource string) client.SubResourceClient {
+	return readOnlySubResourceClient{SubResourceReader: c.Client.SubResource(subResource)}
+}
+
+type readOnlySubResourceWriter struct{}
+
+func (readOnlySubResourceWriter) Create(context.Context, client.Object, client.Object, ...client.SubResourceCreateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlySubResourceWriter) Update(context.Context, client.Object, ...client.SubResourceUpdateOption) error {
+	return ErrReadOnly
+}
+
+func (readOnlySubResourceWriter) Patch(context.Context, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+	return ErrReadOnly
+}
+
+type readOnlySubResourceClient struct {
+	client.SubResourceReader
+	readOnlySubResourceWriter
+}
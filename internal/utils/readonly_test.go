@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_NewReadOnlyClient(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"}}
+	k8sClient := NewReadOnlyClient(fake.NewClientBuilder().WithObjects(secret).Build())
+
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: "secret", Namespace: "default"}, &corev1.Secret{}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	writes := map[string]func() error{
+		"Create": func() error {
+			return k8sClient.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default"}})
+		},
+		"Update":        func() error { return k8sClient.Update(ctx, secret.DeepCopy()) },
+		"Patch":         func() error { return k8sClient.Patch(ctx, secret.DeepCopy(), client.Merge) },
+		"Delete":        func() error { return k8sClient.Delete(ctx, secret.DeepCopy()) },
+		"DeleteAllOf":   func() error { return k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace("default")) },
+		"Status.Update": func() error { return k8sClient.Status().Update(ctx, secret.DeepCopy()) },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() error = %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: "new", Namespace: "default"}, &corev1.Secret{}); err == nil {
+		t.Errorf("Create() wrote through the read-only client")
+	}
+}
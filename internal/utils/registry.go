@@ -0,0 +1,277 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+)
+
+// credentialVerifyRetryInterval is how long rejected credentials are held back, before they're verified again
+const credentialVerifyRetryInterval = time.Minute
+
+// errCredentialsRejected indicates that a registry rejected the credentials during the auth handshake
+var errCredentialsRejected = errors.New("credentials rejected by registry")
+
+// CredentialsRejectedHandler is called, once rotated credentials were rejected by a registry and are held back
+var CredentialsRejectedHandler func(ctx context.Context, err error)
+
+// challengeParamRegexp matches a parameter of a WWW-Authenticate challenge, e.g. realm="https://auth.docker.io/token"
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// credentialRollout verifies rotated credentials against their registries, before they're distributed.
+// Credentials rejected by a registry are held back in favor of the last known good ones.
+type credentialRollout struct {
+	client *http.Client
+
+	// verifyMu serializes the handshakes, which run without holding mu
+	verifyMu sync.Mutex
+
+	mu         sync.Mutex
+	lastGood   credentialBuffer
+	rejected   [sha256.Size]byte
+	rejectedAt time.Time
+	rejectErr  error
+}
+
+var defaultCredentialRollout = &credentialRollout{client: &http.Client{Timeout: 10 * time.Second, Transport: outbound.Transport}}
+
+// verifyRotatedCredentials returns dockerConfigJSON, once its registries accepted it. If one of them rejects
+// it, the last known good credentials are returned instead, or an error, if there are none.
+func verifyRotatedCredentials(ctx context.Context, c *config.Config, dockerConfigJSON string) (string, error) {
+	if !c.FeatureVerifyRotatedCredentials {
+		return dockerConfigJSON, nil
+	}
+	return defaultCredentialRollout.Verify(ctx, dockerConfigJSON)
+}
+
+// Verify returns dockerConfigJSON, if its registries accept it, or the last known good credentials otherwise.
+// While another caller verifies rotated credentials, the last known good ones are returned without waiting.
+func (r *credentialRollout) Verify(ctx context.Context, dockerConfigJSON string) (string, error) {
+	hash := sha256.Sum256([]byte(dockerConfigJSON))
+	if value, ok, err := r.verified(dockerConfigJSON, hash); ok {
+		return value, err
+	}
+
+	if !r.verifyMu.TryLock() {
+		r.mu.Lock()
+		if !r.lastGood.IsEmpty() {
+			defer r.mu.Unlock()
+			return r.lastGood.Value(), nil
+		}
+		r.mu.Unlock()
+		r.verifyMu.Lock()
+	}
+	defer r.verifyMu.Unlock()
+	// The credentials may have been verified, while waiting for the previous handshake
+	if value, ok, err := r.verified(dockerConfigJSON, hash); ok {
+		return value, err
+	}
+
+	verifyErr := r.verify(ctx, dockerConfigJSON)
+
+	r.mu.Lock()
+	if verifyErr == nil {
+		r.lastGood.Set([]byte(dockerConfigJSON))
+		r.rejected = [sha256.Size]byte{}
+		r.rejectErr = nil
+		r.mu.Unlock()
+		metrics.CredentialsHeldBack.Set(0)
+		return dockerConfigJSON, nil
+	}
+	// Notify only once about the same credentials
+	notify := hash != r.rejected
+	r.rejected = hash
+	r.rejectedAt = time.Now()
+	r.rejectErr = verifyErr
+	value, err := r.heldBack()
+	r.mu.Unlock()
+
+	if notify {
+		log.FromContext(ctx).Error(verifyErr, "holding back rotated credentials")
+		if CredentialsRejectedHandler != nil {
+			CredentialsRejectedHandler(ctx, verifyErr)
+		}
+	}
+	return value, err
+}
+
+// verified returns the result for dockerConfigJSON, if it's the last known good one or was rejected
+// within credentialVerifyRetryInterval. ok is false, if it has to be verified.
+func (r *credentialRollout) verified(dockerConfigJSON string, hash [sha256.Size]byte) (value string, ok bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if dockerConfigJSON == r.lastGood.Value() {
+		return dockerConfigJSON, true, nil
+	}
+	if hash == r.rejected && time.Since(r.rejectedAt) < credentialVerifyRetryInterval {
+		value, err := r.heldBack()
+		return value, true, err
+	}
+	return "", false, nil
+}
+
+// heldBack returns the last known good credentials, or the error of the rejected ones, if there are none.
+// r.mu must be held.
+func (r *credentialRollout) heldBack() (string, error) {
+	metrics.CredentialsHeldBack.Set(1)
+	if r.lastGood.IsEmpty() {
+		return "", r.rejectErr
+	}
+	return r.lastGood.Value(), nil
+}
+
+// verify performs the auth handshake with every registry of the dockerConfigJSON content. Only a definite rejection
+// fails it. Registries, which can't be reached or don't require authentication, are skipped.
+func (r *credentialRollout) verify(ctx context.Context, content string) error {
+	parsed := dockerConfigJSON{}
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return fmt.Errorf("failed to parse dockerConfigJSON: %w", err)
+	}
+
+	registries := make([]string, 0, len(parsed.Auths))
+	for registry := range parsed.Auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	for _, registry := range registries {
+		username, password, ok := parsed.Auths[registry].credentials()
+		if !ok {
+			continue
+		}
+		err := r.verifyRegistry(ctx, registryHost(registry), username, password)
+		if errors.Is(err, errCredentialsRejected) {
+			metrics.CredentialVerificationFailuresTotal.WithLabelValues(registry).Inc()
+			return fmt.Errorf("registry '%s': %w", registry, err)
+		}
+		if err != nil {
+			log.FromContext(ctx).Info("unable to verify credentials, skipping registry", "registry", registry, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// verifyRegistry authenticates against the registry API of host, following its WWW-Authenticate challenge
+func (r *credentialRollout) verifyRegistry(ctx context.Context, host string, username string, password string) error {
+	resp, err := r.get(ctx, "https://"+host+"/v2/", "", "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	scheme, _, _ := strings.Cut(challenge, " ")
+	authURL := "https://" + host + "/v2/"
+	switch strings.ToLower(scheme) {
+	case "basic":
+	case "bearer":
+		// Request a token from the realm, without scope, which only succeeds with valid credentials
+		params := map[string]string{}
+		for _, match := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+			params[strings.ToLower(match[1])] = match[2]
+		}
+		realm, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return fmt.Errorf("invalid challenge %q", challenge)
+		}
+		if params["service"] != "" {
+			query := realm.Query()
+			query.Set("service", params["service"])
+			realm.RawQuery = query.Encode()
+		}
+		authURL = realm.String()
+	default:
+		return fmt.Errorf("unsupported challenge %q", challenge)
+	}
+
+	resp, err = r.get(ctx, authURL, username, password)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %s", errCredentialsRejected, resp.Status)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// get requests url, with basic auth if username is set, and discards the body
+func (r *credentialRollout) get(ctx context.Context, url string, username string, password string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// credentials returns the username and password of the entry, decoding auth if required
+func (e dockerConfigEntry) credentials() (string, string, bool) {
+	if e.Username != "" {
+		return e.Username, e.Password, true
+	}
+	decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	return username, password, ok && username != ""
+}
+
+// registryHost returns the host of the registry API for a key of the auths of a .dockerconfigjson,
+// e.g. registry-1.docker.io for https://index.docker.io/v1/
+func registryHost(registry string) string {
+	host := registry
+	if u, err := url.Parse(registry); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host, _, _ = strings.Cut(host, "/")
+	if host == "docker.io" || host == "index.docker.io" {
+		return "registry-1.docker.io"
+	}
+	return host
+}
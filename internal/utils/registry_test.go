@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestRegistry serves the registry API, accepting the password "valid". With bearer, credentials
+// are checked by the token endpoint of the challenge, otherwise by the registry API itself.
+func newTestRegistry(t *testing.T, bearer bool) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, password, ok := req.BasicAuth()
+		switch {
+		case req.URL.Path == "/token" && bearer:
+			if password != "valid" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case req.URL.Path == "/v2/" && bearer:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.URL.Path == "/v2/":
+			if !ok || password != "valid" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testRegistryDockerConfigJSON(server *httptest.Server, password string) string {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:" + password))
+	return fmt.Sprintf(`{"auths":{"%s":{"auth":"%s"}}}`, server.Listener.Addr().String(), auth)
+}
+
+func Test_credentialRollout_Verify(t *testing.T) {
+	for _, bearer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bearer=%v", bearer), func(t *testing.T) {
+			server := newTestRegistry(t, bearer)
+			rollout := &credentialRollout{client: server.Client()}
+			valid := testRegistryDockerConfigJSON(server, "valid")
+			invalid := testRegistryDockerConfigJSON(server, "invalid")
+
+			rejections := 0
+			CredentialsRejectedHandler = func(ctx context.Context, err error) { rejections++ }
+			t.Cleanup(func() { CredentialsRejectedHandler = nil })
+
+			if _, err := rollout.Verify(context.TODO(), invalid); err == nil {
+				t.Errorf("Verify() of rejected credentials without known good ones error = nil, want error")
+			}
+			if got, err := rollout.Verify(context.TODO(), valid); err != nil || got != valid {
+				t.Errorf("Verify() of accepted credentials = %v, %v, want them", got, err)
+			}
+			for range 2 {
+				if got, err := rollout.Verify(context.TODO(), invalid); err != nil || got != valid {
+					t.Errorf("Verify() of rejected credentials = %v, %v, want the last known good ones", got, err)
+				}
+			}
+			if rejections != 2 {
+				t.Errorf("CredentialsRejectedHandler called %d times, want once per rejected credentials", rejections)
+			}
+		})
+	}
+}
+
+func Test_credentialRollout_Verify_Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, password, ok := req.BasicAuth()
+		if password == "slow" {
+			close(started)
+			<-release
+		}
+		if !ok || password == "invalid" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+	rollout := &credentialRollout{client: server.Client()}
+	valid := testRegistryDockerConfigJSON(server, "valid")
+	slow := testRegistryDockerConfigJSON(server, "slow")
+
+	if got, err := rollout.Verify(context.TODO(), valid); err != nil || got != valid {
+		t.Fatalf("Verify() of accepted credentials = %v, %v, want them", got, err)
+	}
+
+	done := make(chan string)
+	go func() {
+		got, _ := rollout.Verify(context.TODO(), slow)
+		done <- got
+	}()
+	<-started
+
+	// The handshake in progress neither blocks the last known good credentials, nor other rotated ones
+	if got, err := rollout.Verify(context.TODO(), valid); err != nil || got != valid {
+		t.Errorf("Verify() during a handshake = %v, %v, want the last known good credentials", got, err)
+	}
+	if got, err := rollout.Verify(context.TODO(), testRegistryDockerConfigJSON(server, "other")); err != nil || got != valid {
+		t.Errorf("Verify() of other credentials during a handshake = %v, %v, want the last known good ones", got, err)
+	}
+
+	close(release)
+	if got := <-done; got != slow {
+		t.Errorf("Verify() of accepted credentials = %v, want them", got)
+	}
+}
+
+func Test_registryHost(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     string
+	}{
+		{"https://index.docker.io/v1/", "registry-1.docker.io"},
+		{"docker.io", "registry-1.docker.io"},
+		{"ghcr.io", "ghcr.io"},
+		{"https://registry.example.com:5000", "registry.example.com:5000"},
+		{"registry.example.com/project", "registry.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			if got := registryHost(tt.registry); got != tt.want {
+				t.Errorf("registryHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+// ReconcileResult is the outcome of reconciling a single object. It's used as metrics
+// label and decides, when the object is requeued.
+type ReconcileResult string
+
+const (
+	// ResultCreated means the object did not exist and was created
+	ResultCreated ReconcileResult = "Created"
+	// ResultPatched means the object differed from the desired state and was patched
+	ResultPatched ReconcileResult = "Patched"
+	// ResultNoOp means the object already matched the desired state
+	ResultNoOp ReconcileResult = "NoOp"
+	// ResultSkippedExcluded means the object is excluded and was left untouched
+	ResultSkippedExcluded ReconcileResult = "SkippedExcluded"
+	// ResultFailed means the object could not be reconciled
+	ResultFailed ReconcileResult = "Failed"
+)
+
+// Changed reports whether the object was created or patched
+func (r ReconcileResult) Changed() bool {
+	return r == ResultCreated || r == ResultPatched
+}
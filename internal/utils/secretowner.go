@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+// ErrSecretOwnerConflict indicates that the imagePullSecret is owned by another Secret provider
+var ErrSecretOwnerConflict = errors.New("imagePullSecret is owned by another Secret provider")
+
+// Secret providers, which may own an imagePullSecret
+const (
+	SecretOwnerExternalSecrets = "external-secrets"
+	SecretOwnerSealedSecrets   = "sealed-secrets"
+)
+
+// secretOwnerMarker is an owner kind, label or annotation, which is set by a Secret provider on the Secrets it owns
+type secretOwnerMarker struct {
+	key      string
+	provider string
+}
+
+// secretOwnerKinds, secretOwnerLabels and secretOwnerAnnotations are set by the Secret providers on the Secrets they own.
+// They're checked in order, so the same provider is reported for a Secret on every reconcile.
+var (
+	secretOwnerKinds = []secretOwnerMarker{
+		{"external-secrets.io/ExternalSecret", SecretOwnerExternalSecrets},
+		{"bitnami.com/SealedSecret", SecretOwnerSealedSecrets},
+	}
+	secretOwnerLabels = []secretOwnerMarker{
+		{"reconcile.external-secrets.io/created-by", SecretOwnerExternalSecrets},
+	}
+	secretOwnerAnnotations = []secretOwnerMarker{
+		{"reconcile.external-secrets.io/data-hash", SecretOwnerExternalSecrets},
+		{"sealedsecrets.bitnami.com/managed", SecretOwnerSealedSecrets},
+	}
+)
+
+// SecretOwner returns the Secret provider, which owns obj, or an empty string
+func SecretOwner(obj client.Object) string {
+	for _, owner := range obj.GetOwnerReferences() {
+		group, _, _ := strings.Cut(owner.APIVersion, "/")
+		for _, marker := range secretOwnerKinds {
+			if marker.key == group+"/"+owner.Kind {
+				return marker.provider
+			}
+		}
+	}
+	for _, marker := range secretOwnerAnnotations {
+		if _, ok := obj.GetAnnotations()[marker.key]; ok {
+			return marker.provider
+		}
+	}
+	for _, marker := range secretOwnerLabels {
+		if _, ok := obj.GetLabels()[marker.key]; ok {
+			return marker.provider
+		}
+	}
+	return ""
+}
+
+// SecretOwnerConflictPolicy returns the configured policy for imagePullSecrets owned by provider
+func SecretOwnerConflictPolicy(c *config.Config, provider string) string {
+	if provider == SecretOwnerSealedSecrets {
+		return c.SealedSecretsConflictPolicy
+	}
+	return c.ExternalSecretsConflictPolicy
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_ReconcileImagePullSecret_SecretOwner(t *testing.T) {
+	dockerConfigJSON := `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`
+	newConfig := func(externalSecretsPolicy string, sealedSecretsPolicy string) *config.Config {
+		return config.NewConfig(config.ConfigOptions{
+			DockerConfigJSON:              dockerConfigJSON,
+			SecretNamespace:               "kube-system",
+			ExternalSecretsConflictPolicy: externalSecretsPolicy,
+			SealedSecretsConflictPolicy:   sealedSecretsPolicy,
+		})
+	}
+	upToDate, err := ConstructImagePullSecret(context.TODO(), fake.NewClientBuilder().Build(), newConfig("", ""), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	externalSecret := upToDate.DeepCopy()
+	externalSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	externalSecret.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "external-secrets.io/v1beta1", Kind: "ExternalSecret", Name: "registry", UID: "1"},
+	}
+	externalSecretLabeled := upToDate.DeepCopy()
+	externalSecretLabeled.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	externalSecretLabeled.Labels["reconcile.external-secrets.io/created-by"] = "abc"
+	sealedSecret := upToDate.DeepCopy()
+	sealedSecret.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	sealedSecret.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "bitnami.com/v1alpha1", Kind: "SealedSecret", Name: "registry", UID: "1"},
+	}
+
+	tests := []struct {
+		name                  string
+		externalSecretsPolicy string
+		sealedSecretsPolicy   string
+		secret                *corev1.Secret
+		wantOwner             string
+		want                  ReconcileResult
+		wantErr               error
+	}{
+		{
+			name:      "Secret not owned. Should be left untouched.",
+			secret:    upToDate,
+			wantOwner: "",
+			want:      ResultNoOp,
+		},
+		{
+			name:      "Secret owned by an ExternalSecret, default policy. Should be skipped.",
+			secret:    externalSecret,
+			wantOwner: SecretOwnerExternalSecrets,
+			want:      ResultSkippedExcluded,
+		},
+		{
+			name:                  "Secret labeled by the External Secrets Operator, adopt policy. Should be patched.",
+			externalSecretsPolicy: config.GitOpsConflictPolicyAdopt,
+			secret:                externalSecretLabeled,
+			wantOwner:             SecretOwnerExternalSecrets,
+			want:                  ResultPatched,
+		},
+		{
+			name:                  "Secret owned by a SealedSecret, alert policy. Should fail with ErrSecretOwnerConflict.",
+			externalSecretsPolicy: config.GitOpsConflictPolicyAdopt,
+			sealedSecretsPolicy:   config.GitOpsConflictPolicyAlert,
+			secret:                sealedSecret,
+			wantOwner:             SecretOwnerSealedSecrets,
+			want:                  ResultFailed,
+			wantErr:               ErrSecretOwnerConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SecretOwner(tt.secret); got != tt.wantOwner {
+				t.Errorf("SecretOwner() = %v, want %v", got, tt.wantOwner)
+			}
+
+			c := newConfig(tt.externalSecretsPolicy, tt.sealedSecretsPolicy)
+			k8sClient := fake.NewClientBuilder().WithObjects(tt.secret.DeepCopy()).Build()
+			got, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, "default")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReconcileImagePullSecret() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileImagePullSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+)
+
+// SignatureKey is the key of the detached signature in the source Secret
+const SignatureKey = ".dockerconfigjson.sig"
+
+// ErrInvalidSignature indicates that the signature of the credentials could not be verified
+var ErrInvalidSignature = errors.New("signature of dockerConfigJSON could not be verified")
+
+// VerifyCredentialSource verifies the detached signature of the dockerConfigJSON read from source,
+// if SignaturePublicKeyFile is set. Signatures are compatible with `cosign sign-blob`.
+// Credentials exchanged via OIDC are minted on demand, and can't carry a signature. Credentials served
+// at DockerConfigJSONURL are authenticated by TLS instead.
+func VerifyCredentialSource(ctx context.Context, k8sClient client.Client, c *config.Config, source string, dockerConfigJSON string) error {
+	if c.SignaturePublicKeyFile == "" || source == config.CredentialSourceOIDC || source == config.CredentialSourceURL {
+		return nil
+	}
+
+	signature, err := getSignature(ctx, k8sClient, c, source)
+	if err == nil {
+		err = VerifySignature(c.SignaturePublicKeyFile, []byte(dockerConfigJSON), signature)
+	}
+	if err != nil {
+		metrics.SignatureVerificationFailuresTotal.WithLabelValues(source).Inc()
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// getSignature reads the detached signature belonging to source
+func getSignature(ctx context.Context, k8sClient client.Client, c *config.Config, source string) ([]byte, error) {
+	switch source {
+	case config.CredentialSourceEnv:
+		if c.DockerConfigJSONSignature == "" {
+			return nil, fmt.Errorf("`CONFIG_DOCKERCONFIGJSON_SIGNATURE` is not set")
+		}
+		return []byte(c.DockerConfigJSONSignature), nil
+	case config.CredentialSourceFile:
+		filename, err := NormalizePath(c.DockerConfigJSONPath)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(filename + ".sig")
+	case config.CredentialSourceSecret:
+		secret := &corev1.Secret{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SourceSecret, Namespace: c.SecretNamespace}, secret); err != nil {
+			return nil, err
+		}
+		signature, ok := secret.Data[SignatureKey]
+		if !ok {
+			return nil, fmt.Errorf("Secret has no `%s` key", SignatureKey)
+		}
+		return signature, nil
+	}
+	return nil, fmt.Errorf("unknown credential source '%s'", source)
+}
+
+// VerifySignature verifies the base64 encoded signature over content, with the PEM encoded
+// ECDSA, Ed25519 or RSA public key in publicKeyFile
+func VerifySignature(publicKeyFile string, content []byte, signature []byte) error {
+	filename, err := NormalizePath(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	publicKeyPEM, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return fmt.Errorf("no PEM encoded public key found in %s", publicKeyFile)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	signature, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	digest := sha256.Sum256(content)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("ECDSA signature mismatch")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, content, signature) {
+			return fmt.Errorf("Ed25519 signature mismatch")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("RSA signature mismatch: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
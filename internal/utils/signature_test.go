@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePublicKey writes the PEM encoded public key to a temporary file
+func writePublicKey(t *testing.T, publicKey crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_VerifySignature(t *testing.T) {
+	content := []byte(`{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`)
+	digest := sha256.Sum256(content)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSignature, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519PublicKey, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Signature := ed25519.Sign(ed25519Key, content)
+
+	tests := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		content   []byte
+		signature []byte
+		wantErr   bool
+	}{
+		{
+			name:      "Valid ECDSA signature. Should verify.",
+			publicKey: &ecdsaKey.PublicKey,
+			content:   content,
+			signature: ecdsaSignature,
+		},
+		{
+			name:      "Valid Ed25519 signature. Should verify.",
+			publicKey: ed25519PublicKey,
+			content:   content,
+			signature: ed25519Signature,
+		},
+		{
+			name:      "Tampered content. Should fail.",
+			publicKey: &ecdsaKey.PublicKey,
+			content:   []byte(`{"auths":{}}`),
+			signature: ecdsaSignature,
+			wantErr:   true,
+		},
+		{
+			name:      "Signature of another key. Should fail.",
+			publicKey: &ecdsaKey.PublicKey,
+			content:   content,
+			signature: ed25519Signature,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signature := []byte(base64.StdEncoding.EncodeToString(tt.signature) + "\n")
+			err := VerifySignature(writePublicKey(t, tt.publicKey), tt.content, signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
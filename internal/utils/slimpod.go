@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SlimPod is a cache transform, which strips Pods down to the fields read by the Pod
+// cleanup and the image pull failure metrics. In clusters with many Pods, the containers,
+// volumes and managedFields make up most of the memory of the Pod cache. The annotations
+// and the statuses of the init containers are kept, as they decide about the cleanup.
+// Objects other than Pods are returned unchanged.
+func SlimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	slim := &corev1.Pod{
+		TypeMeta: pod.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              pod.Name,
+			Namespace:         pod.Namespace,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			CreationTimestamp: pod.CreationTimestamp,
+			DeletionTimestamp: pod.DeletionTimestamp,
+			Labels:            pod.Labels,
+			Annotations:       pod.Annotations,
+			OwnerReferences:   pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: pod.Spec.ServiceAccountName,
+			ImagePullSecrets:   pod.Spec.ImagePullSecrets,
+			Priority:           pod.Spec.Priority,
+		},
+		Status: corev1.PodStatus{
+			Phase:    pod.Status.Phase,
+			QOSClass: pod.Status.QOSClass,
+		},
+	}
+	slim.Status.InitContainerStatuses = slimContainerStatuses(pod.Status.InitContainerStatuses)
+	slim.Status.ContainerStatuses = slimContainerStatuses(pod.Status.ContainerStatuses)
+	return slim, nil
+}
+
+// slimContainerStatuses strips the container statuses down to the waiting state
+func slimContainerStatuses(containerStatuses []corev1.ContainerStatus) []corev1.ContainerStatus {
+	var slim []corev1.ContainerStatus
+	for _, containerStatus := range containerStatuses {
+		slim = append(slim, corev1.ContainerStatus{
+			Name:  containerStatus.Name,
+			Image: containerStatus.Image,
+			State: corev1.ContainerState{Waiting: containerStatus.State.Waiting},
+		})
+	}
+	return slim
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// benchmarkPod is a typical Pod of a Deployment, as served by the API server
+func benchmarkPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-7d9f8c6b5d-x2x4z",
+			Namespace: "team-a",
+			UID:       "0f6c4d4e-5b8a-4d3e-9a63-0c2a1f1b9e7d",
+			Labels:    map[string]string{"app": "app", "pod-template-hash": "7d9f8c6b5d"},
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/restartedAt": "2024-01-01T00:00:00Z",
+				"prometheus.io/scrape":              "true",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app-7d9f8c6b5d", Controller: ptr.To(true)},
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1"},
+				{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "v1", Subresource: "status"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "default",
+			ImagePullSecrets:   []corev1.LocalObjectReference{{Name: "image-pull-secret"}},
+			Priority:           ptr.To[int32](0),
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "registry.example.com/team-a/app:1.2.3",
+				Args:  []string{"--port=8080", "--log-level=info"},
+				Env: []corev1.EnvVar{
+					{Name: "LOG_FORMAT", Value: "json"},
+					{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				},
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "kube-api-access", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount", ReadOnly: true}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "kube-api-access",
+				VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+					{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: ptr.To[int64](3607)}},
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"}}},
+				}}},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:    corev1.PodPending,
+			QOSClass: corev1.PodQOSBurstable,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+				{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "ContainersNotReady"},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				Image: "registry.example.com/team-a/app:1.2.3",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: `Back-off pulling image "registry.example.com/team-a/app:1.2.3"`,
+				}},
+			}},
+		},
+	}
+}
+
+func Test_SlimPod(t *testing.T) {
+	pod := benchmarkPod()
+
+	obj, err := SlimPod(pod)
+	if err != nil {
+		t.Fatalf("SlimPod() error = %v", err)
+	}
+	slim := obj.(*corev1.Pod)
+
+	if len(slim.Spec.Containers) != 0 || len(slim.Spec.Volumes) != 0 || len(slim.ManagedFields) != 0 {
+		t.Errorf("SlimPod() kept containers, volumes or managedFields: %v", slim)
+	}
+	if slim.Annotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("SlimPod() dropped the annotations: %v", slim.Annotations)
+	}
+	if slim.Spec.ServiceAccountName != "default" || !hasPodImagePullSecret(slim, "image-pull-secret") {
+		t.Errorf("SlimPod() dropped the serviceAccountName or imagePullSecrets: %v", slim.Spec)
+	}
+	if reason, ok := GetImagePullFailureReason(slim); !ok || reason != "ImagePullBackOff" {
+		t.Errorf("GetImagePullFailureReason() = %v, %v, want ImagePullBackOff", reason, ok)
+	}
+	if metav1.GetControllerOf(slim) == nil || slim.Status.QOSClass != corev1.PodQOSBurstable {
+		t.Errorf("SlimPod() dropped the controller or QOS class: %v", slim)
+	}
+
+	// Init containers block the other containers, so their pull failures are cleaned up as well
+	pod = benchmarkPod()
+	pod.Status.InitContainerStatuses = pod.Status.ContainerStatuses
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}}}
+	obj, err = SlimPod(pod)
+	if err != nil {
+		t.Fatalf("SlimPod() error = %v", err)
+	}
+	if reason, ok := GetImagePullFailureReason(obj.(*corev1.Pod)); !ok || reason != "ImagePullBackOff" {
+		t.Errorf("GetImagePullFailureReason() of an init container = %v, %v, want ImagePullBackOff", reason, ok)
+	}
+
+	other := &corev1.Namespace{}
+	if obj, _ := SlimPod(other); obj != other {
+		t.Errorf("SlimPod() = %v, want non-Pods unchanged", obj)
+	}
+}
+
+// BenchmarkPodCache compares the memory retained per cached Pod, if Pods are cached in
+// full, stripped by SlimPod or as metadata only. Run with -benchtime=10000x for stable results.
+func BenchmarkPodCache(b *testing.B) {
+	data, err := json.Marshal(benchmarkPod())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	decode := func(b *testing.B, into func() interface{}) interface{} {
+		obj := into()
+		if err := json.Unmarshal(data, obj); err != nil {
+			b.Fatal(err)
+		}
+		return obj
+	}
+	benchmarks := []struct {
+		name      string
+		transform func(b *testing.B) interface{}
+	}{
+		{
+			name: "full",
+			transform: func(b *testing.B) interface{} {
+				return decode(b, func() interface{} { return &corev1.Pod{} })
+			},
+		},
+		{
+			name: "slim",
+			transform: func(b *testing.B) interface{} {
+				obj, _ := SlimPod(decode(b, func() interface{} { return &corev1.Pod{} }))
+				return obj
+			},
+		},
+		{
+			name: "metadata-only",
+			transform: func(b *testing.B) interface{} {
+				return decode(b, func() interface{} { return &metav1.PartialObjectMetadata{} })
+			},
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			cache := make([]interface{}, b.N)
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache[i] = bm.transform(b)
+			}
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N), "retained-B/pod")
+			runtime.KeepAlive(cache)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+)
+
+// conditionReasons holds the reasons of a condition type, if it's true and if it's false
+var conditionReasons = map[string][2]string{
+	imagepullsecretv1alpha1.ConditionSecretSynced:           {"Synced", "SyncFailed"},
+	imagepullsecretv1alpha1.ConditionServiceAccountsPatched: {"Attached", "PatchFailed"},
+	imagepullsecretv1alpha1.ConditionPodsCleaned:            {"Cleaned", "CleanupFailed"},
+}
+
+//+kubebuilder:rbac:groups=imagepullsecret.pborn.eu,resources=imagepullsecretstatuses,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=imagepullsecret.pborn.eu,resources=imagepullsecretstatuses/status,verbs=get;update;patch
+
+// RecordNamespaceCondition records the outcome of a reconciliation step as condition of the ImagePullSecretStatus
+// in namespace, which is created on demand, if FeatureNamespaceStatus is set. A non-nil err turns the condition false.
+// The status is informational only, so failing to write it is logged instead of failing the reconciliation.
+func RecordNamespaceCondition(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, conditionType string, err error) {
+	if !c.FeatureNamespaceStatus {
+		return
+	}
+
+	reasons := conditionReasons[conditionType]
+	condition := metav1.Condition{
+		Type:   conditionType,
+		Status: metav1.ConditionTrue,
+		Reason: reasons[0],
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasons[1]
+		condition.Message = redact.String(err.Error())
+	}
+	recordNamespaceCondition(ctx, k8sClient, c, namespace, condition)
+}
+
+// RecordSecretCondition records the result of reconciling the imagePullSecret in namespace as SecretSynced
+// condition, like RecordNamespaceCondition. A skipped imagePullSecret, e.g. one excluded or owned by a GitOps
+// tool, isn't synced, so the condition is false with the reason Skipped.
+func RecordSecretCondition(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, result ReconcileResult, err error) {
+	if !c.FeatureNamespaceStatus {
+		return
+	}
+	if err != nil || result != ResultSkippedExcluded {
+		RecordNamespaceCondition(ctx, k8sClient, c, namespace, imagepullsecretv1alpha1.ConditionSecretSynced, err)
+		return
+	}
+
+	recordNamespaceCondition(ctx, k8sClient, c, namespace, metav1.Condition{
+		Type:    imagepullsecretv1alpha1.ConditionSecretSynced,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Skipped",
+		Message: "The imagePullSecret is excluded or managed by someone else and left untouched",
+	})
+}
+
+// recordNamespaceCondition writes condition and logs, if that failed
+func recordNamespaceCondition(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, condition metav1.Condition) {
+	if err := setNamespaceCondition(ctx, k8sClient, c, namespace, condition); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update ImagePullSecretStatus in namespace '"+namespace+"'")
+	}
+}
+
+// PruneNamespaceStatuses deletes the ImagePullSecretStatus objects of namespaces, which are excluded by now,
+// and those named after a previous SecretName, if FeatureNamespaceStatus is set. The ones of deleted namespaces
+// are deleted along with the namespace. It returns the number of deleted objects.
+func PruneNamespaceStatuses(ctx context.Context, k8sClient client.Client, c *config.Config) (int, error) {
+	if !c.FeatureNamespaceStatus {
+		return 0, nil
+	}
+
+	statusList := &imagepullsecretv1alpha1.ImagePullSecretStatusList{}
+	if err := k8sClient.List(ctx, statusList, client.MatchingLabels{config.AnnotationManagedBy: config.AnnotationAppName}); err != nil {
+		return 0, fmt.Errorf("error listing ImagePullSecretStatuses: %w", err)
+	}
+
+	deleted := 0
+	for i := range statusList.Items {
+		status := &statusList.Items[i]
+		if status.GetName() == c.SecretName {
+			ns, err := FetchNamespace(ctx, k8sClient, status.GetNamespace())
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return deleted, err
+			}
+			// Paused namespaces are resumed eventually, and terminating ones take their objects with them
+			reason := NamespaceExclusionReason(c, ns)
+			if reason == "" || reason == ExclusionReasonPaused || !ns.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+		}
+
+		if err := k8sClient.Delete(ctx, status); err != nil && !apierrs.IsNotFound(err) {
+			return deleted, fmt.Errorf("error deleting ImagePullSecretStatus '%s' in namespace '%s': %w", status.GetName(), status.GetNamespace(), err)
+		}
+		log.FromContext(ctx).Info("Deleted ImagePullSecretStatus '" + status.GetName() + "' in namespace '" + status.GetNamespace() + "'")
+		deleted++
+	}
+	return deleted, nil
+}
+
+// setNamespaceCondition writes condition to the ImagePullSecretStatus in namespace, if it changed
+func setNamespaceCondition(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, condition metav1.Condition) error {
+	status := &imagepullsecretv1alpha1.ImagePullSecretStatus{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: namespace}, status)
+	if apierrs.IsNotFound(err) {
+		status = &imagepullsecretv1alpha1.ImagePullSecretStatus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.SecretName,
+				Namespace: namespace,
+				Labels:    map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			},
+		}
+		err = k8sClient.Create(ctx, status)
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := meta.SetStatusCondition(&status.Status.Conditions, condition)
+	if !changed && status.Status.SecretName == c.SecretName {
+		return nil
+	}
+	status.Status.SecretName = c.SecretName
+	return k8sClient.Status().Update(ctx, status)
+}
+
+// namespaceSyncRefreshInterval limits how often the last-sync annotation of a namespace is refreshed,
+// so successful syncs don't patch the namespace on every reconcile
+const namespaceSyncRefreshInterval = time.Minute
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;patch
+
+// RecordNamespaceSync annotates namespace with the time of the last successful sync of the imagePullSecret
+// and the error of the last failed one, if FeatureNamespaceSyncAnnotations is set. A successful sync removes
+// the last-error annotation. The annotations are informational only, so failing to write them is logged
+// instead of failing the reconciliation.
+func RecordNamespaceSync(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, err error) {
+	if !c.FeatureNamespaceSyncAnnotations {
+		return
+	}
+
+	if err := setNamespaceSyncAnnotations(ctx, k8sClient, c, namespace, err, time.Now()); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to annotate namespace '"+namespace+"' with the result of the sync")
+	}
+}
+
+// setNamespaceSyncAnnotations writes the result of the sync at now to the annotations of namespace, if it changed
+func setNamespaceSyncAnnotations(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, syncErr error, now time.Time) error {
+	ns, err := FetchNamespace(ctx, k8sClient, namespace)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patchFrom := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	if syncErr == nil {
+		lastSync, err := time.Parse(time.RFC3339, ns.Annotations[c.AnnotationLastSync])
+		_, failed := ns.Annotations[c.AnnotationLastError]
+		if err == nil && !failed && now.Sub(lastSync) < namespaceSyncRefreshInterval {
+			return nil
+		}
+		ns.Annotations[c.AnnotationLastSync] = now.UTC().Format(time.RFC3339)
+		delete(ns.Annotations, c.AnnotationLastError)
+	} else {
+		message := redact.String(syncErr.Error())
+		if ns.Annotations[c.AnnotationLastError] == message {
+			return nil
+		}
+		ns.Annotations[c.AnnotationLastError] = message
+	}
+	return k8sClient.Patch(ctx, ns, patchFrom)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagepullsecretv1alpha1 "github.com/tamcore/imagepullsecret-patcher/api/v1alpha1"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_RecordNamespaceCondition(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = imagepullsecretv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&imagepullsecretv1alpha1.ImagePullSecretStatus{}).
+		Build()
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureNamespaceStatus: true})
+	ctx := context.TODO()
+	key := types.NamespacedName{Name: c.SecretName, Namespace: "team-a"}
+
+	RecordNamespaceCondition(ctx, k8sClient, c, "team-a", imagepullsecretv1alpha1.ConditionSecretSynced, nil)
+	RecordNamespaceCondition(ctx, k8sClient, c, "team-a", imagepullsecretv1alpha1.ConditionPodsCleaned, errors.New("failed to delete Pod"))
+
+	status := &imagepullsecretv1alpha1.ImagePullSecretStatus{}
+	if err := k8sClient.Get(ctx, key, status); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if status.Status.SecretName != c.SecretName {
+		t.Errorf("SecretName = %q, want %q", status.Status.SecretName, c.SecretName)
+	}
+	if !meta.IsStatusConditionTrue(status.Status.Conditions, imagepullsecretv1alpha1.ConditionSecretSynced) {
+		t.Errorf("condition %s is not true", imagepullsecretv1alpha1.ConditionSecretSynced)
+	}
+	cleaned := meta.FindStatusCondition(status.Status.Conditions, imagepullsecretv1alpha1.ConditionPodsCleaned)
+	if cleaned == nil || cleaned.Status != metav1.ConditionFalse || cleaned.Reason != "CleanupFailed" || cleaned.Message != "failed to delete Pod" {
+		t.Errorf("condition %s = %v, want false with the error as message", imagepullsecretv1alpha1.ConditionPodsCleaned, cleaned)
+	}
+
+	// An unchanged condition isn't written again
+	resourceVersion := status.GetResourceVersion()
+	RecordNamespaceCondition(ctx, k8sClient, c, "team-a", imagepullsecretv1alpha1.ConditionSecretSynced, nil)
+	if err := k8sClient.Get(ctx, key, status); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if status.GetResourceVersion() != resourceVersion {
+		t.Errorf("ResourceVersion = %s, want the unchanged %s", status.GetResourceVersion(), resourceVersion)
+	}
+}
+
+func Test_RecordSecretCondition(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = imagepullsecretv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&imagepullsecretv1alpha1.ImagePullSecretStatus{}).
+		Build()
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureNamespaceStatus: true})
+	ctx := context.TODO()
+
+	tests := []struct {
+		name       string
+		result     ReconcileResult
+		err        error
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{"created", ResultCreated, nil, metav1.ConditionTrue, "Synced"},
+		{"skipped", ResultSkippedExcluded, nil, metav1.ConditionFalse, "Skipped"},
+		{"failed", ResultFailed, errors.New("failed to create Secret"), metav1.ConditionFalse, "SyncFailed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RecordSecretCondition(ctx, k8sClient, c, "team-a", tt.result, tt.err)
+
+			status := &imagepullsecretv1alpha1.ImagePullSecretStatus{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SecretName, Namespace: "team-a"}, status); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			synced := meta.FindStatusCondition(status.Status.Conditions, imagepullsecretv1alpha1.ConditionSecretSynced)
+			if synced == nil || synced.Status != tt.wantStatus || synced.Reason != tt.wantReason {
+				t.Errorf("condition %s = %v, want %s with reason %s", imagepullsecretv1alpha1.ConditionSecretSynced, synced, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_PruneNamespaceStatuses(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = imagepullsecretv1alpha1.AddToScheme(scheme)
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureNamespaceStatus: true})
+	status := func(name string, namespace string) *imagepullsecretv1alpha1.ImagePullSecretStatus {
+		return &imagepullsecretv1alpha1.ImagePullSecretStatus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{config.AnnotationManagedBy: config.AnnotationAppName},
+			},
+		}
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Annotations: map[string]string{c.ExcludeAnnotation: "true"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c", Annotations: map[string]string{c.AnnotationAdminPaused: "true"}}},
+			status(c.SecretName, "team-a"),
+			status("previous-imagepullsecret", "team-a"),
+			status(c.SecretName, "team-b"),
+			status(c.SecretName, "team-c"),
+		).
+		Build()
+	ctx := context.TODO()
+
+	deleted, err := PruneNamespaceStatuses(ctx, k8sClient, c)
+	if err != nil {
+		t.Fatalf("PruneNamespaceStatuses() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("PruneNamespaceStatuses() = %d, want 2", deleted)
+	}
+	statusList := &imagepullsecretv1alpha1.ImagePullSecretStatusList{}
+	if err := k8sClient.List(ctx, statusList); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	remaining := map[string]bool{}
+	for _, status := range statusList.Items {
+		remaining[status.GetNamespace()+"/"+status.GetName()] = true
+	}
+	want := map[string]bool{"team-a/" + c.SecretName: true, "team-c/" + c.SecretName: true}
+	if len(remaining) != len(want) || !remaining["team-a/"+c.SecretName] || !remaining["team-c/"+c.SecretName] {
+		t.Errorf("remaining ImagePullSecretStatuses = %v, want %v", remaining, want)
+	}
+}
+
+func Test_setNamespaceSyncAnnotations(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	k8sClient := fake.NewClientBuilder().WithObjects(ns).Build()
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureNamespaceSyncAnnotations: true})
+	ctx := context.TODO()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	annotations := func() map[string]string {
+		t.Helper()
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: "team-a"}, ns); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return ns.GetAnnotations()
+	}
+
+	steps := []struct {
+		name          string
+		err           error
+		at            time.Time
+		wantLastSync  string
+		wantLastError string
+	}{
+		{"successful sync", nil, now, "2024-01-01T12:00:00Z", ""},
+		{"successful sync within the refresh interval", nil, now.Add(30 * time.Second), "2024-01-01T12:00:00Z", ""},
+		{"failed sync keeps the last successful one", errors.New("failed to create Secret"), now.Add(2 * time.Minute), "2024-01-01T12:00:00Z", "failed to create Secret"},
+		{"successful sync clears the error", nil, now.Add(3 * time.Minute), "2024-01-01T12:03:00Z", ""},
+	}
+	for _, step := range steps {
+		if err := setNamespaceSyncAnnotations(ctx, k8sClient, c, "team-a", step.err, step.at); err != nil {
+			t.Fatalf("%s: setNamespaceSyncAnnotations() error = %v", step.name, err)
+		}
+		got := annotations()
+		if got[c.AnnotationLastSync] != step.wantLastSync || got[c.AnnotationLastError] != step.wantLastError {
+			t.Errorf("%s: annotations = %v, want last-sync %q and last-error %q", step.name, got, step.wantLastSync, step.wantLastError)
+		}
+	}
+
+	// Missing namespaces are ignored
+	if err := setNamespaceSyncAnnotations(ctx, k8sClient, c, "missing", nil, now); err != nil {
+		t.Errorf("setNamespaceSyncAnnotations() error = %v, want nil for a missing namespace", err)
+	}
+}
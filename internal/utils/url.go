@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/outbound"
+)
+
+// urlCredentials caches the dockerConfigJSON last fetched from DockerConfigJSONURL, together with its ETag.
+// fetchMu serializes the fetches, so mu is only held to read or swap the cached value, but not during a fetch.
+var urlCredentials struct {
+	fetchMu          sync.Mutex
+	mu               sync.Mutex
+	dockerConfigJSON credentialBuffer
+	etag             string
+}
+
+// GetDockerConfigJSONFromURL returns the dockerConfigJSON served at DockerConfigJSONURL. It's fetched on the
+// first read, and kept up to date by RefreshDockerConfigJSONFromURL afterwards.
+func GetDockerConfigJSONFromURL(ctx context.Context, c *config.Config) (string, error) {
+	if dockerConfigJSON, ok := cachedDockerConfigJSONURL(); ok {
+		return dockerConfigJSON, nil
+	}
+
+	urlCredentials.fetchMu.Lock()
+	defer urlCredentials.fetchMu.Unlock()
+	// Another caller may have fetched it in the meantime
+	if dockerConfigJSON, ok := cachedDockerConfigJSONURL(); ok {
+		return dockerConfigJSON, nil
+	}
+	if _, err := fetchDockerConfigJSONURL(ctx, c); err != nil {
+		return "", err
+	}
+	dockerConfigJSON, _ := cachedDockerConfigJSONURL()
+	return dockerConfigJSON, nil
+}
+
+// cachedDockerConfigJSONURL returns the cached dockerConfigJSON, and whether it was fetched already
+func cachedDockerConfigJSONURL() (string, bool) {
+	urlCredentials.mu.Lock()
+	defer urlCredentials.mu.Unlock()
+
+	if urlCredentials.dockerConfigJSON.IsEmpty() {
+		return "", false
+	}
+	return urlCredentials.dockerConfigJSON.Value(), true
+}
+
+// RefreshDockerConfigJSONFromURL fetches DockerConfigJSONURL, unless it's unchanged according to its ETag,
+// and reports whether the dockerConfigJSON changed
+func RefreshDockerConfigJSONFromURL(ctx context.Context, c *config.Config) (bool, error) {
+	urlCredentials.fetchMu.Lock()
+	defer urlCredentials.fetchMu.Unlock()
+
+	return fetchDockerConfigJSONURL(ctx, c)
+}
+
+// fetchDockerConfigJSONURL fetches DockerConfigJSONURL into urlCredentials. The caller must hold fetchMu.
+func fetchDockerConfigJSONURL(ctx context.Context, c *config.Config) (bool, error) {
+	httpClient, err := newURLHTTPClient(c)
+	if err != nil {
+		return false, err
+	}
+	defer httpClient.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.DockerConfigJSONURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.DockerConfigJSONURLAuthorization != "" {
+		req.Header.Set("Authorization", c.DockerConfigJSONURLAuthorization)
+	}
+	urlCredentials.mu.Lock()
+	if urlCredentials.etag != "" && !urlCredentials.dockerConfigJSON.IsEmpty() {
+		req.Header.Set("If-None-Match", urlCredentials.etag)
+	}
+	urlCredentials.mu.Unlock()
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	// The body isn't part of the error, as it may carry credentials
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("credential endpoint returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	defer clear(body)
+	if err != nil {
+		return false, err
+	}
+	b, err := DecryptDockerConfigJSON(c, body)
+	if err != nil {
+		return false, err
+	}
+	defer clear(b)
+
+	urlCredentials.mu.Lock()
+	defer urlCredentials.mu.Unlock()
+	urlCredentials.etag = resp.Header.Get("ETag")
+	return urlCredentials.dockerConfigJSON.Set(b), nil
+}
+
+// newURLHTTPClient returns a client, which trusts DockerConfigJSONURLCAFile in addition to the CAs of the outbound
+// transport and presents the client certificate, if configured. The files are read on every call, so rotations are
+// picked up.
+func newURLHTTPClient(c *config.Config) (*http.Client, error) {
+	transport, err := outbound.NewTransport()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := transport.TLSClientConfig
+	if c.DockerConfigJSONURLCAFile != "" {
+		caFile, err := NormalizePath(c.DockerConfigJSONURLCAFile)
+		if err != nil {
+			return nil, err
+		}
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := outbound.RootCAs()
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in '%s'", c.DockerConfigJSONURLCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.DockerConfigJSONURLCertFile != "" {
+		certFile, err := NormalizePath(c.DockerConfigJSONURLCertFile)
+		if err != nil {
+			return nil, err
+		}
+		keyFile, err := NormalizePath(c.DockerConfigJSONURLKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+)
+
+func Test_GetDockerConfigJSONFromURL(t *testing.T) {
+	urlCredentials.dockerConfigJSON.Reset()
+	urlCredentials.etag = ""
+
+	version := 1
+	fetches := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		etag := fmt.Sprintf(`"%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"auths":{"registry.example.com":{"auth":"%d"}}}`, version)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.Config{
+		DockerConfigJSONURL:              server.URL,
+		DockerConfigJSONURLCAFile:        caFile,
+		DockerConfigJSONURLAuthorization: "Bearer token",
+	}
+	ctx := context.Background()
+
+	for range 2 {
+		got, err := GetDockerConfigJSONFromURL(ctx, c)
+		if err != nil {
+			t.Fatalf("GetDockerConfigJSONFromURL() error = %v", err)
+		}
+		if want := `{"auths":{"registry.example.com":{"auth":"1"}}}`; got != want {
+			t.Errorf("GetDockerConfigJSONFromURL() = %v, want %v", got, want)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("GetDockerConfigJSONFromURL() fetched %d times, want 1", fetches)
+	}
+
+	if changed, err := RefreshDockerConfigJSONFromURL(ctx, c); err != nil || changed {
+		t.Errorf("RefreshDockerConfigJSONFromURL() = %v, %v, want unchanged", changed, err)
+	}
+
+	version = 2
+	if changed, err := RefreshDockerConfigJSONFromURL(ctx, c); err != nil || !changed {
+		t.Errorf("RefreshDockerConfigJSONFromURL() = %v, %v, want changed", changed, err)
+	}
+	if got, _ := GetDockerConfigJSONFromURL(ctx, c); got != `{"auths":{"registry.example.com":{"auth":"2"}}}` {
+		t.Errorf("GetDockerConfigJSONFromURL() = %v after refresh", got)
+	}
+
+	c.DockerConfigJSONURLAuthorization = "Bearer wrong"
+	if _, err := RefreshDockerConfigJSONFromURL(ctx, c); err == nil {
+		t.Errorf("RefreshDockerConfigJSONFromURL() expected error, if unauthorized")
+	}
+
+	c.DockerConfigJSONURLCAFile = ""
+	if _, err := RefreshDockerConfigJSONFromURL(ctx, c); err == nil {
+		t.Errorf("RefreshDockerConfigJSONFromURL() expected error, if the server certificate isn't trusted")
+	}
+}
@@ -17,24 +17,51 @@ limitations under the License.
 package utils
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/internal/conflict"
+	"github.com/tamcore/imagepullsecret-patcher/internal/eventlog"
+	"github.com/tamcore/imagepullsecret-patcher/internal/metrics"
+	"github.com/tamcore/imagepullsecret-patcher/internal/redact"
+	"github.com/tamcore/imagepullsecret-patcher/internal/watchdog"
 )
 
+// ManagedSecretsSelector selects Secrets by their managed-by label. Unlike a field index of the cache,
+// the label selector is also evaluated by the API server, e.g. when the cache is bypassed.
+var ManagedSecretsSelector = client.MatchingLabels{config.AnnotationManagedBy: config.AnnotationAppName}
+
+// ErrSecretTooLarge indicates that the dockerConfigJSON exceeds the size limit of Secrets
+var ErrSecretTooLarge = fmt.Errorf("dockerConfigJSON exceeds the maximum Secret size of %d bytes", corev1.MaxSecretSize)
+
+// ErrCredentialsInvalid indicates that the source credentials could not be read
+var ErrCredentialsInvalid = errors.New("source credentials are invalid")
+
+// ErrSecretQuotaExceeded indicates that a ResourceQuota does not allow to create another Secret in the namespace
+var ErrSecretQuotaExceeded = errors.New("ResourceQuota for Secrets is exhausted")
+
 func IsServiceAccountManaged(c *config.Config, namespace client.Object, serviceAccount client.Object) bool {
 	if IsNamespaceExcluded(c, namespace) || IsServiceAccountExcluded(c, serviceAccount) {
 		return false
@@ -46,12 +73,83 @@ func IsServiceAccountManaged(c *config.Config, namespace client.Object, serviceA
 	return false
 }
 
+// IsWorkloadManaged checks whether the imagePullSecret should be attached to the Pod template
+// of the workload, based on the namespace, the exclude annotation and WorkloadSelector.
+func IsWorkloadManaged(c *config.Config, namespace client.Object, workload client.Object) bool {
+	if IsNamespaceExcluded(c, namespace) || HasExcludeAnnotation(c, workload) {
+		return false
+	}
+	selector, err := labels.Parse(c.WorkloadSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(workload.GetLabels()))
+}
+
 func IsNamespaceExcluded(c *config.Config, namespace client.Object) bool {
-	if IsStringInList(namespace.GetName(), c.ExcludedNamespaces) {
+	if ns, ok := namespace.(*corev1.Namespace); ok && ns.Status.Phase == corev1.NamespaceTerminating {
 		return true
 	}
 
-	return HasAnnotation(namespace, c.ExcludeAnnotation, "true")
+	return NamespaceExclusionReason(c, namespace) != ""
+}
+
+// NamespaceExclusionReason returns why the namespace is intentionally excluded by the configuration, i.e.
+// ExclusionReasonOperator, ExclusionReasonGlob, ExclusionReasonSelector, ExclusionReasonAnnotation or
+// ExclusionReasonPaused, or an empty string. The namespace of the controller is handled according to
+// OperatorNamespacePolicy.
+func NamespaceExclusionReason(c *config.Config, namespace client.Object) string {
+	isOperatorNamespace := namespace.GetName() == c.OperatorNamespace
+	if isOperatorNamespace && c.OperatorNamespacePolicy == config.OperatorNamespacePolicyExclude {
+		return ExclusionReasonOperator
+	}
+	// The exclude annotation is honored even for an included namespace of the controller
+	isIncluded := isOperatorNamespace && c.OperatorNamespacePolicy == config.OperatorNamespacePolicyInclude
+	if !isIncluded && IsStringInList(namespace.GetName(), c.ExcludedNamespaces) {
+		return ExclusionReasonGlob
+	}
+	if !isIncluded && IsNamespaceLabelExcluded(c, namespace) {
+		return ExclusionReasonSelector
+	}
+	if HasExcludeAnnotation(c, namespace) {
+		return ExclusionReasonAnnotation
+	}
+	if HasAnnotation(namespace, c.AnnotationAdminPaused, "true") {
+		return ExclusionReasonPaused
+	}
+	return ""
+}
+
+// IsNamespaceLabelExcluded matches the value of the NamespaceLabel against the excluded
+// and included globs, for clusters where the namespace name doesn't carry the tenancy
+func IsNamespaceLabelExcluded(c *config.Config, namespace client.Object) bool {
+	if c.NamespaceLabel == "" {
+		return false
+	}
+	value, ok := namespace.GetLabels()[c.NamespaceLabel]
+	if c.ExcludedNamespaceLabelValues != "" && ok && IsStringInList(value, c.ExcludedNamespaceLabelValues) {
+		return true
+	}
+	if c.IncludedNamespaceLabelValues != "" && (!ok || !IsStringInList(value, c.IncludedNamespaceLabelValues)) {
+		return true
+	}
+	return false
+}
+
+// GetNamespaceMinAgeRemaining returns how long the namespace has to age, before it
+// is processed. This avoids racing controllers, which replace ServiceAccounts while
+// provisioning a namespace.
+func GetNamespaceMinAgeRemaining(c *config.Config, namespace client.Object) time.Duration {
+	if c.NamespaceMinAge <= 0 {
+		return 0
+	}
+	return c.NamespaceMinAge - time.Since(namespace.GetCreationTimestamp().Time)
+}
+
+// IsPodDeletionDisabled checks whether the namespace opted out of Pod cleanup,
+// while still receiving the imagePullSecret.
+func IsPodDeletionDisabled(c *config.Config, namespace client.Object) bool {
+	return HasAnnotation(namespace, c.NoPodDeleteAnnotation, "true")
 }
 
 func IsStringInList(find string, list string) bool {
@@ -65,21 +163,123 @@ func IsStringInList(find string, list string) bool {
 }
 
 func IsServiceAccountExcluded(c *config.Config, serviceAccount client.Object) bool {
-	return HasAnnotation(serviceAccount, c.ExcludeAnnotation, "true")
+	return HasExcludeAnnotation(c, serviceAccount)
 }
 
 func IsManagedSecret(c *config.Config, namespace client.Object, secret client.Object) bool {
-	if IsNamespaceExcluded(c, namespace) {
+	if IsNamespaceExcluded(c, namespace) || IsSourceOfTruth(c, secret) {
 		return false
 	}
 
+	return IsProfileSecret(c, secret)
+}
+
+// ProfileName returns the value of the profile label of the Secrets managed with c
+func ProfileName(c *config.Config) string {
+	if c.Profile == "" {
+		return config.DefaultProfile
+	}
+	return c.Profile
+}
+
+// SweepCheckpointName returns the name of the ConfigMap in SecretNamespace, which holds the progress of the
+// initial sweep. It's unique per profile.
+func SweepCheckpointName(c *config.Config) string {
+	return "imagepullsecret-patcher-sweep-" + ProfileName(c)
+}
+
+// IsProfileSecret checks whether secret carries the managed-by annotation and belongs to the profile of c.
+// Secrets created before profiles were introduced carry no profile label. They are attributed to the profile,
+// if it's the only one, or if their name matches SecretName, and are labeled on their next reconciliation.
+func IsProfileSecret(c *config.Config, secret client.Object) bool {
 	// Check whether secret has set annotation of name "app.kubernetes.io/managed-by"
 	// set to value equal to "imagepullsecret-patcher"
-	if HasAnnotation(secret, config.AnnotationManagedBy, config.AnnotationAppName) {
-		return true
+	if !HasAnnotation(secret, config.AnnotationManagedBy, config.AnnotationAppName) && !HasLabel(secret, config.AnnotationManagedBy, config.AnnotationAppName) {
+		return false
 	}
 
-	return secret.GetName() == c.SecretName && secret.GetNamespace() != c.SecretNamespace
+	if profile, ok := secret.GetLabels()[c.LabelProfile]; ok {
+		return profile == ProfileName(c)
+	}
+	return c.Profile == "" || secret.GetName() == c.SecretName
+}
+
+// IsSourceSecret checks whether the Secret is the source Secret referenced by CONFIG_SOURCE_SECRET
+func IsSourceSecret(c *config.Config, name string, namespace string) bool {
+	return c.SourceSecret != "" && name == c.SourceSecret && namespace == c.SecretNamespace
+}
+
+// IsSourceOfTruth checks whether secret is a source Secret, either the one referenced by CONFIG_SOURCE_SECRET
+// or one carrying the source annotation. Unlike IsSourceSecret, the annotation still matches, if the name of the
+// source equals a target name, or if the source belongs to another profile.
+func IsSourceOfTruth(c *config.Config, secret client.Object) bool {
+	return HasAnnotation(secret, c.AnnotationSource, "true") || IsSourceSecret(c, secret.GetName(), secret.GetNamespace())
+}
+
+// IsOrphanedSecret checks whether secret carries the profile label of c, but no longer matches the configured
+// SecretName or ExtraSecrets, e.g. after SecretName has been changed. Secrets without a profile label may belong
+// to another profile, whose name they carry, so they're never orphaned.
+func IsOrphanedSecret(c *config.Config, secret client.Object) bool {
+	return IsProfileSecret(c, secret) && HasLabel(secret, c.LabelProfile, ProfileName(c)) &&
+		secret.GetName() != c.SecretName && !IsExtraSecret(c, secret.GetName()) && !IsSourceOfTruth(c, secret)
+}
+
+// MarkSourceSecret sets the source annotation on the Secret referenced by CONFIG_SOURCE_SECRET, so it's
+// recognized as source of truth, even if it's renamed to or copied over a target name later on
+func MarkSourceSecret(ctx context.Context, k8sClient client.Client, c *config.Config) error {
+	if c.SourceSecret == "" {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: c.SourceSecret, Namespace: c.SecretNamespace}, secret)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("while fetching source Secret: %w", err)
+	}
+	if HasAnnotation(secret, c.AnnotationSource, "true") {
+		return nil
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	annotations := secret.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[c.AnnotationSource] = "true"
+	secret.SetAnnotations(annotations)
+	if err := k8sClient.Patch(ctx, secret, patch); err != nil {
+		return fmt.Errorf("Failed to annotate source Secret: %w", err)
+	}
+	log.FromContext(ctx).Info("Marked Secret '" + c.SourceSecret + "' in namespace '" + c.SecretNamespace + "' as source of truth")
+	return nil
+}
+
+func HasLabel(obj client.Object, labelKey string, labelValue string) bool {
+	value, ok := obj.GetLabels()[labelKey]
+	return ok && value == labelValue
+}
+
+// ExcludeAnnotations returns the keys listed in ExcludeAnnotation. The first one is our own,
+// further ones are usually legacy keys of a previously used tool.
+func ExcludeAnnotations(c *config.Config) []string {
+	keys := []string{}
+	for _, key := range strings.Split(c.ExcludeAnnotation, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// HasExcludeAnnotation checks whether any of the keys listed in ExcludeAnnotation is set to "true" on obj
+func HasExcludeAnnotation(c *config.Config, obj client.Object) bool {
+	for _, key := range ExcludeAnnotations(c) {
+		if HasAnnotation(obj, key, "true") {
+			return true
+		}
+	}
+	return false
 }
 
 func HasAnnotation(obj client.Object, annotationKey string, annotationValue string) bool {
@@ -94,6 +294,47 @@ func HasAnnotation(obj client.Object, annotationKey string, annotationValue stri
 	return false
 }
 
+// HasImagePullSecret checks whether the ServiceAccount references the imagePullSecret secretName.
+func HasImagePullSecret(sa *corev1.ServiceAccount, secretName string) bool {
+	for _, imagePullSecret := range sa.ImagePullSecrets {
+		if imagePullSecret.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPodImagePullSecret checks whether the Pod was admitted with the imagePullSecret secretName.
+func hasPodImagePullSecret(pod *corev1.Pod, secretName string) bool {
+	for _, imagePullSecret := range pod.Spec.ImagePullSecrets {
+		if imagePullSecret.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+//+imagepullsecret-patcher:rbac:optin=secret-namespace,groups=core,resources=namespaces,verbs=create
+
+// CreateSecretNamespace creates the secret namespace, if it doesn't exist yet. It is created
+// instead of looked up, as the cache of the client may not be started yet. The permission is granted
+// by the opt-in RBAC bundle secret-namespace.
+func CreateSecretNamespace(ctx context.Context, k8sClient client.Client, c *config.Config) error {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: c.SecretNamespace,
+		},
+	}
+	if err := k8sClient.Create(ctx, ns); err != nil {
+		if apierrs.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to create secret namespace: %w", err)
+	}
+	log.FromContext(ctx).Info("Created secret namespace '" + c.SecretNamespace + "'")
+	return nil
+}
+
 func FetchNamespace(ctx context.Context, client client.Client, namespaceName string) (*corev1.Namespace, error) {
 	ns := &corev1.Namespace{}
 	err := client.Get(ctx,
@@ -123,116 +364,637 @@ func FetchServiceAccount(ctx context.Context, client client.Client, namespace st
 	return sa, nil
 }
 
+//+imagepullsecret-patcher:rbac:bundles=delete-pods
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
+//+imagepullsecret-patcher:rbac:bundles=delete-pods
+//+kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+//+imagepullsecret-patcher:rbac:bundles=secret-only;serviceaccount-only
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// CleanupPodsForNamespace deletes the Pods of namespace stuck pulling their image, whose ServiceAccount references
+// the imagePullSecret. If the cleanup has to wait, e.g. for PodCleanupDelay, it returns after how long it's due.
+// The caller requeues the namespace then, and calls it again, while IsPodCleanupPending.
+func CleanupPodsForNamespace(ctx context.Context, c *config.Config, k8sClient client.Client, recorder record.EventRecorder, namespace string) (time.Duration, error) {
+	if deferPodCleanup(ctx, c, k8sClient, namespace) {
+		return 0, nil
+	}
+	if remaining := pendingPodCleanups.remaining(podCleanupKey(namespace, ""), c.PodCleanupDelay, time.Now()); remaining > 0 {
+		return remaining, nil
+	}
+
+	ns, err := FetchNamespace(ctx, k8sClient, namespace)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch namespace: %w", err)
+	}
+	if IsPodDeletionDisabled(c, ns) {
+		return 0, nil
+	}
 
-func CleanupPodsForNamespace(ctx context.Context, c *config.Config, k8sClient client.Client, namespace string) error {
 	podList := &corev1.PodList{}
 	if err := k8sClient.List(ctx, podList, client.InNamespace(namespace)); err != nil {
-		return fmt.Errorf("failed to fetch pods: %w", err)
+		return 0, fmt.Errorf("failed to fetch pods: %w", err)
 	}
 
-	for _, pod := range podList.Items {
-		ns, err := FetchNamespace(ctx, k8sClient, namespace)
-		if err != nil {
-			return fmt.Errorf("failed to fetch namespace: %w", err)
-		}
+	pods := []*corev1.Pod{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
 		sa, err := FetchServiceAccount(ctx, k8sClient, namespace, pod.Spec.ServiceAccountName)
 		if err != nil {
-			return fmt.Errorf("failed to fetch serviceAccount: %w", err)
+			return 0, fmt.Errorf("failed to fetch serviceAccount: %w", err)
 		}
 		if !IsServiceAccountManaged(c, ns, sa) {
 			continue
 		}
-
-		for _, containerStatus := range pod.Status.ContainerStatuses {
-			if containerStatus.State.Waiting != nil {
-				if containerStatus.State.Waiting.Reason == "ErrImagePull" || containerStatus.State.Waiting.Reason == "ImagePullBackOff" {
-					log.FromContext(ctx).Info("Deleting Pod " + pod.Name + " in " + pod.Namespace + " due to status " + containerStatus.State.Waiting.Reason)
-					if err := k8sClient.Delete(ctx, &pod); err != nil {
-						return fmt.Errorf("failed to delete Pod "+pod.Name+"in "+pod.Namespace+": %w", err)
-					}
-				}
-			}
+		// Deleting the Pod is pointless, as long as its recreation would not pick up the imagePullSecret
+		if !HasImagePullSecret(sa, c.SecretName) {
+			continue
 		}
+
+		pods = append(pods, pod)
 	}
 
-	return nil
+	// Critical Pods left for their turn are deleted, once requeued
+	wait, err := deletePods(ctx, c, k8sClient, recorder, ns, pods)
+	if wait > 0 {
+		pendingPodCleanups.schedule(podCleanupKey(namespace, ""), time.Now().Add(wait))
+	}
+	return wait, err
 }
 
-func CleanupPodsForSA(ctx context.Context, k8sClient client.Client, namespace string, serviceAccount string) error {
+// CleanupPodsForSA deletes the Pods of serviceAccount stuck pulling their image, which were admitted before the
+// imagePullSecret was attached. It returns after how long a waiting cleanup is due, like CleanupPodsForNamespace.
+func CleanupPodsForSA(ctx context.Context, c *config.Config, k8sClient client.Client, recorder record.EventRecorder, namespace string, serviceAccount string) (time.Duration, error) {
+	if deferPodCleanup(ctx, c, k8sClient, namespace) {
+		return 0, nil
+	}
+	if remaining := pendingPodCleanups.remaining(podCleanupKey(namespace, serviceAccount), c.PodCleanupDelay, time.Now()); remaining > 0 {
+		return remaining, nil
+	}
+
+	ns, err := FetchNamespace(ctx, k8sClient, namespace)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch namespace: %w", err)
+	}
+	if IsPodDeletionDisabled(c, ns) {
+		return 0, nil
+	}
+
 	podList := &corev1.PodList{}
 	if err := k8sClient.List(ctx, podList, client.InNamespace(namespace)); err != nil {
-		return fmt.Errorf("failed to fetch pods: %w", err)
+		return 0, fmt.Errorf("failed to fetch pods: %w", err)
 	}
 
-	for _, pod := range podList.Items {
-		if pod.Spec.ServiceAccountName != serviceAccount {
+	pods := []*corev1.Pod{}
+	for i := range podList.Items {
+		if podList.Items[i].Spec.ServiceAccountName != serviceAccount {
+			continue
+		}
+		// Pods admitted after the ServiceAccount was patched already carry the imagePullSecret
+		if hasPodImagePullSecret(&podList.Items[i], c.SecretName) {
 			continue
 		}
 
-		for _, containerStatus := range pod.Status.ContainerStatuses {
+		pods = append(pods, &podList.Items[i])
+	}
+
+	// Critical Pods left for their turn are deleted, once requeued
+	wait, err := deletePods(ctx, c, k8sClient, recorder, ns, pods)
+	if wait > 0 {
+		pendingPodCleanups.schedule(podCleanupKey(namespace, serviceAccount), time.Now().Add(wait))
+	}
+	return wait, err
+}
+
+// podCleanupPendingRetention is how long a due Pod cleanup is remembered, if it's never run, e.g. as the
+// namespace got excluded in the meantime
+const podCleanupPendingRetention = time.Hour
+
+// pendingPodCleanups holds the Pod cleanups waiting for PodCleanupDelay, which gives the kubelet time
+// to observe the imagePullSecret, before Pods are deleted
+var pendingPodCleanups = &podCleanupSchedule{due: map[string]time.Time{}}
+
+// podCleanupSchedule tracks, when the delayed Pod cleanups are due, so workers requeue instead of sleeping
+type podCleanupSchedule struct {
+	mu  sync.Mutex
+	due map[string]time.Time
+}
+
+// podCleanupKey identifies the Pod cleanup of serviceAccount, or of the whole namespace, if serviceAccount is empty
+func podCleanupKey(namespace string, serviceAccount string) string {
+	return namespace + "/" + serviceAccount
+}
+
+// remaining returns how long the cleanup of key has to wait for delay, or for its turn to delete critical Pods.
+// The first call starts the delay, the call after it has passed ends it.
+func (s *podCleanupSchedule) remaining(key string, delay time.Duration, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, due := range s.due {
+		if now.Sub(due) > podCleanupPendingRetention {
+			delete(s.due, k)
+		}
+	}
+	due, ok := s.due[key]
+	if !ok {
+		if delay <= 0 {
+			return 0
+		}
+		due = now.Add(delay)
+		s.due[key] = due
+	}
+	if remaining := due.Sub(now); remaining > 0 {
+		return remaining
+	}
+	delete(s.due, key)
+	return 0
+}
+
+// schedule makes the cleanup of key wait until due
+func (s *podCleanupSchedule) schedule(key string, due time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.due[key] = due
+}
+
+// pending checks whether the cleanup of key waits for its delay
+func (s *podCleanupSchedule) pending(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.due[key]
+	return ok
+}
+
+// IsPodCleanupPending checks whether a Pod cleanup of serviceAccount, or of the whole namespace, if
+// serviceAccount is empty, waits for PodCleanupDelay. Its reconcile has to run the cleanup again, once requeued.
+func IsPodCleanupPending(namespace string, serviceAccount string) bool {
+	return pendingPodCleanups.pending(podCleanupKey(namespace, serviceAccount))
+}
+
+// deletePods runs deletePodIfImagePullFailed for all Pods, using at most
+// PodCleanupParallelism workers and PodCleanupTimeout per Pod. Pods are deleted from the least
+// to the most important, critical Pods last, one at a time and at most once per PodCleanupCriticalInterval
+// across all namespaces. Instead of waiting for its turn, it returns after how long the next critical Pod is due.
+func deletePods(ctx context.Context, c *config.Config, k8sClient client.Client, recorder record.EventRecorder, namespace client.Object, pods []*corev1.Pod) (time.Duration, error) {
+	parallelism := c.PodCleanupParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	regular := []*corev1.Pod{}
+	critical := []*corev1.Pod{}
+	for _, pod := range pods {
+		if IsPodCritical(c, namespace, pod) {
+			critical = append(critical, pod)
+		} else {
+			regular = append(regular, pod)
+		}
+	}
+	sortPodsForCleanup(regular)
+	sortPodsForCleanup(critical)
+
+	deletePod := func(pod *corev1.Pod) error {
+		podCtx := ctx
+		if c.PodCleanupTimeout > 0 {
+			var cancel context.CancelFunc
+			podCtx, cancel = context.WithTimeout(ctx, c.PodCleanupTimeout)
+			defer cancel()
+		}
+		return deletePodIfImagePullFailed(podCtx, c, k8sClient, recorder, namespace, pod)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	workers := make(chan struct{}, parallelism)
+	for _, pod := range regular {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			if err := deletePod(pod); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, pod := range critical {
+		if _, ok := GetImagePullFailureReason(pod); !ok {
+			continue
+		}
+		if wait := criticalPodThrottle.Reserve(c.PodCleanupCriticalInterval); wait > 0 {
+			return wait, errors.Join(errs...)
+		}
+		if err := deletePod(pod); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return 0, errors.Join(errs...)
+}
+
+// deletePodIfImagePullFailed deletes the Pod, if one of its containers is stuck
+// pulling its image and all PodCleanupPolicies agree, e.g. as it is owned by a
+// controller that will recreate it.
+func deletePodIfImagePullFailed(ctx context.Context, c *config.Config, k8sClient client.Client, recorder record.EventRecorder, namespace client.Object, pod *corev1.Pod) error {
+	containerStatus, ok := getImagePullFailure(pod)
+	if !ok {
+		return nil
+	}
+	reason := containerStatus.State.Waiting.Reason
+	pcc := PodCleanupContext{Config: c, Namespace: namespace, Reason: reason, Image: containerStatus.Image}
+	if ok, policy, why := ShouldDeletePod(pod, pcc); !ok {
+		log.FromContext(ctx).Info("Skipping deletion of Pod "+pod.Name+" in "+pod.Namespace+" "+why, "policy", policy)
+		return nil
+	}
+
+	if c.FeatureEvictPods {
+		log.FromContext(ctx).Info("Evicting Pod " + pod.Name + " in " + pod.Namespace + " due to status " + reason)
+		if err := evictPod(ctx, k8sClient, pod); err != nil {
+			return fmt.Errorf("failed to evict Pod "+pod.Name+" in "+pod.Namespace+": %w", err)
+		}
+	} else {
+		log.FromContext(ctx).Info("Deleting Pod " + pod.Name + " in " + pod.Namespace + " due to status " + reason)
+		if err := k8sClient.Delete(ctx, pod); err != nil {
+			return fmt.Errorf("failed to delete Pod "+pod.Name+"in "+pod.Namespace+": %w", err)
+		}
+	}
+	metrics.PodsDeletedTotal.WithLabelValues(pod.Namespace, reason).Inc()
+	eventlog.Record(eventlog.ActionPodDeleted, pod.Namespace, pod.Name, reason)
+	recordPodDeletion(recorder, pod, reason)
+	return nil
+}
+
+// recordPodDeletion emits an Event on the controller of the Pod (or on the Pod itself,
+// if it has none), so owners of the workload know why their Pod was deleted.
+func recordPodDeletion(recorder record.EventRecorder, pod *corev1.Pod, reason string) {
+	if recorder == nil {
+		return
+	}
+	var obj runtime.Object = pod
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		obj = &metav1.PartialObjectMetadata{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: owner.APIVersion,
+				Kind:       owner.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      owner.Name,
+				Namespace: pod.Namespace,
+				UID:       owner.UID,
+			},
+		}
+	}
+	recorder.Eventf(obj, corev1.EventTypeNormal, "PodDeleted",
+		"Deleted Pod %s due to status %s, to be recreated to pick up imagePullSecret", pod.Name, reason)
+}
+
+// GetImagePullFailureReason returns the waiting reason of the first container
+// that is in ErrImagePull or ImagePullBackOff.
+func GetImagePullFailureReason(pod *corev1.Pod) (string, bool) {
+	containerStatus, ok := getImagePullFailure(pod)
+	if !ok {
+		return "", false
+	}
+	return containerStatus.State.Waiting.Reason, true
+}
+
+// getImagePullFailure returns the status of the first container that is in ErrImagePull or ImagePullBackOff.
+// Init containers are checked first, as they block the other containers from being pulled.
+func getImagePullFailure(pod *corev1.Pod) (corev1.ContainerStatus, bool) {
+	for _, containerStatuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, containerStatus := range containerStatuses {
 			if containerStatus.State.Waiting != nil {
 				if containerStatus.State.Waiting.Reason == "ErrImagePull" || containerStatus.State.Waiting.Reason == "ImagePullBackOff" {
-					log.FromContext(ctx).Info("Deleting Pod " + pod.Name + " in " + pod.Namespace + " due to status " + containerStatus.State.Waiting.Reason)
-					if err := k8sClient.Delete(ctx, &pod); err != nil {
-						return fmt.Errorf("failed to delete Pod "+pod.Name+"in "+pod.Namespace+": %w", err)
-					}
+					return containerStatus, true
 				}
 			}
 		}
 	}
+	return corev1.ContainerStatus{}, false
+}
 
-	return nil
+// IsPodOwnerAllowed checks whether the Pod is controlled by one of the owner kinds
+// configured in PodCleanupOwnerKinds. Bare Pods are only allowed,
+// if FeatureDeletePodsAnyOwner is enabled.
+func IsPodOwnerAllowed(c *config.Config, pod client.Object) bool {
+	if c.FeatureDeletePodsAnyOwner {
+		return true
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false
+	}
+	return IsStringInList(owner.Kind, c.PodCleanupOwnerKinds)
 }
 
-func ReconcileImagePullSecret(ctx context.Context, k8sClient client.Client, c *config.Config, secretName string, namespace string) (bool, error) {
-	desiredSecret, err := ConstructImagePullSecret(c, namespace)
+// IsDaemonSetPodInBackoff checks whether the Pod is controlled by a DaemonSet and younger
+// than DaemonSetPodCleanupBackoff. DaemonSet Pods are recreated right away, so deleting
+// them without a backoff ends in an endless churn loop until the image exists.
+func IsDaemonSetPodInBackoff(c *config.Config, pod client.Object) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "DaemonSet" {
+		return false
+	}
+	return time.Since(pod.GetCreationTimestamp().Time) < c.DaemonSetPodCleanupBackoff
+}
+
+// ReconcileImagePullSecret creates or patches the imagePullSecret in namespace to match the desired one
+func ReconcileImagePullSecret(ctx context.Context, k8sClient client.Client, c *config.Config, secretName string, namespace string) (ReconcileResult, error) {
+	// The imagePullSecret is provisioned externally in ServiceAccount-only mode
+	if c.FeatureServiceAccountOnly {
+		return ResultNoOp, nil
+	}
+	// The source Secret is never overwritten, if the namespace of the controller is managed
+	if IsSourceSecret(c, secretName, namespace) {
+		return ResultSkippedExcluded, nil
+	}
+
+	desiredSecret, err := ConstructImagePullSecret(ctx, k8sClient, c, namespace)
 	if err != nil {
-		return false, fmt.Errorf("Failed to construct imagePullSecret: %v", err)
+		return ResultFailed, fmt.Errorf("Failed to construct imagePullSecret: %w", err)
 	}
 
 	secret := &corev1.Secret{}
-	if err := k8sClient.Get(ctx,
-		types.NamespacedName{
-			Name:      secretName,
-			Namespace: namespace,
-		},
-		secret,
-	); err != nil {
-		if apierrs.IsNotFound(err) {
-			// If Secret does not exist create it right away and return
-			if err := k8sClient.Create(ctx, desiredSecret); err != nil {
-				return false, fmt.Errorf("Failed to create Secret: %v", err)
+	secretKey := types.NamespacedName{
+		Name:      secretName,
+		Namespace: namespace,
+	}
+	err = k8sClient.Get(ctx, secretKey, secret)
+	if apierrs.IsNotFound(err) {
+		// Don't retry a Create, which is going to be rejected by the ResourceQuota anyway
+		if c.FeatureCheckSecretQuota {
+			if quota, err := GetExhaustedSecretQuota(ctx, k8sClient, namespace); err != nil {
+				return ResultFailed, err
+			} else if quota != "" {
+				metrics.SecretQuotaExceededTotal.WithLabelValues(namespace).Inc()
+				return ResultFailed, fmt.Errorf("%w: ResourceQuota '%s'", ErrSecretQuotaExceeded, quota)
 			}
-			return true, nil
 		}
-		return false, fmt.Errorf("while fetching Secret: %v", err)
+		// If Secret does not exist create it right away and return
+		createdSecret := desiredSecret.DeepCopy()
+		err = k8sClient.Create(ctx, createdSecret)
+		if err == nil {
+			RecordOwnWrite(createdSecret)
+			eventlog.RecordHash(eventlog.ActionSecretCreated, namespace, desiredSecret.GetName(), "", secretDataHash(desiredSecret))
+			return ResultCreated, nil
+		}
+		if !apierrs.IsAlreadyExists(err) {
+			return ResultFailed, fmt.Errorf("Failed to create Secret: %v", err)
+		}
+
+		// Another worker created the Secret concurrently, e.g. for a second event of a new namespace.
+		// Wait for it to show up in the cache, and patch it instead.
+		log.FromContext(ctx).Info("Secret '" + secretName + "' in namespace '" + namespace + "' was created concurrently, patching it instead")
+		err = retry.OnError(retry.DefaultBackoff, apierrs.IsNotFound, func() error {
+			return k8sClient.Get(ctx, secretKey, secret)
+		})
+	}
+	if err != nil {
+		return ResultFailed, fmt.Errorf("while fetching Secret: %v", err)
+	}
+
+	// Source Secrets are never overwritten, even if they're named like the imagePullSecret
+	if IsSourceOfTruth(c, secret) {
+		return ResultSkippedExcluded, nil
+	}
+	// Secrets excluded with the annotation are managed by someone else
+	if HasExcludeAnnotation(c, secret) {
+		return ResultSkippedExcluded, nil
+	}
+	// Secrets claimed by ArgoCD or Flux would be reverted by them, after every patch. They're
+	// reconciled like any other Secret with the default adopt policy.
+	if tool := GitOpsTool(secret); tool != "" && c.GitOpsConflictPolicy != config.GitOpsConflictPolicyAdopt {
+		metrics.GitOpsConflictsTotal.WithLabelValues(tool).Inc()
+		switch c.GitOpsConflictPolicy {
+		case config.GitOpsConflictPolicySkip:
+			log.FromContext(ctx).Info("Secret '" + secret.GetName() + "' in namespace '" + namespace + "' is managed by " + tool + ", skipping")
+			return ResultSkippedExcluded, nil
+		case config.GitOpsConflictPolicyAlert:
+			return ResultFailed, fmt.Errorf("%w: Secret '%s' is managed by %s", ErrGitOpsConflict, secret.GetName(), tool)
+		}
+	}
+
+	// Secrets owned by the External Secrets Operator or Sealed Secrets would be reverted by them, after every patch
+	if provider := SecretOwner(secret); provider != "" && SecretOwnerConflictPolicy(c, provider) != config.GitOpsConflictPolicyAdopt {
+		metrics.SecretOwnerConflictsTotal.WithLabelValues(provider).Inc()
+		switch SecretOwnerConflictPolicy(c, provider) {
+		case config.GitOpsConflictPolicySkip:
+			log.FromContext(ctx).Info("Secret '" + secret.GetName() + "' in namespace '" + namespace + "' is owned by " + provider + ", skipping")
+			return ResultSkippedExcluded, nil
+		case config.GitOpsConflictPolicyAlert:
+			return ResultFailed, fmt.Errorf("%w: Secret '%s' is owned by %s", ErrSecretOwnerConflict, secret.GetName(), provider)
+		}
+	}
+
+	// Secrets of another profile are never taken over
+	if profile, ok := secret.GetLabels()[c.LabelProfile]; ok && profile != ProfileName(c) {
+		log.FromContext(ctx).Info("Secret '" + secret.GetName() + "' in namespace '" + namespace + "' belongs to profile '" + profile + "', skipping")
+		return ResultSkippedExcluded, nil
+	}
+
+	if recreate, err := isRecreateRequested(ctx, k8sClient, c, namespace, secret); err != nil {
+		return ResultFailed, err
+	} else if recreate {
+		return recreateImagePullSecret(ctx, k8sClient, c, secret, desiredSecret)
+	}
+
+	// Immutable Secrets can only be rotated, or made mutable again, by recreating them
+	if secret.Immutable != nil && *secret.Immutable &&
+		(!reflect.DeepEqual(secret.Data, desiredSecret.Data) || !c.FeatureImmutableSecrets) {
+		result, err := recreateImagePullSecret(ctx, k8sClient, c, secret, desiredSecret)
+		if err == nil && !reflect.DeepEqual(secret.Data, desiredSecret.Data) {
+			metrics.ObservePropagation(namespace)
+		}
+		return result, err
 	}
 
 	inClusterSecret := secret.DeepCopy()
 	patchFrom := client.MergeFrom(secret.DeepCopy())
 	secret.Annotations = desiredSecret.Annotations
 	secret.Data = desiredSecret.Data
+	secret.Immutable = desiredSecret.Immutable
+	// Merge our label, as other tools commonly label Secrets as well
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	for key, value := range desiredSecret.Labels {
+		secret.Labels[key] = value
+	}
 
 	doPatch := false
+	if !reflect.DeepEqual(inClusterSecret.Labels, secret.Labels) {
+		doPatch = true
+	}
 	if !reflect.DeepEqual(inClusterSecret.Annotations, desiredSecret.Annotations) {
 		doPatch = true
 	}
 	if !reflect.DeepEqual(inClusterSecret.Data, desiredSecret.Data) {
 		doPatch = true
 	}
-	if doPatch {
-		if err = k8sClient.Patch(ctx, secret, patchFrom); err != nil {
-			return false, fmt.Errorf("error while patching Secret '"+desiredSecret.GetName()+"' in namespace '"+desiredSecret.GetNamespace()+"': %v", err)
+	if !reflect.DeepEqual(inClusterSecret.Immutable, desiredSecret.Immutable) {
+		doPatch = true
+	}
+	if !doPatch {
+		return ResultNoOp, nil
+	}
+
+	conflict.Default.Observe(ctx, inClusterSecret, "Secret")
+	if err = k8sClient.Patch(ctx, secret, patchFrom); err != nil {
+		return ResultFailed, fmt.Errorf("error while patching Secret '"+desiredSecret.GetName()+"' in namespace '"+desiredSecret.GetNamespace()+"': %v", err)
+	}
+	RecordOwnWrite(secret)
+	if !reflect.DeepEqual(inClusterSecret.Data, desiredSecret.Data) {
+		metrics.ObservePropagation(namespace)
+	}
+	eventlog.RecordHash(eventlog.ActionSecretUpdated, namespace, secret.GetName(), "", secretDataHash(secret))
+	return ResultPatched, nil
+}
+
+// isRecreateRequested checks whether the recreate annotation is set on the namespace or on secret
+func isRecreateRequested(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string, secret *corev1.Secret) (bool, error) {
+	if HasAnnotation(secret, c.AnnotationRecreate, "true") {
+		return true, nil
+	}
+	ns, err := FetchNamespace(ctx, k8sClient, namespace)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return HasAnnotation(ns, c.AnnotationRecreate, "true"), nil
+}
+
+//+imagepullsecret-patcher:rbac:bundles=secret-only
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;patch
+
+// recreateImagePullSecret replaces secret with desiredSecret by deleting and creating it, instead of
+// patching it. Afterwards the recreate annotation is removed from the namespace.
+func recreateImagePullSecret(ctx context.Context, k8sClient client.Client, c *config.Config, secret *corev1.Secret, desiredSecret *corev1.Secret) (ReconcileResult, error) {
+	if err := k8sClient.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return ResultFailed, fmt.Errorf("Failed to delete Secret for recreation: %w", err)
+	}
+	if err := k8sClient.Create(ctx, desiredSecret); err != nil {
+		return ResultFailed, fmt.Errorf("Failed to recreate Secret: %w", err)
+	}
+	RecordOwnWrite(desiredSecret)
+	eventlog.RecordHash(eventlog.ActionSecretCreated, desiredSecret.GetNamespace(), desiredSecret.GetName(), "recreated", secretDataHash(desiredSecret))
+	log.FromContext(ctx).Info("Recreated Secret '" + desiredSecret.GetName() + "' in namespace '" + desiredSecret.GetNamespace() + "'")
+
+	ns, err := FetchNamespace(ctx, k8sClient, desiredSecret.GetNamespace())
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return ResultCreated, nil
 		}
+		return ResultFailed, err
 	}
-	return doPatch, nil
+	if HasAnnotation(ns, c.AnnotationRecreate, "true") {
+		patchFrom := client.MergeFrom(ns.DeepCopy())
+		delete(ns.Annotations, c.AnnotationRecreate)
+		if err := k8sClient.Patch(ctx, ns, patchFrom); err != nil {
+			return ResultFailed, fmt.Errorf("Failed to remove annotation %s from namespace: %w", c.AnnotationRecreate, err)
+		}
+	}
+	return ResultCreated, nil
 }
 
-func ConstructImagePullSecret(c *config.Config, namespace string) (*corev1.Secret, error) {
-	dockerConfigJSON, err := GetDockerConfigJSON(c)
+//+kubebuilder:rbac:groups=core,resources=resourcequotas,verbs=get;list;watch
+
+// GetExhaustedSecretQuota returns the name of a ResourceQuota in namespace, which does not allow
+// to create another Secret, or an empty string if there is none
+func GetExhaustedSecretQuota(ctx context.Context, k8sClient client.Client, namespace string) (string, error) {
+	quotaList := &corev1.ResourceQuotaList{}
+	if err := k8sClient.List(ctx, quotaList, client.InNamespace(namespace)); err != nil {
+		return "", fmt.Errorf("failed to list ResourceQuotas: %w", err)
+	}
+
+	for _, quota := range quotaList.Items {
+		for _, resourceName := range []corev1.ResourceName{corev1.ResourceSecrets, "count/secrets"} {
+			hard, ok := quota.Status.Hard[resourceName]
+			if !ok {
+				continue
+			}
+			used := quota.Status.Used[resourceName]
+			if used.Cmp(hard) >= 0 {
+				return quota.GetName(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// IsPaused checks whether mutations are halted, either by CONFIG_PAUSED or by the
+// paused annotation on the namespace of the controller. The annotation is taken from
+// PausedHandler, if it's registered, and only fetched otherwise, e.g. in verify mode.
+func IsPaused(ctx context.Context, k8sClient client.Client, c *config.Config) (bool, error) {
+	if c.Paused {
+		return true, nil
+	}
+	if operatorNamespacePaused.observed.Load() {
+		return operatorNamespacePaused.paused.Load(), nil
+	}
+	if c.OperatorNamespace == "" {
+		return false, nil
+	}
+	ns, err := FetchNamespace(ctx, k8sClient, c.OperatorNamespace)
 	if err != nil {
-		return nil, fmt.Errorf("Error while reading dockerConfigJSON from filesystem: %v", err)
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return HasAnnotation(ns, c.AnnotationPaused, "true"), nil
+}
+
+// HasImagePullSecretDrift checks whether the imagePullSecret in namespace is missing or differs from the desired one
+func HasImagePullSecretDrift(ctx context.Context, k8sClient client.Client, c *config.Config, secretName string, namespace string) (bool, error) {
+	// The imagePullSecret is provisioned externally in ServiceAccount-only mode
+	if c.FeatureServiceAccountOnly {
+		return false, nil
+	}
+	desiredSecret, err := ConstructImagePullSecret(ctx, k8sClient, c, namespace)
+	if err != nil {
+		return false, fmt.Errorf("Failed to construct imagePullSecret: %w", err)
+	}
+
+	secret := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret); err != nil {
+		if apierrs.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("while fetching Secret: %v", err)
+	}
+	return !reflect.DeepEqual(secret.Data, desiredSecret.Data), nil
+}
+
+// ReportDrift logs and counts drift of an object of kind, which is not corrected while paused
+func ReportDrift(ctx context.Context, kind string, namespace string, name string) {
+	log.FromContext(ctx).Info("Paused, not correcting drift of " + kind + " '" + name + "' in namespace '" + namespace + "'")
+	metrics.DriftDetectedTotal.WithLabelValues(namespace, kind).Inc()
+}
+
+func ConstructImagePullSecret(ctx context.Context, k8sClient client.Client, c *config.Config, namespace string) (*corev1.Secret, error) {
+	dockerConfigJSON, err := GetDockerConfigJSON(ctx, k8sClient, c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Error while reading dockerConfigJSON: %w", ErrCredentialsInvalid, redact.Error(err))
+	}
+	redact.Replace("dockerconfigjson", dockerConfigJSON)
+	if c.FeatureTemplateDockerConfigJSON {
+		if dockerConfigJSON, err = RenderDockerConfigJSON(dockerConfigJSON, namespace); err != nil {
+			return nil, redact.Error(err)
+		}
+	}
+
+	// Fail as a whole, instead of producing partially applied Secrets
+	if len(dockerConfigJSON) > corev1.MaxSecretSize {
+		metrics.SecretSizeExceededTotal.WithLabelValues(namespace).Inc()
+		return nil, fmt.Errorf("%w: %d bytes", ErrSecretTooLarge, len(dockerConfigJSON))
 	}
 
 	secret := &corev1.Secret{
@@ -241,6 +1003,11 @@ func ConstructImagePullSecret(c *config.Config, namespace string) (*corev1.Secre
 			Namespace: namespace,
 			Annotations: map[string]string{
 				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.AnnotationCredentialHash: dockerConfigJSONHash([]byte(dockerConfigJSON)),
+			},
+			Labels: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+				c.LabelProfile:             ProfileName(c),
 			},
 		},
 		Data: map[string][]byte{
@@ -248,35 +1015,162 @@ func ConstructImagePullSecret(c *config.Config, namespace string) (*corev1.Secre
 		},
 		Type: corev1.SecretTypeDockerConfigJson,
 	}
+	if c.FeatureImmutableSecrets {
+		immutable := true
+		secret.Immutable = &immutable
+	}
 
 	return secret, nil
 }
 
-func GetDockerConfigJSON(c *config.Config) (string, error) {
-	if c.DockerConfigJSON == "" && c.DockerConfigJSONPath == "" {
-		return "", fmt.Errorf("Neither `CONFIG_DOCKERCONFIGJSON or `CONFIG_DOCKERCONFIGJSONPATH defined.")
+// GetDockerConfigJSON returns the credentials to distribute. With FeatureVerifyRotatedCredentials, rotated
+// credentials are only returned, once their registries accepted them. With FeatureLastKnownGood, the last
+// credentials read successfully are returned, while the credential source is broken.
+func GetDockerConfigJSON(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	dockerConfigJSON, err := getDockerConfigJSON(ctx, k8sClient, c)
+	if err == nil {
+		dockerConfigJSON, err = verifyRotatedCredentials(ctx, c, dockerConfigJSON)
+	}
+	return withLastKnownGood(ctx, k8sClient, c, dockerConfigJSON, err)
+}
+
+func getDockerConfigJSON(ctx context.Context, k8sClient client.Client, c *config.Config) (string, error) {
+	if c.CredentialSources != "" {
+		return GetDockerConfigJSONFromChain(ctx, k8sClient, c)
+	}
+	if c.DockerConfigJSON == "" && c.DockerConfigJSONPath == "" && c.SourceSecret == "" && c.OIDCTokenEndpoint == "" && c.DockerConfigJSONURL == "" {
+		return "", fmt.Errorf("Neither `CONFIG_DOCKERCONFIGJSON`, `CONFIG_DOCKERCONFIGJSONPATH`, `CONFIG_SOURCE_SECRET`, `CONFIG_OIDC_TOKEN_ENDPOINT` nor `CONFIG_DOCKERCONFIGJSON_URL` defined.")
 	}
 	if c.DockerConfigJSON != "" && c.DockerConfigJSONPath != "" {
 		return "", fmt.Errorf("Cannot specify both `CONFIG_DOCKERCONFIGJSON` and `CONFIG_DOCKERCONFIGJSONPATH`")
 	}
-	if c.DockerConfigJSON != "" {
+
+	source := credentialSource(c)
+	dockerConfigJSON, err := readCredentialSource(ctx, k8sClient, c, source)
+	if err != nil {
+		return "", err
+	}
+	if err := VerifyCredentialSource(ctx, k8sClient, c, source, dockerConfigJSON); err != nil {
+		return "", err
+	}
+	return dockerConfigJSON, nil
+}
+
+// credentialSource returns the credential source configured without CredentialSources
+func credentialSource(c *config.Config) string {
+	switch {
+	case c.DockerConfigJSON != "":
+		return config.CredentialSourceEnv
+	case c.SourceSecret != "":
+		return config.CredentialSourceSecret
+	case c.OIDCTokenEndpoint != "":
+		return config.CredentialSourceOIDC
+	case c.DockerConfigJSONURL != "":
+		return config.CredentialSourceURL
+	}
+	return config.CredentialSourceFile
+}
+
+// readCredentialSource reads the dockerConfigJSON of a single credential source
+func readCredentialSource(ctx context.Context, k8sClient client.Client, c *config.Config, source string) (string, error) {
+	switch source {
+	case config.CredentialSourceEnv:
 		return c.DockerConfigJSON, nil
+	case config.CredentialSourceFile:
+		return GetDockerConfigJSONFromFile(c)
+	case config.CredentialSourceSecret:
+		return GetDockerConfigJSONFromSecret(ctx, k8sClient, c, c.SecretNamespace, c.SourceSecret)
+	case config.CredentialSourceOIDC:
+		return GetDockerConfigJSONFromOIDC(ctx, c)
+	case config.CredentialSourceURL:
+		return GetDockerConfigJSONFromURL(ctx, c)
+	}
+	return "", fmt.Errorf("unknown credential source '%s'", source)
+}
+
+// fileReadAttempts is how often a credential file, which is empty or can't be parsed, is read,
+// before the error is returned
+const fileReadAttempts = 5
+
+// fileReadRetryInterval is the interval between two reads of a credential file, before jitter is applied
+var fileReadRetryInterval = 100 * time.Millisecond
+
+// GetDockerConfigJSONFromFile reads and, if required, decrypts the file referenced by DockerConfigJSONPath.
+// Updates of mounted Secrets may briefly expose an empty or partially written file. Such files are
+// read again with jitter, so a rotation never propagates broken credentials cluster-wide.
+func GetDockerConfigJSONFromFile(c *config.Config) (string, error) {
+	for attempt := 1; ; attempt++ {
+		dockerConfigJSON, err := readDockerConfigJSONFile(c)
+		if err == nil {
+			return dockerConfigJSON, nil
+		}
+		// A missing file is not transient, it's only created once
+		if os.IsNotExist(err) || attempt >= fileReadAttempts {
+			return "", err
+		}
+		time.Sleep(wait.Jitter(fileReadRetryInterval, 1.0))
+	}
+}
+
+// readDockerConfigJSONFile reads, decrypts and validates the file referenced by DockerConfigJSONPath once
+func readDockerConfigJSONFile(c *config.Config) (string, error) {
+	filename, err := NormalizePath(c.DockerConfigJSONPath)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filename)
+	defer clear(content)
+	if err != nil {
+		return "", err
+	}
+	b, err := DecryptDockerConfigJSON(c, content)
+	defer clear(b)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return "", fmt.Errorf("credential file '%s' is empty", c.DockerConfigJSONPath)
+	}
+	// Templates are only valid JSON, once rendered per namespace
+	if !c.FeatureTemplateDockerConfigJSON && !json.Valid(b) {
+		return "", fmt.Errorf("credential file '%s' is not valid JSON", c.DockerConfigJSONPath)
 	}
-	b, ok := os.ReadFile(c.DockerConfigJSONPath)
-	return string(b), ok
+	return string(b), nil
 }
 
-func WaitUntilFileChanges(filename string) {
-	initialStat, _ := os.Stat(filename)
+// fileErrorLogInterval is the minimum interval, in which WaitUntilFileChanges logs errors checking the file
+const fileErrorLogInterval = time.Minute
+
+// WaitUntilFileChanges blocks until filename changes, and returns its new modification time.
+// A file, that doesn't exist yet, changes once it's created.
+// It returns ctx.Err(), if ctx is cancelled before that.
+func WaitUntilFileChanges(ctx context.Context, filename string) (time.Time, error) {
+	filename, err := NormalizePath(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	initialVersion, _ := getFileVersion(filename)
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	var lastLogged time.Time
 	for {
-		time.Sleep(1 * time.Second)
-		stat, err := os.Stat(filename)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+		watchdog.Beat(ctx)
+		version, err := getFileVersion(filename)
 		if err != nil {
-			fmt.Println("Error:", err)
+			// The file is checked every second, so a missing file would flood the logs otherwise
+			if time.Since(lastLogged) >= fileErrorLogInterval {
+				log.FromContext(ctx).Error(err, "error checking '"+filename+"' for changes")
+				lastLogged = time.Now()
+			}
 			continue
 		}
-		if stat.ModTime() != initialStat.ModTime() {
-			return
+		if version != initialVersion {
+			return version.modTime, nil
 		}
 	}
 }
@@ -17,11 +17,24 @@ limitations under the License.
 package utils
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	"github.com/tamcore/imagepullsecret-patcher/internal/config"
 )
@@ -99,6 +112,27 @@ func Test_IsServiceAccountManaged(t *testing.T) {
 			"*",
 			False,
 		},
+		{
+			"Namespace terminating. ServiceAccount not excluded. Should be unmanaged = false.",
+			args{
+				&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "default",
+					},
+					Status: corev1.NamespaceStatus{
+						Phase: corev1.NamespaceTerminating,
+					},
+				},
+				&corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "default",
+						Namespace: "default",
+					},
+				},
+			},
+			"*",
+			False,
+		},
 		{
 			"Namespace not excluded. ServiceAccount excluded. Should be unmanaged = false.",
 			args{
@@ -164,6 +198,26 @@ func Test_IsManagedSecret(t *testing.T) {
 			},
 			True,
 		},
+		{
+			"Namespace not excluded. Secret has required labels. Should be managed = true.",
+			args{
+				&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "default",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "default",
+						Namespace: "default",
+						Labels: map[string]string{
+							config.AnnotationManagedBy: config.AnnotationAppName,
+						},
+					},
+				},
+			},
+			True,
+		},
 		{
 			"Namespace not excluded. Secret does not have required annotations. Should be unmanaged = false.",
 			args{
@@ -197,6 +251,27 @@ func Test_IsManagedSecret(t *testing.T) {
 			},
 			False,
 		},
+		{
+			"Namespace not excluded. Secret has required annotations, but is annotated as source. Should be unmanaged = false.",
+			args{
+				&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "default",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      config.SecretName,
+						Namespace: "default",
+						Annotations: map[string]string{
+							config.AnnotationManagedBy: config.AnnotationAppName,
+							config.AnnotationSource:    "true",
+						},
+					},
+				},
+			},
+			False,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -207,6 +282,233 @@ func Test_IsManagedSecret(t *testing.T) {
 	}
 }
 
+func Test_IsOrphanedSecret(t *testing.T) {
+	config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	profileLabels := map[string]string{config.LabelProfile: ProfileName(config)}
+	tests := []struct {
+		name   string
+		secret client.Object
+		want   bool
+	}{
+		{
+			"Secret has required annotations and matches SecretName. Should be orphaned = false.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.SecretName,
+					Namespace: "default",
+					Annotations: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+					},
+				},
+			},
+			False,
+		},
+		{
+			"Secret has required annotations and the profile label, but a different name. Should be orphaned = true.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "old-imagepullsecret",
+					Namespace: "default",
+					Annotations: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+					},
+					Labels: profileLabels,
+				},
+			},
+			True,
+		},
+		{
+			"Secret has required annotations and a different name, but no profile label. Should be orphaned = false.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "old-imagepullsecret",
+					Namespace: "default",
+					Annotations: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+					},
+				},
+			},
+			False,
+		},
+		{
+			"Secret does not have required annotations. Should be orphaned = false.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unrelated",
+					Namespace: "default",
+				},
+			},
+			False,
+		},
+		{
+			"Secret has required annotations and a different name, but is annotated as source. Should be orphaned = false.",
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "team-b-imagepullsecret",
+					Namespace: "default",
+					Annotations: map[string]string{
+						config.AnnotationManagedBy: config.AnnotationAppName,
+						config.AnnotationSource:    "true",
+					},
+				},
+			},
+			False,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOrphanedSecret(config, tt.secret); got != tt.want {
+				t.Errorf("IsOrphanedSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IsProfileSecret(t *testing.T) {
+	singleProfile := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	multiProfile := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", Profile: "team-a"})
+	newSecret := func(name string, profile string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+				},
+			},
+		}
+		if profile != "" {
+			secret.Labels[singleProfile.LabelProfile] = profile
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name   string
+		config *config.Config
+		secret client.Object
+		want   bool
+	}{
+		{
+			"Secret of the default profile. Should belong to the default profile = true.",
+			singleProfile,
+			newSecret("old-imagepullsecret", config.DefaultProfile),
+			True,
+		},
+		{
+			"Secret of another profile. Should belong to the default profile = false.",
+			singleProfile,
+			newSecret(singleProfile.SecretName, "team-a"),
+			False,
+		},
+		{
+			"Secret without profile label. Should belong to the only profile = true.",
+			singleProfile,
+			newSecret("old-imagepullsecret", ""),
+			True,
+		},
+		{
+			"Secret of the profile. Should belong to the profile = true.",
+			multiProfile,
+			newSecret("old-imagepullsecret", "team-a"),
+			True,
+		},
+		{
+			"Secret without profile label, matching SecretName. Should belong to the profile = true.",
+			multiProfile,
+			newSecret(multiProfile.SecretName, ""),
+			True,
+		},
+		{
+			"Secret without profile label, not matching SecretName. Should belong to the profile = false.",
+			multiProfile,
+			newSecret("old-imagepullsecret", ""),
+			False,
+		},
+		{
+			"Secret without managed-by label. Should belong to the profile = false.",
+			multiProfile,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      multiProfile.SecretName,
+					Namespace: "default",
+					Labels:    map[string]string{multiProfile.LabelProfile: "team-a"},
+				},
+			},
+			False,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsProfileSecret(tt.config, tt.secret); got != tt.want {
+				t.Errorf("IsProfileSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IsOrphanedSecret_TwoProfiles(t *testing.T) {
+	teamA := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", SecretName: "team-a-imagepullsecret"})
+	teamB := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", SecretName: "team-b-imagepullsecret", Profile: "team-b"})
+	newSecret := func(name string, profile string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					config.AnnotationManagedBy: config.AnnotationAppName,
+				},
+			},
+		}
+		if profile != "" {
+			secret.Labels[teamA.LabelProfile] = profile
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name   string
+		secret client.Object
+		teamA  bool
+		teamB  bool
+	}{
+		{
+			"Unlabeled Secret of the named profile. Should not be orphaned by the default profile.",
+			newSecret(teamB.SecretName, ""),
+			False,
+			False,
+		},
+		{
+			"Unlabeled Secret of neither profile. Should not be orphaned by any profile.",
+			newSecret("old-imagepullsecret", ""),
+			False,
+			False,
+		},
+		{
+			"Secret of the default profile with a previous name. Should only be orphaned by the default profile.",
+			newSecret("old-imagepullsecret", config.DefaultProfile),
+			True,
+			False,
+		},
+		{
+			"Secret of the named profile with a previous name. Should only be orphaned by the named profile.",
+			newSecret("old-imagepullsecret", "team-b"),
+			False,
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOrphanedSecret(teamA, tt.secret); got != tt.teamA {
+				t.Errorf("IsOrphanedSecret() of the default profile = %v, want %v", got, tt.teamA)
+			}
+			if got := IsOrphanedSecret(teamB, tt.secret); got != tt.teamB {
+				t.Errorf("IsOrphanedSecret() of profile team-b = %v, want %v", got, tt.teamB)
+			}
+		})
+	}
+}
+
 func Test_HasAnnotation(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -249,3 +551,997 @@ func Test_HasAnnotation(t *testing.T) {
 		})
 	}
 }
+
+func Test_HasExcludeAnnotation(t *testing.T) {
+	tests := []struct {
+		name              string
+		excludeAnnotation string
+		annotations       map[string]string
+		want              bool
+	}{
+		{
+			name:              "Single key set. Should be excluded.",
+			excludeAnnotation: "pborn.eu/imagepullsecret-patcher-exclude",
+			annotations:       map[string]string{"pborn.eu/imagepullsecret-patcher-exclude": "true"},
+			want:              true,
+		},
+		{
+			name:              "Legacy key set. Should be excluded.",
+			excludeAnnotation: "pborn.eu/imagepullsecret-patcher-exclude, legacy.example.com/exclude",
+			annotations:       map[string]string{"legacy.example.com/exclude": "true"},
+			want:              true,
+		},
+		{
+			name:              "Legacy key set to false. Should not be excluded.",
+			excludeAnnotation: "pborn.eu/imagepullsecret-patcher-exclude,legacy.example.com/exclude",
+			annotations:       map[string]string{"legacy.example.com/exclude": "false"},
+			want:              false,
+		},
+		{
+			name:              "Unlisted key set. Should not be excluded.",
+			excludeAnnotation: "pborn.eu/imagepullsecret-patcher-exclude",
+			annotations:       map[string]string{"legacy.example.com/exclude": "true"},
+			want:              false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config.Config{ExcludeAnnotation: tt.excludeAnnotation}
+			obj := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: tt.annotations}}
+			if got := HasExcludeAnnotation(c, obj); got != tt.want {
+				t.Errorf("HasExcludeAnnotation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_AnnotationDomain(t *testing.T) {
+	tests := []struct {
+		name             string
+		annotationDomain string
+		annotations      map[string]string
+		want             bool
+	}{
+		{
+			name:             "Default domain. Should be excluded and paused.",
+			annotationDomain: "",
+			annotations: map[string]string{
+				"pborn.eu/imagepullsecret-patcher-exclude": "true",
+				"pborn.eu/imagepullsecret-patcher-paused":  "true",
+			},
+			want: true,
+		},
+		{
+			name:             "Custom domain with trailing slash. Should be excluded and paused.",
+			annotationDomain: "imagepullsecret.mycompany.io/",
+			annotations: map[string]string{
+				"imagepullsecret.mycompany.io/imagepullsecret-patcher-exclude": "true",
+				"imagepullsecret.mycompany.io/imagepullsecret-patcher-paused":  "true",
+			},
+			want: true,
+		},
+		{
+			name:             "Custom domain, default annotations set. Should not be excluded or paused.",
+			annotationDomain: "imagepullsecret.mycompany.io",
+			annotations: map[string]string{
+				"pborn.eu/imagepullsecret-patcher-exclude": "true",
+				"pborn.eu/imagepullsecret-patcher-paused":  "true",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "registry", OperatorNamespace: "kube-system", AnnotationDomain: tt.annotationDomain})
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Annotations: tt.annotations}}
+			if got := HasExcludeAnnotation(c, ns); got != tt.want {
+				t.Errorf("HasExcludeAnnotation() = %v, want %v", got, tt.want)
+			}
+			paused, err := IsPaused(context.TODO(), fake.NewClientBuilder().WithObjects(ns).Build(), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if paused != tt.want {
+				t.Errorf("IsPaused() = %v, want %v", paused, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IsPodOwnerAllowed(t *testing.T) {
+	tests := []struct {
+		name                      string
+		ownerKind                 string
+		featureDeletePodsAnyOwner bool
+		want                      bool
+	}{
+		{
+			"Pod owned by ReplicaSet. Should be allowed = true.",
+			"ReplicaSet",
+			False,
+			True,
+		},
+		{
+			"Pod owned by Job. Should be allowed = false.",
+			"Job",
+			False,
+			False,
+		},
+		{
+			"Bare Pod. Should be allowed = false.",
+			"",
+			False,
+			False,
+		},
+		{
+			"Bare Pod with any owner override. Should be allowed = true.",
+			"",
+			True,
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", FeatureDeletePodsAnyOwner: tt.featureDeletePodsAnyOwner})
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod",
+					Namespace: "default",
+				},
+			}
+			if tt.ownerKind != "" {
+				pod.OwnerReferences = []metav1.OwnerReference{
+					{
+						Kind:       tt.ownerKind,
+						Name:       "owner",
+						Controller: &True,
+					},
+				}
+			}
+
+			if got := IsPodOwnerAllowed(config, pod); got != tt.want {
+				t.Errorf("IsPodOwnerAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_HasImagePullSecret(t *testing.T) {
+	tests := []struct {
+		name             string
+		imagePullSecrets []corev1.LocalObjectReference
+		want             bool
+	}{
+		{
+			"No imagePullSecrets attached. Should be false.",
+			nil,
+			False,
+		},
+		{
+			"Other imagePullSecret attached. Should be false.",
+			[]corev1.LocalObjectReference{{Name: "other"}},
+			False,
+		},
+		{
+			"Managed imagePullSecret attached. Should be true.",
+			[]corev1.LocalObjectReference{{Name: "other"}, {Name: "global-imagepullsecret"}},
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sa := &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default",
+					Namespace: "default",
+				},
+				ImagePullSecrets: tt.imagePullSecrets,
+			}
+			if got := HasImagePullSecret(sa, "global-imagepullsecret"); got != tt.want {
+				t.Errorf("HasImagePullSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_CleanupPodsForSA(t *testing.T) {
+	newPod := func(name string, ownerKind string, reason string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "default",
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "app",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: reason},
+						},
+					},
+				},
+			},
+		}
+		if ownerKind != "" {
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{Kind: ownerKind, Name: "owner", Controller: &True},
+			}
+		}
+		return pod
+	}
+
+	tests := []struct {
+		name        string
+		pod         *corev1.Pod
+		wantDeleted bool
+	}{
+		{"ReplicaSet Pod in ImagePullBackOff. Should be deleted.", newPod("rs-backoff", "ReplicaSet", "ImagePullBackOff"), True},
+		{"StatefulSet Pod in ErrImagePull. Should be deleted.", newPod("sts-errimagepull", "StatefulSet", "ErrImagePull"), True},
+		{"ReplicaSet Pod in ContainerCreating. Should be kept.", newPod("rs-creating", "ReplicaSet", "ContainerCreating"), False},
+		{"Job Pod in ImagePullBackOff. Should be kept.", newPod("job-backoff", "Job", "ImagePullBackOff"), False},
+		{"Bare Pod in ImagePullBackOff. Should be kept.", newPod("bare-backoff", "", "ImagePullBackOff"), False},
+	}
+
+	k8sClient := fake.NewClientBuilder().WithObjects(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}).Build()
+	for _, tt := range tests {
+		if err := k8sClient.Create(context.TODO(), tt.pod); err != nil {
+			t.Fatalf("failed to create Pod: %v", err)
+		}
+	}
+
+	config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", PodCleanupParallelism: 2})
+	if _, err := CleanupPodsForSA(context.TODO(), config, k8sClient, record.NewFakeRecorder(len(tests)), "default", "default"); err != nil {
+		t.Fatalf("CleanupPodsForSA() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: tt.pod.Name, Namespace: tt.pod.Namespace}, &corev1.Pod{})
+			if deleted := apierrs.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("Pod deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func Test_podCleanupSchedule(t *testing.T) {
+	s := &podCleanupSchedule{due: map[string]time.Time{}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := podCleanupKey("default", "default")
+
+	if got := s.remaining(key, 0, now); got != 0 {
+		t.Errorf("remaining() without delay = %v, want 0", got)
+	}
+	if s.pending(key) {
+		t.Errorf("pending() without delay = true, want false")
+	}
+
+	if got := s.remaining(key, time.Minute, now); got != time.Minute {
+		t.Errorf("remaining() on first call = %v, want %v", got, time.Minute)
+	}
+	if got := s.remaining(key, time.Minute, now.Add(20*time.Second)); got != 40*time.Second {
+		t.Errorf("remaining() while waiting = %v, want %v", got, 40*time.Second)
+	}
+	if !s.pending(key) {
+		t.Errorf("pending() while waiting = false, want true")
+	}
+	if got := s.remaining(key, time.Minute, now.Add(time.Minute)); got != 0 {
+		t.Errorf("remaining() once due = %v, want 0", got)
+	}
+	if s.pending(key) {
+		t.Errorf("pending() once due = true, want false")
+	}
+
+	// Cleanups waiting for their turn to delete critical Pods are pending without a delay
+	s.schedule(key, now.Add(10*time.Second))
+	if !s.pending(key) {
+		t.Errorf("pending() while waiting for its turn = false, want true")
+	}
+	if got := s.remaining(key, 0, now); got != 10*time.Second {
+		t.Errorf("remaining() while waiting for its turn = %v, want %v", got, 10*time.Second)
+	}
+	if got := s.remaining(key, 0, now.Add(10*time.Second)); got != 0 || s.pending(key) {
+		t.Errorf("remaining() on its turn = %v, want 0 and the cleanup to be no longer pending", got)
+	}
+
+	// Cleanups, which are never run again, are forgotten
+	s.remaining(key, time.Minute, now)
+	s.remaining(podCleanupKey("other", ""), time.Minute, now.Add(2*podCleanupPendingRetention))
+	if s.pending(key) {
+		t.Errorf("pending() after retention = true, want false")
+	}
+}
+
+func Test_GetNamespaceMinAgeRemaining(t *testing.T) {
+	tests := []struct {
+		name        string
+		minAge      time.Duration
+		age         time.Duration
+		wantWaiting bool
+	}{
+		{
+			"Namespace younger than the minimum age. Should wait = true.",
+			10 * time.Minute,
+			10*time.Minute - time.Second,
+			True,
+		},
+		{
+			"Namespace at the minimum age. Should wait = false.",
+			10 * time.Minute,
+			10 * time.Minute,
+			False,
+		},
+		{
+			"Namespace older than the minimum age. Should wait = false.",
+			10 * time.Minute,
+			10*time.Minute + time.Second,
+			False,
+		},
+		{
+			"Minimum age disabled. Should wait = false.",
+			0,
+			0,
+			False,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", NamespaceMinAge: tt.minAge})
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "default",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-tt.age)),
+				},
+			}
+
+			got := GetNamespaceMinAgeRemaining(config, namespace)
+			if waiting := got > 0; waiting != tt.wantWaiting {
+				t.Errorf("GetNamespaceMinAgeRemaining() = %v, want waiting = %v", got, tt.wantWaiting)
+			}
+			if got > tt.minAge-tt.age {
+				t.Errorf("GetNamespaceMinAgeRemaining() = %v, want at most %v", got, tt.minAge-tt.age)
+			}
+		})
+	}
+}
+
+func Test_IsDaemonSetPodInBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		ownerKind string
+		age       time.Duration
+		want      bool
+	}{
+		{
+			"Young DaemonSet Pod. Should be in backoff = true.",
+			"DaemonSet",
+			time.Minute,
+			True,
+		},
+		{
+			"Old DaemonSet Pod. Should be in backoff = false.",
+			"DaemonSet",
+			time.Hour,
+			False,
+		},
+		{
+			"Young ReplicaSet Pod. Should be in backoff = false.",
+			"ReplicaSet",
+			time.Minute,
+			False,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system", DaemonSetPodCleanupBackoff: 10 * time.Minute})
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "pod",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-tt.age)),
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: tt.ownerKind, Name: "owner", Controller: &True},
+					},
+				},
+			}
+
+			if got := IsDaemonSetPodInBackoff(config, pod); got != tt.want {
+				t.Errorf("IsDaemonSetPodInBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_IsPodDeletionDisabled(t *testing.T) {
+	config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: "xx", SecretNamespace: "kube-system"})
+	tests := []struct {
+		name      string
+		namespace client.Object
+		want      bool
+	}{
+		{
+			"Namespace without annotation. Should be disabled = false.",
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+				},
+			},
+			False,
+		},
+		{
+			"Namespace with no-pod-delete annotation. Should be disabled = true.",
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+					Annotations: map[string]string{
+						"pborn.eu/imagepullsecret-patcher-no-pod-delete": "true",
+					},
+				},
+			},
+			True,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPodDeletionDisabled(config, tt.namespace); got != tt.want {
+				t.Errorf("IsPodDeletionDisabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ConstructImagePullSecret(t *testing.T) {
+	tests := []struct {
+		name             string
+		dockerConfigJSON string
+		wantErr          error
+	}{
+		{
+			"dockerConfigJSON within size limit. Should succeed.",
+			`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`,
+			nil,
+		},
+		{
+			"dockerConfigJSON exceeding size limit. Should fail with ErrSecretTooLarge.",
+			`{"auths":{"example.com":{"auth":"` + strings.Repeat("a", corev1.MaxSecretSize) + `"}}}`,
+			ErrSecretTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.NewConfig(config.ConfigOptions{DockerConfigJSON: tt.dockerConfigJSON, SecretNamespace: "kube-system"})
+			_, err := ConstructImagePullSecret(context.TODO(), fake.NewClientBuilder().Build(), config, "default")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ConstructImagePullSecret() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_GetExhaustedSecretQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota *corev1.ResourceQuota
+		want  string
+	}{
+		{
+			name: "No ResourceQuota. Should return none.",
+			want: "",
+		},
+		{
+			name: "count/secrets exhausted. Should return the ResourceQuota.",
+			quota: &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{"count/secrets": resource.MustParse("2")},
+					Used: corev1.ResourceList{"count/secrets": resource.MustParse("2")},
+				},
+			},
+			want: "quota",
+		},
+		{
+			name: "secrets exhausted. Should return the ResourceQuota.",
+			quota: &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{corev1.ResourceSecrets: resource.MustParse("2")},
+					Used: corev1.ResourceList{corev1.ResourceSecrets: resource.MustParse("3")},
+				},
+			},
+			want: "quota",
+		},
+		{
+			name: "count/secrets not exhausted. Should return none.",
+			quota: &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{"count/secrets": resource.MustParse("2")},
+					Used: corev1.ResourceList{"count/secrets": resource.MustParse("1")},
+				},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if tt.quota != nil {
+				builder = builder.WithObjects(tt.quota)
+			}
+			got, err := GetExhaustedSecretQuota(context.TODO(), builder.Build(), "default")
+			if err != nil {
+				t.Fatalf("GetExhaustedSecretQuota() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetExhaustedSecretQuota() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_CreateSecretNamespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+	}{
+		{
+			name: "Namespace missing. Should create it.",
+		},
+		{
+			name:     "Namespace exists. Should keep it.",
+			existing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if tt.existing {
+				builder = builder.WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "registry"}})
+			}
+			k8sClient := builder.Build()
+			if err := CreateSecretNamespace(context.TODO(), k8sClient, &config.Config{SecretNamespace: "registry"}); err != nil {
+				t.Fatalf("CreateSecretNamespace() error = %v", err)
+			}
+			if _, err := FetchNamespace(context.TODO(), k8sClient, "registry"); err != nil {
+				t.Errorf("FetchNamespace() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_IsNamespaceLabelExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  config.ConfigOptions
+		labels   map[string]string
+		excluded bool
+	}{
+		{
+			name:     "No namespace label configured. Should not be excluded.",
+			options:  config.ConfigOptions{ExcludedNamespaceLabelValues: "acme*"},
+			labels:   map[string]string{"tenant": "acme-prod"},
+			excluded: false,
+		},
+		{
+			name:     "Label value matches excluded glob. Should be excluded.",
+			options:  config.ConfigOptions{NamespaceLabel: "tenant", ExcludedNamespaceLabelValues: "acme*"},
+			labels:   map[string]string{"tenant": "acme-prod"},
+			excluded: true,
+		},
+		{
+			name:     "Label value does not match excluded glob. Should not be excluded.",
+			options:  config.ConfigOptions{NamespaceLabel: "tenant", ExcludedNamespaceLabelValues: "acme*"},
+			labels:   map[string]string{"tenant": "globex"},
+			excluded: false,
+		},
+		{
+			name:     "Label value matches included glob. Should not be excluded.",
+			options:  config.ConfigOptions{NamespaceLabel: "tenant", IncludedNamespaceLabelValues: "acme*,globex"},
+			labels:   map[string]string{"tenant": "globex"},
+			excluded: false,
+		},
+		{
+			name:     "Label value does not match included glob. Should be excluded.",
+			options:  config.ConfigOptions{NamespaceLabel: "tenant", IncludedNamespaceLabelValues: "acme*"},
+			labels:   map[string]string{"tenant": "globex"},
+			excluded: true,
+		},
+		{
+			name:     "Label missing with included globs. Should be excluded.",
+			options:  config.ConfigOptions{NamespaceLabel: "tenant", IncludedNamespaceLabelValues: "acme*"},
+			labels:   nil,
+			excluded: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.DockerConfigJSON = "xx"
+			tt.options.SecretNamespace = "kube-system"
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "9b1deb4d-3b7d-4bad-9bdd-2b0d7b3dcb6d",
+					Labels: tt.labels,
+				},
+			}
+			if got := IsNamespaceExcluded(config.NewConfig(tt.options), namespace); got != tt.excluded {
+				t.Errorf("IsNamespaceExcluded() = %v, want %v", got, tt.excluded)
+			}
+		})
+	}
+}
+
+func Test_ReconcileImagePullSecret(t *testing.T) {
+	dockerConfigJSON := `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: dockerConfigJSON, SecretNamespace: "kube-system"})
+	upToDate, err := ConstructImagePullSecret(context.TODO(), fake.NewClientBuilder().Build(), c, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated := upToDate.DeepCopy()
+	outdated.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	excluded := outdated.DeepCopy()
+	excluded.Annotations[c.ExcludeAnnotation] = "true"
+	recreate := upToDate.DeepCopy()
+	recreate.Annotations[c.AnnotationRecreate] = "true"
+	legacy := upToDate.DeepCopy()
+	delete(legacy.Labels, c.LabelProfile)
+	otherProfile := outdated.DeepCopy()
+	otherProfile.Labels[c.LabelProfile] = "other"
+	recreateNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{c.AnnotationRecreate: "true"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		secret    *corev1.Secret
+		namespace *corev1.Namespace
+		want      ReconcileResult
+	}{
+		{
+			name:   "Secret missing. Should be created.",
+			secret: nil,
+			want:   ResultCreated,
+		},
+		{
+			name:   "Secret up to date. Should be left untouched.",
+			secret: upToDate,
+			want:   ResultNoOp,
+		},
+		{
+			name:   "Secret outdated. Should be patched.",
+			secret: outdated,
+			want:   ResultPatched,
+		},
+		{
+			name:   "Secret outdated, but excluded. Should be skipped.",
+			secret: excluded,
+			want:   ResultSkippedExcluded,
+		},
+		{
+			name:   "Secret created before profiles were introduced. Should be labeled.",
+			secret: legacy,
+			want:   ResultPatched,
+		},
+		{
+			name:   "Secret outdated, but of another profile. Should be skipped.",
+			secret: otherProfile,
+			want:   ResultSkippedExcluded,
+		},
+		{
+			name:   "Secret annotated for recreation. Should be recreated.",
+			secret: recreate,
+			want:   ResultCreated,
+		},
+		{
+			name:      "Namespace annotated for recreation. Should be recreated.",
+			secret:    upToDate,
+			namespace: recreateNamespace,
+			want:      ResultCreated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if tt.secret != nil {
+				builder = builder.WithObjects(tt.secret.DeepCopy())
+			}
+			if tt.namespace != nil {
+				builder = builder.WithObjects(tt.namespace.DeepCopy())
+			}
+			k8sClient := builder.Build()
+			got, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, "default")
+			if err != nil {
+				t.Fatalf("ReconcileImagePullSecret() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileImagePullSecret() = %v, want %v", got, tt.want)
+			}
+
+			secret := &corev1.Secret{}
+			if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: c.SecretName, Namespace: "default"}, secret); err != nil {
+				t.Fatalf("Secret not found after reconciling: %v", err)
+			}
+			if HasAnnotation(secret, c.AnnotationRecreate, "true") {
+				t.Errorf("Secret still carries annotation %s", c.AnnotationRecreate)
+			}
+			if tt.namespace != nil {
+				ns, err := FetchNamespace(context.TODO(), k8sClient, "default")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if HasAnnotation(ns, c.AnnotationRecreate, "true") {
+					t.Errorf("Namespace still carries annotation %s", c.AnnotationRecreate)
+				}
+			}
+		})
+	}
+}
+
+func Test_ReconcileImagePullSecret_CreateRace(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{DockerConfigJSON: `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`, SecretNamespace: "kube-system"})
+
+	// Both workers miss the Secret in the cache, before either of them created it
+	var staleGets sync.WaitGroup
+	staleGets.Add(2)
+	var staleGetsLeft atomic.Int32
+	staleGetsLeft.Store(2)
+	k8sClient := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, wrapped client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Secret); ok && staleGetsLeft.Add(-1) >= 0 {
+				staleGets.Done()
+				staleGets.Wait()
+				return apierrs.NewNotFound(corev1.Resource("secrets"), key.Name)
+			}
+			return wrapped.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+
+	results := make(chan ReconcileResult, 2)
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			result, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, "default")
+			results <- result
+			errs <- err
+		}()
+	}
+
+	got := []ReconcileResult{}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("ReconcileImagePullSecret() error = %v", err)
+		}
+		got = append(got, <-results)
+	}
+	if !((got[0] == ResultCreated && got[1] == ResultNoOp) || (got[0] == ResultNoOp && got[1] == ResultCreated)) {
+		t.Errorf("ReconcileImagePullSecret() = %v, want one %v and one %v", got, ResultCreated, ResultNoOp)
+	}
+}
+
+func Test_ReconcileImagePullSecret_SourceSecret(t *testing.T) {
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "global-imagepullsecret", Namespace: "imagepullsecret-patcher"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`),
+		},
+	}
+	c := config.NewConfig(config.ConfigOptions{
+		SourceSecret:            source.GetName(),
+		SecretNamespace:         source.GetNamespace(),
+		OperatorNamespacePolicy: config.OperatorNamespacePolicyInclude,
+	})
+	k8sClient := fake.NewClientBuilder().WithObjects(source.DeepCopy()).Build()
+
+	result, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, source.GetNamespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ResultSkippedExcluded {
+		t.Errorf("ReconcileImagePullSecret() = %v, want %v", result, ResultSkippedExcluded)
+	}
+	got := &corev1.Secret{}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(source), got); err != nil {
+		t.Fatal(err)
+	}
+	if HasLabel(got, config.AnnotationManagedBy, config.AnnotationAppName) {
+		t.Errorf("Source Secret was taken over")
+	}
+}
+
+func Test_ReconcileImagePullSecret_SourceOfTruth(t *testing.T) {
+	// The source Secret carries the name of the imagePullSecret and was copied by an earlier version of the
+	// controller, so it looks like a managed Secret
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "imagepullsecret",
+			Namespace: "imagepullsecret-patcher",
+			Labels: map[string]string{
+				config.AnnotationManagedBy: config.AnnotationAppName,
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`),
+		},
+	}
+	c := config.NewConfig(config.ConfigOptions{
+		SourceSecret:            source.GetName(),
+		SecretName:              source.GetName(),
+		SecretNamespace:         source.GetNamespace(),
+		OperatorNamespacePolicy: config.OperatorNamespacePolicyInclude,
+	})
+	k8sClient := fake.NewClientBuilder().WithObjects(source.DeepCopy()).Build()
+	ctx := context.TODO()
+
+	if err := MarkSourceSecret(ctx, k8sClient, c); err != nil {
+		t.Fatal(err)
+	}
+	marked := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(source), marked); err != nil {
+		t.Fatal(err)
+	}
+	if !HasAnnotation(marked, c.AnnotationSource, "true") {
+		t.Fatalf("Source Secret was not annotated as source of truth")
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: source.GetNamespace()}}
+	if IsManagedSecret(c, ns, marked) || IsOrphanedSecret(c, marked) {
+		t.Errorf("Source Secret is managed or orphaned")
+	}
+
+	// Rotating the credentials must neither overwrite the source, nor be triggered by a copy of it,
+	// even once the source was moved and the annotation is the only thing left to recognize it
+	moved := config.NewConfig(config.ConfigOptions{
+		SourceSecret:            "rotated-imagepullsecret",
+		SecretName:              source.GetName(),
+		SecretNamespace:         source.GetNamespace(),
+		OperatorNamespacePolicy: config.OperatorNamespacePolicyInclude,
+	})
+	for _, c := range []*config.Config{c, moved} {
+		if IsManagedSecret(c, ns, marked) {
+			t.Errorf("Source Secret is managed with SourceSecret '%s'", c.SourceSecret)
+		}
+	}
+	rotated := source.DeepCopy()
+	rotated.SetName(moved.SourceSecret)
+	rotated.SetLabels(nil)
+	rotated.Data = map[string][]byte{
+		corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"YmF6OnF1eA=="}}}`),
+	}
+	if err := k8sClient.Create(ctx, rotated); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ReconcileImagePullSecret(ctx, k8sClient, moved, moved.SecretName, source.GetNamespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != ResultSkippedExcluded {
+		t.Errorf("ReconcileImagePullSecret() = %v, want %v", result, ResultSkippedExcluded)
+	}
+	got := &corev1.Secret{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(source), got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Data, source.Data) {
+		t.Errorf("Source Secret was overwritten")
+	}
+}
+
+func Test_ReconcileImagePullSecret_ServiceAccountOnly(t *testing.T) {
+	c := config.NewConfig(config.ConfigOptions{
+		SecretNamespace:           "imagepullsecret-patcher",
+		FeatureServiceAccountOnly: true,
+	})
+	external := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: c.SecretName, Namespace: "default"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`),
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(external.DeepCopy()).Build()
+
+	for _, namespace := range []string{"default", "missing"} {
+		result, err := ReconcileImagePullSecret(context.TODO(), k8sClient, c, c.SecretName, namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != ResultNoOp {
+			t.Errorf("ReconcileImagePullSecret() in namespace %s = %v, want %v", namespace, result, ResultNoOp)
+		}
+	}
+	got := &corev1.Secret{}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(external), got); err != nil {
+		t.Fatal(err)
+	}
+	if HasLabel(got, config.AnnotationManagedBy, config.AnnotationAppName) {
+		t.Errorf("Externally provisioned Secret was taken over")
+	}
+	if err := k8sClient.Get(context.TODO(), client.ObjectKey{Name: c.SecretName, Namespace: "missing"}, got); !apierrs.IsNotFound(err) {
+		t.Errorf("Secret should not be created, got %v", err)
+	}
+}
+
+func Test_ReconcileImagePullSecret_Immutable(t *testing.T) {
+	dockerConfigJSON := `{"auths":{"example.com":{"auth":"Zm9vOmJhcg=="}}}`
+	immutable := config.NewConfig(config.ConfigOptions{DockerConfigJSON: dockerConfigJSON, SecretNamespace: "kube-system", FeatureImmutableSecrets: true})
+	mutable := config.NewConfig(config.ConfigOptions{DockerConfigJSON: dockerConfigJSON, SecretNamespace: "kube-system"})
+	upToDate, err := ConstructImagePullSecret(context.TODO(), fake.NewClientBuilder().Build(), immutable, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated := upToDate.DeepCopy()
+	outdated.Data[corev1.DockerConfigJsonKey] = []byte(`{"auths":{}}`)
+	outdatedMutable := outdated.DeepCopy()
+	outdatedMutable.Immutable = nil
+
+	tests := []struct {
+		name          string
+		config        *config.Config
+		secret        *corev1.Secret
+		want          ReconcileResult
+		wantImmutable bool
+	}{
+		{
+			name:          "Immutable Secret up to date. Should be left untouched.",
+			config:        immutable,
+			secret:        upToDate,
+			want:          ResultNoOp,
+			wantImmutable: true,
+		},
+		{
+			name:          "Immutable Secret outdated. Should be recreated.",
+			config:        immutable,
+			secret:        outdated,
+			want:          ResultCreated,
+			wantImmutable: true,
+		},
+		{
+			name:          "Mutable Secret outdated. Should be patched and made immutable.",
+			config:        immutable,
+			secret:        outdatedMutable,
+			want:          ResultPatched,
+			wantImmutable: true,
+		},
+		{
+			name:          "Immutable Secret, but immutable Secrets disabled. Should be recreated mutable.",
+			config:        mutable,
+			secret:        upToDate,
+			want:          ResultCreated,
+			wantImmutable: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewClientBuilder().WithObjects(tt.secret.DeepCopy()).Build()
+			got, err := ReconcileImagePullSecret(context.TODO(), k8sClient, tt.config, tt.config.SecretName, "default")
+			if err != nil {
+				t.Fatalf("ReconcileImagePullSecret() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileImagePullSecret() = %v, want %v", got, tt.want)
+			}
+
+			secret := &corev1.Secret{}
+			if err := k8sClient.Get(context.TODO(), types.NamespacedName{Name: tt.config.SecretName, Namespace: "default"}, secret); err != nil {
+				t.Fatalf("Secret not found after reconciling: %v", err)
+			}
+			if gotImmutable := secret.Immutable != nil && *secret.Immutable; gotImmutable != tt.wantImmutable {
+				t.Errorf("Secret immutable = %v, want %v", gotImmutable, tt.wantImmutable)
+			}
+			if string(secret.Data[corev1.DockerConfigJsonKey]) != dockerConfigJSON {
+				t.Errorf("Secret data = %s, want %s", secret.Data[corev1.DockerConfigJsonKey], dockerConfigJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Watchdog supervises long-running goroutines, like the watchers of the credentials. It fails
+// the liveness check, once one of them exited before shutdown, or didn't send a heartbeat within
+// its timeout, so Kubernetes restarts the Pod instead of silently freezing credential rotation.
+// A nil Watchdog supervises nothing.
+type Watchdog struct {
+	mu         sync.Mutex
+	heartbeats map[string]*heartbeat
+	now        func() time.Time
+}
+
+type heartbeat struct {
+	timeout time.Duration
+	last    time.Time
+	exited  bool
+}
+
+type heartbeatKey struct{}
+
+// New returns a Watchdog without supervised goroutines
+func New() *Watchdog {
+	return &Watchdog{
+		heartbeats: map[string]*heartbeat{},
+		now:        time.Now,
+	}
+}
+
+// Runnable returns a Runnable for the manager, which runs run supervised as name. run must
+// send a heartbeat with Beat on the ctx passed to it at least every timeout. A timeout
+// below 1 only supervises, that run doesn't exit before ctx is cancelled.
+func (w *Watchdog) Runnable(name string, timeout time.Duration, run func(ctx context.Context) error) manager.Runnable {
+	if w == nil {
+		return manager.RunnableFunc(run)
+	}
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		w.mu.Lock()
+		w.heartbeats[name] = &heartbeat{timeout: timeout, last: w.now()}
+		w.mu.Unlock()
+
+		err := run(context.WithValue(ctx, heartbeatKey{}, w.heartbeat(name)))
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// Exiting on shutdown is expected
+		if ctx.Err() != nil {
+			delete(w.heartbeats, name)
+		} else {
+			w.heartbeats[name].exited = true
+		}
+		return err
+	})
+}
+
+// heartbeat returns a function recording a heartbeat of name
+func (w *Watchdog) heartbeat(name string) func() {
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if hb, ok := w.heartbeats[name]; ok {
+			hb.last = w.now()
+		}
+	}
+}
+
+// Beat sends a heartbeat of the goroutine supervised with ctx. It does nothing, if ctx is not supervised.
+func Beat(ctx context.Context) {
+	if beat, ok := ctx.Value(heartbeatKey{}).(func()); ok {
+		beat()
+	}
+}
+
+// Checker fails, once a supervised goroutine exited or its heartbeat is overdue. It matches healthz.Checker.
+func (w *Watchdog) Checker(_ *http.Request) error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	names := make([]string, 0, len(w.heartbeats))
+	for name := range w.heartbeats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := w.now()
+	for _, name := range names {
+		hb := w.heartbeats[name]
+		if hb.exited {
+			return fmt.Errorf("%s exited", name)
+		}
+		if hb.timeout > 0 && now.Sub(hb.last) > hb.timeout {
+			return fmt.Errorf("%s sent no heartbeat for %s", name, now.Sub(hb.last).Round(time.Second))
+		}
+	}
+	return nil
+}
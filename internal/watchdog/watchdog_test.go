@@ -0,0 +1,110 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchdog
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Watchdog(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		beat    time.Duration
+		exit    bool
+		elapsed time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "Heartbeat within timeout. Should be healthy.",
+			timeout: time.Minute,
+			beat:    30 * time.Second,
+			elapsed: time.Minute,
+		},
+		{
+			name:    "Heartbeat overdue. Should fail.",
+			timeout: time.Minute,
+			elapsed: 2 * time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "Heartbeat overdue, timeout disabled. Should be healthy.",
+			timeout: -1,
+			elapsed: 2 * time.Minute,
+		},
+		{
+			name:    "Exited before shutdown. Should fail.",
+			timeout: time.Minute,
+			exit:    true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			var elapsed atomic.Int64
+			w := New()
+			w.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+			ctx, cancel := context.WithCancel(context.Background())
+			started := make(chan struct{})
+			done := make(chan struct{})
+			runnable := w.Runnable("watcher", tt.timeout, func(ctx context.Context) error {
+				if tt.beat > 0 {
+					elapsed.Store(int64(tt.beat))
+					Beat(ctx)
+				}
+				close(started)
+				if !tt.exit {
+					<-ctx.Done()
+				}
+				return nil
+			})
+			go func() {
+				_ = runnable.Start(ctx)
+				close(done)
+			}()
+			<-started
+			if tt.exit {
+				<-done
+			}
+			elapsed.Store(int64(tt.elapsed))
+
+			if err := w.Checker(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Checker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			cancel()
+			<-done
+			if !tt.exit {
+				if err := w.Checker(nil); err != nil {
+					t.Errorf("Checker() after shutdown error = %v, want nil", err)
+				}
+			}
+		})
+	}
+
+	t.Run("Nil watchdog. Should be healthy.", func(t *testing.T) {
+		var w *Watchdog
+		if err := w.Checker(nil); err != nil {
+			t.Errorf("Checker() error = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package state allows other controllers to query the state managed by imagepullsecret-patcher,
+// without re-implementing its configuration, like the namespace globs, selectors and annotations.
+// It is backed by the admin API of the controller, see CONFIG_ADMIN_BIND_ADDRESS.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// State is the state of a deployment of the controller, as served by GET /api/v1/state
+type State struct {
+	// Profile is the profile of the deployment, see CONFIG_PROFILE
+	Profile string `json:"profile"`
+	// SecretName is the name of the managed imagePullSecrets
+	SecretName string `json:"secretName"`
+	// CredentialHash is a hash of the current credentials, which changes whenever they're rotated
+	CredentialHash string `json:"credentialHash"`
+}
+
+// Namespace is the state of a namespace, as served by GET /api/v1/namespaces/{name}
+type Namespace struct {
+	Name string `json:"name"`
+	// Managed is true, if the imagePullSecret is distributed to the namespace
+	Managed bool `json:"managed"`
+	// Reason is why the namespace is excluded, i.e. glob, selector, annotation, operator or terminating
+	Reason string `json:"reason,omitempty"`
+}
+
+// NamespaceSync is the sync status of a namespace, as served by GET /api/v1/audit
+type NamespaceSync struct {
+	Namespace string `json:"namespace"`
+	// Excluded is true, if the imagePullSecret isn't distributed to the namespace
+	Excluded bool `json:"excluded"`
+	// SecretExpected is true, if the imagePullSecret should exist in the namespace
+	SecretExpected bool `json:"secretExpected"`
+	SecretExists   bool `json:"secretExists"`
+	// SecretUpToDate is true, if the imagePullSecret carries the current credentials
+	SecretUpToDate bool `json:"secretUpToDate"`
+	// ServiceAccountsMissingSecret are the managed ServiceAccounts, which don't reference the imagePullSecret yet
+	ServiceAccountsMissingSecret []string `json:"serviceAccountsMissingSecret,omitempty"`
+}
+
+// InSync reports whether the namespace matches the expected state
+func (n NamespaceSync) InSync() bool {
+	if n.Excluded {
+		return true
+	}
+	return (!n.SecretExpected || n.SecretExists) &&
+		(!n.SecretExists || n.SecretUpToDate) &&
+		len(n.ServiceAccountsMissingSecret) == 0
+}
+
+// ExplanationRule is a rule of the configuration, as evaluated against an object
+type ExplanationRule struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	// Detail is the configuration or annotation behind the rule
+	Detail string `json:"detail,omitempty"`
+}
+
+// ServiceAccountExplanation describes, why the controller manages a ServiceAccount or not, and what it would do,
+// as served by GET /api/v1/namespaces/{name}/serviceaccounts/{serviceaccount}/explain
+type ServiceAccountExplanation struct {
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"serviceAccount"`
+	// Managed is true, if the imagePullSecret is attached to the ServiceAccount
+	Managed bool              `json:"managed"`
+	Rules   []ExplanationRule `json:"rules"`
+	// Actions are what reconciling the ServiceAccount would do
+	Actions []string `json:"actions"`
+}
+
+// Client queries the admin API of a deployment of the controller
+type Client struct {
+	// URL is the base URL of the admin API, e.g. http://imagepullsecret-patcher.kube-system:8082
+	URL string
+	// Token is the bearer token of the admin API, see CONFIG_ADMIN_TOKEN
+	Token string
+	// HTTPClient is used for the requests. http.DefaultClient is used, if nil.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for the admin API at baseURL
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// IsNamespaceManaged returns whether the imagePullSecret is distributed to namespace
+func (c *Client) IsNamespaceManaged(ctx context.Context, namespace string) (bool, error) {
+	ns, err := c.Namespace(ctx, namespace)
+	if err != nil {
+		return false, err
+	}
+	return ns.Managed, nil
+}
+
+// ManagedSecretName returns the name of the managed imagePullSecrets
+func (c *Client) ManagedSecretName(ctx context.Context) (string, error) {
+	state, err := c.State(ctx)
+	if err != nil {
+		return "", err
+	}
+	return state.SecretName, nil
+}
+
+// CredentialHash returns a hash of the current credentials, which changes whenever they're rotated
+func (c *Client) CredentialHash(ctx context.Context) (string, error) {
+	state, err := c.State(ctx)
+	if err != nil {
+		return "", err
+	}
+	return state.CredentialHash, nil
+}
+
+// Namespace returns the state of namespace
+func (c *Client) Namespace(ctx context.Context, namespace string) (*Namespace, error) {
+	ns := &Namespace{}
+	if err := c.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace), ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// ExplainServiceAccount evaluates the configuration against serviceAccount in namespace
+func (c *Client) ExplainServiceAccount(ctx context.Context, namespace string, serviceAccount string) (*ServiceAccountExplanation, error) {
+	explanation := &ServiceAccountExplanation{}
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/serviceaccounts/" + url.PathEscape(serviceAccount) + "/explain"
+	if err := c.get(ctx, path, explanation); err != nil {
+		return nil, err
+	}
+	return explanation, nil
+}
+
+// State returns the state of the deployment
+func (c *Client) State(ctx context.Context) (*State, error) {
+	state := &State{}
+	if err := c.get(ctx, "/api/v1/state", state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Sync returns the sync status of every namespace. It's computed from the cluster on every call, so it's
+// rejected with an error, while the API server is overloaded.
+func (c *Client) Sync(ctx context.Context) ([]NamespaceSync, error) {
+	namespaces := []NamespaceSync{}
+	if err := c.get(ctx, "/api/v1/audit", &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// ExcludedNamespaces returns the names of the namespaces excluded by the configuration, by reason
+func (c *Client) ExcludedNamespaces(ctx context.Context) (map[string][]string, error) {
+	excluded := map[string][]string{}
+	if err := c.get(ctx, "/api/v1/namespaces/excluded", &excluded); err != nil {
+		return nil, err
+	}
+	return excluded, nil
+}
+
+// Resync triggers a reconciliation of all managed Secrets. It returns, once the resync was accepted.
+func (c *Client) Resync(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/resync", http.StatusAccepted, nil)
+}
+
+// Pause excludes namespace from reconciling, by setting the admin-paused annotation
+func (c *Client) Pause(ctx context.Context, namespace string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pause", http.StatusNoContent, nil)
+}
+
+// Resume removes the admin-paused annotation from namespace. The exclude annotation set by the users is kept.
+func (c *Client) Resume(ctx context.Context, namespace string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pause", http.StatusNoContent, nil)
+}
+
+// get requests path from the admin API and decodes the JSON response into v
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	return c.do(ctx, http.MethodGet, path, http.StatusOK, v)
+}
+
+// do sends a request with method to path of the admin API, expects status and decodes the JSON response
+// into v, unless it's nil
+func (c *Client) do(ctx context.Context, method string, path string, status int, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, path)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tamcore/imagepullsecret-patcher/internal/admin"
+	"github.com/tamcore/imagepullsecret-patcher/internal/config"
+	"github.com/tamcore/imagepullsecret-patcher/pkg/state"
+)
+
+func newTestClient(t *testing.T, token string) *state.Client {
+	c := config.NewConfig(config.ConfigOptions{
+		DockerConfigJSON: `{"auths":{}}`,
+		SecretNamespace:  "kube-system",
+		AdminToken:       "secret-token",
+	})
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"}},
+	).Build()
+	server := httptest.NewServer((&admin.Server{Client: k8sClient, Config: config.NewStore(c)}).Handler())
+	t.Cleanup(server.Close)
+	return state.NewClient(server.URL, token)
+}
+
+func Test_Client(t *testing.T) {
+	client := newTestClient(t, "secret-token")
+	ctx := context.TODO()
+
+	if managed, err := client.IsNamespaceManaged(ctx, "default"); err != nil || !managed {
+		t.Errorf("IsNamespaceManaged(default) = %v, %v, want true", managed, err)
+	}
+	ns, err := client.Namespace(ctx, "kube-system")
+	if err != nil || ns.Managed || ns.Reason != "glob" {
+		t.Errorf("Namespace(kube-system) = %+v, %v, want excluded by glob", ns, err)
+	}
+	if _, err := client.IsNamespaceManaged(ctx, "missing"); err == nil {
+		t.Errorf("IsNamespaceManaged(missing) error = nil, want error")
+	}
+	if secretName, err := client.ManagedSecretName(ctx); err != nil || secretName != "global-imagepullsecret" {
+		t.Errorf("ManagedSecretName() = %v, %v, want global-imagepullsecret", secretName, err)
+	}
+	if hash, err := client.CredentialHash(ctx); err != nil || len(hash) != 64 {
+		t.Errorf("CredentialHash() = %v, %v, want a sha256 hash", hash, err)
+	}
+}
+
+func Test_Client_Sync(t *testing.T) {
+	client := newTestClient(t, "secret-token")
+	ctx := context.TODO()
+
+	namespaces, err := client.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range namespaces {
+		if inSync := ns.InSync(); inSync != (ns.Namespace == "kube-system") {
+			t.Errorf("Sync() namespace %s in sync = %v, want the imagePullSecret missing in default only", ns.Namespace, inSync)
+		}
+	}
+	if excluded, err := client.ExcludedNamespaces(ctx); err != nil || len(excluded["glob"]) != 1 {
+		t.Errorf("ExcludedNamespaces() = %v, %v, want kube-system excluded by glob", excluded, err)
+	}
+	if err := client.Resync(ctx); err == nil {
+		t.Errorf("Resync() error = nil, want error without a Resyncer")
+	}
+}
+
+func Test_Client_Pause(t *testing.T) {
+	client := newTestClient(t, "secret-token")
+	ctx := context.TODO()
+
+	if err := client.Pause(ctx, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if ns, err := client.Namespace(ctx, "default"); err != nil || ns.Reason != "paused" {
+		t.Errorf("Namespace(default) = %+v, %v, want paused", ns, err)
+	}
+	if err := client.Resume(ctx, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if managed, err := client.IsNamespaceManaged(ctx, "default"); err != nil || !managed {
+		t.Errorf("IsNamespaceManaged(default) = %v, %v, want true", managed, err)
+	}
+}
+
+func Test_Client_ExplainServiceAccount(t *testing.T) {
+	client := newTestClient(t, "secret-token")
+	ctx := context.TODO()
+
+	explanation, err := client.ExplainServiceAccount(ctx, "default", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Managed || len(explanation.Rules) == 0 || len(explanation.Actions) == 0 {
+		t.Errorf("ExplainServiceAccount(default, default) = %+v, want managed with rules and actions", explanation)
+	}
+	if _, err := client.ExplainServiceAccount(ctx, "default", "missing"); err == nil {
+		t.Errorf("ExplainServiceAccount(default, missing) error = nil, want error")
+	}
+}
+
+func Test_Client_Unauthorized(t *testing.T) {
+	client := newTestClient(t, "wrong-token")
+	if _, err := client.State(context.TODO()); err == nil {
+		t.Errorf("State() error = nil, want error")
+	}
+}